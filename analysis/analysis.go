@@ -0,0 +1,323 @@
+// Package analysis finds the code in a binary by recursive-descent
+// disassembly.
+//
+// Starting from function entries, instructions are decoded by following
+// direct branches and calls, so data and padding mixed in code are not taken
+// as instructions, which is the problem of linear sweep. Targets of direct
+// calls are taken as function starts, even if they have no symbol. Jump
+// tables used by jmp *tbl(,%reg,4) (or scale 8 in 64-bit mode) are read from
+// the binary, other indirect branches are not followed.
+package analysis
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+
+	dis "github.com/cyfdecyf/GoEmu/dis-x86"
+)
+
+// Section is a range of memory of the binary. Only code sections are
+// disassembled, other sections are used when reading jump tables.
+type Section struct {
+	Name string
+	Addr uint64 // Virtual address of the first byte
+	Data []byte
+	Code bool
+}
+
+// Function found by the analysis.
+type Function struct {
+	Entry uint64
+	Name  string   // Symbol name, empty if found as call target
+	Insns []uint64 // Addresses of instructions reachable from the entry, sorted
+}
+
+// Program holds the instructions and functions found in a binary.
+type Program struct {
+	Mode     dis.Mode
+	sections []Section
+
+	insns map[uint64]*dis.Instruction
+	// Instruction falling through to the address, used to find the bound
+	// of jump table
+	prev       map[uint64]uint64
+	funcs      map[uint64]*Function
+	jumpTables map[uint64][]uint64
+	errs       map[uint64]error
+	work       []uint64 // Addresses to disassemble from
+}
+
+var ErrNotCode = errors.New("address not in code section")
+
+// Max number of jump table entries read if no bound is found.
+const maxJumpTable = 512
+
+func NewProgram(mode dis.Mode) *Program {
+	return &Program{
+		Mode:       mode,
+		insns:      make(map[uint64]*dis.Instruction),
+		prev:       make(map[uint64]uint64),
+		funcs:      make(map[uint64]*Function),
+		jumpTables: make(map[uint64][]uint64),
+		errs:       make(map[uint64]error),
+	}
+}
+
+func (p *Program) AddSection(s Section) {
+	p.sections = append(p.sections, s)
+}
+
+// AddFunction adds a function entry to start disassembling from. Name may be
+// empty if unknown.
+func (p *Program) AddFunction(entry uint64, name string) {
+	if f, ok := p.funcs[entry]; ok {
+		if f.Name == "" {
+			f.Name = name
+		}
+		return
+	}
+	p.funcs[entry] = &Function{Entry: entry, Name: name}
+	p.work = append(p.work, entry)
+}
+
+// Analyze disassembles from all the function entries added, and finds the
+// instructions of each function. It can be called again after adding more
+// functions.
+func (p *Program) Analyze() {
+	for len(p.work) > 0 {
+		addr := p.work[len(p.work)-1]
+		p.work = p.work[:len(p.work)-1]
+		p.disassemble(addr)
+	}
+	for _, f := range p.funcs {
+		p.findFuncInsns(f)
+	}
+}
+
+// Disassemble from addr till the control flow stops, branch targets are
+// added to the work list.
+func (p *Program) disassemble(addr uint64) {
+	for {
+		if _, ok := p.insns[addr]; ok {
+			return
+		}
+		if _, ok := p.errs[addr]; ok {
+			return
+		}
+		insn, err := p.decode(addr)
+		if err != nil {
+			p.errs[addr] = err
+			return
+		}
+		p.insns[addr] = insn
+
+		if insn.FlowControl() == dis.FlowCall && !insn.IsIndirect() {
+			p.AddFunction(insn.Target, "")
+		}
+		if insn.FlowControl() == dis.FlowBranch && insn.IsIndirect() {
+			p.readJumpTable(insn)
+		}
+		next, fallsThrough := insn.Successors(p.JumpTable)
+		p.work = append(p.work, next...)
+		if !fallsThrough {
+			return
+		}
+		addr = insn.Addr + uint64(insn.Length)
+		p.prev[addr] = insn.Addr
+	}
+}
+
+func (p *Program) section(addr uint64) *Section {
+	for i := range p.sections {
+		s := &p.sections[i]
+		if s.Addr <= addr && addr-s.Addr < uint64(len(s.Data)) {
+			return s
+		}
+	}
+	return nil
+}
+
+func (p *Program) decode(addr uint64) (*dis.Instruction, error) {
+	s := p.section(addr)
+	if s == nil || !s.Code {
+		return nil, ErrNotCode
+	}
+	insn, err := dis.DecodeAt(s.Data[addr-s.Addr:], addr, p.Mode)
+	if err != nil {
+		return nil, err
+	}
+	return &insn, nil
+}
+
+// Read a pointer sized value at addr from any section.
+func (p *Program) readPtr(addr uint64) (uint64, bool) {
+	size := uint64(4)
+	if p.Mode == dis.Mode64 {
+		size = 8
+	}
+	s := p.section(addr)
+	if s == nil || addr-s.Addr+size > uint64(len(s.Data)) {
+		return 0, false
+	}
+	b := s.Data[addr-s.Addr:]
+	if size == 8 {
+		return binary.LittleEndian.Uint64(b), true
+	}
+	return uint64(binary.LittleEndian.Uint32(b)), true
+}
+
+// Read the jump table used by insn, which is in the form of
+// jmp *tbl(,%reg,4). The number of entries is found from the bound check
+// before the jmp, e.g. cmp $0x5,%eax; ja default. Without bound check,
+// entries are read until one not pointing to code.
+func (p *Program) readJumpTable(insn *dis.Instruction) {
+	if len(insn.Operands) != 1 {
+		return
+	}
+	m, ok := insn.Operands[0].(dis.Mem)
+	ptrSize := 4
+	if p.Mode == dis.Mode64 {
+		ptrSize = 8
+	}
+	if !ok || m.Base.Class != 0 || m.Index.Class != dis.RegGP || int(m.Scale) != ptrSize {
+		return
+	}
+	table := uint64(m.Disp)
+	if p.Mode != dis.Mode64 {
+		table &= 0xffffffff
+	}
+
+	n, bounded := p.jumpTableBound(insn.Addr, m.Index.Num)
+	if !bounded {
+		n = maxJumpTable
+	}
+	var targets []uint64
+	for i := 0; i < n; i++ {
+		target, ok := p.readPtr(table + uint64(i*ptrSize))
+		if !ok {
+			break
+		}
+		if s := p.section(target); s == nil || !s.Code {
+			break
+		}
+		targets = append(targets, target)
+	}
+	if len(targets) != 0 {
+		p.jumpTables[insn.Addr] = targets
+	}
+}
+
+// Find the number of jump table entries from the cmp and ja (or jae) before
+// the instruction at addr, which checks the index register.
+func (p *Program) jumpTableBound(addr uint64, index byte) (n int, ok bool) {
+	var jcc uint16
+	for i := 0; i < 4; i++ {
+		if addr, ok = p.prev[addr]; !ok {
+			return 0, false
+		}
+		insn := p.insns[addr]
+		switch insn.OpId {
+		case dis.Insn_Ja, dis.Insn_Jae:
+			if jcc == 0 {
+				jcc = insn.OpId
+			}
+			continue
+		case dis.Insn_Cmp:
+		default:
+			continue
+		}
+		if jcc == 0 || len(insn.Operands) != 2 {
+			return 0, false
+		}
+		reg, ok1 := insn.Operands[0].(dis.Reg)
+		imm, ok2 := insn.Operands[1].(dis.Imm)
+		if !ok1 || !ok2 || reg.Class != dis.RegGP || reg.Num != index || imm.Value < 0 {
+			return 0, false
+		}
+		n = int(imm.Value)
+		if jcc == dis.Insn_Ja {
+			n++
+		}
+		if n > maxJumpTable {
+			return 0, false
+		}
+		return n, true
+	}
+	return 0, false
+}
+
+// Find the instructions reachable from the function entry. Branch to another
+// function is a tail call, which stops there.
+func (p *Program) findFuncInsns(f *Function) {
+	visited := make(map[uint64]bool)
+	work := []uint64{f.Entry}
+	for len(work) > 0 {
+		addr := work[len(work)-1]
+		work = work[:len(work)-1]
+		for {
+			insn, ok := p.insns[addr]
+			if !ok || visited[addr] {
+				break
+			}
+			if _, ok := p.funcs[addr]; ok && addr != f.Entry {
+				break
+			}
+			visited[addr] = true
+			targets, fallsThrough := insn.Successors(p.JumpTable)
+			work = append(work, targets...)
+			if !fallsThrough {
+				break
+			}
+			addr += uint64(insn.Length)
+		}
+	}
+	f.Insns = make([]uint64, 0, len(visited))
+	for addr := range visited {
+		f.Insns = append(f.Insns, addr)
+	}
+	sort.Slice(f.Insns, func(i, j int) bool { return f.Insns[i] < f.Insns[j] })
+}
+
+// Insn returns the instruction starting at addr, nil if addr is not a found
+// instruction boundary.
+func (p *Program) Insn(addr uint64) *dis.Instruction {
+	return p.insns[addr]
+}
+
+// Insns returns all the instructions found, sorted by address.
+func (p *Program) Insns() []*dis.Instruction {
+	insns := make([]*dis.Instruction, 0, len(p.insns))
+	for _, insn := range p.insns {
+		insns = append(insns, insn)
+	}
+	sort.Slice(insns, func(i, j int) bool { return insns[i].Addr < insns[j].Addr })
+	return insns
+}
+
+// Functions returns all the functions, sorted by entry address.
+func (p *Program) Functions() []*Function {
+	funcs := make([]*Function, 0, len(p.funcs))
+	for _, f := range p.funcs {
+		funcs = append(funcs, f)
+	}
+	sort.Slice(funcs, func(i, j int) bool { return funcs[i].Entry < funcs[j].Entry })
+	return funcs
+}
+
+// Function returns the function with the entry, nil if there's none.
+func (p *Program) Function(entry uint64) *Function {
+	return p.funcs[entry]
+}
+
+// JumpTable returns the targets of the indirect jmp at addr, nil if the jump
+// table is not found.
+func (p *Program) JumpTable(addr uint64) []uint64 {
+	return p.jumpTables[addr]
+}
+
+// Errors returns the addresses reached by control flow but failed to be
+// decoded, and the errors.
+func (p *Program) Errors() map[uint64]error {
+	return p.errs
+}
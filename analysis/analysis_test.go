@@ -0,0 +1,132 @@
+package analysis
+
+import (
+	"debug/elf"
+	"os"
+	"reflect"
+	"runtime"
+	"testing"
+
+	dis "github.com/cyfdecyf/GoEmu/dis-x86"
+)
+
+var testCode = []byte{
+	0xe8, 0x0b, 0x00, 0x00, 0x00, // 0x1000: call 0x1010
+	0xeb, 0x07, // 0x1005: jmp 0x100e
+	0xb8, 0x90, 0x90, 0x90, 0x90, 0x90, 0x90, // 0x1007: data
+	0xc3,             // 0x100e: ret
+	0xcc,             // 0x100f: padding
+	0x83, 0xf8, 0x02, // 0x1010: cmp $0x2,%eax
+	0x77, 0x0a, // 0x1013: ja 0x101f
+	0xff, 0x24, 0x85, 0x00, 0x20, 0x00, 0x00, // 0x1015: jmp *0x2000(,%eax,4)
+	0x40,                                     // 0x101c: inc %eax
+	0xc3,                                     // 0x101d: ret
+	0x48,                                     // 0x101e: dec %eax
+	0xc3,                                     // 0x101f: ret
+	0xff, 0x24, 0x85, 0x10, 0x20, 0x00, 0x00, // 0x1020: jmp *0x2010(,%eax,4)
+}
+
+var testData = []byte{
+	0x1c, 0x10, 0x00, 0x00, // 0x2000: jump table
+	0x1e, 0x10, 0x00, 0x00,
+	0x1f, 0x10, 0x00, 0x00,
+	0x00, 0x10, 0x00, 0x00, // Beyond the bound
+	0x1d, 0x10, 0x00, 0x00, // 0x2010: jump table without bound
+	0x1f, 0x10, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00,
+}
+
+func testProgram() *Program {
+	p := NewProgram(dis.Mode32)
+	p.AddSection(Section{Name: ".text", Addr: 0x1000, Data: testCode, Code: true})
+	p.AddSection(Section{Name: ".rodata", Addr: 0x2000, Data: testData})
+	p.AddFunction(0x1000, "main")
+	p.AddFunction(0x1020, "dispatch")
+	p.Analyze()
+	return p
+}
+
+func TestAnalyze(t *testing.T) {
+	p := testProgram()
+
+	var addrs []uint64
+	for _, insn := range p.Insns() {
+		addrs = append(addrs, insn.Addr)
+	}
+	expected := []uint64{0x1000, 0x1005, 0x100e, 0x1010, 0x1013, 0x1015,
+		0x101c, 0x101d, 0x101e, 0x101f, 0x1020}
+	if !reflect.DeepEqual(addrs, expected) {
+		t.Errorf("instructions at %#x, should be %#x", addrs, expected)
+	}
+	if p.Insn(0x1007) != nil {
+		t.Error("data decoded as instruction")
+	}
+	if len(p.Errors()) != 0 {
+		t.Errorf("errors: %v", p.Errors())
+	}
+
+	funcs := []Function{
+		{0x1000, "main", []uint64{0x1000, 0x1005, 0x100e}},
+		{0x1010, "", []uint64{0x1010, 0x1013, 0x1015, 0x101c, 0x101d, 0x101e, 0x101f}},
+		{0x1020, "dispatch", []uint64{0x101d, 0x101f, 0x1020}},
+	}
+	found := p.Functions()
+	if len(found) != len(funcs) {
+		t.Fatalf("found %d functions, should be %d", len(found), len(funcs))
+	}
+	for i, f := range found {
+		if !reflect.DeepEqual(*f, funcs[i]) {
+			t.Errorf("function %#x: got %+v, should be %+v", funcs[i].Entry, *f, funcs[i])
+		}
+	}
+}
+
+func TestJumpTable(t *testing.T) {
+	p := testProgram()
+	testdata := []struct {
+		addr    uint64
+		targets []uint64
+	}{
+		{0x1015, []uint64{0x101c, 0x101e, 0x101f}},
+		{0x1020, []uint64{0x101d, 0x101f}},
+		{0x1000, nil},
+	}
+	for _, td := range testdata {
+		if targets := p.JumpTable(td.addr); !reflect.DeepEqual(targets, td.targets) {
+			t.Errorf("jump table at %#x: %#x, should be %#x", td.addr, targets, td.targets)
+		}
+	}
+}
+
+func TestLoadELF(t *testing.T) {
+	if runtime.GOOS != "linux" || runtime.GOARCH != "amd64" {
+		t.Skip("test binary is not x86-64 ELF")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Skip(err)
+	}
+	f, err := elf.Open(exe)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer f.Close()
+
+	p, err := LoadELF(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Analyze()
+	if p.Function(f.Entry) == nil {
+		t.Errorf("no function at entry %#x", f.Entry)
+	}
+	// Instructions found should not overlap
+	insns := p.Insns()
+	for i := 1; i < len(insns); i++ {
+		prev := insns[i-1]
+		if prev.Addr+uint64(prev.Length) > insns[i].Addr {
+			t.Errorf("instruction at %#x overlaps with %#x", prev.Addr, insns[i].Addr)
+			break
+		}
+	}
+}
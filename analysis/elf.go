@@ -0,0 +1,58 @@
+package analysis
+
+import (
+	"debug/elf"
+	"fmt"
+
+	dis "github.com/cyfdecyf/GoEmu/dis-x86"
+)
+
+// LoadELF creates a program with the allocated sections of f. The entry point
+// and function symbols are added as function entries, call Analyze to
+// disassemble.
+func LoadELF(f *elf.File) (*Program, error) {
+	var mode dis.Mode
+	switch f.Machine {
+	case elf.EM_386:
+		mode = dis.Mode32
+	case elf.EM_X86_64:
+		mode = dis.Mode64
+	default:
+		return nil, fmt.Errorf("unsupported machine %v", f.Machine)
+	}
+	p := NewProgram(mode)
+
+	for _, s := range f.Sections {
+		if s.Type == elf.SHT_NOBITS || s.Flags&elf.SHF_ALLOC == 0 {
+			continue
+		}
+		data, err := s.Data()
+		if err != nil {
+			return nil, fmt.Errorf("reading section %s: %v", s.Name, err)
+		}
+		p.AddSection(Section{
+			Name: s.Name,
+			Addr: s.Addr,
+			Data: data,
+			Code: s.Flags&elf.SHF_EXECINSTR != 0,
+		})
+	}
+
+	if f.Entry != 0 {
+		p.AddFunction(f.Entry, "")
+	}
+	syms, err := f.Symbols()
+	if err == elf.ErrNoSymbols {
+		syms, err = f.DynamicSymbols()
+	}
+	if err != nil {
+		// Stripped binary, start from the entry point only
+		return p, nil
+	}
+	for _, s := range syms {
+		if elf.ST_TYPE(s.Info) == elf.STT_FUNC && s.Section != elf.SHN_UNDEF && s.Value != 0 {
+			p.AddFunction(s.Value, s.Name)
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,244 @@
+// Package cfg builds the control-flow graph of a function.
+//
+// The function is split into basic blocks, which are linked by edges telling
+// how control is transferred. Calls end basic blocks and are assumed to
+// return. Dominators and natural loops of the graph can be computed, and the
+// graph can be written in Graphviz DOT format.
+package cfg
+
+import (
+	"errors"
+	"sort"
+
+	dis "github.com/cyfdecyf/GoEmu/dis-x86"
+)
+
+// Source provides the instructions of the function.
+// analysis.Program implements Source.
+type Source interface {
+	// Insn returns the instruction at addr, nil if it can't be decoded.
+	Insn(addr uint64) *dis.Instruction
+	// JumpTable returns the targets of the indirect jmp at addr, nil if
+	// unknown.
+	JumpTable(addr uint64) []uint64
+}
+
+// Code is a Source decoding instructions from Data, which is loaded at Addr.
+type Code struct {
+	Addr uint64
+	Data []byte
+	Mode dis.Mode
+}
+
+func (c *Code) Insn(addr uint64) *dis.Instruction {
+	if addr < c.Addr || addr-c.Addr >= uint64(len(c.Data)) {
+		return nil
+	}
+	insn, err := dis.DecodeAt(c.Data[addr-c.Addr:], addr, c.Mode)
+	if err != nil {
+		return nil
+	}
+	return &insn
+}
+
+func (c *Code) JumpTable(addr uint64) []uint64 {
+	return nil
+}
+
+type EdgeKind byte
+
+const (
+	EdgeFallthrough EdgeKind = iota // To the next block, which is a branch target
+	EdgeTaken                       // Conditional branch taken, or jmp
+	EdgeNotTaken                    // Conditional branch not taken
+	EdgeCall                        // From call to the instruction after it
+	EdgeReturn                      // From return to the exit block
+	EdgeIndirect                    // Indirect jmp with known targets
+)
+
+var edgeKindName = [...]string{
+	EdgeFallthrough: "fallthrough",
+	EdgeTaken:       "taken",
+	EdgeNotTaken:    "not taken",
+	EdgeCall:        "call",
+	EdgeReturn:      "return",
+	EdgeIndirect:    "indirect",
+}
+
+func (k EdgeKind) String() string {
+	if int(k) < len(edgeKindName) {
+		return edgeKindName[k]
+	}
+	return "unknown"
+}
+
+type Edge struct {
+	From, To *Block
+	Kind     EdgeKind
+}
+
+// Block is a basic block. The exit block has no instructions and zero
+// address.
+type Block struct {
+	Start uint64 // Address of the first instruction
+	End   uint64 // Address after the last instruction
+	Insns []*dis.Instruction
+	Succs []*Edge
+	Preds []*Edge
+	Index int // Index in Graph.Blocks, len(Graph.Blocks) for the exit block
+
+	idom *Block
+	rpo  int // Reverse postorder number, -1 if unreachable
+}
+
+// Last returns the last instruction of the block, nil for the exit block.
+func (b *Block) Last() *dis.Instruction {
+	if len(b.Insns) == 0 {
+		return nil
+	}
+	return b.Insns[len(b.Insns)-1]
+}
+
+// Graph is the control-flow graph of a function.
+type Graph struct {
+	Entry  *Block
+	Blocks []*Block // Sorted by address
+	// Successor of all the return blocks, nil if the function has no
+	// return. Not included in Blocks.
+	Exit *Block
+
+	loops []*Loop
+}
+
+var ErrEntry = errors.New("cfg: can't decode function entry")
+
+// Build creates the control-flow graph of the function starting at entry.
+// Direct branches are followed, and so are indirect jmps with known jump
+// table.
+func Build(src Source, entry uint64) (*Graph, error) {
+	insns := make(map[uint64]*dis.Instruction)
+	leaders := map[uint64]bool{entry: true}
+	work := []uint64{entry}
+	for len(work) > 0 {
+		addr := work[len(work)-1]
+		work = work[:len(work)-1]
+		for {
+			if _, ok := insns[addr]; ok {
+				break
+			}
+			insn := src.Insn(addr)
+			if insn == nil {
+				break
+			}
+			insns[addr] = insn
+			targets, next := insn.Successors(src.JumpTable)
+			for _, target := range targets {
+				leaders[target] = true
+				work = append(work, target)
+			}
+			if !next {
+				break
+			}
+			addr += uint64(insn.Length)
+			if endsBlock(insn) {
+				leaders[addr] = true
+			}
+		}
+	}
+	if insns[entry] == nil {
+		return nil, ErrEntry
+	}
+
+	g := &Graph{}
+	blocks := make(map[uint64]*Block)
+	for addr := range leaders {
+		if insns[addr] == nil {
+			continue
+		}
+		b := &Block{Start: addr}
+		for {
+			insn := insns[addr]
+			b.Insns = append(b.Insns, insn)
+			addr += uint64(insn.Length)
+			if endsBlock(insn) || leaders[addr] || insns[addr] == nil {
+				break
+			}
+		}
+		b.End = addr
+		blocks[b.Start] = b
+		g.Blocks = append(g.Blocks, b)
+	}
+	sort.Slice(g.Blocks, func(i, j int) bool { return g.Blocks[i].Start < g.Blocks[j].Start })
+	for i, b := range g.Blocks {
+		b.Index = i
+	}
+	g.Entry = blocks[entry]
+
+	for _, b := range g.Blocks {
+		g.linkBlock(src, b, blocks)
+	}
+	g.computeDominators()
+	return g, nil
+}
+
+// Instructions transferring control, other than int and syscall which return
+// to the next instruction, end the basic block.
+func endsBlock(insn *dis.Instruction) bool {
+	switch insn.FlowControl() {
+	case dis.FlowNone:
+		return false
+	case dis.FlowInterrupt, dis.FlowSys:
+		return !insn.FallsThrough()
+	}
+	return true
+}
+
+func (g *Graph) addEdge(from, to *Block, kind EdgeKind) {
+	if to == nil {
+		return
+	}
+	e := &Edge{From: from, To: to, Kind: kind}
+	from.Succs = append(from.Succs, e)
+	to.Preds = append(to.Preds, e)
+}
+
+func (g *Graph) linkBlock(src Source, b *Block, blocks map[uint64]*Block) {
+	last := b.Last()
+	switch last.FlowControl() {
+	case dis.FlowCondBranch:
+		g.addEdge(b, blocks[last.Target], EdgeTaken)
+		g.addEdge(b, blocks[b.End], EdgeNotTaken)
+	case dis.FlowBranch:
+		if !last.IsIndirect() {
+			g.addEdge(b, blocks[last.Target], EdgeTaken)
+			return
+		}
+		seen := make(map[uint64]bool)
+		for _, target := range src.JumpTable(last.Addr) {
+			if !seen[target] {
+				seen[target] = true
+				g.addEdge(b, blocks[target], EdgeIndirect)
+			}
+		}
+	case dis.FlowCall:
+		g.addEdge(b, blocks[b.End], EdgeCall)
+	case dis.FlowReturn:
+		if g.Exit == nil {
+			g.Exit = &Block{Index: len(g.Blocks)}
+		}
+		g.addEdge(b, g.Exit, EdgeReturn)
+	default:
+		if last.FallsThrough() {
+			g.addEdge(b, blocks[b.End], EdgeFallthrough)
+		}
+	}
+}
+
+// Block returns the block containing addr, nil if there's none.
+func (g *Graph) Block(addr uint64) *Block {
+	i := sort.Search(len(g.Blocks), func(i int) bool { return g.Blocks[i].End > addr })
+	if i < len(g.Blocks) && g.Blocks[i].Start <= addr {
+		return g.Blocks[i]
+	}
+	return nil
+}
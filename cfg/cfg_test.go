@@ -0,0 +1,155 @@
+package cfg
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cyfdecyf/GoEmu/analysis"
+	dis "github.com/cyfdecyf/GoEmu/dis-x86"
+)
+
+var _ Source = (*analysis.Program)(nil)
+
+// Sum ecx, ecx-1, ..., 1 with a call in the loop.
+var loopCode = []byte{
+	0x31, 0xc0, // 0x1000: xor %eax,%eax
+	0x85, 0xc9, // 0x1002: test %ecx,%ecx
+	0x74, 0x0a, // 0x1004: je 0x1010
+	0x01, 0xc8, // 0x1006: add %ecx,%eax
+	0xe8, 0xf3, 0xff, 0xff, 0xff, // 0x1008: call 0x1000
+	0x49,       // 0x100d: dec %ecx
+	0x75, 0xf6, // 0x100e: jne 0x1006
+	0xc3, // 0x1010: ret
+}
+
+// Nested loops ending with hlt.
+var nestedCode = []byte{
+	0x41,       // 0x1000: inc %ecx
+	0x42,       // 0x1001: inc %edx
+	0x39, 0xca, // 0x1002: cmp %ecx,%edx
+	0x72, 0xfb, // 0x1004: jb 0x1001
+	0x39, 0xc1, // 0x1006: cmp %eax,%ecx
+	0x72, 0xf6, // 0x1008: jb 0x1000
+	0xf4, // 0x100a: hlt
+}
+
+var switchCode = []byte{
+	0xff, 0x24, 0x85, 0x00, 0x20, 0x00, 0x00, // 0x1000: jmp *0x2000(,%eax,4)
+	0x40,       // 0x1007: inc %eax
+	0xc3,       // 0x1008: ret
+	0x48,       // 0x1009: dec %eax
+	0xeb, 0xfb, // 0x100a: jmp 0x1007
+}
+
+// Code with jump tables.
+type switchSource struct {
+	Code
+	tables map[uint64][]uint64
+}
+
+func (s *switchSource) JumpTable(addr uint64) []uint64 {
+	return s.tables[addr]
+}
+
+func build(t *testing.T, src Source) *Graph {
+	g, err := Build(src, 0x1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func graphString(g *Graph) string {
+	var lines []string
+	for _, b := range g.allBlocks() {
+		for _, e := range b.Succs {
+			lines = append(lines, fmt.Sprintf("%s->%s %v", blockName(e.From), blockName(e.To), e.Kind))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func blockRange(b *Block) string {
+	return fmt.Sprintf("%#x-%#x", b.Start, b.End)
+}
+
+func TestBuild(t *testing.T) {
+	testdata := []struct {
+		src    Source
+		blocks string
+		edges  string
+	}{
+		{
+			&Code{0x1000, loopCode, dis.Mode32},
+			"0x1000-0x1006 0x1006-0x100d 0x100d-0x1010 0x1010-0x1011",
+			`b1000->b1010 taken
+b1000->b1006 not taken
+b1006->b100d call
+b100d->b1006 taken
+b100d->b1010 not taken
+b1010->exit return`,
+		},
+		{
+			&Code{0x1000, nestedCode, dis.Mode32},
+			"0x1000-0x1001 0x1001-0x1006 0x1006-0x100a 0x100a-0x100b",
+			`b1000->b1001 fallthrough
+b1001->b1001 taken
+b1001->b1006 not taken
+b1006->b1000 taken
+b1006->b100a not taken`,
+		},
+		{
+			&switchSource{Code{0x1000, switchCode, dis.Mode32},
+				map[uint64][]uint64{0x1000: {0x1007, 0x1009, 0x1007}}},
+			"0x1000-0x1007 0x1007-0x1009 0x1009-0x100c",
+			`b1000->b1007 indirect
+b1000->b1009 indirect
+b1007->exit return
+b1009->b1007 taken`,
+		},
+	}
+	for i, td := range testdata {
+		g := build(t, td.src)
+		var blocks []string
+		for _, b := range g.Blocks {
+			blocks = append(blocks, blockRange(b))
+		}
+		if s := strings.Join(blocks, " "); s != td.blocks {
+			t.Errorf("%d: blocks %s, should be %s", i, s, td.blocks)
+		}
+		if s := graphString(g); s != td.edges {
+			t.Errorf("%d: edges\n%s\nshould be\n%s", i, s, td.edges)
+		}
+	}
+}
+
+func TestBuildError(t *testing.T) {
+	if _, err := Build(&Code{0x1000, loopCode, dis.Mode32}, 0x2000); err != ErrEntry {
+		t.Errorf("error %v, should be %v", err, ErrEntry)
+	}
+}
+
+func TestBlock(t *testing.T) {
+	g := build(t, &Code{0x1000, loopCode, dis.Mode32})
+	testdata := []struct {
+		addr  uint64
+		block string
+	}{
+		{0x1000, "0x1000-0x1006"},
+		{0x1005, "0x1000-0x1006"},
+		{0x1008, "0x1006-0x100d"},
+		{0x1010, "0x1010-0x1011"},
+		{0x1011, ""},
+		{0xfff, ""},
+	}
+	for _, td := range testdata {
+		var s string
+		if b := g.Block(td.addr); b != nil {
+			s = blockRange(b)
+		}
+		if s != td.block {
+			t.Errorf("block of %#x: %s, should be %s", td.addr, s, td.block)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package cfg
+
+import "sort"
+
+// Loop is a natural loop. Loops with the same header are merged.
+type Loop struct {
+	Header    *Block
+	Blocks    []*Block // Sorted by address, including the header
+	BackEdges []*Edge  // Edges to the header from inside the loop
+	Parent    *Loop    // Innermost loop containing this one, nil if outermost
+}
+
+// Contains tells whether b is in the loop.
+func (l *Loop) Contains(b *Block) bool {
+	i := sort.Search(len(l.Blocks), func(i int) bool { return l.Blocks[i].Start >= b.Start })
+	return i < len(l.Blocks) && l.Blocks[i] == b
+}
+
+// All the blocks including the exit block.
+func (g *Graph) allBlocks() []*Block {
+	if g.Exit == nil {
+		return g.Blocks
+	}
+	return append(g.Blocks[:len(g.Blocks):len(g.Blocks)], g.Exit)
+}
+
+// Blocks reachable from the entry in reverse postorder.
+func (g *Graph) reversePostorder() []*Block {
+	visited := make([]bool, len(g.Blocks)+1)
+	var order []*Block
+	var visit func(b *Block)
+	visit = func(b *Block) {
+		visited[b.Index] = true
+		for _, e := range b.Succs {
+			if !visited[e.To.Index] {
+				visit(e.To)
+			}
+		}
+		order = append(order, b)
+	}
+	visit(g.Entry)
+	for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+		order[i], order[j] = order[j], order[i]
+	}
+	return order
+}
+
+// Compute immediate dominators with the algorithm in "A Simple, Fast
+// Dominance Algorithm" by Cooper, Harvey and Kennedy.
+func (g *Graph) computeDominators() {
+	for _, b := range g.allBlocks() {
+		b.rpo = -1
+		b.idom = nil
+	}
+	order := g.reversePostorder()
+	for i, b := range order {
+		b.rpo = i
+	}
+	intersect := func(b1, b2 *Block) *Block {
+		for b1 != b2 {
+			for b1.rpo > b2.rpo {
+				b1 = b1.idom
+			}
+			for b2.rpo > b1.rpo {
+				b2 = b2.idom
+			}
+		}
+		return b1
+	}
+
+	g.Entry.idom = g.Entry
+	for changed := true; changed; {
+		changed = false
+		for _, b := range order[1:] {
+			var idom *Block
+			for _, e := range b.Preds {
+				p := e.From
+				if p.idom == nil {
+					continue
+				}
+				if idom == nil {
+					idom = p
+				} else {
+					idom = intersect(p, idom)
+				}
+			}
+			if b.idom != idom {
+				b.idom = idom
+				changed = true
+			}
+		}
+	}
+}
+
+// Idom returns the immediate dominator of b, nil for the entry block and
+// blocks not reachable from the entry.
+func (g *Graph) Idom(b *Block) *Block {
+	if b == g.Entry {
+		return nil
+	}
+	return b.idom
+}
+
+// Dominates tells whether every path from the entry to b goes through a.
+// A block dominates itself.
+func (g *Graph) Dominates(a, b *Block) bool {
+	if a.rpo < 0 || b.rpo < 0 {
+		return false
+	}
+	for b.rpo > a.rpo {
+		b = b.idom
+	}
+	return a == b
+}
+
+// Loops returns the natural loops in the graph, sorted by header address.
+func (g *Graph) Loops() []*Loop {
+	if g.loops != nil {
+		return g.loops
+	}
+	loops := make(map[*Block]*Loop)
+	for _, h := range g.Blocks {
+		for _, e := range h.Preds {
+			if !g.Dominates(h, e.From) {
+				continue
+			}
+			l := loops[h]
+			if l == nil {
+				l = &Loop{Header: h}
+				loops[h] = l
+			}
+			l.BackEdges = append(l.BackEdges, e)
+		}
+	}
+
+	g.loops = make([]*Loop, 0, len(loops))
+	for _, l := range loops {
+		g.findLoopBlocks(l)
+		g.loops = append(g.loops, l)
+	}
+	sort.Slice(g.loops, func(i, j int) bool { return g.loops[i].Header.Start < g.loops[j].Header.Start })
+
+	// The innermost loop containing l is the smallest one containing its
+	// header.
+	for _, l := range g.loops {
+		for _, outer := range g.loops {
+			if outer == l || !outer.Contains(l.Header) || len(outer.Blocks) <= len(l.Blocks) {
+				continue
+			}
+			if l.Parent == nil || len(outer.Blocks) < len(l.Parent.Blocks) {
+				l.Parent = outer
+			}
+		}
+	}
+	return g.loops
+}
+
+// Blocks of the loop are those reaching a back edge without going through
+// the header.
+func (g *Graph) findLoopBlocks(l *Loop) {
+	in := map[*Block]bool{l.Header: true}
+	l.Blocks = []*Block{l.Header}
+	var work []*Block
+	for _, e := range l.BackEdges {
+		work = append(work, e.From)
+	}
+	for len(work) > 0 {
+		b := work[len(work)-1]
+		work = work[:len(work)-1]
+		if in[b] {
+			continue
+		}
+		in[b] = true
+		l.Blocks = append(l.Blocks, b)
+		for _, e := range b.Preds {
+			if e.From.rpo >= 0 {
+				work = append(work, e.From)
+			}
+		}
+	}
+	sort.Slice(l.Blocks, func(i, j int) bool { return l.Blocks[i].Start < l.Blocks[j].Start })
+}
@@ -0,0 +1,79 @@
+package cfg
+
+import (
+	"strings"
+	"testing"
+
+	dis "github.com/cyfdecyf/GoEmu/dis-x86"
+)
+
+func TestDominators(t *testing.T) {
+	testdata := []struct {
+		code []byte
+		idom string // Immediate dominator of each block and the exit block
+	}{
+		{loopCode, " b1000 b1006 b1000 b1010"},
+		{nestedCode, " b1000 b1001 b1006"},
+	}
+	for i, td := range testdata {
+		g := build(t, &Code{0x1000, td.code, dis.Mode32})
+		var idoms []string
+		for _, b := range g.allBlocks() {
+			var s string
+			if idom := g.Idom(b); idom != nil {
+				s = blockName(idom)
+			}
+			idoms = append(idoms, s)
+		}
+		if s := strings.Join(idoms, " "); s != td.idom {
+			t.Errorf("%d: idom %q, should be %q", i, s, td.idom)
+		}
+		for _, a := range g.allBlocks() {
+			for _, b := range g.allBlocks() {
+				dom := a == b
+				for d := g.Idom(b); d != nil && !dom; d = g.Idom(d) {
+					dom = a == d
+				}
+				if g.Dominates(a, b) != dom {
+					t.Errorf("%d: %s dominates %s should be %v", i, blockName(a), blockName(b), dom)
+				}
+			}
+		}
+	}
+}
+
+func TestLoops(t *testing.T) {
+	testdata := []struct {
+		code  []byte
+		loops []string // Header, blocks and parent header
+	}{
+		{loopCode, []string{"b1006: b1006 b100d"}},
+		{nestedCode, []string{
+			"b1000: b1000 b1001 b1006",
+			"b1001: b1001 in b1000",
+		}},
+		{[]byte{0xc3}, nil},
+	}
+	for i, td := range testdata {
+		g := build(t, &Code{0x1000, td.code, dis.Mode32})
+		var loops []string
+		for _, l := range g.Loops() {
+			s := blockName(l.Header) + ":"
+			for _, b := range l.Blocks {
+				s += " " + blockName(b)
+			}
+			if l.Parent != nil {
+				s += " in " + blockName(l.Parent.Header)
+			}
+			for _, e := range l.BackEdges {
+				if e.To != l.Header || !l.Contains(e.From) {
+					t.Errorf("%d: bad back edge %s->%s", i, blockName(e.From), blockName(e.To))
+				}
+			}
+			loops = append(loops, s)
+		}
+		if strings.Join(loops, "\n") != strings.Join(td.loops, "\n") {
+			t.Errorf("%d: loops %q, should be %q", i, loops, td.loops)
+		}
+	}
+}
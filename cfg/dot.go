@@ -0,0 +1,65 @@
+package cfg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	dis "github.com/cyfdecyf/GoEmu/dis-x86"
+)
+
+var edgeAttr = [...]string{
+	EdgeFallthrough: "",
+	EdgeTaken:       "color=green",
+	EdgeNotTaken:    "color=red",
+	EdgeCall:        "style=dashed",
+	EdgeReturn:      "style=dotted",
+	EdgeIndirect:    "color=blue",
+}
+
+func blockName(b *Block) string {
+	if len(b.Insns) == 0 {
+		return "exit"
+	}
+	return fmt.Sprintf("b%x", b.Start)
+}
+
+// Escape string in DOT label. Lines are left aligned by \l.
+func dotEscape(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return strings.Replace(s, `"`, `\"`, -1)
+}
+
+// WriteDOT writes the graph in Graphviz DOT format. Instructions are
+// formatted with f, AT&T syntax is used if f is nil.
+func (g *Graph) WriteDOT(w io.Writer, f dis.Formatter) error {
+	if f == nil {
+		f = dis.ATTSyntax{}
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph \"%#x\" {\n", g.Entry.Start)
+	fmt.Fprintln(bw, "\tnode [shape=box fontname=monospace];")
+	for _, b := range g.allBlocks() {
+		var label string
+		if len(b.Insns) == 0 {
+			label = "exit"
+		} else {
+			for _, insn := range b.Insns {
+				label += fmt.Sprintf("%#x: %s\\l", insn.Addr, dotEscape(strings.TrimRight(insn.Format(f), " ")))
+			}
+		}
+		fmt.Fprintf(bw, "\t%s [label=\"%s\"];\n", blockName(b), label)
+	}
+	for _, b := range g.allBlocks() {
+		for _, e := range b.Succs {
+			fmt.Fprintf(bw, "\t%s -> %s", blockName(e.From), blockName(e.To))
+			if attr := edgeAttr[e.Kind]; attr != "" {
+				fmt.Fprintf(bw, " [%s]", attr)
+			}
+			fmt.Fprintln(bw, ";")
+		}
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
@@ -0,0 +1,38 @@
+package cfg
+
+import (
+	"bytes"
+	"testing"
+
+	dis "github.com/cyfdecyf/GoEmu/dis-x86"
+)
+
+func TestWriteDOT(t *testing.T) {
+	expected := `digraph "0x1000" {
+	node [shape=box fontname=monospace];
+	b1000 [label="0x1000: xor %eax,%eax\l0x1002: test %ecx,%ecx\l0x1004: jz 0x1010\l"];
+	b1006 [label="0x1006: add %ecx,%eax\l0x1008: call 0x1000\l"];
+	b100d [label="0x100d: dec %ecx\l0x100e: jnz 0x1006\l"];
+	b1010 [label="0x1010: ret\l"];
+	exit [label="exit"];
+	b1000 -> b1010 [color=green];
+	b1000 -> b1006 [color=red];
+	b1006 -> b100d [style=dashed];
+	b100d -> b1006 [color=green];
+	b100d -> b1010 [color=red];
+	b1010 -> exit [style=dotted];
+}
+`
+	g := build(t, &Code{0x1000, loopCode, dis.Mode32})
+	var buf bytes.Buffer
+	if err := g.WriteDOT(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != expected {
+		t.Errorf("got\n%s\nshould be\n%s", buf.String(), expected)
+	}
+
+	if got := dotEscape(`"a\b"`); got != `\"a\\b\"` {
+		t.Errorf("escape: got %s", got)
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	dis "github.com/cyfdecyf/GoEmu/dis-x86"
+)
+
+// Section of code to disassemble.
+type section struct {
+	name string
+	addr uint64 // Virtual address of the first byte
+	data []byte
+	code bool // Contains instructions, disassembled by default
+}
+
+type symbol struct {
+	name string
+	addr uint64
+	size uint64
+}
+
+// Executable or raw binary file loaded into memory.
+type binFile struct {
+	sections   []section
+	mode       dis.Mode // Mode given by the file header, 0 if unknown
+	symbols    []symbol
+	symbolizer dis.Symbolizer // nil if the file has no symbols
+}
+
+// Open file in the given format. Format "auto" detects ELF, PE and Mach-O by
+// the magic number, and takes anything else as raw binary.
+func openBinFile(path, format string, base uint64) (*binFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if format == "auto" {
+		format = detectFormat(f)
+	}
+	switch format {
+	case "elf":
+		return loadELF(f)
+	case "pe":
+		return loadPE(f)
+	case "macho":
+		return loadMachO(f)
+	case "raw":
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return nil, err
+		}
+		return &binFile{sections: []section{{name: "raw", addr: base, data: data, code: true}}}, nil
+	}
+	return nil, fmt.Errorf("unknown file format %s", format)
+}
+
+func detectFormat(r io.ReaderAt) string {
+	var magic [4]byte
+	if _, err := r.ReadAt(magic[:], 0); err != nil {
+		return "raw"
+	}
+	switch {
+	case string(magic[:]) == elf.ELFMAG:
+		return "elf"
+	case magic[0] == 'M' && magic[1] == 'Z':
+		return "pe"
+	}
+	switch uint32(magic[0]) | uint32(magic[1])<<8 | uint32(magic[2])<<16 | uint32(magic[3])<<24 {
+	case macho.Magic32, macho.Magic64:
+		return "macho"
+	}
+	return "raw"
+}
+
+func loadELF(r io.ReaderAt) (*binFile, error) {
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	bf := &binFile{}
+	switch f.Machine {
+	case elf.EM_386:
+		bf.mode = dis.Mode32
+	case elf.EM_X86_64:
+		bf.mode = dis.Mode64
+	}
+
+	for _, s := range f.Sections {
+		if s.Type == elf.SHT_NOBITS || s.Flags&elf.SHF_ALLOC == 0 {
+			continue
+		}
+		data, err := s.Data()
+		if err != nil {
+			return nil, fmt.Errorf("reading section %s: %v", s.Name, err)
+		}
+		bf.sections = append(bf.sections, section{
+			name: s.Name,
+			addr: s.Addr,
+			data: data,
+			code: s.Flags&elf.SHF_EXECINSTR != 0,
+		})
+	}
+
+	syms, err := f.Symbols()
+	if err == elf.ErrNoSymbols {
+		syms, err = f.DynamicSymbols()
+	}
+	if err != nil {
+		// Disassemble without symbols
+		return bf, nil
+	}
+	for _, s := range syms {
+		if s.Section != elf.SHN_UNDEF && s.Name != "" {
+			bf.symbols = append(bf.symbols, symbol{s.Name, s.Value, s.Size})
+		}
+	}
+	if bf.symbolizer, err = dis.NewELFSymbolizer(f); err != nil {
+		return nil, err
+	}
+	return bf, nil
+}
+
+func loadPE(r io.ReaderAt) (*binFile, error) {
+	f, err := pe.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	bf := &binFile{}
+	var imageBase uint64
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		imageBase = uint64(oh.ImageBase)
+	case *pe.OptionalHeader64:
+		imageBase = oh.ImageBase
+	}
+	switch f.Machine {
+	case pe.IMAGE_FILE_MACHINE_I386:
+		bf.mode = dis.Mode32
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		bf.mode = dis.Mode64
+	}
+
+	for _, s := range f.Sections {
+		data, err := s.Data()
+		if err != nil {
+			return nil, fmt.Errorf("reading section %s: %v", s.Name, err)
+		}
+		// Raw data is padded to the file alignment
+		if s.VirtualSize != 0 && int(s.VirtualSize) < len(data) {
+			data = data[:s.VirtualSize]
+		}
+		bf.sections = append(bf.sections, section{
+			name: s.Name,
+			addr: imageBase + uint64(s.VirtualAddress),
+			data: data,
+			code: s.Characteristics&pe.IMAGE_SCN_CNT_CODE != 0,
+		})
+	}
+
+	for _, s := range f.Symbols {
+		if s.SectionNumber <= 0 || int(s.SectionNumber) > len(f.Sections) {
+			continue
+		}
+		addr := imageBase + uint64(f.Sections[s.SectionNumber-1].VirtualAddress) + uint64(s.Value)
+		bf.symbols = append(bf.symbols, symbol{name: s.Name, addr: addr})
+	}
+	bf.setSymbolizer()
+	return bf, nil
+}
+
+func loadMachO(r io.ReaderAt) (*binFile, error) {
+	f, err := macho.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	bf := &binFile{}
+	switch f.Cpu {
+	case macho.Cpu386:
+		bf.mode = dis.Mode32
+	case macho.CpuAmd64:
+		bf.mode = dis.Mode64
+	}
+
+	const (
+		pureInstructions = 0x80000000 // S_ATTR_PURE_INSTRUCTIONS
+		someInstructions = 0x400      // S_ATTR_SOME_INSTRUCTIONS
+		zeroFill         = 0x1        // S_ZEROFILL
+	)
+	for _, s := range f.Sections {
+		if s.Flags&0xff == zeroFill {
+			continue
+		}
+		data, err := s.Data()
+		if err != nil {
+			return nil, fmt.Errorf("reading section %s: %v", s.Name, err)
+		}
+		bf.sections = append(bf.sections, section{
+			name: s.Seg + "," + s.Name,
+			addr: s.Addr,
+			data: data,
+			code: s.Flags&(pureInstructions|someInstructions) != 0,
+		})
+	}
+
+	if f.Symtab != nil {
+		const nType, nSect = 0x0e, 0x0e
+		for _, s := range f.Symtab.Syms {
+			if s.Type&nType == nSect && s.Sect != 0 {
+				bf.symbols = append(bf.symbols, symbol{name: s.Name, addr: s.Value})
+			}
+		}
+	}
+	bf.setSymbolizer()
+	return bf, nil
+}
+
+// PE and Mach-O symbols have no size, take the distance to the next symbol
+// as the size and use them for symbolizing addresses.
+func (bf *binFile) setSymbolizer() {
+	if len(bf.symbols) == 0 {
+		return
+	}
+	sort.SliceStable(bf.symbols, func(i, j int) bool {
+		return bf.symbols[i].addr < bf.symbols[j].addr
+	})
+	for i := range bf.symbols {
+		if i+1 < len(bf.symbols) {
+			bf.symbols[i].size = bf.symbols[i+1].addr - bf.symbols[i].addr
+		} else if sec := bf.sectionAt(bf.symbols[i].addr); sec != nil {
+			bf.symbols[i].size = sec.addr + uint64(len(sec.data)) - bf.symbols[i].addr
+		}
+	}
+	bf.symbolizer = symbolTable(bf.symbols)
+}
+
+// Symbols sorted by address.
+type symbolTable []symbol
+
+func (st symbolTable) Symbolize(addr uint64) (name string, base uint64) {
+	i := sort.Search(len(st), func(i int) bool {
+		return st[i].addr > addr
+	}) - 1
+	if i < 0 || addr-st[i].addr >= st[i].size && addr != st[i].addr {
+		return "", 0
+	}
+	return st[i].name, st[i].addr
+}
+
+// Return the section containing addr, nil if there's none.
+func (bf *binFile) sectionAt(addr uint64) *section {
+	for i := range bf.sections {
+		s := &bf.sections[i]
+		if s.addr <= addr && addr-s.addr < uint64(len(s.data)) {
+			return s
+		}
+	}
+	return nil
+}
+
+func (bf *binFile) section(name string) *section {
+	for i := range bf.sections {
+		if bf.sections[i].name == name {
+			return &bf.sections[i]
+		}
+	}
+	return nil
+}
+
+func (bf *binFile) symbol(name string) *symbol {
+	for i := range bf.symbols {
+		if bf.symbols[i].name == name {
+			return &bf.symbols[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+// Godis disassembles x86 code in ELF, PE, Mach-O or raw binary files. It
+// works the same on every host, so it can be used in place of objdump.
+//
+// Usage:
+//
+//	godis [flags] file
+//
+// By default all code sections are disassembled. Use -section, -sym or
+// -start/-stop to select the code to disassemble. Mode is taken from the file
+// header, and defaults to 32-bit for raw binary.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	dis "github.com/cyfdecyf/GoEmu/dis-x86"
+)
+
+type options struct {
+	mode      dis.Mode
+	formatter dis.Formatter
+	showAddr  bool
+	showBytes bool
+	start     uint64
+	stop      uint64 // 0 means till the end of section
+}
+
+var (
+	modeFlag    = flag.Int("mode", 0, "processor mode: 16, 32 or 64 (default from file header)")
+	syntaxFlag  = flag.String("syntax", "att", "assembly syntax: att, intel or go")
+	formatFlag  = flag.String("format", "auto", "file format: auto, elf, pe, macho or raw")
+	baseFlag    = flag.String("base", "0", "load address of raw binary")
+	sectionFlag = flag.String("section", "", "disassemble the named section")
+	symFlag     = flag.String("sym", "", "disassemble the named symbol")
+	startFlag   = flag.String("start", "", "start disassembling at the address")
+	stopFlag    = flag.String("stop", "", "stop disassembling at the address")
+	addrFlag    = flag.Bool("addr", true, "show instruction address")
+	bytesFlag   = flag.Bool("bytes", true, "show instruction raw bytes")
+	noSymFlag   = flag.Bool("nosym", false, "show addresses without symbol names")
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: godis [flags] file")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func fatal(format string, a ...interface{}) {
+	fmt.Fprintf(os.Stderr, "godis: "+format+"\n", a...)
+	os.Exit(1)
+}
+
+func parseAddr(name, s string) uint64 {
+	addr, err := strconv.ParseUint(s, 0, 64)
+	if err != nil {
+		fatal("invalid %s address %s", name, s)
+	}
+	return addr
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		usage()
+	}
+
+	bf, err := openBinFile(flag.Arg(0), *formatFlag, parseAddr("base", *baseFlag))
+	if err != nil {
+		fatal("%v", err)
+	}
+
+	opt := options{showAddr: *addrFlag, showBytes: *bytesFlag}
+	switch *modeFlag {
+	case 0:
+		opt.mode = bf.mode
+		if opt.mode == 0 {
+			opt.mode = dis.Mode32
+		}
+	case 16, 32, 64:
+		opt.mode = dis.Mode(*modeFlag)
+	default:
+		fatal("invalid mode %d", *modeFlag)
+	}
+	symbolizer := bf.symbolizer
+	if *noSymFlag {
+		symbolizer = nil
+	}
+	switch *syntaxFlag {
+	case "att":
+		opt.formatter = dis.ATTSyntax{Symbolizer: symbolizer}
+	case "intel":
+		opt.formatter = dis.IntelSyntax{Symbolizer: symbolizer}
+	case "go":
+		opt.formatter = dis.GoSyntax{Symbolizer: symbolizer}
+	default:
+		fatal("invalid syntax %s", *syntaxFlag)
+	}
+
+	sections, err := selectCode(bf, &opt)
+	if err != nil {
+		fatal("%v", err)
+	}
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	for _, sec := range sections {
+		fmt.Fprintf(w, "\nDisassembly of section %s:\n", sec.name)
+		disassemble(w, sec, symbolizer, &opt)
+	}
+}
+
+// Find the sections to disassemble and the address range according to flags.
+func selectCode(bf *binFile, opt *options) ([]*section, error) {
+	switch {
+	case *symFlag != "":
+		sym := bf.symbol(*symFlag)
+		if sym == nil {
+			return nil, fmt.Errorf("symbol %s not found", *symFlag)
+		}
+		sec := bf.sectionAt(sym.addr)
+		if sec == nil {
+			return nil, fmt.Errorf("symbol %s is not in any section", *symFlag)
+		}
+		opt.start = sym.addr
+		if sym.size != 0 {
+			opt.stop = sym.addr + sym.size
+		}
+		return []*section{sec}, nil
+	case *sectionFlag != "":
+		sec := bf.section(*sectionFlag)
+		if sec == nil {
+			return nil, fmt.Errorf("section %s not found", *sectionFlag)
+		}
+		opt.start, opt.stop = sec.addr, 0
+	}
+	if *startFlag != "" {
+		opt.start = parseAddr("start", *startFlag)
+	}
+	if *stopFlag != "" {
+		opt.stop = parseAddr("stop", *stopFlag)
+	}
+	if *sectionFlag != "" {
+		return []*section{bf.section(*sectionFlag)}, nil
+	}
+
+	var sections []*section
+	for i := range bf.sections {
+		sec := &bf.sections[i]
+		if *startFlag != "" || *stopFlag != "" {
+			// Address range may be in any section
+			end := sec.addr + uint64(len(sec.data))
+			if end > opt.start && (opt.stop == 0 || sec.addr < opt.stop) {
+				sections = append(sections, sec)
+			}
+		} else if sec.code {
+			sections = append(sections, sec)
+		}
+	}
+	if len(sections) == 0 {
+		return nil, errors.New("no code to disassemble")
+	}
+	return sections, nil
+}
+
+// Disassemble instructions of sec starting within the address range given in
+// opt. Like objdump's --stop-address, the last instruction may extend beyond
+// the stop address. Undecodable byte is shown as (bad), and disassembly
+// continues with the next byte.
+func disassemble(w io.Writer, sec *section, symbolizer dis.Symbolizer, opt *options) {
+	start := uint64(0)
+	if opt.start > sec.addr {
+		start = opt.start - sec.addr
+	}
+	if start >= uint64(len(sec.data)) {
+		return
+	}
+	stop := sec.addr + uint64(len(sec.data))
+	if opt.stop != 0 && opt.stop < stop {
+		stop = opt.stop
+	}
+
+	dc := dis.NewDisContext(bytes.NewReader(sec.data[start:]))
+	dc.SetMode(opt.mode)
+	dc.BaseAddr = sec.addr + start
+	for addr := dc.BaseAddr; addr < stop; {
+		insn, err := dc.NextInsn()
+		var raw []byte
+		var text string
+		var derr *dis.DecodeError
+		switch {
+		case err == io.EOF:
+			return
+		case errors.As(err, &derr):
+			// Decoding restarts from the next byte
+			raw, text = derr.Bytes[:1], "(bad)"
+		case err != nil:
+			fmt.Fprintf(w, "error: %v\n", err)
+			return
+		default:
+			raw = insn.Bytes()
+			text = strings.TrimSpace(insn.Format(opt.formatter))
+		}
+
+		if symbolizer != nil {
+			if name, base := symbolizer.Symbolize(addr); name != "" && base == addr {
+				fmt.Fprintf(w, "\n%0*x <%s>:\n", int(opt.mode)/4, addr, name)
+			}
+		}
+		if opt.showAddr {
+			fmt.Fprintf(w, "%8x:\t", addr)
+		}
+		if opt.showBytes {
+			fmt.Fprintf(w, "%-21s\t", fmt.Sprintf("% x", raw))
+		}
+		fmt.Fprintln(w, text)
+		addr += uint64(len(raw))
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	dis "github.com/cyfdecyf/GoEmu/dis-x86"
+)
+
+func TestDisassemble(t *testing.T) {
+	sec := &section{
+		name: "raw",
+		addr: 0x1000,
+		data: []byte{
+			0x55,       // push %ebp
+			0x89, 0xe5, // mov %esp,%ebp
+			0xe8, 0xf8, 0xff, 0xff, 0xff, // call 0x1000
+			0x0f,       // (bad), decoding restarts from the next byte
+			0xff, 0xc3, // inc %ebx
+		},
+		code: true,
+	}
+	symbols := symbolTable{{name: "main", addr: 0x1000, size: 0x0b}}
+	opt := &options{
+		mode:      dis.Mode32,
+		formatter: dis.ATTSyntax{Symbolizer: symbols},
+		showAddr:  true,
+		showBytes: true,
+	}
+	var buf bytes.Buffer
+	disassemble(&buf, sec, symbols, opt)
+	expected := `
+00001000 <main>:
+    1000:	55                   	push %ebp
+    1001:	89 e5                	mov %esp,%ebp
+    1003:	e8 f8 ff ff ff       	call 1000 <main>
+    1008:	0f                   	(bad)
+    1009:	ff c3                	inc %ebx
+`
+	if buf.String() != expected {
+		t.Errorf("got\n%s\nshould be\n%s", buf.String(), expected)
+	}
+
+	// Address range inside the section, without address and raw bytes
+	buf.Reset()
+	opt = &options{
+		mode:      dis.Mode32,
+		formatter: dis.IntelSyntax{},
+		start:     0x1001,
+		stop:      0x1003,
+	}
+	disassemble(&buf, sec, nil, opt)
+	if expected = "mov ebp,esp\n"; buf.String() != expected {
+		t.Errorf("got %q, should be %q", buf.String(), expected)
+	}
+
+	// Instruction starting before the stop address is shown completely
+	buf.Reset()
+	opt.stop = 0x1002
+	disassemble(&buf, sec, nil, opt)
+	if expected = "mov ebp,esp\n"; buf.String() != expected {
+		t.Errorf("got %q, should be %q", buf.String(), expected)
+	}
+}
+
+func TestSymbolTable(t *testing.T) {
+	bf := &binFile{
+		sections: []section{{name: ".text", addr: 0x1000, data: make([]byte, 0x100)}},
+		symbols: []symbol{
+			{name: "b", addr: 0x1040},
+			{name: "a", addr: 0x1000},
+		},
+	}
+	bf.setSymbolizer()
+	testdata := []struct {
+		addr uint64
+		name string
+	}{
+		{0x0fff, ""},
+		{0x1000, "a"},
+		{0x103f, "a"},
+		{0x1040, "b"},
+		{0x10ff, "b"},
+		{0x1100, ""},
+	}
+	for _, td := range testdata {
+		if name, _ := bf.symbolizer.Symbolize(td.addr); name != td.name {
+			t.Errorf("%#x: got %q, should be %q", td.addr, name, td.name)
+		}
+	}
+}
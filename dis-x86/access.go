@@ -0,0 +1,691 @@
+package dis
+
+import "strings"
+
+// Access tells whether an operand is read or written by the instruction.
+type Access byte
+
+const (
+	AccessRead Access = 1 << iota
+	AccessWrite
+	AccessReadWrite = AccessRead | AccessWrite
+)
+
+// Flags is a set of EFLAGS bits.
+type Flags uint32
+
+const (
+	FlagCF   Flags = 1 << 0
+	FlagPF   Flags = 1 << 2
+	FlagAF   Flags = 1 << 4
+	FlagZF   Flags = 1 << 6
+	FlagSF   Flags = 1 << 7
+	FlagTF   Flags = 1 << 8
+	FlagIF   Flags = 1 << 9
+	FlagDF   Flags = 1 << 10
+	FlagOF   Flags = 1 << 11
+	FlagIOPL Flags = 3 << 12
+	FlagNT   Flags = 1 << 14
+	FlagRF   Flags = 1 << 16
+	FlagVM   Flags = 1 << 17
+	FlagAC   Flags = 1 << 18
+	FlagVIF  Flags = 1 << 19
+	FlagVIP  Flags = 1 << 20
+	FlagID   Flags = 1 << 21
+
+	// Status flags set by arithmetic instructions
+	FlagsStatus = FlagCF | FlagPF | FlagAF | FlagZF | FlagSF | FlagOF
+	FlagsAll    = FlagsStatus | FlagTF | FlagIF | FlagDF | FlagIOPL | FlagNT |
+		FlagRF | FlagVM | FlagAC | FlagVIF | FlagVIP | FlagID
+)
+
+// FlagEffect tells how an instruction uses EFLAGS.
+type FlagEffect struct {
+	Tested    Flags // Read by the instruction
+	Modified  Flags // Set or cleared according to the result
+	Undefined Flags // Left in an undefined state
+}
+
+// Flags tested by the condition code of jcc, setcc and cmovcc, in the order
+// of the condition code, e.g. o, no, b, ae.
+var conditionFlags = [16]Flags{
+	FlagOF, FlagOF,
+	FlagCF, FlagCF,
+	FlagZF, FlagZF,
+	FlagCF | FlagZF, FlagCF | FlagZF,
+	FlagSF, FlagSF,
+	FlagPF, FlagPF,
+	FlagSF | FlagOF, FlagSF | FlagOF,
+	FlagZF | FlagSF | FlagOF, FlagZF | FlagSF | FlagOF,
+}
+
+var conditionalInsns = [...][16]uint16{
+	{Insn_Jo, Insn_Jno, Insn_Jb, Insn_Jae, Insn_Jz, Insn_Jnz, Insn_Jbe, Insn_Ja,
+		Insn_Js, Insn_Jns, Insn_Jp, Insn_Jnp, Insn_Jl, Insn_Jge, Insn_Jle, Insn_Jg},
+	{Insn_Seto, Insn_Setno, Insn_Setb, Insn_Setae, Insn_Setz, Insn_Setnz, Insn_Setbe, Insn_Seta,
+		Insn_Sets, Insn_Setns, Insn_Setp, Insn_Setnp, Insn_Setl, Insn_Setge, Insn_Setle, Insn_Setg},
+	{Insn_Cmovo, Insn_Cmovno, Insn_Cmovb, Insn_Cmovae, Insn_Cmovz, Insn_Cmovnz, Insn_Cmovbe, Insn_Cmova,
+		Insn_Cmovs, Insn_Cmovns, Insn_Cmovp, Insn_Cmovnp, Insn_Cmovl, Insn_Cmovge, Insn_Cmovle, Insn_Cmovg},
+}
+
+const (
+	flagsLogic = FlagCF | FlagPF | FlagZF | FlagSF | FlagOF // CF and OF are cleared
+	flagsMul   = FlagCF | FlagOF
+	flagsShift = FlagCF | FlagPF | FlagZF | FlagSF | FlagOF
+	flagsBCD   = FlagPF | FlagZF | FlagSF
+	// Interrupts clear TF, NT, RF and VM, and also IF through interrupt gate
+	flagsInt = FlagTF | FlagIF | FlagNT | FlagRF | FlagVM
+)
+
+// EFLAGS usage of instructions, indexed by opcode id. Instructions not listed
+// here don't use EFLAGS.
+var flagEffectOfInsn = map[uint16]FlagEffect{
+	Insn_Add:     {Modified: FlagsStatus},
+	Insn_Sub:     {Modified: FlagsStatus},
+	Insn_Cmp:     {Modified: FlagsStatus},
+	Insn_Neg:     {Modified: FlagsStatus},
+	Insn_Xadd:    {Modified: FlagsStatus},
+	Insn_Cmpxchg: {Modified: FlagsStatus},
+	Insn_Adc:     {Tested: FlagCF, Modified: FlagsStatus},
+	Insn_Sbb:     {Tested: FlagCF, Modified: FlagsStatus},
+	Insn_Inc:     {Modified: FlagsStatus &^ FlagCF},
+	Insn_Dec:     {Modified: FlagsStatus &^ FlagCF},
+	Insn_And:     {Modified: flagsLogic, Undefined: FlagAF},
+	Insn_Or:      {Modified: flagsLogic, Undefined: FlagAF},
+	Insn_Xor:     {Modified: flagsLogic, Undefined: FlagAF},
+	Insn_Test:    {Modified: flagsLogic, Undefined: FlagAF},
+
+	Insn_Mul:  {Modified: flagsMul, Undefined: FlagsStatus &^ flagsMul},
+	Insn_Imul: {Modified: flagsMul, Undefined: FlagsStatus &^ flagsMul},
+	Insn_Div:  {Undefined: FlagsStatus},
+	Insn_Idiv: {Undefined: FlagsStatus},
+
+	// OF is undefined if the count is greater than 1, which is only known
+	// when executing
+	Insn_Shl:  {Modified: flagsShift, Undefined: FlagAF},
+	Insn_Sal:  {Modified: flagsShift, Undefined: FlagAF},
+	Insn_Shr:  {Modified: flagsShift, Undefined: FlagAF},
+	Insn_Sar:  {Modified: flagsShift, Undefined: FlagAF},
+	Insn_Shld: {Modified: flagsShift, Undefined: FlagAF},
+	Insn_Shrd: {Modified: flagsShift, Undefined: FlagAF},
+	Insn_Rol:  {Modified: FlagCF | FlagOF},
+	Insn_Ror:  {Modified: FlagCF | FlagOF},
+	Insn_Rcl:  {Tested: FlagCF, Modified: FlagCF | FlagOF},
+	Insn_Rcr:  {Tested: FlagCF, Modified: FlagCF | FlagOF},
+
+	Insn_Bt:     {Modified: FlagCF, Undefined: FlagPF | FlagAF | FlagSF | FlagOF},
+	Insn_Bts:    {Modified: FlagCF, Undefined: FlagPF | FlagAF | FlagSF | FlagOF},
+	Insn_Btr:    {Modified: FlagCF, Undefined: FlagPF | FlagAF | FlagSF | FlagOF},
+	Insn_Btc:    {Modified: FlagCF, Undefined: FlagPF | FlagAF | FlagSF | FlagOF},
+	Insn_Bsf:    {Modified: FlagZF, Undefined: FlagsStatus &^ FlagZF},
+	Insn_Bsr:    {Modified: FlagZF, Undefined: FlagsStatus &^ FlagZF},
+	Insn_Lzcnt:  {Modified: FlagCF | FlagZF, Undefined: FlagPF | FlagAF | FlagSF | FlagOF},
+	Insn_Popcnt: {Modified: FlagsStatus},
+
+	Insn_Aaa:  {Tested: FlagAF, Modified: FlagAF | FlagCF, Undefined: flagsBCD | FlagOF},
+	Insn_Aas:  {Tested: FlagAF, Modified: FlagAF | FlagCF, Undefined: flagsBCD | FlagOF},
+	Insn_Daa:  {Tested: FlagAF | FlagCF, Modified: flagsBCD | FlagAF | FlagCF, Undefined: FlagOF},
+	Insn_Das:  {Tested: FlagAF | FlagCF, Modified: flagsBCD | FlagAF | FlagCF, Undefined: FlagOF},
+	Insn_Aam:  {Modified: flagsBCD, Undefined: FlagAF | FlagCF | FlagOF},
+	Insn_Aad:  {Modified: flagsBCD, Undefined: FlagAF | FlagCF | FlagOF},
+	Insn_Salc: {Tested: FlagCF},
+
+	Insn_Clc:  {Modified: FlagCF},
+	Insn_Stc:  {Modified: FlagCF},
+	Insn_Cmc:  {Tested: FlagCF, Modified: FlagCF},
+	Insn_Cld:  {Modified: FlagDF},
+	Insn_Std:  {Modified: FlagDF},
+	Insn_Cli:  {Modified: FlagIF},
+	Insn_Sti:  {Modified: FlagIF},
+	Insn_Lahf: {Tested: FlagsStatus &^ FlagOF},
+	Insn_Sahf: {Modified: FlagsStatus &^ FlagOF},
+
+	Insn_Pushf:    {Tested: FlagsAll},
+	Insn_Popf:     {Modified: FlagsAll},
+	Insn_Iret:     {Modified: FlagsAll},
+	Insn_Rsm:      {Modified: FlagsAll},
+	Insn_Int:      {Modified: flagsInt},
+	Insn_Int_3:    {Modified: flagsInt},
+	Insn_Int1:     {Modified: flagsInt},
+	Insn_Into:     {Tested: FlagOF, Modified: flagsInt},
+	Insn_Syscall:  {Tested: FlagsAll, Modified: FlagsAll},
+	Insn_Sysret:   {Modified: FlagsAll},
+	Insn_Sysenter: {Modified: FlagIF | FlagVM | FlagRF},
+
+	Insn_Loopz:  {Tested: FlagZF},
+	Insn_Loopnz: {Tested: FlagZF},
+
+	Insn_Movs: {Tested: FlagDF},
+	Insn_Lods: {Tested: FlagDF},
+	Insn_Stos: {Tested: FlagDF},
+	Insn_Ins:  {Tested: FlagDF},
+	Insn_Outs: {Tested: FlagDF},
+	Insn_Cmps: {Tested: FlagDF, Modified: FlagsStatus},
+	Insn_Scas: {Tested: FlagDF, Modified: FlagsStatus},
+
+	Insn_Arpl:       {Modified: FlagZF},
+	Insn_Lar:        {Modified: FlagZF},
+	Insn_Lsl:        {Modified: FlagZF},
+	Insn_Verr:       {Modified: FlagZF},
+	Insn_Verw:       {Modified: FlagZF},
+	Insn_Cmpxchg8b:  {Modified: FlagZF},
+	Insn_Cmpxchg16b: {Modified: FlagZF},
+
+	// Compare and test of floating point and vector, the flags not holding
+	// the result are cleared
+	Insn_Fcomi:      {Modified: FlagsStatus},
+	Insn_Fcomip:     {Modified: FlagsStatus},
+	Insn_Fucomi:     {Modified: FlagsStatus},
+	Insn_Fucomip:    {Modified: FlagsStatus},
+	Insn_Fcmovb:     {Tested: FlagCF},
+	Insn_Fcmovnb:    {Tested: FlagCF},
+	Insn_Fcmove:     {Tested: FlagZF},
+	Insn_Fcmovne:    {Tested: FlagZF},
+	Insn_Fcmovbe:    {Tested: FlagCF | FlagZF},
+	Insn_Fcmovnbe:   {Tested: FlagCF | FlagZF},
+	Insn_Fcmovu:     {Tested: FlagPF},
+	Insn_Fcmovnu:    {Tested: FlagPF},
+	Insn_Comiss:     {Modified: FlagsStatus},
+	Insn_Ucomiss:    {Modified: FlagsStatus},
+	Insn_Comisd:     {Modified: FlagsStatus},
+	Insn_Ucomisd:    {Modified: FlagsStatus},
+	Insn_Vcomiss:    {Modified: FlagsStatus},
+	Insn_Vucomiss:   {Modified: FlagsStatus},
+	Insn_Vcomisd:    {Modified: FlagsStatus},
+	Insn_Vucomisd:   {Modified: FlagsStatus},
+	Insn_Ptest:      {Modified: FlagsStatus},
+	Insn_Vptest:     {Modified: FlagsStatus},
+	Insn_Vtestps:    {Modified: FlagsStatus},
+	Insn_Vtestpd:    {Modified: FlagsStatus},
+	Insn_Pcmpestri:  {Modified: FlagsStatus},
+	Insn_Pcmpestrm:  {Modified: FlagsStatus},
+	Insn_Pcmpistri:  {Modified: FlagsStatus},
+	Insn_Pcmpistrm:  {Modified: FlagsStatus},
+	Insn_Vpcmpestri: {Modified: FlagsStatus},
+	Insn_Vpcmpestrm: {Modified: FlagsStatus},
+	Insn_Vpcmpistri: {Modified: FlagsStatus},
+	Insn_Vpcmpistrm: {Modified: FlagsStatus},
+	Insn_Kortestb:   {Modified: FlagsStatus},
+	Insn_Kortestw:   {Modified: FlagsStatus},
+	Insn_Kortestd:   {Modified: FlagsStatus},
+	Insn_Kortestq:   {Modified: FlagsStatus},
+	Insn_Ktestb:     {Modified: FlagsStatus},
+	Insn_Ktestw:     {Modified: FlagsStatus},
+	Insn_Ktestd:     {Modified: FlagsStatus},
+	Insn_Ktestq:     {Modified: FlagsStatus},
+}
+
+// Condition code of jcc, setcc and cmovcc.
+var conditionOfInsn = make(map[uint16]byte)
+
+func init() {
+	for _, insns := range conditionalInsns {
+		for cc, opid := range insns {
+			flagEffectOfInsn[opid] = FlagEffect{Tested: conditionFlags[cc]}
+			conditionOfInsn[opid] = byte(cc)
+		}
+	}
+}
+
+// Condition returns the condition code of jcc, setcc and cmovcc, which is the
+// low 4 bits of the opcode, e.g. 4 for jz. ok is false for other instructions.
+func (insn *Instruction) Condition() (cc byte, ok bool) {
+	cc, ok = conditionOfInsn[insn.OpId]
+	return
+}
+
+// FlagEffect returns the EFLAGS bits tested, modified and left undefined by
+// the instruction. Flags which are only affected in some conditions, e.g.
+// shift with count 0, are reported as if the instruction always affects them.
+func (insn *Instruction) FlagEffect() FlagEffect {
+	fe := flagEffectOfInsn[insn.OpId]
+	// repz and repnz terminate by the ZF set by cmps and scas
+	if (insn.OpId == Insn_Cmps || insn.OpId == Insn_Scas) &&
+		insn.Prefix&(PrefixREPZ|PrefixREPNZ) != 0 {
+		fe.Tested |= FlagZF
+	}
+	return fe
+}
+
+// Size of implicit register operands, in addition to OpSizeByte etc.
+const (
+	sizeAddress = OpSizeFull + 1 + iota // Address size, e.g. ecx of loop
+	sizeStack                           // Size of the stack pointer
+	sizeOperand                         // Size of the first explicit operand
+)
+
+// Register used by an instruction but not encoded in it, e.g. esp of push.
+// OpSizeFull means the operand size.
+type implicitReg struct {
+	class RegClass
+	num   byte
+	size  byte
+}
+
+type implicitRegs struct {
+	read, written []implicitReg
+}
+
+func gpr(num, size byte) implicitReg {
+	return implicitReg{RegGP, num, size}
+}
+
+func sreg(num byte) implicitReg {
+	return implicitReg{RegSeg, num, OpSizeWord}
+}
+
+var (
+	stackPtr   = []implicitReg{gpr(Esp, sizeStack)}
+	stackFrame = []implicitReg{gpr(Esp, sizeStack), gpr(Ebp, sizeStack)}
+	allGPRegs  = []implicitReg{
+		gpr(Eax, OpSizeFull), gpr(Ecx, OpSizeFull), gpr(Edx, OpSizeFull), gpr(Ebx, OpSizeFull),
+		gpr(Esp, OpSizeFull), gpr(Ebp, OpSizeFull), gpr(Esi, OpSizeFull), gpr(Edi, OpSizeFull),
+	}
+	st0     = []implicitReg{{RegFPU, 0, OpSizeNone}}
+	eaxEdx  = []implicitReg{gpr(Eax, OpSizeLong), gpr(Edx, OpSizeLong)}
+	ecxOnly = []implicitReg{gpr(Ecx, OpSizeLong)}
+)
+
+// Implicit registers of instructions, indexed by opcode id. Those depending on
+// the operand size, e.g. mul, are handled in implicitRegsOf.
+var implicitRegsOfInsn = map[uint16]implicitRegs{
+	Insn_Push:  {stackPtr, stackPtr},
+	Insn_Pushf: {stackPtr, stackPtr},
+	Insn_Pusha: {allGPRegs, stackPtr},
+	Insn_Pop:   {stackPtr, stackPtr},
+	Insn_Popf:  {stackPtr, stackPtr},
+	Insn_Popa: {stackPtr, []implicitReg{
+		gpr(Eax, OpSizeFull), gpr(Ecx, OpSizeFull), gpr(Edx, OpSizeFull), gpr(Ebx, OpSizeFull),
+		gpr(Esp, sizeStack), gpr(Ebp, OpSizeFull), gpr(Esi, OpSizeFull), gpr(Edi, OpSizeFull),
+	}},
+	Insn_Call:     {stackPtr, stackPtr},
+	Insn_Ret:      {stackPtr, stackPtr},
+	Insn_Enter:    {stackFrame, stackFrame},
+	Insn_Leave:    {[]implicitReg{gpr(Ebp, sizeStack)}, stackFrame},
+	Insn_Call_far: {[]implicitReg{gpr(Esp, sizeStack), sreg(CS)}, []implicitReg{gpr(Esp, sizeStack), sreg(CS)}},
+	Insn_Jmp_far:  {nil, []implicitReg{sreg(CS)}},
+	Insn_Retf:     {stackPtr, []implicitReg{gpr(Esp, sizeStack), sreg(CS)}},
+	Insn_Iret:     {stackPtr, []implicitReg{gpr(Esp, sizeStack), sreg(CS), sreg(SS)}},
+	Insn_Int:      {stackPtr, []implicitReg{gpr(Esp, sizeStack), sreg(CS)}},
+	Insn_Int_3:    {stackPtr, []implicitReg{gpr(Esp, sizeStack), sreg(CS)}},
+	Insn_Int1:     {stackPtr, []implicitReg{gpr(Esp, sizeStack), sreg(CS)}},
+	Insn_Into:     {stackPtr, []implicitReg{gpr(Esp, sizeStack), sreg(CS)}},
+
+	Insn_Cbw:  {[]implicitReg{gpr(Eax, OpSizeByte)}, []implicitReg{gpr(Eax, OpSizeWord)}},
+	Insn_Cwde: {[]implicitReg{gpr(Eax, OpSizeWord)}, []implicitReg{gpr(Eax, OpSizeLong)}},
+	Insn_Cdqe: {[]implicitReg{gpr(Eax, OpSizeLong)}, []implicitReg{gpr(Eax, OpSizeQuad)}},
+	Insn_Cwd:  {[]implicitReg{gpr(Eax, OpSizeWord)}, []implicitReg{gpr(Edx, OpSizeWord)}},
+	Insn_Cdq:  {[]implicitReg{gpr(Eax, OpSizeLong)}, []implicitReg{gpr(Edx, OpSizeLong)}},
+	Insn_Cqo:  {[]implicitReg{gpr(Eax, OpSizeQuad)}, []implicitReg{gpr(Edx, OpSizeQuad)}},
+	Insn_Lahf: {nil, []implicitReg{gpr(Ah, OpSizeByte)}},
+	Insn_Sahf: {[]implicitReg{gpr(Ah, OpSizeByte)}, nil},
+	Insn_Aaa:  {[]implicitReg{gpr(Eax, OpSizeWord)}, []implicitReg{gpr(Eax, OpSizeWord)}},
+	Insn_Aas:  {[]implicitReg{gpr(Eax, OpSizeWord)}, []implicitReg{gpr(Eax, OpSizeWord)}},
+	Insn_Daa:  {[]implicitReg{gpr(Al, OpSizeByte)}, []implicitReg{gpr(Al, OpSizeByte)}},
+	Insn_Das:  {[]implicitReg{gpr(Al, OpSizeByte)}, []implicitReg{gpr(Al, OpSizeByte)}},
+	Insn_Aam:  {[]implicitReg{gpr(Al, OpSizeByte)}, []implicitReg{gpr(Eax, OpSizeWord)}},
+	Insn_Aad:  {[]implicitReg{gpr(Eax, OpSizeWord)}, []implicitReg{gpr(Eax, OpSizeWord)}},
+	Insn_Salc: {nil, []implicitReg{gpr(Al, OpSizeByte)}},
+	Insn_Xlat: {nil, []implicitReg{gpr(Al, OpSizeByte)}},
+
+	Insn_Cmpxchg: {[]implicitReg{gpr(Eax, sizeOperand)}, []implicitReg{gpr(Eax, sizeOperand)}},
+	Insn_Cmpxchg8b: {
+		[]implicitReg{gpr(Eax, OpSizeLong), gpr(Edx, OpSizeLong), gpr(Ebx, OpSizeLong), gpr(Ecx, OpSizeLong)},
+		eaxEdx,
+	},
+	Insn_Cmpxchg16b: {
+		[]implicitReg{gpr(Eax, OpSizeQuad), gpr(Edx, OpSizeQuad), gpr(Ebx, OpSizeQuad), gpr(Ecx, OpSizeQuad)},
+		[]implicitReg{gpr(Eax, OpSizeQuad), gpr(Edx, OpSizeQuad)},
+	},
+
+	// Registers of string instructions used for addressing are in the
+	// memory operands, these are the updated index registers
+	Insn_Movs: {nil, []implicitReg{gpr(Esi, sizeAddress), gpr(Edi, sizeAddress)}},
+	Insn_Cmps: {nil, []implicitReg{gpr(Esi, sizeAddress), gpr(Edi, sizeAddress)}},
+	Insn_Lods: {nil, []implicitReg{gpr(Esi, sizeAddress)}},
+	Insn_Outs: {nil, []implicitReg{gpr(Esi, sizeAddress)}},
+	Insn_Stos: {nil, []implicitReg{gpr(Edi, sizeAddress)}},
+	Insn_Scas: {nil, []implicitReg{gpr(Edi, sizeAddress)}},
+	Insn_Ins:  {nil, []implicitReg{gpr(Edi, sizeAddress)}},
+
+	Insn_Loop:   {[]implicitReg{gpr(Ecx, sizeAddress)}, []implicitReg{gpr(Ecx, sizeAddress)}},
+	Insn_Loopz:  {[]implicitReg{gpr(Ecx, sizeAddress)}, []implicitReg{gpr(Ecx, sizeAddress)}},
+	Insn_Loopnz: {[]implicitReg{gpr(Ecx, sizeAddress)}, []implicitReg{gpr(Ecx, sizeAddress)}},
+	Insn_Jcxz:   {[]implicitReg{gpr(Ecx, OpSizeWord)}, nil},
+	Insn_Jecxz:  {[]implicitReg{gpr(Ecx, OpSizeLong)}, nil},
+	Insn_Jrcxz:  {[]implicitReg{gpr(Ecx, OpSizeQuad)}, nil},
+
+	Insn_Lds: {nil, []implicitReg{sreg(DS)}},
+	Insn_Les: {nil, []implicitReg{sreg(ES)}},
+	Insn_Lfs: {nil, []implicitReg{sreg(FS)}},
+	Insn_Lgs: {nil, []implicitReg{sreg(GS)}},
+	Insn_Lss: {nil, []implicitReg{sreg(SS)}},
+
+	Insn_Cpuid: {
+		[]implicitReg{gpr(Eax, OpSizeLong), gpr(Ecx, OpSizeLong)},
+		[]implicitReg{gpr(Eax, OpSizeLong), gpr(Ebx, OpSizeLong), gpr(Ecx, OpSizeLong), gpr(Edx, OpSizeLong)},
+	},
+	Insn_Rdtsc:      {nil, eaxEdx},
+	Insn_Rdtscp:     {nil, []implicitReg{gpr(Eax, OpSizeLong), gpr(Edx, OpSizeLong), gpr(Ecx, OpSizeLong)}},
+	Insn_Rdmsr:      {ecxOnly, eaxEdx},
+	Insn_Rdpmc:      {ecxOnly, eaxEdx},
+	Insn_Wrmsr:      {[]implicitReg{gpr(Ecx, OpSizeLong), gpr(Eax, OpSizeLong), gpr(Edx, OpSizeLong)}, nil},
+	Insn_Xsave:      {eaxEdx, nil},
+	Insn_Xsave64:    {eaxEdx, nil},
+	Insn_Xsaveopt:   {eaxEdx, nil},
+	Insn_Xsaveopt64: {eaxEdx, nil},
+	Insn_Xrstor:     {eaxEdx, nil},
+	Insn_Xrstor64:   {eaxEdx, nil},
+	Insn_Monitor:    {[]implicitReg{gpr(Eax, sizeAddress), gpr(Ecx, OpSizeLong), gpr(Edx, OpSizeLong)}, nil},
+	Insn_Mwait:      {[]implicitReg{gpr(Eax, OpSizeLong), gpr(Ecx, OpSizeLong)}, nil},
+	Insn_Syscall:    {nil, []implicitReg{gpr(Ecx, OpSizeQuad), gpr(11, OpSizeQuad), sreg(CS), sreg(SS)}},
+	Insn_Sysret:     {[]implicitReg{gpr(Ecx, OpSizeQuad), gpr(11, OpSizeQuad)}, []implicitReg{sreg(CS), sreg(SS)}},
+	Insn_Sysenter:   {nil, []implicitReg{gpr(Esp, sizeStack), sreg(CS), sreg(SS)}},
+	Insn_Sysexit:    {[]implicitReg{gpr(Ecx, sizeStack), gpr(Edx, sizeStack)}, []implicitReg{gpr(Esp, sizeStack), sreg(CS), sreg(SS)}},
+
+	Insn_Maskmovq:    {[]implicitReg{gpr(Edi, sizeAddress)}, nil},
+	Insn_Maskmovdqu:  {[]implicitReg{gpr(Edi, sizeAddress)}, nil},
+	Insn_Vmaskmovdqu: {[]implicitReg{gpr(Edi, sizeAddress)}, nil},
+	Insn_Pcmpestri:   {eaxEdx, ecxOnly},
+	Insn_Vpcmpestri:  {eaxEdx, ecxOnly},
+	Insn_Pcmpistri:   {nil, ecxOnly},
+	Insn_Vpcmpistri:  {nil, ecxOnly},
+	Insn_Pcmpestrm:   {eaxEdx, []implicitReg{{RegVec, 0, OpSizeNone}}},
+	Insn_Vpcmpestrm:  {eaxEdx, []implicitReg{{RegVec, 0, OpSizeNone}}},
+	Insn_Pcmpistrm:   {nil, []implicitReg{{RegVec, 0, OpSizeNone}}},
+	Insn_Vpcmpistrm:  {nil, []implicitReg{{RegVec, 0, OpSizeNone}}},
+
+	// x87 instructions with memory operand use st(0) implicitly
+	Insn_Fadd:    {st0, st0},
+	Insn_Fmul:    {st0, st0},
+	Insn_Fsub:    {st0, st0},
+	Insn_Fsubr:   {st0, st0},
+	Insn_Fdiv:    {st0, st0},
+	Insn_Fdivr:   {st0, st0},
+	Insn_Fiadd:   {st0, st0},
+	Insn_Fimul:   {st0, st0},
+	Insn_Fisub:   {st0, st0},
+	Insn_Fisubr:  {st0, st0},
+	Insn_Fidiv:   {st0, st0},
+	Insn_Fidivr:  {st0, st0},
+	Insn_Fcom:    {st0, nil},
+	Insn_Fcomp:   {st0, nil},
+	Insn_Ficom:   {st0, nil},
+	Insn_Ficomp:  {st0, nil},
+	Insn_Fucom:   {st0, nil},
+	Insn_Fucomp:  {st0, nil},
+	Insn_Fld:     {nil, st0},
+	Insn_Fild:    {nil, st0},
+	Insn_Fbld:    {nil, st0},
+	Insn_Fst:     {st0, nil},
+	Insn_Fstp:    {st0, nil},
+	Insn_Fist:    {st0, nil},
+	Insn_Fistp:   {st0, nil},
+	Insn_Fisttp:  {st0, nil},
+	Insn_Fbstp:   {st0, nil},
+	Insn_Fxch:    {st0, st0},
+	Insn_Fchs:    {st0, st0},
+	Insn_Fabs:    {st0, st0},
+	Insn_Fsqrt:   {st0, st0},
+	Insn_Frndint: {st0, st0},
+	Insn_Ftst:    {st0, nil},
+	Insn_Fxam:    {st0, nil},
+	Insn_Fld1:    {nil, st0},
+	Insn_Fldz:    {nil, st0},
+	Insn_Fldpi:   {nil, st0},
+	Insn_Fldl2t:  {nil, st0},
+	Insn_Fldl2e:  {nil, st0},
+	Insn_Fldlg2:  {nil, st0},
+	Insn_Fldln2:  {nil, st0},
+}
+
+// Implicit registers of the instruction.
+func (insn *Instruction) implicitRegsOf() implicitRegs {
+	switch insn.OpId {
+	case Insn_Mul, Insn_Imul, Insn_Div, Insn_Idiv:
+		// imul with more than one operand has no implicit operand
+		if len(insn.Operands) != 1 {
+			break
+		}
+		size := insn.operandSize(0)
+		if size == OpSizeByte {
+			if insn.OpId == Insn_Mul || insn.OpId == Insn_Imul {
+				return implicitRegs{[]implicitReg{gpr(Al, OpSizeByte)}, []implicitReg{gpr(Eax, OpSizeWord)}}
+			}
+			return implicitRegs{[]implicitReg{gpr(Eax, OpSizeWord)}, []implicitReg{gpr(Eax, OpSizeWord)}}
+		}
+		pair := []implicitReg{gpr(Eax, size), gpr(Edx, size)}
+		if insn.OpId == Insn_Mul || insn.OpId == Insn_Imul {
+			return implicitRegs{pair[:1], pair}
+		}
+		return implicitRegs{pair, pair}
+	}
+	return implicitRegsOfInsn[insn.OpId]
+}
+
+// Size of the i-th operand, which must be a register or memory operand.
+func (insn *Instruction) operandSize(i int) byte {
+	switch op := insn.Operands[i].(type) {
+	case Reg:
+		return sizeOfBytes(op.Size)
+	case Mem:
+		return sizeOfBytes(op.Size)
+	}
+	return insn.EffectiveOperandSize()
+}
+
+func (insn *Instruction) implicitReg(ir implicitReg) Reg {
+	size := ir.size
+	switch size {
+	case OpSizeFull:
+		size = insn.EffectiveOperandSize()
+	case sizeAddress:
+		size = insn.EffectiveAddressSize()
+	case sizeStack:
+		size = insn.Mode.defaultAddressSize()
+	case sizeOperand:
+		size = insn.operandSize(0)
+	}
+	r := Reg{Class: ir.class, Num: ir.num}
+	switch ir.class {
+	case RegGP:
+		r.Size = sizeInBytes[size]
+		if size == OpSizeByte && ir.num >= Ah && ir.num <= Bh {
+			r.Num -= Ah
+			r.High = true
+		}
+	case RegSeg:
+		r.Size = 2
+	case RegFPU:
+		r.Size = 10
+	case RegVec:
+		r.Size = 16
+	}
+	return r
+}
+
+// Instructions which write the first operand without reading it. Other
+// instructions with IFLAG_DST_WR both read and write it.
+var dstWriteOnly = map[uint16]bool{
+	Insn_Mov: true, Insn_Movzx: true, Insn_Movsx: true, Insn_Movsxd: true,
+	Insn_Movbe: true, Insn_Movs: true, Insn_Lea: true, Insn_Pop: true,
+	Insn_Lds: true, Insn_Les: true, Insn_Lfs: true, Insn_Lgs: true, Insn_Lss: true,
+	Insn_Sldt: true, Insn_Str: true, Insn_Sgdt: true, Insn_Sidt: true, Insn_Smsw: true,
+	Insn_Lzcnt: true, Insn_Popcnt: true, Insn_In: true, Insn_Ins: true,
+	Insn_Stos: true, Insn_Lods: true,
+	Insn_Stmxcsr: true, Insn_Vstmxcsr: true, Insn_Fxsave: true, Insn_Fxsave64: true,
+	Insn_Xsave: true, Insn_Xsave64: true, Insn_Xsaveopt: true, Insn_Xsaveopt64: true,
+	Insn_Fst: true, Insn_Fstp: true, Insn_Fist: true, Insn_Fistp: true, Insn_Fisttp: true,
+	Insn_Fbstp: true, Insn_Fstsw: true, Insn_Fnstsw: true, Insn_Fstcw: true, Insn_Fnstcw: true,
+	Insn_Fstenv: true, Insn_Fnstenv: true, Insn_Fsave: true, Insn_Fnsave: true,
+
+	// Legacy SSE instructions which don't merge the result into the
+	// destination. With VEX or EVEX encoding, the destination is always
+	// write only except for FMA instructions.
+	Insn_Movd: true, Insn_Movq: true, Insn_Movups: true, Insn_Movaps: true,
+	Insn_Movupd: true, Insn_Movapd: true, Insn_Movdqa: true, Insn_Movdqu: true,
+	Insn_Movntps: true, Insn_Movntpd: true, Insn_Movntdq: true, Insn_Movntq: true,
+	Insn_Movnti: true, Insn_Movntdqa: true, Insn_Movddup: true, Insn_Movshdup: true,
+	Insn_Movsldup: true, Insn_Movdq2q: true, Insn_Movq2dq: true, Insn_Lddqu: true,
+	Insn_Movmskps: true, Insn_Movmskpd: true, Insn_Pmovmskb: true,
+	Insn_Pextrb: true, Insn_Pextrw: true, Insn_Pextrd: true, Insn_Pextrq: true,
+	Insn_Extractps: true, Insn_Pshufw: true, Insn_Pshufd: true, Insn_Pshufhw: true,
+	Insn_Pshuflw: true, Insn_Sqrtps: true, Insn_Sqrtpd: true, Insn_Rcpps: true,
+	Insn_Rsqrtps: true, Insn_Roundps: true, Insn_Roundpd: true, Insn_Phminposuw: true,
+	Insn_Pabsb: true, Insn_Pabsw: true, Insn_Pabsd: true, Insn_Aesimc: true,
+	Insn_Aeskeygenassist: true,
+	Insn_Cvtps2pd:        true, Insn_Cvtdq2ps: true, Insn_Cvttps2pi: true, Insn_Cvtps2pi: true,
+	Insn_Cvttss2si: true, Insn_Cvtss2si: true, Insn_Cvtpi2pd: true, Insn_Cvttpd2pi: true,
+	Insn_Cvtpd2pi: true, Insn_Cvtpd2ps: true, Insn_Cvtps2dq: true, Insn_Cvttpd2dq: true,
+	Insn_Cvttsd2si: true, Insn_Cvtsd2si: true, Insn_Cvtpd2dq: true, Insn_Cvttps2dq: true,
+	Insn_Cvtdq2pd: true,
+	Insn_Pmovsxbw: true, Insn_Pmovsxbd: true, Insn_Pmovsxbq: true, Insn_Pmovsxwd: true,
+	Insn_Pmovsxwq: true, Insn_Pmovsxdq: true, Insn_Pmovzxbw: true, Insn_Pmovzxbd: true,
+	Insn_Pmovzxbq: true, Insn_Pmovzxwd: true, Insn_Pmovzxwq: true, Insn_Pmovzxdq: true,
+}
+
+func init() {
+	for _, opid := range conditionalInsns[1] {
+		dstWriteOnly[opid] = true
+	}
+}
+
+// Memory operand of these instructions is not accessed.
+var memNotAccessed = map[uint16]bool{
+	Insn_Lea:         true,
+	Insn_Nop:         true,
+	Insn_Prefetchnta: true,
+	Insn_Prefetcht0:  true,
+	Insn_Prefetcht1:  true,
+	Insn_Prefetcht2:  true,
+	Insn_Invlpg:      true,
+}
+
+// Whether the destination is written without being read.
+func (insn *Instruction) isDstWriteOnly() bool {
+	switch {
+	case insn.Vex != 0 || insn.Evex != 0:
+		// FMA instructions, vpternlog, vpermi2 and vpermt2 use the
+		// destination as one source
+		name := InsnName[insn.OpId]
+		for _, prefix := range []string{"vfm", "vfnm", "vpternlog", "vpermi2", "vpermt2"} {
+			if strings.HasPrefix(name, prefix) {
+				return false
+			}
+		}
+		return true
+	case insn.OpId == Insn_Imul:
+		// Three operand form
+		return len(insn.Operands) == 3
+	case insn.OpId == Insn_Movss || insn.OpId == Insn_Movsd:
+		// Register to register move merges into the destination
+		if _, ok := insn.Operands[1].(Reg); ok {
+			return false
+		}
+		return true
+	case insn.OpId == Insn_Movlps || insn.OpId == Insn_Movhps ||
+		insn.OpId == Insn_Movlpd || insn.OpId == Insn_Movhpd:
+		// Store to memory
+		_, ok := insn.Operands[0].(Mem)
+		return ok
+	}
+	return dstWriteOnly[insn.OpId]
+}
+
+// OperandAccess returns how the i-th operand in Operands is accessed. For
+// memory operands, it's about the memory, registers used for addressing are
+// always read. Immediate operands are read.
+func (insn *Instruction) OperandAccess(i int) Access {
+	if i < 0 || i >= len(insn.Operands) {
+		return 0
+	}
+	if _, ok := insn.Operands[i].(Mem); ok && memNotAccessed[insn.OpId] {
+		return 0
+	}
+	switch i {
+	case 0:
+		if insn.Info.Flag&IFLAG_DST_WR != 0 {
+			if insn.isDstWriteOnly() {
+				return AccessWrite
+			}
+			return AccessReadWrite
+		}
+	case 1:
+		switch insn.OpId {
+		case Insn_Xchg, Insn_Xadd:
+			return AccessReadWrite
+		}
+	}
+	return AccessRead
+}
+
+// RegsRead returns the registers read by the instruction, including implicit
+// ones and those used for addressing memory. Segment registers are only
+// included if they are explicit operands or loaded by the instruction.
+func (insn *Instruction) RegsRead() []Reg {
+	read, _ := insn.regAccess()
+	return read
+}
+
+// RegsWritten returns the registers written by the instruction, including
+// implicit ones.
+func (insn *Instruction) RegsWritten() []Reg {
+	_, written := insn.regAccess()
+	return written
+}
+
+func appendReg(regs []Reg, r Reg) []Reg {
+	for _, reg := range regs {
+		if reg == r {
+			return regs
+		}
+	}
+	return append(regs, r)
+}
+
+func (insn *Instruction) regAccess() (read, written []Reg) {
+	for i, op := range insn.Operands {
+		switch op := op.(type) {
+		case Reg:
+			access := insn.OperandAccess(i)
+			if access&AccessRead != 0 {
+				read = appendReg(read, op)
+			}
+			if access&AccessWrite != 0 {
+				written = appendReg(written, op)
+			}
+		case Mem:
+			for _, r := range []Reg{op.Base, op.Index} {
+				if r.Class != 0 && r.Class != RegIP {
+					read = appendReg(read, r)
+				}
+			}
+		}
+	}
+	if insn.Opmask != 0 {
+		read = appendReg(read, Reg{Class: RegMask, Num: insn.Opmask, Size: 8})
+	}
+
+	implicit := insn.implicitRegsOf()
+	for _, ir := range implicit.read {
+		read = appendReg(read, insn.implicitReg(ir))
+	}
+	for _, ir := range implicit.written {
+		written = appendReg(written, insn.implicitReg(ir))
+	}
+	// Repeated string instructions count down ecx
+	if insn.Prefix&(PrefixREPZ|PrefixREPNZ) != 0 && isStringInsn(insn.OpId) {
+		ecx := insn.implicitReg(gpr(Ecx, sizeAddress))
+		read = appendReg(read, ecx)
+		written = appendReg(written, ecx)
+	}
+	return
+}
+
+func isStringInsn(opid uint16) bool {
+	switch opid {
+	case Insn_Movs, Insn_Cmps, Insn_Lods, Insn_Stos, Insn_Scas, Insn_Ins, Insn_Outs:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+package dis
+
+import (
+	"strings"
+	"testing"
+)
+
+func regNames(regs []Reg) string {
+	names := make([]string, len(regs))
+	for i, r := range regs {
+		names[i] = r.String()
+	}
+	return strings.Join(names, ",")
+}
+
+func TestRegAccess(t *testing.T) {
+	testdata := []struct {
+		code    []byte
+		mode    Mode
+		read    string
+		written string
+	}{
+		{[]byte{0x01, 0xc8}, Mode32, "eax,ecx", "eax"},                                       // add %ecx,%eax
+		{[]byte{0x89, 0xc8}, Mode32, "ecx", "eax"},                                           // mov %ecx,%eax
+		{[]byte{0x39, 0xc8}, Mode32, "eax,ecx", ""},                                          // cmp %ecx,%eax
+		{[]byte{0x8b, 0x44, 0x8b, 0x04}, Mode32, "ebx,ecx", "eax"},                           // mov 0x4(%ebx,%ecx,4),%eax
+		{[]byte{0x01, 0x03}, Mode32, "ebx,eax", ""},                                          // add %eax,(%ebx)
+		{[]byte{0x8d, 0x04, 0x8b}, Mode32, "ebx,ecx", "eax"},                                 // lea (%ebx,%ecx,4),%eax
+		{[]byte{0x87, 0xd8}, Mode32, "eax,ebx", "eax,ebx"},                                   // xchg %ebx,%eax
+		{[]byte{0x0f, 0x44, 0xc1}, Mode32, "eax,ecx", "eax"},                                 // cmove %ecx,%eax
+		{[]byte{0x0f, 0x94, 0xc4}, Mode32, "", "ah"},                                         // sete %ah
+		{[]byte{0x6b, 0xc1, 0x10}, Mode32, "ecx", "eax"},                                     // imul $0x10,%ecx,%eax
+		{[]byte{0xf7, 0xe3}, Mode32, "ebx,eax", "eax,edx"},                                   // mul %ebx
+		{[]byte{0xf6, 0xe3}, Mode32, "bl,al", "ax"},                                          // mul %bl
+		{[]byte{0xf7, 0xf1}, Mode32, "ecx,eax,edx", "eax,edx"},                               // div %ecx
+		{[]byte{0x50}, Mode32, "eax,esp", "esp"},                                             // push %eax
+		{[]byte{0x58}, Mode64, "rsp", "rax,rsp"},                                             // pop %rax
+		{[]byte{0xc9}, Mode32, "ebp", "esp,ebp"},                                             // leave
+		{[]byte{0x99}, Mode32, "eax", "edx"},                                                 // cltd
+		{[]byte{0x48, 0x98}, Mode64, "eax", "rax"},                                           // cltq
+		{[]byte{0x0f, 0xa2}, Mode32, "eax,ecx", "eax,ebx,ecx,edx"},                           // cpuid
+		{[]byte{0xf3, 0xa4}, Mode32, "edi,esi,ecx", "esi,edi,ecx"},                           // rep movsb
+		{[]byte{0xaa}, Mode32, "edi,al", "edi"},                                              // stos %al,%es:(%edi)
+		{[]byte{0xd7}, Mode32, "ebx,al", "al"},                                               // xlat
+		{[]byte{0xe2, 0xfe}, Mode32, "ecx", "ecx"},                                           // loop
+		{[]byte{0x0f, 0xb1, 0x0b}, Mode32, "ebx,ecx,eax", "eax"},                             // cmpxchg %ecx,(%ebx)
+		{[]byte{0x0f, 0xb0, 0xd1}, Mode32, "cl,dl,al", "cl,al"},                              // cmpxchg %dl,%cl
+		{[]byte{0x9f}, Mode32, "", "ah"},                                                     // lahf
+		{[]byte{0xc5, 0xf0, 0x5e, 0x00}, Mode32, "xmm1,eax", "xmm0"},                         // vdivps (%eax),%xmm1,%xmm0
+		{[]byte{0x0f, 0x58, 0xc1}, Mode32, "xmm0,xmm1", "xmm0"},                              // addps %xmm1,%xmm0
+		{[]byte{0x0f, 0x28, 0xc1}, Mode32, "xmm1", "xmm0"},                                   // movaps %xmm1,%xmm0
+		{[]byte{0xf3, 0x0f, 0x10, 0xc1}, Mode32, "xmm0,xmm1", "xmm0"},                        // movss %xmm1,%xmm0
+		{[]byte{0xc5, 0xf0, 0x58, 0xc2}, Mode32, "xmm1,xmm2", "xmm0"},                        // vaddps %xmm2,%xmm1,%xmm0
+		{[]byte{0xc4, 0xe2, 0x71, 0xa8, 0xc2}, Mode32, "xmm0,xmm1,xmm2", "xmm0"},             // vfmadd213ps
+		{[]byte{0x62, 0xf1, 0x74, 0x49, 0x58, 0xc2}, Mode64, "zmm1,zmm2,k1", "zmm0"},         // vaddps %zmm2,%zmm1,%zmm0{%k1}
+		{[]byte{0x62, 0xf1, 0x7d, 0x4a, 0x76, 0xc1}, Mode64, "zmm0,zmm1,k2", "k0"},           // vpcmpeqd %zmm1,%zmm0,%k0{%k2}
+		{[]byte{0x62, 0xf3, 0x75, 0x48, 0x25, 0xc2, 0x96}, Mode64, "zmm0,zmm1,zmm2", "zmm0"}, // vpternlogd
+		{[]byte{0xc5, 0xec, 0x41, 0xcb}, Mode64, "k2,k3", "k1"},                              // kandw %k3,%k2,%k1
+		{[]byte{0xc5, 0xf8, 0x98, 0xca}, Mode64, "k1,k2", ""},                                // kortestw %k2,%k1
+		{[]byte{0x0f, 0x2e, 0xc1}, Mode32, "xmm0,xmm1", ""},                                  // ucomiss %xmm1,%xmm0
+		{[]byte{0x66, 0x0f, 0x3a, 0x61, 0xc1, 0x00}, Mode32, "xmm0,xmm1,eax,edx", "ecx"},     // pcmpestri
+		{[]byte{0xd8, 0x00}, Mode32, "eax,st(0)", "st(0)"},                                   // fadds (%eax)
+		{[]byte{0xd9, 0x18}, Mode32, "eax,st(0)", ""},                                        // fstps (%eax)
+		{[]byte{0xd9, 0xc1}, Mode32, "st(1)", "st(0)"},                                       // fld %st(1)
+		{[]byte{0xd8, 0xc1}, Mode32, "st(0),st(1)", "st(0)"},                                 // fadd %st(1),%st
+		{[]byte{0x8e, 0xd8}, Mode32, "eax", "ds"},                                            // mov %eax,%ds
+		{[]byte{0xc5, 0x00}, Mode32, "eax", "eax,ds"},                                        // lds (%eax),%eax
+		{[]byte{0x48, 0x8b, 0x05, 0x00, 0x00, 0x00, 0x00}, Mode64, "", "rax"},                // mov 0x0(%rip),%rax
+		{[]byte{0x0f, 0x05}, Mode64, "", "rcx,r11,cs,ss"},                                    // syscall
+	}
+	for _, td := range testdata {
+		insn, err := Decode(td.code, td.mode)
+		if err != nil {
+			t.Errorf("% x: %v", td.code, err)
+			continue
+		}
+		if read := regNames(insn.RegsRead()); read != td.read {
+			t.Errorf("% x %s: read %s, should be %s", td.code, insn.Format(IntelSyntax{}), read, td.read)
+		}
+		if written := regNames(insn.RegsWritten()); written != td.written {
+			t.Errorf("% x %s: written %s, should be %s", td.code, insn.Format(IntelSyntax{}), written, td.written)
+		}
+	}
+}
+
+func TestOperandAccess(t *testing.T) {
+	testdata := []struct {
+		code   []byte
+		access []Access
+	}{
+		{[]byte{0x01, 0x03}, []Access{AccessReadWrite, AccessRead}},            // add %eax,(%ebx)
+		{[]byte{0x89, 0x03}, []Access{AccessWrite, AccessRead}},                // mov %eax,(%ebx)
+		{[]byte{0x3b, 0x03}, []Access{AccessRead, AccessRead}},                 // cmp (%ebx),%eax
+		{[]byte{0x0f, 0xc1, 0x03}, []Access{AccessReadWrite, AccessReadWrite}}, // xadd %eax,(%ebx)
+		{[]byte{0x8d, 0x03}, []Access{AccessWrite, 0}},                         // lea (%ebx),%eax
+		{[]byte{0xc1, 0xe0, 0x04}, []Access{AccessReadWrite, AccessRead}},      // shl $0x4,%eax
+		{[]byte{0xff, 0x33}, []Access{AccessRead}},                             // push (%ebx)
+		{[]byte{0x8f, 0x03}, []Access{AccessWrite}},                            // pop (%ebx)
+		{[]byte{0x0f, 0x11, 0x03}, []Access{AccessWrite, AccessRead}},          // movups %xmm0,(%ebx)
+		{[]byte{0x0f, 0x13, 0x03}, []Access{AccessWrite, AccessRead}},          // movlps %xmm0,(%ebx)
+		{[]byte{0x0f, 0x12, 0x03}, []Access{AccessReadWrite, AccessRead}},      // movlps (%ebx),%xmm0
+		{[]byte{0x0f, 0x18, 0x03}, []Access{0}},                                // prefetchnta (%ebx)
+		{[]byte{0xdd, 0x1b}, []Access{AccessWrite}},                            // fstpl (%ebx)
+		{[]byte{0xdd, 0x03}, []Access{AccessRead}},                             // fldl (%ebx)
+	}
+	for _, td := range testdata {
+		insn, err := Decode(td.code, Mode32)
+		if err != nil {
+			t.Errorf("% x: %v", td.code, err)
+			continue
+		}
+		if len(insn.Operands) != len(td.access) {
+			t.Errorf("% x: %d operands, should be %d", td.code, len(insn.Operands), len(td.access))
+			continue
+		}
+		for i, access := range td.access {
+			if a := insn.OperandAccess(i); a != access {
+				t.Errorf("% x %s: operand %d access %d, should be %d",
+					td.code, insn.Format(IntelSyntax{}), i, a, access)
+			}
+		}
+	}
+}
+
+func TestFlagEffect(t *testing.T) {
+	testdata := []struct {
+		code []byte
+		fe   FlagEffect
+	}{
+		{[]byte{0x01, 0xc8}, FlagEffect{Modified: FlagsStatus}},                                                   // add
+		{[]byte{0x11, 0xc8}, FlagEffect{Tested: FlagCF, Modified: FlagsStatus}},                                   // adc
+		{[]byte{0x40}, FlagEffect{Modified: FlagsStatus &^ FlagCF}},                                               // inc
+		{[]byte{0x21, 0xc8}, FlagEffect{Modified: FlagsStatus &^ FlagAF, Undefined: FlagAF}},                      // and
+		{[]byte{0xf7, 0xe3}, FlagEffect{Modified: FlagCF | FlagOF, Undefined: FlagPF | FlagAF | FlagZF | FlagSF}}, // mul
+		{[]byte{0x74, 0x00}, FlagEffect{Tested: FlagZF}},                                                          // je
+		{[]byte{0x0f, 0x4f, 0xc1}, FlagEffect{Tested: FlagZF | FlagSF | FlagOF}},                                  // cmovg
+		{[]byte{0x0f, 0x92, 0xc0}, FlagEffect{Tested: FlagCF}},                                                    // setb
+		{[]byte{0xfc}, FlagEffect{Modified: FlagDF}},                                                              // cld
+		{[]byte{0xa4}, FlagEffect{Tested: FlagDF}},                                                                // movsb
+		{[]byte{0xf3, 0xa6}, FlagEffect{Tested: FlagDF | FlagZF, Modified: FlagsStatus}},                          // repz cmpsb
+		{[]byte{0x9c}, FlagEffect{Tested: FlagsAll}},                                                              // pushf
+		{[]byte{0x0f, 0x2f, 0xc1}, FlagEffect{Modified: FlagsStatus}},                                             // comiss
+		{[]byte{0xc5, 0xf8, 0x98, 0xca}, FlagEffect{Modified: FlagsStatus}},                                       // kortestw
+		{[]byte{0x89, 0xc8}, FlagEffect{}},                                                                        // mov
+	}
+	for _, td := range testdata {
+		insn, err := Decode(td.code, Mode32)
+		if err != nil {
+			t.Errorf("% x: %v", td.code, err)
+			continue
+		}
+		if fe := insn.FlagEffect(); fe != td.fe {
+			t.Errorf("% x %s: got %+v, should be %+v", td.code, insn.Format(IntelSyntax{}), fe, td.fe)
+		}
+	}
+}
+
+func TestCondition(t *testing.T) {
+	testdata := []struct {
+		code []byte
+		cc   byte
+		ok   bool
+	}{
+		{[]byte{0x70, 0x00}, 0, true},                         // jo
+		{[]byte{0x0f, 0x84, 0x00, 0x00, 0x00, 0x00}, 4, true}, // jz
+		{[]byte{0x0f, 0x9f, 0xc0}, 0xf, true},                 // setg
+		{[]byte{0x0f, 0x42, 0xc1}, 2, true},                   // cmovb
+		{[]byte{0xe3, 0x00}, 0, false},                        // jecxz
+		{[]byte{0xeb, 0x00}, 0, false},                        // jmp
+	}
+	for _, td := range testdata {
+		insn, err := Decode(td.code, Mode32)
+		if err != nil {
+			t.Errorf("% x: %v", td.code, err)
+			continue
+		}
+		if cc, ok := insn.Condition(); cc != td.cc || ok != td.ok {
+			t.Errorf("% x %s: condition %d %v, should be %d %v",
+				td.code, insn.Format(IntelSyntax{}), cc, ok, td.cc, td.ok)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package dis
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEvex(t *testing.T) {
+	testdata := []codeText{
+		{[]byte{0x62, 0xf1, 0x6c, 0xc9, 0x58, 0xd9}, "vaddps %zmm1,%zmm2,%zmm3{%k1}{z}"},
+		{[]byte{0x62, 0xf1, 0x74, 0x58, 0x58, 0x10}, "vaddps (%eax){1to16},%zmm1,%zmm2"},
+		{[]byte{0x62, 0xf1, 0xf5, 0x5a, 0x58, 0x10}, "vaddpd (%eax){1to8},%zmm1,%zmm2{%k2}"},
+		{[]byte{0x62, 0xf1, 0x6c, 0x18, 0x58, 0xd9}, "vaddps {rn-sae},%zmm1,%zmm2,%zmm3"},
+		{[]byte{0x62, 0xf1, 0x6c, 0x38, 0x58, 0xd9}, "vaddps {rd-sae},%zmm1,%zmm2,%zmm3"},
+		{[]byte{0x62, 0xf1, 0x6c, 0x18, 0x5f, 0xd9}, "vmaxps {sae},%zmm1,%zmm2,%zmm3"},
+		{[]byte{0x62, 0xf1, 0x6c, 0x0b, 0x58, 0xd9}, "vaddps %xmm1,%xmm2,%xmm3{%k3}"},
+		{[]byte{0x62, 0xf1, 0x74, 0x48, 0x58, 0x50, 0x01}, "vaddps 0x40(%eax),%zmm1,%zmm2"},
+		{[]byte{0x62, 0xf1, 0x74, 0x48, 0x58, 0x90, 0x44, 0x00, 0x00, 0x00}, "vaddps 0x44(%eax),%zmm1,%zmm2"},
+		{[]byte{0x62, 0xf1, 0x74, 0x58, 0x58, 0x50, 0x02}, "vaddps 0x8(%eax){1to16},%zmm1,%zmm2"},
+		{[]byte{0x62, 0xf1, 0x6e, 0x78, 0x58, 0xd9}, "vaddss {rz-sae},%xmm1,%xmm2,%xmm3"},
+		{[]byte{0x62, 0xf1, 0x76, 0x09, 0x58, 0x50, 0x02}, "vaddss 0x8(%eax),%xmm1,%xmm2{%k1}"},
+		{[]byte{0x62, 0xf1, 0x7c, 0x48, 0x51, 0xd1}, "vsqrtps %zmm1,%zmm2"},
+		{[]byte{0x62, 0xf1, 0xfd, 0x58, 0x51, 0xd1}, "vsqrtpd {ru-sae},%zmm1,%zmm2"},
+		{[]byte{0x62, 0xf1, 0x7c, 0x49, 0x29, 0x40, 0x01}, "vmovaps %zmm0,0x40(%eax){%k1}"},
+		{[]byte{0x62, 0xf1, 0x7c, 0xc9, 0x28, 0x40, 0x01}, "vmovaps 0x40(%eax),%zmm0{%k1}{z}"},
+		{[]byte{0x62, 0xf1, 0xfd, 0x48, 0x10, 0xc8}, "vmovupd %zmm0,%zmm1"},
+		{[]byte{0x62, 0xf1, 0x7d, 0x48, 0x6f, 0xc8}, "vmovdqa32 %zmm0,%zmm1"},
+		{[]byte{0x62, 0xf1, 0xfd, 0x48, 0x6f, 0x08}, "vmovdqa64 (%eax),%zmm1"},
+		{[]byte{0x62, 0xf1, 0x7e, 0x48, 0x7f, 0x44, 0x24, 0x02}, "vmovdqu32 %zmm0,0x80(%esp)"},
+		{[]byte{0x62, 0xf1, 0xfe, 0xa9, 0x6f, 0xc8}, "vmovdqu64 %ymm0,%ymm1{%k1}{z}"},
+		{[]byte{0x62, 0xf1, 0x75, 0x58, 0xfe, 0x10}, "vpaddd (%eax){1to16},%zmm1,%zmm2"},
+		{[]byte{0x62, 0xf1, 0xf5, 0x58, 0xd4, 0x50, 0x08}, "vpaddq 0x40(%eax){1to8},%zmm1,%zmm2"},
+		{[]byte{0x62, 0xf1, 0x6d, 0x48, 0xfc, 0xd9}, "vpaddb %zmm1,%zmm2,%zmm3"},
+		{[]byte{0x62, 0xf1, 0x75, 0x48, 0xf9, 0x50, 0x01}, "vpsubw 0x40(%eax),%zmm1,%zmm2"},
+		{[]byte{0x62, 0xf1, 0x6d, 0x48, 0xef, 0xd9}, "vpxord %zmm1,%zmm2,%zmm3"},
+		{[]byte{0x62, 0xf1, 0xf5, 0x38, 0xef, 0x10}, "vpxorq (%eax){1to4},%ymm1,%ymm2"},
+		{[]byte{0x62, 0xf1, 0x6d, 0x08, 0xdf, 0xd9}, "vpandnd %xmm1,%xmm2,%xmm3"},
+		{[]byte{0x62, 0xf2, 0x6d, 0x48, 0x98, 0xd9}, "vfmadd132ps %zmm1,%zmm2,%zmm3"},
+		{[]byte{0x62, 0xf2, 0xf5, 0x59, 0xb8, 0x10}, "vfmadd231pd (%eax){1to8},%zmm1,%zmm2{%k1}"},
+		{[]byte{0x62, 0xf2, 0x6d, 0x18, 0xa9, 0xd9}, "vfmadd213ss {rn-sae},%xmm1,%xmm2,%xmm3"},
+		{[]byte{0x62, 0xf2, 0x7d, 0x48, 0x18, 0xd1}, "vbroadcastss %xmm1,%zmm2"},
+		{[]byte{0x62, 0xf2, 0x7d, 0x49, 0x18, 0x50, 0x01}, "vbroadcastss 0x4(%eax),%zmm2{%k1}"},
+	}
+	testDump(testdata, t)
+
+	testdata = []codeText{
+		{[]byte{0x62, 0xa1, 0x6c, 0x40, 0x58, 0xd9}, "vaddps %zmm17,%zmm18,%zmm19"},
+		{[]byte{0x62, 0x61, 0x0c, 0x47, 0x58, 0xf9}, "vaddps %zmm1,%zmm30,%zmm31{%k7}"},
+		{[]byte{0x62, 0x61, 0x0c, 0x50, 0x58, 0x38}, "vaddps (%rax){1to16},%zmm30,%zmm31"},
+		{[]byte{0x62, 0x41, 0xbd, 0x48, 0x58, 0x4c, 0x24, 0x01}, "vaddpd 0x40(%r12),%zmm8,%zmm25"},
+		{[]byte{0x62, 0xf1, 0x74, 0x48, 0x58, 0x15, 0xc0, 0xff, 0xff, 0xff}, "vaddps -0x40(%rip),%zmm1,%zmm2"},
+		{[]byte{0x62, 0x21, 0xfe, 0x48, 0x7f, 0x04, 0xec}, "vmovdqu64 %zmm24,(%rsp,%r13,8)"},
+		{[]byte{0x62, 0xa1, 0xfd, 0x40, 0xef, 0xc0}, "vpxorq %zmm16,%zmm16,%zmm16"},
+		{[]byte{0x62, 0xa1, 0x56, 0x00, 0x58, 0xf4}, "vaddss %xmm20,%xmm21,%xmm22"},
+		{[]byte{0x62, 0x72, 0xfd, 0x48, 0x19, 0x4d, 0xff}, "vbroadcastsd -0x8(%rbp),%zmm9"},
+		{[]byte{0x62, 0xc1, 0x6e, 0xa2, 0x58, 0x4b, 0x13}, "vaddss 0x4c(%r11),%xmm18,%xmm17{%k2}{z}"},
+		{[]byte{0x62, 0xf1, 0xff, 0x08, 0x58, 0x48, 0x13}, "vaddsd 0x98(%rax),%xmm0,%xmm1"},
+	}
+	testDumpMode(testdata, Mode64, t)
+
+	insn, err := Decode([]byte{0x62, 0xf1, 0x7c, 0xc9, 0x28, 0x40, 0x01}, Mode32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if insn.Evex != 0x62 || insn.Opmask != 1 || !insn.Zeroing || insn.EvexB || insn.VexL != 2 || insn.Disp != 0x40 {
+		t.Errorf("EVEX fields: evex %#x opmask %d z %v b %v L %d disp %#x",
+			insn.Evex, insn.Opmask, insn.Zeroing, insn.EvexB, insn.VexL, insn.Disp)
+	}
+	insn, err = Decode([]byte{0x62, 0xf1, 0x6c, 0x78, 0x58, 0xd9}, Mode32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !insn.EvexB || insn.Rounding != 3 || insn.VexL != 2 {
+		t.Errorf("EVEX rounding: b %v rounding %d L %d", insn.EvexB, insn.Rounding, insn.VexL)
+	}
+
+	// 62 is bound if not followed by register ModR/M
+	insn, err = Decode([]byte{0x62, 0x10}, Mode32)
+	if err != nil || insn.OpId != Insn_Bound || insn.Evex != 0 {
+		t.Errorf("62 10 should be bound, got opid %#x err %v", insn.OpId, err)
+	}
+
+	invalid := [][]byte{
+		{0xf3, 0x62, 0xf1, 0x6c, 0x48, 0x58, 0xd9}, // F3 before EVEX
+		{0x62, 0xf0, 0x6c, 0x48, 0x58, 0xd9},       // Reserved opcode map
+		{0x62, 0xfd, 0x6c, 0x48, 0x58, 0xd9},       // Reserved bits in P0
+		{0x62, 0xf1, 0x68, 0x48, 0x58, 0xd9},       // Fixed bit in P1 cleared
+		{0x62, 0xf1, 0x6c, 0x68, 0x58, 0xd9},       // Reserved vector length
+		{0x62, 0xf1, 0x7c, 0x18, 0x28, 0xc1},       // vmovaps has no rounding
+		{0x62, 0xf1, 0x7c, 0x58, 0x28, 0x00},       // vmovaps has no broadcast
+		{0x62, 0xf1, 0x7c, 0xc9, 0x29, 0x00},       // Zeroing store
+		{0x62, 0xf1, 0x74, 0x48, 0x28, 0xc1},       // vvvv is not used by vmovaps
+		{0x62, 0xf2, 0xfd, 0x08, 0x19, 0xd1},       // vbroadcastsd requires 256 or 512-bit
+		{0x62, 0xf1, 0x6c, 0x48},                   // Truncated
+	}
+	for _, code := range invalid {
+		if _, err := Decode(code, Mode32); !errors.As(err, new(*DecodeError)) {
+			t.Errorf("% x should be invalid, got %v", code, err)
+		}
+	}
+
+	// EVEX.W must match the element size
+	invalid = [][]byte{
+		{0x62, 0x41, 0x6f, 0x26, 0x59, 0xe9},       // vmulsd with W0
+		{0x62, 0x01, 0x86, 0x00, 0x5e, 0xd8},       // vdivss with W1
+		{0x62, 0xc1, 0xee, 0xa2, 0x58, 0x4b, 0x13}, // vaddss with W1
+		{0x62, 0xf1, 0x8e, 0x48, 0x5f, 0x03},       // vmaxss with W1
+		{0x62, 0xf1, 0xfc, 0x48, 0x28, 0xc1},       // vmovaps with W1
+		{0x62, 0x02, 0x7d, 0x4c, 0x19, 0xc1},       // vbroadcastf32x2, not vbroadcastsd
+	}
+	for _, code := range invalid {
+		if _, err := Decode(code, Mode64); !errors.Is(err, ErrUnknownOpcode) {
+			t.Errorf("% x should be invalid, got %v", code, err)
+		}
+	}
+
+	// REX before EVEX is invalid in 64-bit mode
+	if _, err := Decode([]byte{0x48, 0x62, 0xf1, 0x6c, 0x48, 0x58, 0xd9}, Mode64); !errors.Is(err, ErrUnknownOpcode) {
+		t.Error("REX before EVEX should be invalid, got", err)
+	}
+}
+
+func TestOpmask(t *testing.T) {
+	testdata := []codeText{
+		{[]byte{0xc5, 0xf8, 0x92, 0xc8}, "kmovw %eax,%k1"},
+		{[]byte{0xc5, 0xf8, 0x93, 0xc1}, "kmovw %k1,%eax"},
+		{[]byte{0xc4, 0xe1, 0xfb, 0x92, 0xc8}, "kmovq %rax,%k1"},
+		{[]byte{0xc4, 0xe1, 0xfb, 0x93, 0xc1}, "kmovq %k1,%rax"},
+		{[]byte{0xc5, 0xfb, 0x92, 0xc8}, "kmovd %eax,%k1"},
+		{[]byte{0xc5, 0xfb, 0x93, 0xc1}, "kmovd %k1,%eax"},
+		{[]byte{0xc5, 0xf9, 0x92, 0xc8}, "kmovb %eax,%k1"},
+		{[]byte{0xc5, 0xf9, 0x93, 0xc1}, "kmovb %k1,%eax"},
+		{[]byte{0xc5, 0xf8, 0x90, 0x08}, "kmovw (%rax),%k1"},
+		{[]byte{0xc5, 0xf8, 0x91, 0x08}, "kmovw %k1,(%rax)"},
+		{[]byte{0xc4, 0xe1, 0xf8, 0x90, 0x08}, "kmovq (%rax),%k1"},
+		{[]byte{0xc4, 0xe1, 0xf9, 0x91, 0x08}, "kmovd %k1,(%rax)"},
+		{[]byte{0xc5, 0xf9, 0x90, 0xc1}, "kmovb %k1,%k0"},
+		{[]byte{0xc4, 0xe1, 0xf9, 0x90, 0xc1}, "kmovd %k1,%k0"},
+		{[]byte{0xc5, 0xec, 0x41, 0xcb}, "kandw %k3,%k2,%k1"},
+		{[]byte{0xc4, 0xe1, 0xec, 0x41, 0xcb}, "kandq %k3,%k2,%k1"},
+		{[]byte{0xc5, 0xed, 0x41, 0xcb}, "kandb %k3,%k2,%k1"},
+		{[]byte{0xc4, 0xe1, 0xed, 0x41, 0xcb}, "kandd %k3,%k2,%k1"},
+		{[]byte{0xc5, 0xec, 0x42, 0xcb}, "kandnw %k3,%k2,%k1"},
+		{[]byte{0xc5, 0xec, 0x45, 0xcb}, "korw %k3,%k2,%k1"},
+		{[]byte{0xc5, 0xed, 0x45, 0xcb}, "korb %k3,%k2,%k1"},
+		{[]byte{0xc5, 0xec, 0x46, 0xcb}, "kxnorw %k3,%k2,%k1"},
+		{[]byte{0xc5, 0xec, 0x47, 0xcb}, "kxorw %k3,%k2,%k1"},
+		{[]byte{0xc5, 0xec, 0x4a, 0xcb}, "kaddw %k3,%k2,%k1"},
+		{[]byte{0xc5, 0xf8, 0x44, 0xca}, "knotw %k2,%k1"},
+		{[]byte{0xc4, 0xe1, 0xf8, 0x44, 0xca}, "knotq %k2,%k1"},
+		{[]byte{0xc5, 0xf8, 0x98, 0xca}, "kortestw %k2,%k1"},
+		{[]byte{0xc5, 0xf9, 0x98, 0xca}, "kortestb %k2,%k1"},
+		{[]byte{0xc4, 0xe1, 0xf8, 0x98, 0xca}, "kortestq %k2,%k1"},
+		{[]byte{0xc4, 0xe1, 0xf9, 0x98, 0xca}, "kortestd %k2,%k1"},
+		{[]byte{0xc5, 0xf8, 0x99, 0xca}, "ktestw %k2,%k1"},
+		{[]byte{0xc5, 0xf9, 0x99, 0xca}, "ktestb %k2,%k1"},
+		{[]byte{0xc4, 0xe3, 0xf9, 0x30, 0xca, 0x05}, "kshiftrw $0x5,%k2,%k1"},
+		{[]byte{0xc4, 0xe3, 0x79, 0x30, 0xca, 0x05}, "kshiftrb $0x5,%k2,%k1"},
+		{[]byte{0xc4, 0xe3, 0x79, 0x31, 0xca, 0x05}, "kshiftrd $0x5,%k2,%k1"},
+		{[]byte{0xc4, 0xe3, 0xf9, 0x31, 0xca, 0x05}, "kshiftrq $0x5,%k2,%k1"},
+		{[]byte{0xc4, 0xe3, 0xf9, 0x33, 0xca, 0x05}, "kshiftlq $0x5,%k2,%k1"},
+		{[]byte{0xc5, 0xed, 0x4b, 0xcb}, "kunpckbw %k3,%k2,%k1"},
+		{[]byte{0xc5, 0xec, 0x4b, 0xcb}, "kunpckwd %k3,%k2,%k1"},
+		{[]byte{0xc4, 0xe1, 0xec, 0x4b, 0xcb}, "kunpckdq %k3,%k2,%k1"},
+	}
+	testDumpMode(testdata, Mode64, t)
+
+	// There are only 8 opmask registers
+	invalid := [][]byte{
+		{0xc5, 0x78, 0x92, 0xc8},             // VEX.R in kmovw
+		{0xc4, 0xc1, 0x6c, 0x41, 0xcb},       // VEX.B in kandw
+		{0xc5, 0x6c, 0x41, 0xcb},             // VEX.R in kandw
+		{0xc4, 0xc1, 0x78, 0x93, 0xc1},       // VEX.B in kmovw
+		{0x62, 0x71, 0x7d, 0x48, 0x76, 0xc1}, // EVEX.R in vpcmpeqd
+		{0x62, 0xe1, 0x7d, 0x48, 0x76, 0xc1}, // EVEX.R' in vpcmpeqd
+		{0x62, 0xd2, 0x7e, 0x48, 0x28, 0xc1}, // EVEX.B in vpmovm2b
+		{0xc5, 0xf8, 0x91, 0xc0},             // kmovw store to register
+	}
+	for _, code := range invalid {
+		if _, err := Decode(code, Mode64); !errors.Is(err, ErrInvalidModRM) {
+			t.Errorf("% x should be invalid, got %v", code, err)
+		}
+	}
+	invalid = [][]byte{
+		{0xc5, 0xfc, 0x92, 0xc8}, // kmovw with VEX.L
+		{0xc5, 0xe8, 0x41, 0xcb}, // kandw without VEX.L
+		{0xc5, 0xb8, 0x44, 0xca}, // vvvv is not used by knotw
+	}
+	for _, code := range invalid {
+		if _, err := Decode(code, Mode64); !errors.Is(err, ErrUnknownOpcode) {
+			t.Errorf("% x should be invalid, got %v", code, err)
+		}
+	}
+}
+
+func TestEvexCompare(t *testing.T) {
+	testdata := []codeText{
+		{[]byte{0x62, 0xf1, 0x7d, 0x48, 0x76, 0xc1}, "vpcmpeqd %zmm1,%zmm0,%k0"},
+		{[]byte{0x62, 0xf1, 0x7d, 0x4a, 0x76, 0xc1}, "vpcmpeqd %zmm1,%zmm0,%k0{%k2}"},
+		{[]byte{0x62, 0xf1, 0x7d, 0x48, 0x76, 0x40, 0x01}, "vpcmpeqd 0x40(%rax),%zmm0,%k0"},
+		{[]byte{0x62, 0xf1, 0x7d, 0x58, 0x76, 0x40, 0x01}, "vpcmpeqd 0x4(%rax){1to16},%zmm0,%k0"},
+		{[]byte{0x62, 0xf1, 0x7d, 0x48, 0x74, 0xc1}, "vpcmpeqb %zmm1,%zmm0,%k0"},
+		{[]byte{0x62, 0xf1, 0x7d, 0x48, 0x75, 0xc1}, "vpcmpeqw %zmm1,%zmm0,%k0"},
+		{[]byte{0x62, 0xf2, 0xfd, 0x48, 0x29, 0xc1}, "vpcmpeqq %zmm1,%zmm0,%k0"},
+		{[]byte{0x62, 0xf1, 0x7d, 0x48, 0x64, 0xc1}, "vpcmpgtb %zmm1,%zmm0,%k0"},
+		{[]byte{0x62, 0xf1, 0x7d, 0x48, 0x65, 0xc1}, "vpcmpgtw %zmm1,%zmm0,%k0"},
+		{[]byte{0x62, 0xf1, 0x7d, 0x48, 0x66, 0xc1}, "vpcmpgtd %zmm1,%zmm0,%k0"},
+		{[]byte{0x62, 0xf2, 0xfd, 0x48, 0x37, 0xc1}, "vpcmpgtq %zmm1,%zmm0,%k0"},
+		{[]byte{0x62, 0xf3, 0x75, 0x48, 0x1f, 0xc2, 0x01}, "vpcmpltd %zmm2,%zmm1,%k0"},
+		{[]byte{0x62, 0xf3, 0x75, 0x48, 0x1f, 0xc2, 0x03}, "vpcmpd $0x3,%zmm2,%zmm1,%k0"},
+		{[]byte{0x62, 0xf3, 0x75, 0x48, 0x1f, 0xc2, 0x07}, "vpcmpd $0x7,%zmm2,%zmm1,%k0"},
+		{[]byte{0x62, 0xf3, 0xf5, 0x48, 0x1f, 0xc2, 0x01}, "vpcmpltq %zmm2,%zmm1,%k0"},
+		{[]byte{0x62, 0xf3, 0x75, 0x48, 0x1e, 0xc2, 0x01}, "vpcmpltud %zmm2,%zmm1,%k0"},
+		{[]byte{0x62, 0xf3, 0xf5, 0x48, 0x1e, 0xc2, 0x01}, "vpcmpltuq %zmm2,%zmm1,%k0"},
+		{[]byte{0x62, 0xf3, 0x75, 0x48, 0x3f, 0xc2, 0x01}, "vpcmpltb %zmm2,%zmm1,%k0"},
+		{[]byte{0x62, 0xf3, 0xf5, 0x48, 0x3f, 0xc2, 0x01}, "vpcmpltw %zmm2,%zmm1,%k0"},
+		{[]byte{0x62, 0xf3, 0x75, 0x48, 0x3e, 0xc2, 0x01}, "vpcmpltub %zmm2,%zmm1,%k0"},
+		{[]byte{0x62, 0xf3, 0xf5, 0x48, 0x3e, 0xc2, 0x06}, "vpcmpnleuw %zmm2,%zmm1,%k0"},
+		{[]byte{0x62, 0xf1, 0x74, 0x48, 0xc2, 0xc2, 0x01}, "vcmpltps %zmm2,%zmm1,%k0"},
+		{[]byte{0x62, 0xf1, 0x74, 0x48, 0xc2, 0xc2, 0x1f}, "vcmptrue_usps %zmm2,%zmm1,%k0"},
+		{[]byte{0x62, 0xf1, 0x74, 0x48, 0xc2, 0xc2, 0x20}, "vcmpps $0x20,%zmm2,%zmm1,%k0"},
+		{[]byte{0x62, 0xf1, 0xf5, 0x48, 0xc2, 0xc2, 0x01}, "vcmpltpd %zmm2,%zmm1,%k0"},
+		{[]byte{0x62, 0xf1, 0x76, 0x18, 0xc2, 0xc2, 0x01}, "vcmpltss {sae},%xmm2,%xmm1,%k0"},
+		{[]byte{0x62, 0xf1, 0xf7, 0x18, 0xc2, 0xc2, 0x05}, "vcmpnltsd {sae},%xmm2,%xmm1,%k0"},
+		{[]byte{0x62, 0xf1, 0x74, 0x18, 0xc2, 0xca, 0x01}, "vcmpltps {sae},%zmm2,%zmm1,%k1"},
+		{[]byte{0x62, 0xf1, 0x74, 0x18, 0xc2, 0xca, 0x20}, "vcmpps $0x20,{sae},%zmm2,%zmm1,%k1"},
+		{[]byte{0x62, 0xf2, 0x7d, 0x48, 0x26, 0xc2}, "vptestmb %zmm2,%zmm0,%k0"},
+		{[]byte{0x62, 0xf2, 0xfd, 0x48, 0x26, 0xc2}, "vptestmw %zmm2,%zmm0,%k0"},
+		{[]byte{0x62, 0xf2, 0x7d, 0x48, 0x27, 0xc2}, "vptestmd %zmm2,%zmm0,%k0"},
+		{[]byte{0x62, 0xf2, 0xfd, 0x48, 0x27, 0xc2}, "vptestmq %zmm2,%zmm0,%k0"},
+		{[]byte{0x62, 0xf2, 0x7e, 0x48, 0x26, 0xc2}, "vptestnmb %zmm2,%zmm0,%k0"},
+		{[]byte{0x62, 0xf2, 0x7e, 0x48, 0x27, 0xc2}, "vptestnmd %zmm2,%zmm0,%k0"},
+		{[]byte{0x62, 0xf2, 0xfe, 0x48, 0x27, 0xc2}, "vptestnmq %zmm2,%zmm0,%k0"},
+		{[]byte{0x62, 0xf2, 0x7e, 0x48, 0x29, 0xc1}, "vpmovb2m %zmm1,%k0"},
+		{[]byte{0x62, 0xf2, 0xfe, 0x48, 0x29, 0xc1}, "vpmovw2m %zmm1,%k0"},
+		{[]byte{0x62, 0xf2, 0x7e, 0x48, 0x39, 0xc1}, "vpmovd2m %zmm1,%k0"},
+		{[]byte{0x62, 0xf2, 0xfe, 0x48, 0x39, 0xc1}, "vpmovq2m %zmm1,%k0"},
+	}
+	testDumpMode(testdata, Mode64, t)
+}
+
+func TestEvexTernlogBroadcastPermute(t *testing.T) {
+	testdata := []codeText{
+		{[]byte{0x62, 0xf3, 0x75, 0x48, 0x25, 0xc2, 0x96}, "vpternlogd $0x96,%zmm2,%zmm1,%zmm0"},
+		{[]byte{0x62, 0xf3, 0xf5, 0x48, 0x25, 0xc2, 0x96}, "vpternlogq $0x96,%zmm2,%zmm1,%zmm0"},
+		{[]byte{0x62, 0xf3, 0xf5, 0x58, 0x25, 0x40, 0x01, 0x96}, "vpternlogq $0x96,0x8(%rax){1to8},%zmm1,%zmm0"},
+		{[]byte{0x62, 0xf2, 0x7d, 0x48, 0x58, 0xc0}, "vpbroadcastd %xmm0,%zmm0"},
+		{[]byte{0x62, 0xf2, 0x7d, 0x48, 0x58, 0x78, 0x01}, "vpbroadcastd 0x4(%rax),%zmm7"},
+		{[]byte{0x62, 0xf2, 0xfd, 0x48, 0x59, 0xc0}, "vpbroadcastq %xmm0,%zmm0"},
+		{[]byte{0x62, 0xf2, 0x7d, 0x48, 0x59, 0xc0}, "vbroadcasti32x2 %xmm0,%zmm0"},
+		{[]byte{0x62, 0xf2, 0xfd, 0x48, 0x59, 0x40, 0x01}, "vpbroadcastq 0x8(%rax),%zmm0"},
+		{[]byte{0x62, 0xf2, 0x7d, 0x48, 0x78, 0x50, 0x01}, "vpbroadcastb 0x1(%rax),%zmm2"},
+		{[]byte{0x62, 0xf2, 0x7d, 0x48, 0x79, 0x50, 0x01}, "vpbroadcastw 0x2(%rax),%zmm2"},
+		{[]byte{0x62, 0xf2, 0x7d, 0x48, 0x7a, 0xc0}, "vpbroadcastb %eax,%zmm0"},
+		{[]byte{0x62, 0xf2, 0x7d, 0x48, 0x7b, 0xc0}, "vpbroadcastw %eax,%zmm0"},
+		{[]byte{0x62, 0xf2, 0x7d, 0x48, 0x7c, 0xc0}, "vpbroadcastd %eax,%zmm0"},
+		{[]byte{0x62, 0xf2, 0xfd, 0x48, 0x7c, 0xc0}, "vpbroadcastq %rax,%zmm0"},
+		{[]byte{0x62, 0xf2, 0x75, 0x48, 0x76, 0xc2}, "vpermi2d %zmm2,%zmm1,%zmm0"},
+		{[]byte{0x62, 0xf2, 0xf5, 0x48, 0x76, 0xc2}, "vpermi2q %zmm2,%zmm1,%zmm0"},
+		{[]byte{0x62, 0xf2, 0x75, 0x48, 0x77, 0xc2}, "vpermi2ps %zmm2,%zmm1,%zmm0"},
+		{[]byte{0x62, 0xf2, 0xf5, 0x48, 0x77, 0xc2}, "vpermi2pd %zmm2,%zmm1,%zmm0"},
+		{[]byte{0x62, 0xf2, 0x75, 0x48, 0x7e, 0xc2}, "vpermt2d %zmm2,%zmm1,%zmm0"},
+		{[]byte{0x62, 0xf2, 0xf5, 0x48, 0x7e, 0xc2}, "vpermt2q %zmm2,%zmm1,%zmm0"},
+		{[]byte{0x62, 0xf2, 0x75, 0x48, 0x7f, 0xc2}, "vpermt2ps %zmm2,%zmm1,%zmm0"},
+		{[]byte{0x62, 0xf2, 0xf5, 0x48, 0x7f, 0xc2}, "vpermt2pd %zmm2,%zmm1,%zmm0"},
+		{[]byte{0x62, 0xf2, 0xf5, 0x48, 0x75, 0xc2}, "vpermi2w %zmm2,%zmm1,%zmm0"},
+		{[]byte{0x62, 0xf2, 0x75, 0x48, 0x75, 0xc2}, "vpermi2b %zmm2,%zmm1,%zmm0"},
+		{[]byte{0x62, 0xf2, 0xf5, 0x48, 0x7d, 0xc2}, "vpermt2w %zmm2,%zmm1,%zmm0"},
+		{[]byte{0x62, 0xf2, 0x75, 0x48, 0x76, 0x40, 0x01}, "vpermi2d 0x40(%rax),%zmm1,%zmm0"},
+		{[]byte{0x62, 0xf2, 0x7d, 0x4a, 0x7c, 0xc0}, "vpbroadcastd %eax,%zmm0{%k2}"},
+		// EVEX.R extends the vector register, EVEX.B the general purpose register
+		{[]byte{0x62, 0x72, 0x7d, 0x48, 0x7c, 0xc0}, "vpbroadcastd %eax,%zmm8"},
+		{[]byte{0x62, 0xd2, 0x7d, 0x48, 0x7c, 0xc0}, "vpbroadcastd %r8d,%zmm0"},
+	}
+	testDumpMode(testdata, Mode64, t)
+}
+
+// AVX512BW and AVX512DQ byte, word and mask forms
+func TestEvexByteWord(t *testing.T) {
+	testdata := []codeText{
+		{[]byte{0x62, 0xf1, 0xff, 0x48, 0x6f, 0xc1}, "vmovdqu16 %zmm1,%zmm0"},
+		{[]byte{0x62, 0xf1, 0x7f, 0x48, 0x6f, 0xc1}, "vmovdqu8 %zmm1,%zmm0"},
+		{[]byte{0x62, 0xf1, 0x7f, 0x48, 0x7f, 0x40, 0x01}, "vmovdqu8 %zmm0,0x40(%rax)"},
+		{[]byte{0x62, 0xf1, 0xff, 0x48, 0x6f, 0x40, 0x01}, "vmovdqu16 0x40(%rax),%zmm0"},
+		{[]byte{0x62, 0xf2, 0xfe, 0x48, 0x28, 0xc1}, "vpmovm2w %k1,%zmm0"},
+		{[]byte{0x62, 0xf2, 0x7e, 0x48, 0x38, 0xc1}, "vpmovm2d %k1,%zmm0"},
+		{[]byte{0x62, 0xf2, 0xfe, 0x48, 0x38, 0xc1}, "vpmovm2q %k1,%zmm0"},
+		// EVEX.X does not extend opmask register
+		{[]byte{0x62, 0xb2, 0x7e, 0x48, 0x28, 0xc1}, "vpmovm2b %k1,%zmm0"},
+		{[]byte{0x62, 0xf1, 0x74, 0x48, 0x54, 0xc2}, "vandps %zmm2,%zmm1,%zmm0"},
+		{[]byte{0x62, 0xf1, 0xf5, 0x48, 0x54, 0xc2}, "vandpd %zmm2,%zmm1,%zmm0"},
+		{[]byte{0x62, 0xf1, 0x74, 0x48, 0x55, 0xc2}, "vandnps %zmm2,%zmm1,%zmm0"},
+		{[]byte{0x62, 0xf1, 0x74, 0x48, 0x56, 0xc2}, "vorps %zmm2,%zmm1,%zmm0"},
+		{[]byte{0x62, 0xf1, 0x74, 0x48, 0x57, 0xc2}, "vxorps %zmm2,%zmm1,%zmm0"},
+		{[]byte{0x62, 0xf1, 0xf5, 0x48, 0x57, 0xc2}, "vxorpd %zmm2,%zmm1,%zmm0"},
+		{[]byte{0x62, 0xf2, 0xf5, 0x48, 0x40, 0xc2}, "vpmullq %zmm2,%zmm1,%zmm0"},
+		{[]byte{0x62, 0xf2, 0x75, 0x48, 0x40, 0xc2}, "vpmulld %zmm2,%zmm1,%zmm0"},
+		{[]byte{0x62, 0xf1, 0x75, 0x48, 0xd5, 0xc2}, "vpmullw %zmm2,%zmm1,%zmm0"},
+	}
+	testDumpMode(testdata, Mode64, t)
+}
@@ -0,0 +1,117 @@
+package dis
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestVex(t *testing.T) {
+	testdata := []codeText{
+		// 2-byte VEX
+		{[]byte{0xc5, 0xf0, 0x58, 0xc2}, "vaddps %xmm2,%xmm1,%xmm0"},
+		{[]byte{0xc5, 0xf4, 0x58, 0x00}, "vaddps (%eax),%ymm1,%ymm0"},
+		{[]byte{0xc5, 0xfc, 0x28, 0x44, 0x24, 0x08}, "vmovaps 0x8(%esp),%ymm0"},
+		{[]byte{0xc5, 0xf9, 0xd4, 0xc1}, "vpaddq %xmm1,%xmm0,%xmm0"},
+		{[]byte{0xc5, 0xfd, 0xd4, 0xc1}, "vpaddq %ymm1,%ymm0,%ymm0"},
+		{[]byte{0xc5, 0xf1, 0x6c, 0x00}, "vpunpcklqdq (%eax),%xmm1,%xmm0"},
+		{[]byte{0xc5, 0xf9, 0x73, 0xf2, 0x03}, "vpsllq $0x3,%xmm2,%xmm0"},
+		{[]byte{0xc5, 0xfd, 0xd7, 0xc1}, "vpmovmskb %ymm1,%eax"},
+		{[]byte{0xc5, 0xfa, 0x5b, 0xc1}, "vcvttps2dq %xmm1,%xmm0"},
+		{[]byte{0xc5, 0xfd, 0xe6, 0x00}, "vcvttpd2dqy (%eax),%xmm0"},
+		{[]byte{0xc5, 0xf8, 0x77}, "vzeroupper "},
+		{[]byte{0xc5, 0xfc, 0x77}, "vzeroall "},
+		{[]byte{0xc5, 0xf9, 0x6e, 0xc0}, "vmovd %eax,%xmm0"},
+		{[]byte{0xc5, 0xf9, 0x7e, 0xc0}, "vmovd %xmm0,%eax"},
+		// vmovss has different operands for register and memory
+		{[]byte{0xc5, 0xfa, 0x10, 0x0c, 0x24}, "vmovss (%esp),%xmm1"},
+		{[]byte{0xc5, 0xfa, 0x10, 0xc1}, "vmovss %xmm1,%xmm0,%xmm0"},
+		{[]byte{0xc5, 0xfa, 0x11, 0x08}, "vmovss %xmm1,(%eax)"},
+
+		// 3-byte VEX
+		{[]byte{0xc4, 0xc1, 0x74, 0x58, 0xc7}, "vaddps %ymm7,%ymm1,%ymm0"},
+		{[]byte{0xc4, 0xe2, 0x79, 0x18, 0xc1}, "vbroadcastss %xmm1,%xmm0"},
+		{[]byte{0xc4, 0xe2, 0x7d, 0x18, 0x00}, "vbroadcastss (%eax),%ymm0"},
+		{[]byte{0xc4, 0xe2, 0x7d, 0x19, 0xc1}, "vbroadcastsd %xmm1,%ymm0"},
+		{[]byte{0xc4, 0xe3, 0x7d, 0x18, 0xc1, 0x01}, "vinsertf128 $0x1,%xmm1,%ymm0,%ymm0"},
+		{[]byte{0xc4, 0xe3, 0x7d, 0x19, 0xc1, 0x01}, "vextractf128 $0x1,%ymm0,%xmm1"},
+		{[]byte{0xc4, 0xe3, 0x71, 0x4a, 0xc2, 0x30}, "vblendvps %xmm3,%xmm2,%xmm1,%xmm0"},
+		{[]byte{0xc4, 0xe3, 0x79, 0x0f, 0xc1, 0x05}, "vpalignr $0x5,%xmm1,%xmm0,%xmm0"},
+		{[]byte{0xc4, 0xe3, 0x79, 0x16, 0xc0, 0x01}, "vpextrd $0x1,%xmm0,%eax"},
+		// VEX.W is ignored for general purpose register outside 64-bit mode
+		{[]byte{0xc4, 0xe1, 0xf9, 0x6e, 0xc0}, "vmovd %eax,%xmm0"},
+		// FMA, VEX.W selects the mnemonic
+		{[]byte{0xc4, 0xe2, 0x79, 0x98, 0xc2}, "vfmadd132ps %xmm2,%xmm0,%xmm0"},
+		{[]byte{0xc4, 0xe2, 0xf9, 0x98, 0xc2}, "vfmadd132pd %xmm2,%xmm0,%xmm0"},
+		{[]byte{0xc4, 0xe2, 0x79, 0x99, 0x00}, "vfmadd132ss (%eax),%xmm0,%xmm0"},
+		// AVX2
+		{[]byte{0xc4, 0xe3, 0x7d, 0x39, 0xc1, 0x01}, "vextracti128 $0x1,%ymm0,%xmm1"},
+		{[]byte{0xc4, 0xe2, 0x7d, 0x58, 0xc1}, "vpbroadcastd %xmm1,%ymm0"},
+		{[]byte{0xc4, 0xe2, 0x7d, 0x36, 0xc2}, "vpermd %ymm2,%ymm0,%ymm0"},
+		{[]byte{0xc4, 0xe2, 0xfd, 0x8c, 0x00}, "vpmaskmovq (%eax),%ymm0,%ymm0"},
+
+		// c4 and c5 are les and lds if not followed by register ModR/M
+		{[]byte{0xc5, 0x20}, "lds (%eax),%esp"},
+	}
+	testDump(testdata, t)
+
+	testdata = []codeText{
+		{[]byte{0xc4, 0x41, 0x74, 0x58, 0xc7}, "vaddps %ymm15,%ymm1,%ymm8"},
+		{[]byte{0xc4, 0x61, 0x7d, 0x58, 0xc9}, "vaddpd %ymm1,%ymm0,%ymm9"},
+		// VEX.L selects 256-bit vaes and vpclmulqdq
+		{[]byte{0xc4, 0x42, 0xdd, 0xdd, 0xfa}, "vaesenclast %ymm10,%ymm4,%ymm15"},
+		{[]byte{0xc4, 0xe2, 0x5d, 0xde, 0x00}, "vaesdec (%rax),%ymm4,%ymm0"},
+		{[]byte{0xc4, 0xe2, 0x59, 0xdc, 0xc1}, "vaesenc %xmm1,%xmm4,%xmm0"},
+		{[]byte{0xc4, 0xe3, 0x5d, 0x44, 0xc1, 0x01}, "vpclmulqdq $0x1,%ymm1,%ymm4,%ymm0"},
+		{[]byte{0xc4, 0xc1, 0x7d, 0x28, 0x44, 0x24, 0x08}, "vmovapd 0x8(%r12),%ymm0"},
+		{[]byte{0xc5, 0xfa, 0x10, 0x05, 0xf0, 0xff, 0xff, 0xff}, "vmovss -0x10(%rip),%xmm0"},
+		{[]byte{0xc4, 0xe1, 0xf9, 0x6e, 0xc0}, "vmovq %rax,%xmm0"},
+		{[]byte{0xc4, 0xe1, 0xf9, 0x7e, 0xc0}, "vmovq %xmm0,%rax"},
+		{[]byte{0xc4, 0xc3, 0xf9, 0x16, 0xc0, 0x01}, "vpextrq $0x1,%xmm0,%r8"},
+		{[]byte{0xc4, 0x43, 0x7d, 0x19, 0xc1, 0x01}, "vextractf128 $0x1,%ymm8,%xmm9"},
+		{[]byte{0xc4, 0xe3, 0x71, 0x4a, 0xc2, 0xf0}, "vblendvps %xmm15,%xmm2,%xmm1,%xmm0"},
+		{[]byte{0xc4, 0x42, 0xfd, 0x8c, 0x00}, "vpmaskmovq (%r8),%ymm0,%ymm8"},
+
+		// VEX.W and VEX.L are ignored unless the instruction requires a value
+		{[]byte{0xc5, 0xfe, 0x58, 0xc1}, "vaddss %xmm1,%xmm0,%xmm0"},
+		{[]byte{0xc4, 0xe1, 0xf4, 0x58, 0xc2}, "vaddps %ymm2,%ymm1,%ymm0"},
+		{[]byte{0xc4, 0xe2, 0x79, 0x0c, 0xc1}, "vpermilps %xmm1,%xmm0,%xmm0"},
+		{[]byte{0xc4, 0xe3, 0xfd, 0x00, 0xc1, 0x1b}, "vpermq $0x1b,%ymm1,%ymm0"},
+	}
+	testDumpMode(testdata, Mode64, t)
+
+	insn, err := Decode([]byte{0xc4, 0xe2, 0xf9, 0x98, 0xc2}, Mode32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if insn.Vex != 0xc4 || !insn.VexW || insn.VexL != 0 || insn.Vvvv != 0 || insn.MandatoryPrefix != 0x66 {
+		t.Errorf("VEX fields: vex %#x W %v L %d vvvv %d mandatory prefix %#x",
+			insn.Vex, insn.VexW, insn.VexL, insn.Vvvv, insn.MandatoryPrefix)
+	}
+
+	invalid := [][]byte{
+		{0xf0, 0xc5, 0xf0, 0x58, 0xc2},       // LOCK before VEX
+		{0x66, 0xc5, 0xf0, 0x58, 0xc2},       // 66 before VEX
+		{0xc4, 0xe4, 0x79, 0x58, 0xc2},       // Reserved opcode map
+		{0xc4, 0xe2, 0x79, 0x19, 0xc1},       // vbroadcastsd requires VEX.L
+		{0xc5, 0xfd, 0x6e, 0xc1},             // vmovd requires VEX.L0
+		{0xc4, 0xe3, 0x75, 0x21, 0xc1, 0x00}, // vinsertps requires VEX.L0
+		{0xc4, 0xe2, 0xf9, 0x0c, 0xc1},       // vpermilps requires VEX.W0
+		{0xc4, 0xe2, 0xfd, 0x58, 0xc1},       // vpbroadcastd requires VEX.W0
+		{0xc4, 0xe3, 0x7d, 0x00, 0xc1, 0x00}, // vpermq requires VEX.W1
+		{0xc5, 0xf4, 0x28, 0xc1},             // vvvv is not used by vmovaps
+		{0xc5, 0xfd, 0xd7, 0x00},             // vpmovmskb register operand only
+		{0xc4, 0xe2, 0x79, 0x2a, 0xc1},       // vmovntdqa memory operand only
+		{0xc4, 0xe2, 0x79, 0x90, 0x04, 0xc8}, // Gather with VSIB is not supported
+		{0xc5, 0xf9},                         // Truncated
+	}
+	for _, code := range invalid {
+		if _, err := Decode(code, Mode32); !errors.As(err, new(*DecodeError)) {
+			t.Errorf("% x should be invalid, got %v", code, err)
+		}
+	}
+
+	// REX before VEX is invalid in 64-bit mode
+	if _, err := Decode([]byte{0x48, 0xc5, 0xf0, 0x58, 0xc2}, Mode64); !errors.Is(err, ErrUnknownOpcode) {
+		t.Error("REX before VEX should be invalid, got", err)
+	}
+}
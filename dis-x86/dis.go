@@ -1,8 +1,18 @@
+// Package dis decodes x86 instructions in 16, 32 and 64-bit mode, and
+// formats them in AT&T, Intel or Go (Plan 9) syntax.
+//
+// The instruction tables are generated from diStorm's instruction set
+// description in disOps. They cover the integer, x87, MMX, SSE to SSE4.2,
+// AVX, AVX2, FMA and part of AVX-512 instructions. 3DNow!, VMX and SVM
+// instructions are not generated. AVX2 gather instructions (66 0f 38 90-93,
+// e.g. vpgatherdd) are not supported either, because their VSIB memory
+// operand uses a vector register as the index; they are decoded as
+// ErrUnknownOpcode.
 package dis
 
 import (
+	"bytes"
 	"encoding/binary"
-	"fmt"
 	"io"
 	"log"
 	"os"
@@ -18,6 +28,10 @@ For each instruction, the disassemble contains several passes:
 
 This assembler only parses the instruction and put the information in the
 DisContext. This is intended to make it useful for different purpose.
+
+Use DisContext to decode a stream of instructions. If only a single
+instruction is needed, or decoded instructions should be kept around, use
+Decode which returns a self-contained Instruction value.
 */
 
 var debug = log.New(os.Stderr, "DEBUG ", log.Lshortfile)
@@ -62,6 +76,7 @@ const (
 	Cr2 byte = 2
 	Cr3 byte = 3
 	Cr4 byte = 4
+	Cr8 byte = 8
 )
 
 const (
@@ -84,14 +99,55 @@ const (
 	OpSizeFull // means size depend on operand-size
 )
 
+// Mode is the processor mode in which code is decoded. It determines the
+// default operand-size and address-size attributes.
+type Mode byte
+
+const (
+	Mode16 Mode = 16 // Real-address mode, or protected mode with D flag cleared
+	Mode32 Mode = 32 // Protected mode with D flag set
+	Mode64 Mode = 64 // 64-bit sub-mode of IA-32e mode
+)
+
+// Refer to Intel Manual 2A Section 2.2.1.2 Table 2-4 for the operand-size
+// and address-size attributes in 64-bit mode.
+func (m Mode) defaultOperandSize() byte {
+	if m == Mode16 {
+		return OpSizeWord
+	}
+	return OpSizeLong
+}
+
+func (m Mode) defaultAddressSize() byte {
+	switch m {
+	case Mode16:
+		return OpSizeWord
+	case Mode64:
+		return OpSizeQuad
+	}
+	return OpSizeLong
+}
+
 type InsnInfo struct {
-	OpId byte
+	OpId uint16
 	Flag uint64 // Contains information about how to parse the instruction
 	// The operand type is defined in insn.go. Look at diStorm's instructions.h
 	// for the meaning of each operand type.
 	Operand [4]byte
 }
 
+// Whether the instruction has any of the given operand types.
+func (ii *InsnInfo) hasOperand(types ...byte) bool {
+	for _, op := range ii.Operand {
+		for _, t := range types {
+			if op == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (ii *InsnInfo) countOperand() int {
 	cnt := 0
 	for _, op := range ii.Operand {
@@ -104,12 +160,46 @@ func (ii *InsnInfo) countOperand() int {
 }
 
 type Instruction struct {
-	Prefix int
-	Info   *InsnInfo
-
-	Disp   int32 // Displacement. For lgdt and related, this is the limit
-	ImmOff int32 // Immediate value or Offset. For lgdt and related, this is base
+	Mode   Mode   // Mode the instruction is decoded in
+	Addr   uint64 // Virtual address of the instruction
+	Length int    // Length of the instruction in bytes
+	Raw    []byte
 
+	Prefix int
+	Rex    byte // REX prefix in 64-bit mode, 0 if not present
+	// 66, F2 or F3 prefix used as part of the opcode, 0 if not present.
+	// The mandatory prefix is not recorded in Prefix.
+	MandatoryPrefix byte
+	Info            *InsnInfo
+	// Opcode id of the mnemonic. Different from Info.OpId for instructions
+	// with alternative mnemonics, e.g. cwde and movq.
+	OpId uint16
+
+	// VEX prefix (C4 or C5), 0 if not present. In 64-bit mode, VEX.R, VEX.X
+	// and VEX.B are stored in Rex, VEX.W is stored in VexW.
+	Vex  byte
+	VexW bool
+	VexL byte // Vector length, 0 for 128-bit, 1 for 256-bit, 2 for 512-bit
+	Vvvv byte // Extra register operand encoded in VEX.vvvv, not inverted
+
+	// EVEX prefix (62), 0 if not present. EVEX.W, EVEX.L'L and EVEX.V'vvvv
+	// are stored in VexW, VexL and Vvvv.
+	Evex     byte
+	Opmask   byte // Opmask register k0-k7 in EVEX.aaa, 0 means no masking
+	Zeroing  bool // EVEX.z, zeroing-masking instead of merging-masking
+	EvexB    bool // EVEX.b, broadcast, rounding control or SAE
+	Rounding byte // Rounding control in EVEX.L'L, if EvexB is set for register operands
+	evexR2   bool // EVEX.R', not inverted
+
+	Disp   int32 // Displacement, disp8*N is already scaled. For lgdt and related, this is the limit
+	ImmOff int64 // Immediate value or Offset. For lgdt and related, this is base
+	// Absolute target address of relative branch and call, which is the
+	// address of the next instruction plus the relative offset.
+	Target uint64
+
+	// Reg, Rm, Index and Base are extended by the REX prefix, so they range
+	// from 0 to 15 in 64-bit mode. Reg and Rm can be up to 31 for EVEX
+	// encoded vector registers.
 	Mod byte
 	Reg byte
 	Rm  byte
@@ -118,9 +208,10 @@ type Instruction struct {
 	Index byte
 	Base  byte
 
-	Src      byte // source operand type
-	Dst      byte // destination operand type
-	Noperand byte // how many operands
+	// Operands in Intel order, built from the fields above. Not available
+	// for length-only decoding.
+	Operands []Operand
+	imm2     int64 // Second immediate of enter, or selector of far pointer
 
 	// Instruction specific operand/address size attribute.
 	// This will only be set and overrides the information in DisContext if:
@@ -142,16 +233,29 @@ type Instruction struct {
 	DispSize byte
 
 	opcodeAll int // Include escape code, and reg field if needed. e.g. str has (0x0f0001)
+
+	symbolizer Symbolizer // Set by formatter to show addresses as symbols
 }
 
 // Disassemble. Record information in each pass.
+//
+// A DisContext should not be used by multiple goroutines at the same time,
+// but different DisContext can decode concurrently.
 type DisContext struct {
 	binary    io.ReaderAt
 	offset    int64 // Record position in the binary code
 	insnStart int64 // Begin offset of the current instruction
+	readBuf   [8]byte
+
+	BaseAddr uint64 // Virtual address of the start of binary
+
+	// Only find the instruction length, operands are not parsed. Set by
+	// NextLen during decoding.
+	lengthOnly bool
 
 	Dflag     bool // Affects the operand-size and address-size attributes
 	Protected bool // in Protected mode?
+	Long      bool // in 64-bit mode? Dflag is ignored if set
 
 	OperandSize byte // These should be set when Dflag and Protected bit is
 	AddressSize byte // changed
@@ -159,6 +263,21 @@ type DisContext struct {
 	Instruction
 }
 
+// Len returns the length of the instruction in bytes.
+func (insn *Instruction) Len() int {
+	return insn.Length
+}
+
+// Bytes returns the raw bytes of the instruction.
+func (insn *Instruction) Bytes() []byte {
+	return insn.Raw
+}
+
+// Address returns the virtual address of the instruction.
+func (insn *Instruction) Address() uint64 {
+	return insn.Addr
+}
+
 // Create a new DisContext with protected mode on, dflag set.
 func NewDisContext(binary io.ReaderAt) (dc *DisContext) {
 	dc = new(DisContext)
@@ -169,10 +288,39 @@ func NewDisContext(binary io.ReaderAt) (dc *DisContext) {
 	dc.Protected = true
 	dc.OperandSize = OpSizeLong
 	dc.AddressSize = OpSizeLong
+	dc.Mode = Mode32
 
 	return
 }
 
+// Decode the first instruction in code. The returned Instruction shares no
+// memory with code or with any decoder, so it stays valid after decoding
+// other instructions. Decode is safe for concurrent use. Branch targets are
+// calculated as if code starts at address 0.
+func Decode(code []byte, mode Mode) (insn Instruction, err error) {
+	return DecodeAt(code, 0, mode)
+}
+
+// DecodeAt is the same as Decode, except that code starts at addr.
+func DecodeAt(code []byte, addr uint64, mode Mode) (insn Instruction, err error) {
+	dc := NewDisContext(bytes.NewReader(code))
+	dc.SetMode(mode)
+	dc.BaseAddr = addr
+	if err = dc.decode(); err != nil {
+		return
+	}
+	return dc.Instruction, nil
+}
+
+// Return the length of the first instruction in code, which is the same as
+// Decode(code, mode).Length but faster. Useful for finding instruction
+// boundaries.
+func DecodeLen(code []byte, mode Mode) (int, error) {
+	dc := NewDisContext(bytes.NewReader(code))
+	dc.SetMode(mode)
+	return dc.NextLen()
+}
+
 // Convert byte to int. true = 1, false = 0
 func Btoi(b bool) int {
 	if b {
@@ -188,27 +336,52 @@ func (dc *DisContext) updateOperandAddressSize() {
 	}
 	dc.OperandSize = size
 	dc.AddressSize = size
+	if size == OpSizeWord {
+		dc.Mode = Mode16
+	} else {
+		dc.Mode = Mode32
+	}
+	if dc.Protected && dc.Long {
+		dc.OperandSize = OpSizeLong
+		dc.AddressSize = OpSizeQuad
+		dc.Mode = Mode64
+	}
 }
 
 var overrideSize = [...]byte{
 	OpSizeLong: OpSizeWord,
 	OpSizeWord: OpSizeLong,
+	OpSizeQuad: OpSizeLong, // Only for address-size
 }
 
-func (dc *DisContext) EffectiveOperandSize() (size byte) {
-	if dc.opSizeOverride {
-		size = overrideSize[dc.OperandSize]
-	} else {
-		size = dc.OperandSize
+func (insn *Instruction) EffectiveOperandSize() (size byte) {
+	if insn.Mode == Mode64 {
+		switch {
+		case insn.Rex&RexW != 0:
+			// REX.W takes precedence over operand-size prefix
+			return OpSizeQuad
+		case insn.opSizeOverride:
+			return OpSizeWord
+		case insn.Info != nil &&
+			insn.Info.Flag&(IFLAG_64BITS|IFLAG_PRE_REX) == IFLAG_64BITS:
+			// Instructions such as push and near branches default to
+			// 64-bit operand size. Those also flagged with PRE_REX need
+			// REX.W to be promoted.
+			return OpSizeQuad
+		}
+		return OpSizeLong
+	}
+	size = insn.Mode.defaultOperandSize()
+	if insn.opSizeOverride {
+		size = overrideSize[size]
 	}
 	return
 }
 
-func (dc *DisContext) EffectiveAddressSize() (size byte) {
-	if dc.addrSizeOverride {
-		size = overrideSize[dc.AddressSize]
-	} else {
-		size = dc.AddressSize
+func (insn *Instruction) EffectiveAddressSize() (size byte) {
+	size = insn.Mode.defaultAddressSize()
+	if insn.addrSizeOverride {
+		size = overrideSize[size]
 	}
 	return
 }
@@ -229,55 +402,213 @@ func (dc *DisContext) SetProtected(v bool) {
 	dc.updateOperandAddressSize()
 }
 
-// Parse 1 instruction. Return nil if no more data available.
-func (dc *DisContext) NextInsn() *DisContext {
-	// Error handling
+// Enter or leave 64-bit mode. This corresponds to the L bit of the code
+// segment descriptor in IA-32e mode.
+func (dc *DisContext) SetLong(v bool) {
+	if v == dc.Long {
+		return
+	}
+	dc.Long = v
+	dc.updateOperandAddressSize()
+}
+
+// Set the Dflag, Protected and Long bit according to the mode.
+func (dc *DisContext) SetMode(m Mode) {
+	switch m {
+	case Mode16:
+		dc.Dflag = false
+		dc.Long = false
+	case Mode32:
+		dc.Protected = true
+		dc.Dflag = true
+		dc.Long = false
+	case Mode64:
+		dc.Protected = true
+		dc.Long = true
+	}
+	dc.updateOperandAddressSize()
+}
+
+// Parse 1 instruction. Return io.EOF if no more data available.
+//
+// If the bytes at the current offset is not a valid instruction, a
+// *DecodeError is returned and the offset advances by one byte, so the caller
+// can continue decoding from the next byte.
+func (dc *DisContext) NextInsn() (*DisContext, error) {
+	if err := dc.decode(); err != nil {
+		if _, ok := err.(*DecodeError); ok {
+			dc.offset = dc.insnStart + 1
+		}
+		return nil, err
+	}
+	return dc, nil
+}
+
+// SetOffset sets the offset in the binary of the next instruction to decode. The
+// address of the instruction is BaseAddr plus the offset.
+func (dc *DisContext) SetOffset(offset int64) {
+	dc.offset = offset
+}
+
+// Return the length of the next instruction and skip it. Immediate and
+// displacement values, register operands, mnemonic, branch target and raw
+// bytes are not available in the embedded Instruction. Errors are the same as
+// NextInsn.
+func (dc *DisContext) NextLen() (int, error) {
+	dc.lengthOnly = true
+	defer func() { dc.lengthOnly = false }()
+	if err := dc.decode(); err != nil {
+		if _, ok := err.(*DecodeError); ok {
+			dc.offset = dc.insnStart + 1
+		}
+		return 0, err
+	}
+	return dc.Length, nil
+}
+
+// Decode the instruction at the current offset into the embedded
+// Instruction. Parsing code reports errors by panic, they are recovered here.
+func (dc *DisContext) decode() (err error) {
 	defer func() {
-		if err := recover(); err != nil {
-			if err != io.EOF {
-				log.Println("work failed:", err)
+		if r := recover(); r != nil {
+			// Only errors raised by the decoder are converted, others are
+			// bugs, e.g. runtime.Error.
+			switch e := r.(type) {
+			case readError:
+				err = dc.decodeError(e.err)
+			case error:
+				if e != ErrUnknownOpcode && e != ErrInvalidModRM && e != ErrTooLong {
+					panic(r)
+				}
+				err = dc.decodeError(e)
+			default:
+				panic(r)
 			}
-			return
 		}
 	}()
-	dc.DispSize = 0
-	dc.Scale = 0
-	dc.Prefix = 0
-	dc.addrSizeOverride = false
-	dc.opSizeOverride = false
+	dc.Instruction = Instruction{Mode: dc.Mode}
 	dc.insnStart = dc.offset
 
 	dc.parsePrefix()
 	dc.parseOpcode()
-	return dc
+	if dc.offset-dc.insnStart > maxInsnLen {
+		panic(ErrTooLong)
+	}
+
+	dc.Addr = dc.BaseAddr + uint64(dc.insnStart)
+	dc.Length = int(dc.offset - dc.insnStart)
+	if dc.lengthOnly {
+		return
+	}
+	if dc.Info.hasOperand(OT_RELCB, OT_RELC_FULL) {
+		dc.setTarget()
+	}
+	// After setTarget, which is needed by the Rel operand
+	dc.buildOperands()
+	dc.Raw = make([]byte, dc.Length)
+	if _, err = dc.binary.ReadAt(dc.Raw, dc.insnStart); err == io.EOF {
+		// Some io.ReaderAt returns EOF when reading till the end.
+		err = nil
+	}
+	return
+}
+
+// Calculate the target of relative branch and call. The instruction pointer
+// is truncated to the operand size, e.g. jmp with operand-size prefix in
+// 32-bit mode only jumps inside the low 64K.
+func (dc *DisContext) setTarget() {
+	target := dc.Addr + uint64(dc.Length) + uint64(dc.ImmOff)
+	if dc.EffectiveOperandSize() == OpSizeWord {
+		target &= 0xffff
+	} else if dc.Mode != Mode64 {
+		target &= 0xffffffff
+	}
+	dc.Target = target
+}
+
+// Convert error encountered when parsing the current instruction to the error
+// returned to the caller.
+func (dc *DisContext) decodeError(err error) error {
+	switch err {
+	case io.EOF:
+		if dc.offset == dc.insnStart {
+			// No more instruction
+			return io.EOF
+		}
+		err = ErrTruncated
+	case ErrUnknownOpcode, ErrInvalidModRM, ErrTooLong:
+	default:
+		// Error returned by the underlying reader
+		return err
+	}
+	e := &DecodeError{Offset: dc.insnStart, Err: err}
+	e.Bytes = make([]byte, dc.offset-dc.insnStart)
+	dc.binary.ReadAt(e.Bytes, dc.insnStart)
+	return e
 }
 
 var nopInsnInfo = InsnInfo{Insn_Nop, 0x00, [4]byte{}}
+var pauseInsnInfo = InsnInfo{Insn_Pause, 0x00, [4]byte{}}
+
+// 0x63 is arpl in 16/32-bit mode, but movsxd in 64-bit mode. The instruction
+// table only has arpl.
+var movsxdInsnInfo = InsnInfo{Insn_Movsxd, IFLAG_MODRM_REQUIRED, [4]byte{OT_REG_FULL, OT_RM32}}
 
 func (dc *DisContext) parseOpcode() {
 	opcode := dc.nextByte()
 
 	// nop is nasty. 0x90 is nop if not prefixed, but if prefixed with 0x66, it's xchg
-	if opcode == 0x90 && dc.Prefix&PrefixOperandSize == 0 {
+	// In 64-bit mode, 0x90 with REX.B is xchg %r8,%rax
+	// F3 90 is pause.
+	if opcode == 0x90 && dc.Prefix&PrefixOperandSize == 0 && dc.Rex&RexB == 0 {
 		dc.Info = &nopInsnInfo
+		if dc.Prefix&PrefixREPZ != 0 {
+			dc.Prefix &^= PrefixREPZ
+			dc.MandatoryPrefix = 0xf3
+			dc.Info = &pauseInsnInfo
+		}
+		dc.OpId = dc.Info.OpId
 		return
 	}
 
 	dc.opcodeAll = int(opcode)
 
-	// If this is a escape, we need to access InsnDB2 using the second opcode byte
-	if opcode != 0x0f {
+	// If this is a escape, we need to access InsnDB2 using the second opcode
+	// byte, and InsnDB0F38 or InsnDB0F3A using the third opcode byte.
+	if (opcode == 0xc4 || opcode == 0xc5) && dc.isVex() {
+		opcode = dc.parseVex(opcode)
+	} else if opcode == 0x62 && dc.isVex() {
+		opcode = dc.parseEvex()
+	} else if opcode == 0x63 && dc.Mode == Mode64 {
+		dc.Info = &movsxdInsnInfo
+	} else if opcode == 0x9b && dc.isFwaitPrefixed() {
+		// fwait followed by a x87 instruction, e.g. fstcw (9b d9 /7). Look
+		// up in the group map with 9b as part of the opcode.
+		opcode = dc.nextByte()
+		dc.opcodeAll = dc.opcodeAll<<8 + int(opcode)
+		dc.Info = &InsnDB[opcode]
+	} else if opcode != 0x0f {
 		dc.Info = &InsnDB[opcode]
 		// debug.Printf("opcode: %#02x\n", opcode)
 	} else {
+		table := &InsnDB2
 		opcode = dc.nextByte()
-		dc.Info = &InsnDB2[opcode]
+		if opcode == 0x38 || opcode == 0x3a {
+			if opcode == 0x38 {
+				table = &InsnDB0F38
+			} else {
+				table = &InsnDB0F3A
+			}
+			dc.opcodeAll = dc.opcodeAll<<8 + int(opcode)
+			opcode = dc.nextByte()
+		}
 		dc.opcodeAll = dc.opcodeAll<<8 + int(opcode)
-		// debug.Printf("opcode: 0x0f, %#02x\n", dc.opcodeAll)
+		dc.Info = dc.lookupMandatoryPrefix(table, opcode)
+		// debug.Printf("opcode: %#02x\n", dc.opcodeAll)
 	}
 
 	if dc.Info.OpId == 0 {
-		panic(fmt.Sprintf("No such opcode %#x", dc.opcodeAll))
+		panic(ErrUnknownOpcode)
 	}
 
 	if dc.Info.Flag&IFLAG_MODRM_REQUIRED != 0 {
@@ -288,15 +619,494 @@ func (dc *DisContext) parseOpcode() {
 		// Because of Go's address operator's limitation, we first find the
 		// index in the grpInsnInfoIndex, then use the index to access the
 		// grpInsnInfo array.
-		dc.opcodeAll = dc.opcodeAll<<8 + int(dc.Reg)
+		reg := dc.Reg
+		if dc.Info.Flag&IFLAG_MODRR_BASED != 0 {
+			// Register and memory form use different operands, e.g. vmovss.
+			// 1 is used for the register form, 0 for the memory form.
+			reg = byte(Btoi(dc.Mod == 3))
+		} else if dc.Info.Flag&IFLAG_DIVIDED != 0 && dc.Mod == 3 {
+			// Register form of divided instructions is selected by the
+			// whole ModR/M byte, e.g. d9 e8 is fld1. If not defined, use the
+			// memory form, which will reject the register operand.
+			modrm := 0xc0 | dc.Reg<<3 | dc.Rm
+			if _, ok := grpInsnInfoIndex[dc.opcodeAll<<8+int(modrm)]; ok {
+				reg = modrm
+			}
+		}
+		dc.opcodeAll = dc.opcodeAll<<8 + int(reg)
 		idx, ok := grpInsnInfoIndex[dc.opcodeAll]
 		if !ok {
-			panic(fmt.Sprintf("Group instruction key %#x lookup failed", dc.opcodeAll))
+			// The reg field does not encode a valid instruction
+			panic(ErrUnknownOpcode)
 		}
 		dc.Info = &(grpInsnInfo[idx])
 		// debug.Printf("Opcode: %#02x reg field %#x used as insn encoding, OpId: %#02x", dc.opcodeAll, dc.Reg, dc.Info.OpId)
 	}
+	if dc.Mode == Mode64 && dc.Info.Flag&IFLAG_INVALID_64BITS != 0 {
+		panic(ErrUnknownOpcode)
+	}
+	if dc.Evex != 0 {
+		dc.checkEvex()
+	}
+	if dc.Vex != 0 || dc.Evex != 0 {
+		dc.checkVex()
+	}
+	if dc.Info.Flag&IFLAG_MODRM_REQUIRED != 0 {
+		dc.checkModRM()
+		// Extend the register fields after the reg field is used for
+		// opcode lookup.
+		dc.applyRex()
+		if dc.Evex != 0 {
+			dc.applyEvex()
+		}
+	}
+	if dc.lengthOnly {
+		dc.skipOperand()
+		return
+	}
 	dc.parseOperand(opcode)
+	dc.OpId = dc.selectMnemonic()
+}
+
+// Check if the bytes following fwait (0x9b) are an instruction which has
+// fwait as part of its opcode, e.g. 9b db e3 is finit. If not, 0x9b is a
+// standalone fwait.
+func (dc *DisContext) isFwaitPrefixed() bool {
+	var buf [2]byte
+	if n, _ := dc.binary.ReadAt(buf[:], dc.offset); n != len(buf) {
+		return false
+	}
+	modrm := buf[1]
+	if modrm < 0xc0 {
+		modrm = modrm >> 3 & 0x7
+	}
+	_, ok := grpInsnInfoIndex[0x9b<<16+int(buf[0])<<8+int(modrm)]
+	return ok
+}
+
+// Select the mnemonic for instructions with alternative mnemonics. Refer to
+// diStorm's USE_EXMNEMONIC, USE_EXMNEMONIC2 and MNEMONIC_MODRM_BASED flags.
+func (dc *DisContext) selectMnemonic() uint16 {
+	ex, ok := exMnemonic[dc.Info.OpId]
+	if !ok {
+		return dc.Info.OpId
+	}
+	flag := dc.Info.Flag
+	// 0 is the first mnemonic, 1 and 2 are the alternative ones.
+	idx := 0
+	switch {
+	case flag&IFLAG_MNEMONIC_MODRM_BASED != 0:
+		// The first mnemonic is for register operand, e.g. movhlps and movlps
+		if dc.Mod != 3 {
+			idx = 1
+			if flag&IFLAG_USE_EXMNEMONIC2 != 0 && dc.Rex&RexW != 0 {
+				idx = 2
+			}
+		}
+	case flag&IFLAG_USE_EXMNEMONIC != 0:
+		// Selected by operand size, e.g. cbw, cwde and cdqe. jcxz is selected
+		// by address size.
+		size := dc.EffectiveOperandSize()
+		if flag&IFLAG_PRE_ADDR_SIZE != 0 {
+			size = dc.EffectiveAddressSize()
+		}
+		switch size {
+		case OpSizeLong:
+			idx = 1
+		case OpSizeQuad:
+			idx = 2
+		}
+	case flag&IFLAG_MNEMONIC_VEXW_BASED != 0:
+		// e.g. vfmadd132ps and vfmadd132pd. VEX.W is ignored outside 64-bit
+		// mode for general purpose register operand, e.g. vmovd and vmovq.
+		if dc.VexW && (dc.Mode == Mode64 || !dc.Info.hasOperand(OT_WREG32_64, OT_WRM32_64)) {
+			idx = 1
+		}
+	case flag&IFLAG_MNEMONIC_VEXL_BASED != 0:
+		// vzeroupper and vzeroall
+		if dc.VexL != 0 {
+			idx = 1
+		}
+	default:
+		// Only REX.W selects the 3rd mnemonic, e.g. movd and movq
+		if dc.Rex&RexW != 0 {
+			idx = 2
+		}
+	}
+	if idx == 0 || ex[idx-1] == 0 {
+		return dc.Info.OpId
+	}
+	return ex[idx-1]
+}
+
+// Mandatory prefix, used as the first index into the escape opcode tables.
+const (
+	mandatoryNone = iota
+	mandatory66
+	mandatoryF3
+	mandatoryF2
+)
+
+// Prefixes which can be used as mandatory prefix, in the order they are
+// tried. F2 and F3 take precedence over 66, so 66 can still be used as
+// operand-size prefix for instructions like crc32 and popcnt.
+var mandatoryPrefixes = [...]struct {
+	prefix int
+	index  int
+	code   byte
+}{
+	{PrefixREPNZ, mandatoryF2, 0xf2},
+	{PrefixREPZ, mandatoryF3, 0xf3},
+	{PrefixOperandSize, mandatory66, 0x66},
+}
+
+// Find the instruction in an escape table. SSE instructions use 66, F2 or F3
+// prefix as part of the opcode. If an instruction is defined for the prefix,
+// the prefix is consumed, otherwise the prefix keeps its normal meaning.
+//
+// F2 and F3 without a definition are invalid if the opcode is selected by
+// mandatory prefix, e.g. f2 0f 7f is neither movq nor movdqu.
+func (dc *DisContext) lookupMandatoryPrefix(table *[4][256]InsnInfo, opcode byte) *InsnInfo {
+	for _, mp := range mandatoryPrefixes {
+		info := &table[mp.index][opcode]
+		if dc.Prefix&mp.prefix == 0 || info.OpId == 0 {
+			continue
+		}
+		// Group instructions are keyed with the mandatory prefix.
+		opcodeAll := dc.opcodeAll | int(mp.code)<<(8*uint(opcodeBytes(dc.opcodeAll)))
+		if dc.hasGroupInsn(info, opcodeAll) {
+			dc.Prefix &^= mp.prefix
+			dc.MandatoryPrefix = mp.code
+			dc.opcodeAll = opcodeAll
+			return info
+		}
+	}
+	if dc.Prefix&(PrefixREPZ|PrefixREPNZ) != 0 {
+		for _, mp := range mandatoryPrefixes {
+			// Prefixed groups only define some forms, others keep the prefix
+			// as rep, e.g. f2 0f c7 /1 is cmpxchg8b with repnz.
+			info := &table[mp.index][opcode]
+			if info.OpId != 0 && info.Flag&IFLAG_MODRM_INCLUDED == 0 {
+				panic(ErrUnknownOpcode)
+			}
+		}
+	}
+	return &table[mandatoryNone][opcode]
+}
+
+// Check if the ModR/M byte following the opcode selects an instruction in the
+// group keyed with opcodeAll. A group with mandatory prefix may define only
+// some ModR/M values, e.g. f3 0f 1e fa is endbr64, while f3 0f 1e 00 is nop
+// with repz prefix.
+func (dc *DisContext) hasGroupInsn(info *InsnInfo, opcodeAll int) bool {
+	if info.Flag&IFLAG_MODRM_INCLUDED == 0 {
+		return true
+	}
+	var buf [1]byte
+	if n, _ := dc.binary.ReadAt(buf[:], dc.offset); n != len(buf) {
+		// Let parseModRM report the read error.
+		return true
+	}
+	modrm := buf[0]
+	reg := modrm >> 3 & 7
+	if info.Flag&IFLAG_MODRR_BASED != 0 {
+		reg = byte(Btoi(modrm >= 0xc0))
+	} else if info.Flag&IFLAG_DIVIDED != 0 && modrm >= 0xc0 {
+		if _, ok := grpInsnInfoIndex[opcodeAll<<8+int(modrm)]; ok {
+			return true
+		}
+	}
+	_, ok := grpInsnInfoIndex[opcodeAll<<8+int(reg)]
+	return ok
+}
+
+// Byte value of each mandatory prefix index. Also used for the implied
+// prefix encoded in VEX.pp.
+var mandatoryPrefixCode = [...]byte{
+	mandatoryNone: 0,
+	mandatory66:   0x66,
+	mandatoryF3:   0xf3,
+	mandatoryF2:   0xf2,
+}
+
+// Opcode table and the implied escape opcode of a VEX or EVEX opcode map.
+type vexOpcodeMap struct {
+	table  *[4][256]InsnInfo
+	escape int
+}
+
+// VEX opcode maps, indexed by VEX.mmmmm.
+var vexTable = [...]vexOpcodeMap{
+	1: {&VexDB2, 0x0f},
+	2: {&VexDB0F38, 0x0f38},
+	3: {&VexDB0F3A, 0x0f3a},
+}
+
+// EVEX opcode maps, indexed by EVEX.mm.
+var evexTable = [...]vexOpcodeMap{
+	1: {&EvexDB2, 0x0f},
+	2: {&EvexDB0F38, 0x0f38},
+	3: {&EvexDB0F3A, 0x0f3a},
+}
+
+// C4, C5 and 62 are les, lds and bound outside 64-bit mode. They are used as
+// VEX or EVEX prefix only if the next byte has the two highest bits set,
+// which would be an invalid register ModR/M for les, lds and bound.
+func (dc *DisContext) isVex() bool {
+	if dc.Mode == Mode64 {
+		return true
+	}
+	b := dc.nextByte()
+	dc.putByte()
+	return b >= 0xc0
+}
+
+// Parse the 3-byte (C4) or 2-byte (C5) VEX prefix, look up the VEX opcode
+// table and return the opcode byte. Refer to Intel Manual 2A Section 2.3.
+func (dc *DisContext) parseVex(prefix byte) (opcode byte) {
+	// VEX with LOCK, 66, F2, F3 or REX prefix causes #UD.
+	if dc.Prefix&(PrefixLOCK|PrefixREPNZ|PrefixREPZ|PrefixOperandSize) != 0 || dc.Rex != 0 {
+		panic(ErrUnknownOpcode)
+	}
+	dc.Vex = prefix
+
+	// R, X and B are inverted, so is vvvv.
+	var rxb, mmmmm byte
+	b := dc.nextByte()
+	if prefix == 0xc5 {
+		// Byte 1: R vvvv L pp. Escape is always 0f.
+		rxb = ^b >> 5 & RexR
+		mmmmm = 1
+	} else {
+		// Byte 1: R X B mmmmm. Byte 2: W vvvv L pp.
+		rxb = ^b >> 5 & (RexR | RexX | RexB)
+		mmmmm = b & 0x1f
+		b = dc.nextByte()
+		dc.VexW = b&0x80 != 0
+	}
+	dc.Vvvv = ^b >> 3 & 0xf
+	dc.VexL = b >> 2 & 1
+	pp := b & 3
+	if dc.Mode == Mode64 {
+		dc.Rex = rxb
+	} else {
+		// Only 8 registers outside 64-bit mode, the high bit is ignored.
+		dc.Vvvv &= 7
+	}
+	return dc.lookupVexTable(vexTable[:], mmmmm, pp)
+}
+
+// Parse the 4-byte EVEX prefix (62), look up the EVEX opcode table and return
+// the opcode byte. Refer to Intel Manual 2A Section 2.6.
+func (dc *DisContext) parseEvex() (opcode byte) {
+	// Same as VEX, LOCK, 66, F2, F3 or REX prefix causes #UD.
+	if dc.Prefix&(PrefixLOCK|PrefixREPNZ|PrefixREPZ|PrefixOperandSize) != 0 || dc.Rex != 0 {
+		panic(ErrUnknownOpcode)
+	}
+	dc.Evex = 0x62
+
+	// P0: R X B R' 0 0 m m. P1: W vvvv 1 pp. P2: z L'L b V' aaa.
+	// R, X, B, R', vvvv and V' are inverted.
+	p0 := dc.nextByte()
+	p1 := dc.nextByte()
+	p2 := dc.nextByte()
+	if p0&0x0c != 0 || p1&0x04 == 0 {
+		panic(ErrUnknownOpcode)
+	}
+	dc.VexW = p1&0x80 != 0
+	dc.Vvvv = ^p1>>3&0xf | ^p2<<1&0x10
+	dc.Zeroing = p2&0x80 != 0
+	dc.VexL = p2 >> 5 & 3
+	dc.EvexB = p2&0x10 != 0
+	dc.Opmask = p2 & 7
+	if dc.Mode == Mode64 {
+		dc.Rex = ^p0 >> 5 & (RexR | RexX | RexB)
+		dc.evexR2 = p0&0x10 == 0
+	} else {
+		// Only 8 registers outside 64-bit mode, the high bits are ignored.
+		dc.Vvvv &= 7
+	}
+	return dc.lookupVexTable(evexTable[:], p0&3, p1&3)
+}
+
+// Read the opcode byte following VEX or EVEX prefix and look up the table of
+// the opcode map m. pp is the implied mandatory prefix.
+func (dc *DisContext) lookupVexTable(maps []vexOpcodeMap, m, pp byte) (opcode byte) {
+	if int(m) >= len(maps) || maps[m].table == nil {
+		panic(ErrUnknownOpcode)
+	}
+	vt := maps[m]
+	opcode = dc.nextByte()
+
+	// Group instructions are keyed with c4 (62 for EVEX), the implied
+	// mandatory prefix and escape opcode.
+	dc.MandatoryPrefix = mandatoryPrefixCode[pp]
+	dc.opcodeAll = 0xc4
+	if dc.Evex != 0 {
+		dc.opcodeAll = 0x62
+	}
+	if dc.MandatoryPrefix != 0 {
+		dc.opcodeAll = dc.opcodeAll<<8 + int(dc.MandatoryPrefix)
+	}
+	dc.opcodeAll = dc.opcodeAll<<(8*uint(opcodeBytes(vt.escape))) + vt.escape
+	dc.opcodeAll = dc.opcodeAll<<8 + int(opcode)
+	dc.Info = &vt.table[pp][opcode]
+	return
+}
+
+// Check if VEX.W, VEX.L and VEX.vvvv are allowed for the instruction. W and
+// L are not checked for instructions which ignore them (WIG and LIG in Intel
+// manual). vvvv must be 1111b (0 after inverted) if the instruction has no
+// operand encoded in it.
+func (dc *DisContext) checkVex() {
+	flag := dc.Info.Flag
+	if flag&IFLAG_FORCE_VEXL != 0 && dc.VexL == 0 {
+		panic(ErrUnknownOpcode)
+	}
+	// EVEX.W and EVEX.L'L are checked in checkEvex.
+	if dc.Vex != 0 && (flag&IFLAG_VEX_W0 != 0 && dc.VexW ||
+		flag&IFLAG_VEX_W1 != 0 && !dc.VexW ||
+		flag&IFLAG_VEX_L0 != 0 && dc.VexL != 0) {
+		panic(ErrUnknownOpcode)
+	}
+	if dc.Vvvv != 0 && (flag&IFLAG_VEX_V_UNUSED != 0 ||
+		!dc.Info.hasOperand(OT_VXMM, OT_VYXMM, OT_VYMM, OT_VK)) {
+		panic(ErrUnknownOpcode)
+	}
+}
+
+// Check if EVEX.b, EVEX.L'L and EVEX.z are allowed for the instruction, and
+// scale the compressed 8-bit displacement.
+func (dc *DisContext) checkEvex() {
+	flag := dc.Info.Flag
+	switch {
+	case dc.EvexB && dc.Mod == 3:
+		// L'L is the rounding control for register operands, vector length
+		// is 512-bit for packed instructions.
+		if flag&(IFLAG_EVEX_ER|IFLAG_EVEX_SAE) == 0 {
+			panic(ErrUnknownOpcode)
+		}
+		dc.Rounding = dc.VexL
+		dc.VexL = 2
+	case dc.EvexB && flag&IFLAG_EVEX_BCST == 0:
+		// Broadcast is only allowed for full vector memory operand
+		panic(ErrUnknownOpcode)
+	case dc.VexL == 3:
+		panic(ErrUnknownOpcode)
+	}
+	// EVEX.W is part of the opcode, it selects the element size.
+	if flag&IFLAG_VEX_W == 0 && dc.VexW != (flag&IFLAG_EVEX_W1 != 0) {
+		panic(ErrUnknownOpcode)
+	}
+	// Zeroing-masking is not allowed for memory destination, e.g. vmovaps.
+	if dc.Zeroing && dc.Mod != 3 && dc.Info.Operand[0] == OT_YXMM128_256 {
+		panic(ErrUnknownOpcode)
+	}
+	if dc.Mod != 3 && dc.DispSize == OpSizeByte {
+		dc.Disp *= int32(dc.disp8N())
+	}
+}
+
+// Size of a vector element in bytes, selected by EVEX.W.
+func (insn *Instruction) evexElemSize() int {
+	if insn.VexW {
+		return 8
+	}
+	return 4
+}
+
+// N in disp8*N. Refer to Intel Manual 2A Section 2.7.5. Full vector memory
+// operand uses the vector length, unless it's broadcast from one element.
+// Scalar memory operand uses its size, or the element size if it's selected
+// by EVEX.W, e.g. vfmadd132ss.
+func (insn *Instruction) disp8N() int {
+	switch {
+	case insn.EvexB:
+		return insn.evexElemSize()
+	case insn.Info.Flag&IFLAG_EVEX_T1S != 0:
+		for _, op := range insn.Info.Operand {
+			switch op {
+			case OT_XMM8, OT_XMM16, OT_XMM32, OT_XMM64:
+				return insn.memSize(op)
+			}
+		}
+		return insn.evexElemSize()
+	}
+	return 16 << insn.VexL
+}
+
+// Number of bytes in opcodeAll.
+func opcodeBytes(opcodeAll int) (n int) {
+	for ; opcodeAll != 0; opcodeAll >>= 8 {
+		n++
+	}
+	return
+}
+
+// Operand types which can only refer to memory.
+var memOnlyOperand = map[byte]bool{
+	OT_MEM:         true,
+	OT_FPUM16:      true,
+	OT_FPUM32:      true,
+	OT_FPUM64:      true,
+	OT_FPUM80:      true,
+	OT_MEM16_FULL:  true,
+	OT_MEM16_3264:  true,
+	OT_MEM32:       true,
+	OT_MEM32_64:    true,
+	OT_MEM64:       true,
+	OT_MEM128:      true,
+	OT_MEM64_128:   true,
+	OT_LMEM128_256: true,
+}
+
+// Check if the ModR/M byte is allowed for the instruction.
+func (dc *DisContext) checkModRM() {
+	if !dc.validReg() || !dc.validOpmask() {
+		panic(ErrInvalidModRM)
+	}
+	if dc.Mod != 3 {
+		if dc.Info.Flag&IFLAG_MODRR_REQUIRED != 0 {
+			// Only register operand is allowed, e.g. movmskps
+			panic(ErrInvalidModRM)
+		}
+		return
+	}
+	for _, op := range dc.Info.Operand {
+		if memOnlyOperand[op] {
+			panic(ErrInvalidModRM)
+		}
+	}
+}
+
+// Whether the reg field encodes a defined segment, control or debug
+// register. REX.R is ignored for segment registers.
+func (dc *DisContext) validReg() bool {
+	info, reg := dc.Info, dc.Reg|dc.rexBit(RexR)
+	switch {
+	case info.hasOperand(OT_SREG):
+		return dc.Reg <= GS
+	case info.hasOperand(OT_CREG):
+		return reg == Cr0 || (reg >= Cr2 && reg <= Cr4) || reg == Cr8
+	case info.hasOperand(OT_DREG):
+		return reg <= Dr7
+	}
+	return true
+}
+
+// Whether the opmask register operands are in k0-k7. Opmask registers are
+// not extended by REX.R, EVEX.R', the high bit of vvvv or REX.B.
+func (dc *DisContext) validOpmask() bool {
+	info := dc.Info
+	switch {
+	case info.hasOperand(OT_KREG) && (dc.Rex&RexR != 0 || dc.evexR2):
+		return false
+	case info.hasOperand(OT_VK) && dc.Vvvv >= 8:
+		return false
+	case info.hasOperand(OT_KRM) && dc.Mod == 3 && dc.Rex&RexB != 0:
+		return false
+	}
+	return true
 }
 
 func (dc *DisContext) parseOperand(opcode byte) {
@@ -305,78 +1115,212 @@ func (dc *DisContext) parseOperand(opcode byte) {
 			break
 		}
 
+		// Operands with fixed register, such as OT_ACC_FULL and
+		// OT_REGCL, are not recorded in the reg field, as the instruction
+		// may have another register operand. E.g. xchg (0x91).
 		switch byte(op) {
-		case OT_REGI_EDI:
-			dc.Reg = Edi
-		case OT_REGCL:
-			dc.Reg = Cl
-		case OT_ACC8, OT_ACC16, OT_ACC_FULL:
-			// debug.Println("parseOperand eax as reg")
-			dc.Reg = Eax
 		// Immediate value
-		case OT_IMM8, OT_IMM16, OT_IMM32:
+		case OT_IMM8, OT_IMM16, OT_IMM32, OT_IMM16_1, OT_IMM8_1:
 			// debug.Println("parseOperand read immediate")
 			dc.ImmOff = dc.readNBytes(ot2size[op])
+		// Second immediate, e.g. enter
+		case OT_IMM8_2:
+			dc.imm2 = dc.readNBytes(OpSizeByte)
+		// Far pointer, the offset is followed by 16-bit selector
+		case OT_PTR16_FULL:
+			dc.ImmOff = dc.readNBytes(dc.EffectiveOperandSize())
+			dc.imm2 = dc.readNBytes(OpSizeWord)
 		case OT_IMM_FULL:
 			// debug.Println("parseOperand read full immediate")
-			dc.ImmOff = dc.readNBytes(dc.EffectiveOperandSize())
+			dc.ImmOff = dc.readNBytes(dc.immFullSize())
 
 		// Instruction block (opcode) contains reg field
 		case OT_IB_R_FULL, OT_IB_RB:
 			// debug.Println("parseOperand instruction block contains reg field")
-			dc.Reg = opcode & 0x7
+			dc.Reg = opcode&0x7 | dc.rexBit(RexB)
 		case OT_SEG:
-			// debug.Println("parseOperand opcode lowest 3 bits contains reg field")
-			dc.Reg = opcode >> 3 & 0x03
+			// Bits 3-5 of the opcode contains the segment register, e.g.
+			// 0x1e is push %ds, 0x0f 0xa8 is push %gs
+			dc.Reg = opcode >> 3 & 0x07
 
-		case OT_MOFFS8, OT_MOFFS_FULL, // Memory offset. Only used by mov (0xa0 & 0xa2)
-			OT_RELC_FULL,  // Relative code offset
-			OT_MEM16_FULL: // call far and jmp far. Indirect target.
+		case OT_MOFFS8, OT_MOFFS_FULL: // Memory offset. Only used by mov (0xa0 & 0xa2)
 			// According to Intel Manual, the size of the offset is affected
 			// by address-size attribute. The size of the data is either
 			// determinied by the instruction itself or operand-size
 			// attribute.
-			// debug.Println("parseOperand moffset/recl/mem16_full")
+			// debug.Println("parseOperand moffset")
 			dc.ImmOff = dc.readNBytes(dc.EffectiveAddressSize())
 
 		// Relative code offset
+		case OT_RELC_FULL:
+			dc.ImmOff = dc.readNBytes(dc.relFullSize())
 		case OT_RELCB:
-			dc.ImmOff = int32(dc.nextByte())
+			dc.ImmOff = int64(int8(dc.nextByte()))
 			// debug.Printf("RECB: %#x\n", dc.ImmOff)
 
 		// sign-extended 8-bit immediate
 		case OT_SEIMM8:
-			dc.ImmOff = int32(int8(dc.nextByte()))
+			dc.ImmOff = int64(int8(dc.nextByte()))
+
+		// Register encoded in the high 4 bits of an 8-bit immediate, e.g.
+		// vblendvps
+		case OT_XMM_IMM, OT_YXMM_IMM:
+			dc.ImmOff = int64(dc.nextByte())
 		}
 	}
+	// Pseudo opcode instructions like cmpps use an 8-bit immediate to
+	// select the real instruction, which is not listed in the operands.
+	if dc.Info.Flag&IFLAG_PSEUDO_OPCODE != 0 {
+		dc.ImmOff = int64(dc.nextByte())
+	}
+}
+
+// Skip the bytes of operands following the opcode and ModR/M, without
+// decoding their values. Must consume the same bytes as parseOperand.
+func (dc *DisContext) skipOperand() {
+	n := 0
+	for _, op := range dc.Info.Operand {
+		switch op {
+		case OT_IMM8, OT_IMM16, OT_IMM32, OT_IMM16_1, OT_IMM8_1:
+			n += sizeInBytes[ot2size[op]]
+		case OT_IMM8_2:
+			n++
+		case OT_PTR16_FULL:
+			n += sizeInBytes[dc.EffectiveOperandSize()] + 2
+		case OT_IMM_FULL:
+			n += sizeInBytes[dc.immFullSize()]
+		case OT_MOFFS8, OT_MOFFS_FULL:
+			n += sizeInBytes[dc.EffectiveAddressSize()]
+		case OT_RELC_FULL:
+			n += sizeInBytes[dc.relFullSize()]
+		case OT_RELCB, OT_SEIMM8, OT_XMM_IMM, OT_YXMM_IMM:
+			n++
+		}
+	}
+	if dc.Info.Flag&IFLAG_PSEUDO_OPCODE != 0 {
+		n++
+	}
+	dc.skipBytes(n)
+}
+
+// Size of OT_IMM_FULL immediate. Immediate is at most 32-bit and
+// sign-extended in 64-bit mode, except mov r64, imm64.
+func (dc *DisContext) immFullSize() byte {
+	size := dc.EffectiveOperandSize()
+	if size == OpSizeQuad && !dc.isMovImm64() {
+		size = OpSizeLong
+	}
+	return size
+}
+
+// Size of relative code offset. The size is determined by operand-size
+// attribute, and is always 32-bit in 64-bit mode.
+func (dc *DisContext) relFullSize() byte {
+	size := dc.EffectiveOperandSize()
+	if size == OpSizeQuad {
+		size = OpSizeLong
+	}
+	return size
+}
+
+// mov (0xb8+r) is the only instruction with 64-bit immediate.
+func (dc *DisContext) isMovImm64() bool {
+	return dc.opcodeAll >= 0xb8 && dc.opcodeAll <= 0xbf
+}
+
+// Return the REX bit shifted to the 4th bit, used to extend 3-bit register
+// field.
+func (dc *DisContext) rexBit(bit byte) byte {
+	if dc.Rex&bit != 0 {
+		return 8
+	}
+	return 0
+}
+
+// Extend the ModR/M and SIB register fields with REX.R, REX.X and REX.B.
+func (dc *DisContext) applyRex() {
+	if dc.Rex == 0 {
+		return
+	}
+	if !dc.Info.hasOperand(OT_SREG) {
+		// There are only 6 segment registers, REX.R is ignored.
+		dc.Reg |= dc.rexBit(RexR)
+	}
+	if dc.Scale != 0 {
+		dc.Index |= dc.rexBit(RexX)
+		dc.Base |= dc.rexBit(RexB)
+	} else if !dc.IsRipRelative() {
+		dc.Rm |= dc.rexBit(RexB)
+	}
+}
+
+// Extend the reg field with EVEX.R', and the rm field with EVEX.X for
+// register operand, so they can refer to all 32 vector registers. EVEX.X is
+// ignored for general purpose and opmask registers, e.g. vpbroadcastd %eax.
+func (dc *DisContext) applyEvex() {
+	if dc.evexR2 {
+		dc.Reg |= 16
+	}
+	if dc.Mod == 3 && !dc.Info.hasOperand(OT_RM32, OT_WRM32_64, OT_KRM) {
+		dc.Rm |= dc.rexBit(RexX) << 1
+	}
+}
+
+// Whether the memory operand is addressed relative to the next instruction.
+// Only in 64-bit mode, when mod is 0 and rm is 5.
+func (insn *Instruction) IsRipRelative() bool {
+	return insn.Mode == Mode64 && insn.Info != nil &&
+		insn.Info.Flag&IFLAG_MODRM_REQUIRED != 0 &&
+		insn.Mod == 0 && insn.Rm&7 == 5
 }
 
 /* Reading binary */
 
-var readBuf = [...][]byte{
-	nil,
-	make([]byte, 1),
-	make([]byte, 2),
-	make([]byte, 4),
+// Error returned by the underlying reader, including io.EOF. It's wrapped
+// when panicking so decode can tell it from other errors.
+type readError struct {
+	err error
 }
 
-// Size can only be OpSizeByte/Word/Long
-func (dc *DisContext) readNBytes(size byte) (val int32) {
-	n, err := dc.binary.ReadAt(readBuf[size], dc.offset)
-	if err != nil {
-		panic(err)
+// Fill buf with the bytes at off. io.ReaderAt may return io.EOF along with
+// all the bytes at the end of input, so only a short read is an error.
+func (dc *DisContext) readAt(buf []byte, off int64) {
+	n, err := dc.binary.ReadAt(buf, off)
+	if n < len(buf) {
+		if err == nil {
+			err = io.EOF
+		}
+		panic(readError{err})
 	}
+}
+
+// Number of bytes of each operand size
+var sizeInBytes = [...]int{
+	OpSizeByte: 1,
+	OpSizeWord: 2,
+	OpSizeLong: 4,
+	OpSizeQuad: 8,
+}
+
+// Size can only be OpSizeByte/Word/Long/Quad. The value is sign-extended.
+func (dc *DisContext) readNBytes(size byte) (val int64) {
+	// Use the buffer in DisContext so different DisContext can be used
+	// concurrently.
+	buf := dc.readBuf[:sizeInBytes[size]]
+	dc.readAt(buf, dc.offset)
 
 	switch size {
 	case OpSizeByte:
-		val = int32(readBuf[size][0])
+		val = int64(int8(buf[0]))
 	case OpSizeWord:
-		val = int32(binary.LittleEndian.Uint16(readBuf[size]))
+		val = int64(int16(binary.LittleEndian.Uint16(buf)))
 	case OpSizeLong:
-		val = int32(binary.LittleEndian.Uint32(readBuf[size]))
+		val = int64(int32(binary.LittleEndian.Uint32(buf)))
+	case OpSizeQuad:
+		val = int64(binary.LittleEndian.Uint64(buf))
 	}
 
-	dc.offset += int64(n)
+	dc.offset += int64(len(buf))
 	return
 }
 
@@ -390,7 +1334,16 @@ func (dc *DisContext) nextWord() int16 {
 }
 
 func (dc *DisContext) nextLong() int32 {
-	return dc.readNBytes(OpSizeLong)
+	return int32(dc.readNBytes(OpSizeLong))
+}
+
+// Skip n bytes. Only the last byte is read to make sure they are available.
+func (dc *DisContext) skipBytes(n int) {
+	if n == 0 {
+		return
+	}
+	dc.readAt(dc.readBuf[:1], dc.offset+int64(n)-1)
+	dc.offset += int64(n)
 }
 
 // Put back the previously read byte
@@ -414,12 +1367,20 @@ func parseBitField(b byte) (mod, reg, rm byte) {
 
 func (dc *DisContext) parseModRM() {
 	dc.Mod, dc.Reg, dc.Rm = parseBitField(dc.nextByte())
-	// XXX Is the addressing mode of ModR/M byte affected by the address-size
-	// attribute?
-	switch dc.AddressSize {
+	if dc.Info.hasOperand(OT_CREG, OT_DREG) {
+		// mov to/from control and debug registers ignores mod, it's always
+		// the register form.
+		dc.Mod = 3
+		return
+	}
+	// The addressing form of ModR/M byte is determined by the address-size
+	// attribute. Refer to Intel Manual 2A Section 2.1.5. 64-bit addressing
+	// uses the same form as 32-bit, with the rm and SIB fields extended by
+	// REX prefix.
+	switch dc.EffectiveAddressSize() {
 	case OpSizeWord:
 		dc.parseAfterModRM16bit()
-	case OpSizeLong:
+	case OpSizeLong, OpSizeQuad:
 		dc.parseAfterModRM32bit()
 	default:
 		log.Fatalln("Address-size error")
@@ -475,7 +1436,7 @@ func (dc *DisContext) getDisp(size byte) {
 
 // Get memory offset
 func (dc *DisContext) getMOffset() {
-	dc.ImmOff = int32(dc.readNBytes(dc.EffectiveAddressSize()))
+	dc.ImmOff = dc.readNBytes(dc.EffectiveAddressSize())
 }
 
 func init() {
@@ -22,10 +22,25 @@ class InstructionDB():
 		# Opcode id starts from 1, so we can easily identify whether the
 		# instruction is in the DB.
 		self.insn_opid = 1
-		# Hold opcode information, (opcode, opcode length, opcodeid, flags, [4 operand])
+		# Hold opcode information, (opcode, opcode length, opcodeid, flags, [4 operand], mandatory prefix)
 		self.insn_info = []
+		# VEX encoded instructions, same format as insn_info
+		self.vex_insn_info = []
+		# EVEX encoded instructions, same format as insn_info
+		self.evex_insn_info = []
 		self.opid_name = None
 		self.grp_insn_info = {}
+		# Alternative mnemonics, { opcodeid : [opcodeid, opcodeid] }
+		self.ex_mnemonic = {}
+		# Flow control kind of branch instructions, { opcodeid : "FlowCall" }
+		self.flow_control = {}
+
+	def add_mnemonics(self, mnemonics):
+		""" Allocate opcode id for mnemonics not in x86sets.py. """
+		for mn in mnemonics:
+			if mn.lower() not in self.name_opid:
+				self.name_opid[mn.lower()] = self.insn_opid
+				self.insn_opid += 1
 
 	def post_process(self):
 		self.opid_name = [ (opid, name) for (name, opid) in self.name_opid.iteritems() ]
@@ -43,7 +58,7 @@ var InsnName = [...]string{
 		# We need to specify iota for the first opcode id
 		l = ( "\tInsn_%s\t// %#04x\n" % (name.capitalize().replace(' ', '_'), opid)  for opid, name in self.opid_name[1:] )
 		return """const (
-	Insn_%s byte = iota+1\t// 0x01
+	Insn_%s uint16 = iota+1\t// 0x01
 %s)
 """ % (self.opid_name[0][1].capitalize().replace(' ', '_'), ''.join(l))
 
@@ -89,6 +104,16 @@ var InsnName = [...]string{
 	IFLAG_FORCE_VEXL
 	IFLAG_MODRR_BASED
 	IFLAG_VEX_V_UNUSED
+	IFLAG_PRE_EVEX
+	IFLAG_EVEX_BCST
+	IFLAG_EVEX_T1S
+	IFLAG_EVEX_ER
+	IFLAG_EVEX_SAE
+	IFLAG_DIVIDED
+	IFLAG_EVEX_W1
+	IFLAG_VEX_W0
+	IFLAG_VEX_W1
+	IFLAG_VEX_L0
 )
 """
 
@@ -106,17 +131,55 @@ var InsnName = [...]string{
 		key = self.pos2key(list(reversed(pos + [reg])))
 		self.grp_insn_info[key] = info
 
+	def addExMnemonic(self, opcodeid, mnemonics):
+		ex = [self.name_opid.get(mn, 0) for mn in (mnemonics + ["", ""])[1:3]]
+		if self.ex_mnemonic.get(opcodeid, ex) != ex:
+			raise DBException("Different alternative mnemonics for %s" % mnemonics[0])
+		self.ex_mnemonic[opcodeid] = ex
+
 	def SetInstruction(self, *args):
 		""" This function is used in order to insert an instruction info into the DB. """
 		mnemonics = [a.lower() for a in args[2]]
 		flags = args[4]
 		operands = args[3]
 
+		# Register form of divided instructions which use the rm field as
+		# operand, e.g. "d9 //c0" is fld st(0) ... fld st(7). Generate the
+		# instruction for all the 8 ModR/M bytes.
+		if flags & InstFlag.GEN_BLOCK and args[1].find("//") != -1:
+			modrm = int(args[1][-2:], 16)
+			for i in xrange(8):
+				self.SetInstruction(args[0], "%s%02x" % (args[1][:-2], modrm + i),
+					args[2], operands, flags & ~InstFlag.GEN_BLOCK)
+			return
+
+		# The real mnemonic of pseudo opcode instruction depends on the
+		# immediate operand, e.g. cmpeqps. Use the concatenated mnemonic, e.g.
+		# cmpps, as opcode id. If W selects the mnemonic, e.g. vpcmpd and
+		# vpcmpq, the mnemonics are listed in full.
+		if flags & InstFlag.PSEUDO_OPCODE and not flags & InstFlag.MNEMONIC_VEXW_BASED:
+			mnemonics = [''.join(mnemonics)]
+			flags &= ~InstFlag.USE_EXMNEMONIC
+
+		# Mnemonics selected by ModR/M are different instructions, e.g.
+		# mfence and xsaveopt. Set the flag if any of them writes.
+		if [mn for mn in mnemonics if isDestinationWritten(args[0], mn, operands)]:
+			flags |= InstFlag.DST_WR
+
 		# *args = ISetClass, OL, pos, mnemonics, operands, flags
 		# Construct an Instruction Info object with the info given in args.
 		opcode = args[1].replace(" ", "").split(",")
 		# The number of bytes is the base length, now we need to check the last entry.
 		pos = [int(i[:2], 16) for i in opcode]
+		fullpos = pos
+
+		# 66, f2 and f3 are part of the opcode for SSE instructions. Remove
+		# the prefix, the instruction is put into the table for that prefix.
+		prefix = MANDATORY_NONE
+		if len(pos) > 2 and pos[1] == 0x0f and pos[0] in MANDATORY_PREFIX:
+			prefix = MANDATORY_PREFIX[pos[0]]
+			opcode = opcode[1:]
+			pos = pos[1:]
 
 		# if len(self.insn_info):
 		# 	print >>sys.stderr, pos, self.insn_info[-1][0]
@@ -127,15 +190,25 @@ var InsnName = [...]string{
 				self.name_opid[mn] = self.insn_opid
 				self.insn_opid += 1
 
+		for mn in mnemonics:
+			if mn in FLOW_CONTROL:
+				self.flow_control[self.name_opid[mn]] = FLOW_CONTROL[mn]
+
 		# Use the first mnemonics id if mnemonics is modrm based
 		opcodeid = self.name_opid[mnemonics[0]]
+		if flags & (InstFlag.USE_EXMNEMONIC | InstFlag.USE_EXMNEMONIC2 | InstFlag.MNEMONIC_MODRM_BASED |
+			InstFlag.MNEMONIC_VEXW_BASED | InstFlag.MNEMONIC_VEXL_BASED):
+			self.addExMnemonic(opcodeid, mnemonics)
 
 		last = opcode[-1][2:] # Skip hex of last full byte
 		isModRMIncluded = False # Indicates whether 3 bits of the REG field in the ModRM byte were used.
 		if last[:2] == "//": # Divided Instruction
 			reg = int(last[2:], 16)
-			assert reg < 0xff
+			assert reg <= 0xff
 			isModRMIncluded = True
+			# Values less than 0xc0 are the reg field of memory form, others
+			# are the whole ModR/M byte of register form.
+			flags |= InstFlag.DIVIDED | InstFlag.MODRM_REQUIRED
 			try:
 				OL = {1:OpcodeLength.OL_1d, 2:OpcodeLength.OL_2d}[len(opcode)]
 			except KeyError:
@@ -157,19 +230,54 @@ var InsnName = [...]string{
 			except KeyError:
 				raise DBException("Invalid normal instruction opcode")
 
-		insninfo = [pos, OL, opcodeid, flags, operands]
+		# Instructions which use different operands for register and memory
+		# ModR/M, such as vmovss. Use 1 for the register form, 0 for the
+		# memory form as the reg part of the group key.
+		if flags & InstFlag.MODRR_BASED:
+			isModRMIncluded = True
+			reg = 1
+			if [op for op in operands if op in MEM_OPERANDS]:
+				reg = 0
+
+		insninfo = [pos, OL, opcodeid, flags, operands, prefix]
+
+		# VEX and EVEX encoded instructions are stored in separate tables. Use
+		# c4 or 62 as the first byte of the group key.
+		insn_info = self.insn_info
+		if flags & InstFlag.PRE_VEX:
+			insn_info = self.vex_insn_info
+			fullpos = [0xc4] + fullpos
+		elif flags & InstFlag.PRE_EVEX:
+			insn_info = self.evex_insn_info
+			fullpos = [0x62] + fullpos
 
 		# Store the instruction info in the grp insntruction specific map
+		# The mandatory prefix is part of the key.
 		if isModRMIncluded:
 			insninfo[3] |= InstFlag.MODRM_INCLUDED
-			self.addToGrpInsnInfo(pos, reg, insninfo)
+			# Some register forms are listed again without operand after
+			# the generated block, e.g. "d9 //c9" fxch. Keep the one with
+			# operand, which is what objdump shows.
+			if flags & InstFlag.DIVIDED and reg >= 0xc0 and \
+				self.pos2key(list(reversed(fullpos + [reg]))) in self.grp_insn_info:
+				return
+			self.addToGrpInsnInfo(fullpos, reg, insninfo)
+			# fwait (9b) followed by a x87 instruction is decoded as one
+			# instruction, only the group map is needed.
+			if fullpos[0] == 0x9b:
+				return
 
 		# In case handling instruction with modrm included, we still need to
 		# add (only one) InsnInfo in the 1st and 2nd InsnDB, so we know we
 		# need to look up in the grpInsnInfo map to get the actual InsnInfo.
-		if len(self.insn_info) > 0 and self.insn_info[-1][0] == pos:
+		# Group entries of VEX instructions are not listed together, so search
+		# all the previous entries.
+		if isModRMIncluded and [i for i in insn_info if i[0] == pos and i[5] == prefix]:
+			return
+		if len(insn_info) > 0 and insn_info[-1][0] == pos and \
+			insn_info[-1][5] == prefix:
 			return
-		self.insn_info.append(insninfo)
+		insn_info.append(insninfo)
 
 		# Generate all opcode for instructions which use the lowest 3 bits are reg field
 		# Ugly code here ...
@@ -177,9 +285,9 @@ var InsnName = [...]string{
 			if (op == OperandType.IB_RB) or (op == OperandType.IB_R_FULL):
 				for i in xrange(1, 8):
 					if len(opcode) == 1:
-						self.insn_info.append(([pos[0] + i] + pos[1:], OL, opcodeid, flags, operands))
+						self.insn_info.append(([pos[0] + i] + pos[1:], OL, opcodeid, flags, operands, prefix))
 					elif len(opcode) == 2:
-						self.insn_info.append(([pos[0], pos[1] + i] + pos[2:], OL, opcodeid, flags, operands))
+						self.insn_info.append(([pos[0], pos[1] + i] + pos[2:], OL, opcodeid, flags, operands, prefix))
 				break
 
 	OPERAND_TYPE = """const (
@@ -276,6 +384,10 @@ var InsnName = [...]string{
 	OT_YXMM128_256
 	OT_LXMM64_128
 	OT_LMEM128_256
+	OT_XMM8
+	OT_KREG
+	OT_VK
+	OT_KRM
 )
 """
 
@@ -305,29 +417,74 @@ var InsnName = [...]string{
 	def dump_1insn(self, pos, opcodeid, flag, operand):
 		return '%#04x: InsnInfo{ %#04x, %#x, [4]byte{%s} },\n' % (pos, opcodeid, flag, ', '.join('%d' % i for i in operand))
 
-	def dump_insninfo(self):
+	def dump_prefixed_table(self, insn_lists):
+		tables = []
+		for (prefix, name) in enumerate(MANDATORY_PREFIX_NAME):
+			if len(insn_lists[prefix]) == 0:
+				continue
+			tables.append("%s: {\n\t\t%s\t},\n" % (name, '\t\t'.join(insn_lists[prefix])))
+		return '\t'.join(tables)
+
+	def split_insninfo(self, insn_info):
 		insn_list = [] # table for the 1st byte of instruction
-		insn_list2 = [] # table for the 2nd byte of instruction
-		for (pos, OL, opcodeid, flag, operand) in self.insn_info:
+		# Escape tables are indexed by mandatory prefix first
+		insn_list2 = [[] for _ in MANDATORY_PREFIX_NAME] # 0f xx
+		insn_list38 = [[] for _ in MANDATORY_PREFIX_NAME] # 0f 38 xx
+		insn_list3a = [[] for _ in MANDATORY_PREFIX_NAME] # 0f 3a xx
+		for (pos, OL, opcodeid, flag, operand, prefix) in insn_info:
 			if OL in (OpcodeLength.OL_1, OpcodeLength.OL_13, OpcodeLength.OL_1d):
 				insn_list.append(self.dump_1insn(pos[0], opcodeid, flag, operand))
 			elif OL in (OpcodeLength.OL_2, OpcodeLength.OL_23, OpcodeLength.OL_2d):
-				insn_list2.append(self.dump_1insn(pos[1], opcodeid, flag, operand))
+				insn_list2[prefix].append(self.dump_1insn(pos[1], opcodeid, flag, operand))
+			elif OL == OpcodeLength.OL_3 and pos[:2] == [0x0f, 0x38]:
+				insn_list38[prefix].append(self.dump_1insn(pos[2], opcodeid, flag, operand))
+			elif OL == OpcodeLength.OL_3 and pos[:2] == [0x0f, 0x3a]:
+				insn_list3a[prefix].append(self.dump_1insn(pos[2], opcodeid, flag, operand))
 			else:
-				print pos
-				raise DBException("Does not support instruction longer than 2 bytes")
-				# s = '\t%#04x: InsnInfo{ %#04x, %#x, [4]byte{%s} },\n' % (pos[3], opcodeid, flag, ', '.join(['%d' % i for i in operand]))
-				# insn_list2.append(s)
+				print >>sys.stderr, pos
+				raise DBException("Only support 0f, 0f 38 and 0f 3a escape opcode")
+		return (insn_list, insn_list2, insn_list38, insn_list3a)
 
+	def dump_insninfo(self):
+		(insn_list, insn_list2, insn_list38, insn_list3a) = self.split_insninfo(self.insn_info)
 		dump = """// Opcode to instruction info map.
 // Table for the 1st byte of instruction
-var InsnDB = [...]InsnInfo{
+var InsnDB = [256]InsnInfo{
+	%s}
+
+// Table for the 2nd byte of instruction, escaped by 0f
+var InsnDB2 = [4][256]InsnInfo{
+	%s}
+
+// Table for the 3rd byte of instruction, escaped by 0f 38
+var InsnDB0F38 = [4][256]InsnInfo{
 	%s}
 
-// Table for the 2nd byte of instruction
-var InsnDB2 = [...]InsnInfo{
+// Table for the 3rd byte of instruction, escaped by 0f 3a
+var InsnDB0F3A = [4][256]InsnInfo{
 	%s}
-""" % ('\t'.join(insn_list), '\t'.join(insn_list2))
+""" % ('\t'.join(insn_list), self.dump_prefixed_table(insn_list2),
+			self.dump_prefixed_table(insn_list38), self.dump_prefixed_table(insn_list3a))
+		return dump
+
+	def dump_vex_insninfo(self, vex, insn_info):
+		(insn_list, insn_list2, insn_list38, insn_list3a) = self.split_insninfo(insn_info)
+		if len(insn_list) != 0:
+			raise DBException("%s instruction must use escape opcode" % vex)
+		name = vex.capitalize()
+		dump = """// Tables for %s encoded instructions, selected by the opcode map field.
+// Indexed by %s.pp and the opcode byte.
+var %sDB2 = [4][256]InsnInfo{
+	%s}
+
+var %sDB0F38 = [4][256]InsnInfo{
+	%s}
+
+var %sDB0F3A = [4][256]InsnInfo{
+	%s}
+""" % (vex, vex, name, self.dump_prefixed_table(insn_list2),
+			name, self.dump_prefixed_table(insn_list38),
+			name, self.dump_prefixed_table(insn_list3a))
 		return dump
 
 	def dump_grp_insninfo(self):
@@ -339,7 +496,7 @@ var InsnDB2 = [...]InsnInfo{
 		insninfo_arr.sort()
 		idx = []
 		infos = []
-		for (i, (key, (pos, _, opcodeid, flag, operand))) in enumerate(insninfo_arr):
+		for (i, (key, (pos, _, opcodeid, flag, operand, _))) in enumerate(insninfo_arr):
 			if key >= 0xf0000:
 				idx.append("\t%#08x: %d,\n" % (key, i))
 			else:
@@ -353,6 +510,26 @@ var grpInsnInfo = [...]InsnInfo{
 %s}""" % (''.join(idx), ''.join(infos))
 		return dump
 
+	def dump_ex_mnemonic(self):
+		names = dict(self.opid_name)
+		l = [ "\t%#04x: {%#04x, %#04x}, // %s\n" % (opid, ex[0], ex[1], names[opid]) for (opid, ex) in sorted(self.ex_mnemonic.iteritems()) ]
+		dump = """// Alternative mnemonics of instructions with IFLAG_USE_EXMNEMONIC or
+// IFLAG_USE_EXMNEMONIC2, indexed by the opcode id of the first mnemonic.
+var exMnemonic = map[uint16][2]uint16{
+%s}
+""" % ''.join(l)
+		return dump
+
+	def dump_flow_control(self):
+		names = dict(self.opid_name)
+		l = [ "\tInsn_%s: %s,\n" % (names[opid].capitalize().replace(' ', '_'), flow) for (opid, flow) in sorted(self.flow_control.iteritems()) ]
+		dump = """// Flow control kind of instructions, indexed by opcode id. Instructions not
+// listed here don't change control flow.
+var flowControlOfInsn = map[uint16]FlowControl{
+%s}
+""" % ''.join(l)
+		return dump
+
 	def dump(self):
 		self.post_process()
 		print 'package dis\n'
@@ -361,12 +538,100 @@ var grpInsnInfo = [...]InsnInfo{
 		print self.dump_ot2size()
 		print self.dump_opcodeid()
 		print self.dump_insn_name()
+		print self.dump_ex_mnemonic()
+		print self.dump_flow_control()
 		print self.dump_insninfo()
+		print self.dump_vex_insninfo("VEX", self.vex_insn_info)
+		print self.dump_vex_insninfo("EVEX", self.evex_insn_info)
 		print self.dump_grp_insninfo()
 
+# Mandatory prefix of SSE instructions. The index must match the mandatory*
+# constants in dis.go.
+MANDATORY_NONE = 0
+MANDATORY_PREFIX = {0x66: 1, 0xf3: 2, 0xf2: 3}
+MANDATORY_PREFIX_NAME = ["mandatoryNone", "mandatory66", "mandatoryF3", "mandatoryF2"]
+
+# Operand types which can only refer to memory
+MEM_OPERANDS = (OperandType.MEM, OperandType.MEM32, OperandType.MEM32_64, OperandType.MEM64,
+	OperandType.MEM128, OperandType.MEM64_128, OperandType.LMEM128_256)
+
+# Operand types which are never written
+READ_ONLY_OPERANDS = (OperandType.IMM8, OperandType.IMM16, OperandType.IMM_FULL, OperandType.IMM32,
+	OperandType.SEIMM8, OperandType.IMM16_1, OperandType.IMM8_1, OperandType.IMM8_2,
+	OperandType.PTR16_FULL, OperandType.RELCB, OperandType.RELC_FULL, OperandType.CONST1,
+	OperandType.REGDX, OperandType.REGI_EBXAL)
+
+# Integer and x87 instructions which write the first operand. This extends
+# CheckWritableDestinationOperand in disOps.py.
+DST_WR_PREFIXES = ("mov", "set", "cmov", "cmpxchg", "fcmov", "fst", "fnst", "fist", "fbstp",
+	"fsave", "fnsave", "fxsave", "xsave")
+DST_WR_MNEMONICS = set([
+	"add", "or", "adc", "sbb", "and", "sub", "xor", "inc", "dec", "lea", "xchg",
+	"rol", "ror", "rcl", "rcr", "shl", "shr", "sal", "sar", "shld", "shrd",
+	"neg", "not", "imul", "pop", "btr", "bts", "btc", "xadd", "bswap",
+	"lzcnt", "popcnt", "crc32", "smsw", "sldt", "str", "sgdt", "sidt", "arpl",
+	"lar", "lsl", "lds", "les", "lfs", "lgs", "lss", "bsf", "bsr", "in", "ins",
+	"stos", "lods", "stmxcsr",
+])
+
+# x87 arithmetic instructions, which write the first operand only if it's a
+# register. The destination of the memory form is the implicit st(0).
+FPU_ARITH_MNEMONICS = set([
+	"fadd", "fmul", "fsub", "fsubr", "fdiv", "fdivr", "faddp", "fmulp", "fsubp",
+	"fsubrp", "fdivp", "fdivrp", "fxch",
+])
+FPU_REG_OPERANDS = (OperandType.FPU_SI, OperandType.FPU_SSI, OperandType.FPU_SIS)
+
+# Vector instructions which only read the first operand.
+DST_RD_MNEMONICS = set([
+	"comiss", "ucomiss", "comisd", "ucomisd", "vcomiss", "vucomiss", "vcomisd", "vucomisd",
+	"ptest", "vptest", "vtestps", "vtestpd", "maskmovq", "maskmovdqu", "vmaskmovdqu",
+	"pcmpestri", "pcmpestrm", "pcmpistri", "pcmpistrm", "vpcmpestri", "vpcmpestrm",
+	"vpcmpistri", "vpcmpistrm", "ldmxcsr", "vldmxcsr", "prefetchnta", "prefetcht0",
+	"prefetcht1", "prefetcht2", "kortestb", "kortestw", "kortestd", "kortestq", "ktestb",
+	"ktestw", "ktestd", "ktestq",
+])
+
+def isDestinationWritten(isetClass, mnemonic, operands):
+	""" Whether the first operand is written. It's set as DST_WR in the flags. """
+	if len(operands) == 0 or operands[0] in READ_ONLY_OPERANDS:
+		return False
+	if isetClass in (ISetClass.INTEGER, ISetClass.FPU, ISetClass.P6):
+		# imul with one operand writes edx:eax only
+		if mnemonic == "imul" and len(operands) == 1:
+			return False
+		if mnemonic in FPU_ARITH_MNEMONICS:
+			return operands[0] in FPU_REG_OPERANDS
+		return mnemonic.startswith(DST_WR_PREFIXES) or mnemonic in DST_WR_MNEMONICS
+	return mnemonic not in DST_RD_MNEMONICS
+
+# Flow control kind of instructions, the Go constant is used as value. Same
+# as CheckForFlowControl in disOps.py, except that far jmp and hlt have their
+# own kinds, and cmov is not considered as flow control.
+FLOW_CONTROL = {}
+for (mnemonics, flow) in [
+	(["int", "int1", "int 3", "into", "ud2"], "FlowInterrupt"),
+	(["call", "call far"], "FlowCall"),
+	(["ret", "iret", "retf"], "FlowReturn"),
+	(["syscall", "sysenter", "sysret", "sysexit"], "FlowSys"),
+	(["jmp"], "FlowBranch"),
+	(["jmp far"], "FlowFarBranch"),
+	(["jcxz", "jecxz", "jrcxz", "jo", "jno", "jb", "jae", "jz", "jnz", "jbe", "ja", "js", "jns",
+		"jp", "jnp", "jl", "jge", "jle", "jg", "loop", "loopz", "loopnz"], "FlowCondBranch"),
+	(["hlt"], "FlowHalt"),
+]:
+	for mn in mnemonics:
+		FLOW_CONTROL[mn] = flow
+
+# Instructions decoded by special code in Go, which need an opcode id.
+# MOVSXD shares the opcode 0x63 with ARPL, it's only valid in 64-bit mode.
+# F3 90 is pause, which is decoded together with nop.
+EXTRA_MNEMONICS = ["MOVSXD", "PAUSE"]
+
 def main():
 	db = InstructionDB()
 	x86sets.Instructions(db.SetInstruction)
+	db.add_mnemonics(EXTRA_MNEMONICS)
 
 	db.dump()
 
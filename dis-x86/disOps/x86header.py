@@ -101,7 +101,12 @@ class OperandType:
 	YXMM64_256,
 	YXMM128_256,
 	LXMM64_128,
-	LMEM128_256) = range(93)
+	LMEM128_256,
+	# Below new for AVX-512:
+	XMM8,
+	KREG,
+	VK,
+	KRM) = range(97)
 
 class OpcodeLength:
 	""" The length of the opcode in bytes.
@@ -176,8 +181,18 @@ class InstFlag:
 	FORCE_VEXL,             # 36
 	MODRR_BASED,            # 37
 	VEX_V_UNUSED,           # 38
-	GEN_BLOCK               # 39
-	) = [1 << i for i in xrange(40)]
+	PRE_EVEX,               # 39 From here on: EVEX, not in diStorm.
+	EVEX_BCST,              # 40
+	EVEX_T1S,               # 41
+	EVEX_ER,                # 42
+	EVEX_SAE,               # 43
+	DIVIDED,                # 44
+	EVEX_W1,                # 45
+	VEX_W0,                 # 46
+	VEX_W1,                 # 47
+	VEX_L0,                 # 48
+	GEN_BLOCK               # 49
+	) = [1 << i for i in xrange(50)]
 	# Nodes are extended if they have any of the following flags:
 	EXTENDED = (PRE_VEX | USE_EXMNEMONIC | USE_EXMNEMONIC2 | USE_OP3 | USE_OP4)
 	SEGMENTS = (PRE_CS | PRE_SS | PRE_DS | PRE_ES | PRE_FS | PRE_FS)
@@ -203,7 +218,9 @@ class ISetClass:
 	AVX,
 	FMA,
 	CLMUL,
-	AES) = range(1, 20)
+	AES,
+	AVX2,
+	AVX512) = range(1, 22)
 
 class FlowControl:
 	""" The flow control instruction will be flagged in the lo nibble of the 'meta' field in _InstInfo of diStorm.
@@ -2,10 +2,15 @@ package dis
 
 import (
 	"bufio"
+	"bytes"
 	"debug/elf"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -36,12 +41,10 @@ func (buf SliceReader) ReadAt(p []byte, off int64) (n int, err error) {
 	return i, nil
 }
 
-func (dc *DisContext) dumpInsnBinary() (dump string) {
-	bin := dc.binary.(SliceReader)
-	for i := dc.insnStart; i < dc.offset; i++ {
-		dump += fmt.Sprintf("%02x ", bin[i])
-	}
-	return
+type readerFunc func(p []byte, off int64) (int, error)
+
+func (f readerFunc) ReadAt(p []byte, off int64) (int, error) {
+	return f(p, off)
 }
 
 func checkDump1(dc *DisContext, expected string, t *testing.T) bool {
@@ -51,28 +54,35 @@ func checkDump1(dc *DisContext, expected string, t *testing.T) bool {
 	dump := dc.DumpInsn()
 	if dump != expected {
 		t.Logf("\nbinary: %s\nexpect: %s\nget:    %s\n",
-			dc.dumpInsnBinary(), expected, dump)
+			fmt.Sprintf("% x", dc.Bytes()), expected, dump)
 		return false
 	}
 	return true
 }
 
-func checkDump(dc *DisContext, expected string, t *testing.T) {
-	if dc == nil {
-		if expected != "" {
-			t.Fatal("EOF not handled correctly")
-		}
-	} else if !checkDump1(dc, expected, t) {
+func checkDump(dc *DisContext, err error, expected string, t *testing.T) {
+	if err != nil {
+		t.Fatal("decode error:", err)
+	}
+	if !checkDump1(dc, expected, t) {
 		t.FailNow()
 	}
 }
 
 func testDump(testdata []codeText, t *testing.T) {
+	testDumpMode(testdata, Mode32, t)
+}
+
+func testDumpMode(testdata []codeText, mode Mode, t *testing.T) {
 	dc := NewDisContext(codeTextArr2ReaderAt(testdata))
+	dc.SetMode(mode)
 	for _, ct := range testdata {
-		checkDump(dc.NextInsn(), ct.assembly, t)
+		insn, err := dc.NextInsn()
+		checkDump(insn, err, ct.assembly, t)
+	}
+	if _, err := dc.NextInsn(); err != io.EOF {
+		t.Fatal("EOF not handled correctly")
 	}
-	checkDump(dc.NextInsn(), "", t)
 }
 
 func TestPrefixParse(t *testing.T) {
@@ -112,6 +122,17 @@ func TestPrefixParse(t *testing.T) {
 		{[]byte{0x64, 0x8b, 0x35, 0x40, 0xce, 0x2f, 0xc0}, "mov %fs:0xc02fce40,%esi"},
 		{[]byte{0xf0, 0x83, 0x04, 0x24, 0x00}, "lock addl $0x0,(%esp)"},
 		{[]byte{0xf3, 0xab}, "rep stos %eax,%es:(%edi)"},
+		// F2 is repnz, or bnd for near branches. F3 is repz except for string
+		// instructions not testing ZF.
+		{[]byte{0xf2, 0xa4}, "repnz movsb %ds:(%esi),%es:(%edi)"},
+		{[]byte{0xf0, 0xf3, 0xa4}, "lock rep movsb %ds:(%esi),%es:(%edi)"},
+		{[]byte{0xf3, 0xc3}, "repz ret "},
+		{[]byte{0xf2, 0xc3}, "bnd ret "},
+		{[]byte{0xf2, 0xe9, 0x00, 0x00, 0x00, 0x00}, "bnd jmp 0x1d"},
+		{[]byte{0xf2, 0xff, 0xe0}, "bnd jmp *%eax"},
+		{[]byte{0xf2, 0x74, 0x00}, "bnd jz 0x23"},
+		{[]byte{0xf2, 0xe2, 0xfe}, "repnz loop 0x24"},
+		{[]byte{0xf2, 0x9a, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00}, "repnz lcall $0x10,$0x0"},
 	}
 	testDump(testdata, t)
 }
@@ -130,6 +151,49 @@ func TestArith(t *testing.T) {
 	testDump(testdata, t)
 }
 
+func TestShift(t *testing.T) {
+	testdata := []codeText{
+		{[]byte{0xd1, 0xe0}, "shl %eax"},
+		{[]byte{0xd0, 0xe0}, "shl %al"},
+		{[]byte{0x66, 0xd1, 0xf8}, "sar %ax"},
+		{[]byte{0xd1, 0x25, 0x00, 0x00, 0x00, 0x00}, "shll 0x0"},
+		{[]byte{0xc0, 0x20, 0x02}, "shlb $0x2,(%eax)"},
+		{[]byte{0xd3, 0xe0}, "shl %cl,%eax"},
+		{[]byte{0xd3, 0x38}, "sarl %cl,(%eax)"},
+	}
+	testDump(testdata, t)
+}
+
+// String instructions show the implicit memory operands.
+func TestString(t *testing.T) {
+	testdata := []codeText{
+		{[]byte{0xa6}, "cmpsb %es:(%edi),%ds:(%esi)"},
+		{[]byte{0x66, 0xa7}, "cmpsw %es:(%edi),%ds:(%esi)"},
+		{[]byte{0xf3, 0xa6}, "repz cmpsb %es:(%edi),%ds:(%esi)"},
+		{[]byte{0xf2, 0xae}, "repnz scas %es:(%edi),%al"},
+		{[]byte{0xac}, "lods %ds:(%esi),%al"},
+		{[]byte{0x2e, 0xad}, "lods %cs:(%esi),%eax"},
+		{[]byte{0x67, 0xac}, "lods %ds:(%si),%al"},
+		{[]byte{0x6c}, "insb (%dx),%es:(%edi)"},
+		{[]byte{0x66, 0x6f}, "outsw %ds:(%esi),(%dx)"},
+		{[]byte{0x26, 0xa4}, "movsb %es:(%esi),%es:(%edi)"},
+		{[]byte{0xd7}, "xlat %ds:(%ebx)"},
+	}
+	testDump(testdata, t)
+
+	testdata = []codeText{
+		{[]byte{0xf2, 0xae}, "repnz scas %es:(%rdi),%al"},
+		{[]byte{0x48, 0xa7}, "cmpsq %es:(%rdi),%ds:(%rsi)"},
+		{[]byte{0x48, 0xad}, "lods %ds:(%rsi),%rax"},
+		{[]byte{0x2e, 0xac}, "lods %ds:(%rsi),%al"},
+		{[]byte{0x64, 0xac}, "lods %fs:(%rsi),%al"},
+		{[]byte{0x67, 0xae}, "scas %es:(%edi),%al"},
+		{[]byte{0x48, 0x6d}, "insl (%dx),%es:(%rdi)"},
+		{[]byte{0x6e}, "outsb %ds:(%rsi),(%dx)"},
+	}
+	testDumpMode(testdata, Mode64, t)
+}
+
 func TestIncDec(t *testing.T) {
 	testdata := []codeText{
 		codeText{[]byte{0x40}, "inc %eax"},
@@ -148,6 +212,8 @@ func TestPushPop(t *testing.T) {
 		{[]byte{0x16}, "push %ss"},
 		{[]byte{0x1e}, "push %ds"},
 		{[]byte{0x1f}, "pop %ds"},
+		{[]byte{0x0f, 0xa0}, "push %fs"},
+		{[]byte{0x0f, 0xa9}, "pop %gs"},
 	}
 	testDump(testdata, t)
 }
@@ -156,6 +222,8 @@ func TestMov(t *testing.T) {
 	testdata := []codeText{
 		{[]byte{0x0f, 0x21, 0xca}, "mov %db1,%edx"},
 		{[]byte{0x0f, 0x20, 0xc2}, "mov %cr0,%edx"},
+		{[]byte{0x0f, 0x22, 0x1c}, "mov %esp,%cr3"}, // mod is ignored
+		{[]byte{0x0f, 0x21, 0x45}, "mov %db0,%ebp"},
 		{[]byte{0x66, 0x8c, 0xd0}, "mov %ss,%ax"},
 		{[]byte{0x64, 0xa1, 0x40, 0xce, 0x2f, 0xc0}, "mov %fs:0xc02fce40,%eax"},
 		{[]byte{0x8b, 0x1d, 0xa8, 0x6b, 0x25, 0xc0}, "mov 0xc0256ba8,%ebx"},
@@ -183,11 +251,53 @@ func TestTest(t *testing.T) {
 
 func TestJcc(t *testing.T) {
 	testdata := []codeText{
-		{[]byte{0x75, 0x16}, "jnz "},
+		{[]byte{0x75, 0x16}, "jnz 0x18"},
 	}
 	testDump(testdata, t)
 }
 
+func TestBranchTarget(t *testing.T) {
+	testdata := []codeText{
+		{[]byte{0x75, 0x16}, "jnz 0xc0100018"},
+		{[]byte{0xe8, 0x52, 0x9e, 0x0f, 0x00}, "call 0xc01f9e59"},
+		// Target is truncated to 16-bit
+		{[]byte{0x66, 0xe9, 0x00, 0x10}, "jmpw 0x100b"},
+		{[]byte{0x66, 0xe8, 0x00, 0x10}, "callw 0x100f"},
+		{[]byte{0xeb, 0xfe}, "jmp 0xc010000f"},
+		{[]byte{0xe2, 0xfe}, "loop 0xc0100011"},
+		{[]byte{0xe3, 0x00}, "jecxz 0xc0100015"},
+		{[]byte{0x0f, 0x84, 0xf0, 0xff, 0xff, 0xff}, "jz 0xc010000b"},
+	}
+	dc := NewDisContext(codeTextArr2ReaderAt(testdata))
+	dc.BaseAddr = 0xc0100000
+	for _, ct := range testdata {
+		insn, err := dc.NextInsn()
+		checkDump(insn, err, ct.assembly, t)
+	}
+
+	testdata = []codeText{
+		{[]byte{0x75, 0x16}, "jnz 0xffffffff81000018"},
+		{[]byte{0xe8, 0x52, 0x9e, 0x0f, 0x00}, "call 0xffffffff810f9e59"},
+		{[]byte{0x0f, 0x85, 0x00, 0x00, 0x00, 0x80}, "jnz 0xffffffff0100000d"},
+		{[]byte{0xe3, 0x00}, "jrcxz 0xffffffff8100000f"},
+	}
+	dc = NewDisContext(codeTextArr2ReaderAt(testdata))
+	dc.SetMode(Mode64)
+	dc.BaseAddr = 0xffffffff81000000
+	for _, ct := range testdata {
+		insn, err := dc.NextInsn()
+		checkDump(insn, err, ct.assembly, t)
+	}
+
+	insn, err := Decode([]byte{0xeb, 0x10}, Mode32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if insn.Addr != 0 || insn.Target != 0x12 {
+		t.Errorf("jmp address %#x target %#x, should be 0 and 0x12", insn.Addr, insn.Target)
+	}
+}
+
 func TestLgdt(t *testing.T) {
 	testdata := []codeText{
 		{[]byte{0x0f, 0x01, 0x15, 0xd2, 0xcd, 0x2b, 0x00}, "lgdtl 0x2bcdd2"},
@@ -202,29 +312,492 @@ func TestLea(t *testing.T) {
 	testDump(testdata, t)
 }
 
+// objdump shows eiz or riz for SIB without index, unless the SIB is needed
+// for esp, rsp or r12 base with scale 1, or absolute address in 64-bit mode.
+func TestPseudoIndex(t *testing.T) {
+	testdata := []codeText{
+		{[]byte{0x8d, 0x44, 0x24, 0x40}, "lea 0x40(%esp),%eax"},
+		{[]byte{0x8d, 0x74, 0x26, 0x00}, "lea 0x0(%esi,%eiz,1),%esi"},
+		{[]byte{0x8b, 0x04, 0x20}, "mov (%eax,%eiz,1),%eax"},
+		{[]byte{0x8b, 0x04, 0xa4}, "mov (%esp,%eiz,4),%eax"},
+		{[]byte{0x8b, 0x04, 0x25, 0x00, 0x00, 0x00, 0x00}, "mov 0x0(,%eiz,1),%eax"},
+	}
+	testDump(testdata, t)
+
+	testdata = []codeText{
+		{[]byte{0x48, 0x8d, 0x44, 0x24, 0x40}, "lea 0x40(%rsp),%rax"},
+		{[]byte{0x41, 0x8b, 0x04, 0x24}, "mov (%r12),%eax"},
+		{[]byte{0x41, 0x8b, 0x04, 0xa4}, "mov (%r12,%riz,4),%eax"},
+		{[]byte{0x42, 0x8b, 0x04, 0x24}, "mov (%rsp,%r12,1),%eax"},
+		{[]byte{0x8d, 0x44, 0x20, 0x40}, "lea 0x40(%rax,%riz,1),%eax"},
+		{[]byte{0x8b, 0x04, 0x25, 0x00, 0x00, 0x00, 0x00}, "mov 0x0,%eax"},
+		{[]byte{0x8b, 0x04, 0x65, 0x00, 0x00, 0x00, 0x00}, "mov 0x0(,%riz,2),%eax"},
+		{[]byte{0x67, 0x8b, 0x04, 0x25, 0x00, 0x00, 0x00, 0x00}, "mov 0x0(,%eiz,1),%eax"},
+	}
+	testDumpMode(testdata, Mode64, t)
+}
+
 func TestNop(t *testing.T) {
 	testdata := []codeText{
 		{[]byte{0x90}, "nop "},
 		{[]byte{0x66, 0x90}, "xchg %ax,%ax"},
+		{[]byte{0x0f, 0x1f, 0x44, 0x00, 0x00}, "nopl 0x0(%eax,%eax,1)"},
+		{[]byte{0x66, 0x0f, 0x1f, 0x00}, "nopw (%eax)"},
+		{[]byte{0x0f, 0x19, 0x00}, "nopl (%eax)"},
+		{[]byte{0x0f, 0x1d, 0x04, 0x88}, "nopl (%eax,%ecx,4)"},
+		{[]byte{0x0f, 0x1e, 0xfa}, "nop %edx"},
+		{[]byte{0x66, 0x0f, 0x1e, 0xc0}, "nop %ax"},
+		{[]byte{0xf3, 0x0f, 0x1e, 0xfb}, "endbr32 "},
+		{[]byte{0xf3, 0x0f, 0x1e, 0x00}, "repz nopl (%eax)"},
+		{[]byte{0xf2, 0x0f, 0x1e, 0xfa}, "repnz nop %edx"},
 	}
 	testDump(testdata, t)
+
+	testdata = []codeText{
+		{[]byte{0xf3, 0x0f, 0x1e, 0xfa}, "endbr64 "},
+		{[]byte{0x0f, 0x1e, 0x00}, "nopl (%rax)"},
+		{[]byte{0xf2, 0x0f, 0xc7, 0x0e}, "repnz cmpxchg8b (%rsi)"},
+	}
+	testDumpMode(testdata, Mode64, t)
 }
 
 func TestCall(t *testing.T) {
 	testdata := []codeText{
-		{[]byte{0xe8, 0x52, 0x9e, 0x0f, 0x00}, "call "},
+		{[]byte{0xe8, 0x52, 0x9e, 0x0f, 0x00}, "call 0xf9e57"},
 		{[]byte{0xff, 0x15, 0x5c, 0xb7, 0x30, 0xc0}, "call *0xc030b75c"},
 	}
 	testDump(testdata, t)
 }
 
+func TestFarAndEnter(t *testing.T) {
+	testdata := []codeText{
+		{[]byte{0xea, 0x78, 0x56, 0x34, 0x12, 0x10, 0x00}, "ljmp $0x10,$0x12345678"},
+		{[]byte{0x9a, 0x78, 0x56, 0x34, 0x12, 0x10, 0x00}, "lcall $0x10,$0x12345678"},
+		{[]byte{0x66, 0xea, 0x78, 0x56, 0x10, 0x00}, "ljmpw $0x10,$0x5678"},
+		{[]byte{0xff, 0x28}, "ljmp *(%eax)"},
+		{[]byte{0x66, 0xff, 0x18}, "lcallw *(%eax)"},
+		{[]byte{0xff, 0xe0}, "jmp *%eax"},
+		{[]byte{0x66, 0xff, 0x20}, "jmpw *(%eax)"},
+		{[]byte{0xc8, 0x10, 0x00, 0x01}, "enter $0x10,$0x1"},
+		{[]byte{0x66, 0xc8, 0x10, 0x00, 0x01}, "enterw $0x10,$0x1"},
+	}
+	testDump(testdata, t)
+}
+
 func TestMisc(t *testing.T) {
 	testdata := []codeText{
 		{[]byte{0x0f, 0x0b}, "ud2 "},
+		{[]byte{0x0f, 0x37}, "getsec "},
 	}
 	testDump(testdata, t)
 }
 
+func TestLongMode(t *testing.T) {
+	testdata := []codeText{
+		{[]byte{0x55}, "push %rbp"},
+		{[]byte{0x48, 0x89, 0xe5}, "mov %rsp,%rbp"},
+		{[]byte{0x41, 0x54}, "push %r12"},
+		{[]byte{0x41, 0x5f}, "pop %r15"},
+		{[]byte{0x48, 0x83, 0xec, 0x10}, "sub $0x10,%rsp"},
+		{[]byte{0x48, 0x83, 0xe0, 0xf0}, "and $0xfffffffffffffff0,%rax"},
+		{[]byte{0x45, 0x31, 0xc0}, "xor %r8d,%r8d"},
+		{[]byte{0x66, 0x89, 0xc8}, "mov %cx,%ax"},
+		{[]byte{0x66, 0x41, 0x89, 0xc8}, "mov %cx,%r8w"},
+		{[]byte{0x40, 0x88, 0xc6}, "mov %al,%sil"},
+		{[]byte{0x88, 0xe0}, "mov %ah,%al"},
+		{[]byte{0x44, 0x88, 0xc0}, "mov %r8b,%al"},
+		{[]byte{0xb8, 0x01, 0x00, 0x00, 0x00}, "mov $0x1,%eax"},
+		{[]byte{0x41, 0xb9, 0x01, 0x00, 0x00, 0x00}, "mov $0x1,%r9d"},
+		{[]byte{0x48, 0xb8, 0x88, 0x77, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11}, "movabs $0x1122334455667788,%rax"},
+		{[]byte{0x48, 0xc7, 0xc0, 0xff, 0xff, 0xff, 0xff}, "mov $0xffffffffffffffff,%rax"},
+		{[]byte{0x48, 0xa1, 0x88, 0x77, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11}, "movabs 0x1122334455667788,%rax"},
+		{[]byte{0x48, 0x8b, 0x05, 0x10, 0x00, 0x00, 0x00}, "mov 0x10(%rip),%rax"},
+		{[]byte{0x4c, 0x8d, 0x1d, 0xf0, 0xff, 0xff, 0xff}, "lea -0x10(%rip),%r11"},
+		{[]byte{0x41, 0x8b, 0x45, 0x00}, "mov 0x0(%r13),%eax"},
+		{[]byte{0x42, 0x8b, 0x04, 0x88}, "mov (%rax,%r9,4),%eax"},
+		{[]byte{0x4d, 0x8b, 0x44, 0x24, 0x08}, "mov 0x8(%r12),%r8"},
+		{[]byte{0x64, 0x48, 0x8b, 0x04, 0x25, 0x28, 0x00, 0x00, 0x00}, "mov %fs:0x28,%rax"},
+		{[]byte{0x67, 0x8b, 0x03}, "mov (%ebx),%eax"},
+		{[]byte{0x48, 0x63, 0xc8}, "movslq %eax,%rcx"},
+		{[]byte{0x0f, 0x20, 0xc0}, "mov %cr0,%rax"},
+		{[]byte{0x44, 0x0f, 0x20, 0xc0}, "mov %cr8,%rax"},
+		{[]byte{0x44, 0x8c, 0xd8}, "mov %ds,%eax"}, // REX.R is ignored for segment register
+		{[]byte{0x4d, 0x8c, 0x26}, "mov %fs,(%r14)"},
+		{[]byte{0x44, 0x8e, 0xc2}, "mov %edx,%es"},
+		{[]byte{0x4c, 0x0f, 0xb6, 0x39}, "movzbq (%rcx),%r15"},
+		{[]byte{0x48, 0x0f, 0xbf, 0xc1}, "movswq %cx,%rax"},
+		{[]byte{0x66, 0x0f, 0xbe, 0x00}, "movsbw (%rax),%ax"},
+		{[]byte{0x66, 0x0f, 0xb7, 0xc1}, "movzww %cx,%ax"},
+		{[]byte{0x41, 0xff, 0xd3}, "call *%r11"},
+		{[]byte{0x48, 0xc7, 0x00, 0x01, 0x00, 0x00, 0x00}, "movq $0x1,(%rax)"},
+		{[]byte{0xc7, 0x00, 0x01, 0x00, 0x00, 0x00}, "movl $0x1,(%rax)"},
+		{[]byte{0xf3, 0x48, 0xab}, "rep stos %rax,%es:(%rdi)"},
+		{[]byte{0xf3, 0xa4}, "rep movsb %ds:(%rsi),%es:(%rdi)"},
+		{[]byte{0x41, 0x90}, "xchg %eax,%r8d"},
+		{[]byte{0x90}, "nop "},
+		{[]byte{0xc3}, "ret "},
+	}
+	testDumpMode(testdata, Mode64, t)
+
+	// Opcodes invalid in 64-bit mode
+	invalid := [][]byte{
+		{0x06},             // push %es
+		{0x27},             // daa
+		{0x60},             // pusha
+		{0x82, 0xc0, 0x01}, // add
+		{0xce},             // into
+		{0xd4, 0x0a},       // aam
+	}
+	for _, b := range invalid {
+		if _, err := Decode(b, Mode64); !errors.Is(err, ErrUnknownOpcode) {
+			t.Errorf("% x should be invalid in 64-bit mode, got %v", b, err)
+		}
+	}
+
+	// REX not immediately preceding the opcode is ignored
+	insn, err := Decode([]byte{0x48, 0x66, 0x89, 0xc8}, Mode64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dump := insn.DumpInsn(); dump != "mov %cx,%ax" {
+		t.Error("REX before legacy prefix not ignored:", dump)
+	}
+	// inc/dec in 32-bit mode
+	insn, err = Decode([]byte{0x48}, Mode32)
+	if err != nil || insn.DumpInsn() != "dec %eax" {
+		t.Error("0x48 should be dec in 32-bit mode:", err)
+	}
+}
+
+func TestThreeByteOpcode(t *testing.T) {
+	testdata := []codeText{
+		{[]byte{0x0f, 0x38, 0xf0, 0x00}, "movbe (%eax),%eax"},
+		{[]byte{0x0f, 0x38, 0xf1, 0x08}, "movbe %ecx,(%eax)"},
+		{[]byte{0xf2, 0x0f, 0x38, 0xf1, 0xc1}, "crc32 %ecx,%eax"},
+		{[]byte{0xf2, 0x0f, 0x38, 0xf0, 0xc1}, "crc32 %cl,%eax"},
+		{[]byte{0x66, 0xf2, 0x0f, 0x38, 0xf1, 0xc1}, "crc32 %cx,%eax"},
+		{[]byte{0xf2, 0x0f, 0x38, 0xf1, 0x00}, "crc32l (%eax),%eax"},
+		{[]byte{0xf3, 0x0f, 0xb8, 0xc1}, "popcnt %ecx,%eax"},
+		{[]byte{0x66, 0xf3, 0x0f, 0xb8, 0xc1}, "popcnt %cx,%ax"},
+		{[]byte{0xf3, 0x0f, 0xbd, 0xc1}, "lzcnt %ecx,%eax"},
+		{[]byte{0xf3, 0x0f, 0xbc, 0xc1}, "tzcnt %ecx,%eax"},
+	}
+	testDump(testdata, t)
+
+	testdata = []codeText{
+		{[]byte{0xf2, 0x48, 0x0f, 0x38, 0xf1, 0xc1}, "crc32 %rcx,%rax"},
+	}
+	testDumpMode(testdata, Mode64, t)
+
+	// SSE operands are not dumped yet, check the opcode only
+	sse := []struct {
+		code      []byte
+		mode      Mode
+		mnemonic  string
+		mandatory byte
+	}{
+		{[]byte{0x0f, 0x38, 0x00, 0xc1}, Mode32, "pshufb", 0},
+		{[]byte{0x66, 0x0f, 0x38, 0x00, 0xc1}, Mode32, "pshufb", 0x66},
+		{[]byte{0x66, 0x0f, 0x38, 0x10, 0xc1}, Mode32, "pblendvb", 0x66},
+		{[]byte{0x66, 0x0f, 0x3a, 0x0f, 0xc1, 0x05}, Mode32, "palignr", 0x66},
+		{[]byte{0x0f, 0x3a, 0x0f, 0x44, 0x24, 0x08, 0x05}, Mode32, "palignr", 0},
+		{[]byte{0x66, 0x0f, 0x38, 0x2a, 0x04, 0x85, 0x00, 0x10, 0x00, 0x00}, Mode32, "movntdqa", 0x66},
+		{[]byte{0x66, 0x0f, 0x3a, 0x63, 0x05, 0x10, 0x00, 0x00, 0x00, 0x0c}, Mode64, "pcmpistri", 0x66},
+		{[]byte{0x66, 0x45, 0x0f, 0x38, 0x17, 0xc1}, Mode64, "ptest", 0x66},
+	}
+	for _, c := range sse {
+		insn, err := Decode(c.code, c.mode)
+		if err != nil {
+			t.Errorf("% x: %v", c.code, err)
+			continue
+		}
+		if name := InsnName[insn.OpId]; name != c.mnemonic {
+			t.Errorf("% x: got %s, should be %s", c.code, name, c.mnemonic)
+		}
+		if insn.Length != len(c.code) {
+			t.Errorf("% x: length %d, should be %d", c.code, insn.Length, len(c.code))
+		}
+		if insn.MandatoryPrefix != c.mandatory || insn.Prefix != 0 {
+			t.Errorf("% x: mandatory prefix %#x prefix %#x", c.code, insn.MandatoryPrefix, insn.Prefix)
+		}
+	}
+
+	// Movntdqa only accepts memory operand
+	if _, err := Decode([]byte{0x66, 0x0f, 0x38, 0x2a, 0xc1}, Mode32); !errors.Is(err, ErrInvalidModRM) {
+		t.Error("movntdqa with register operand should be invalid, got", err)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	code := []byte{0x03, 0x45, 0x08, 0x89, 0xd8}
+
+	first, err := Decode(code, Mode32)
+	if err != nil {
+		t.Fatal("decode error:", err)
+	}
+	second, err := Decode(code[first.Length:], Mode32)
+	if err != nil {
+		t.Fatal("decode error:", err)
+	}
+	// Modifying the input should not affect decoded instructions
+	code[0] = 0x90
+
+	if first.Length != 3 || !bytes.Equal(first.Raw, []byte{0x03, 0x45, 0x08}) {
+		t.Errorf("first instruction length %d raw % x", first.Length, first.Raw)
+	}
+	if dump := first.DumpInsn(); dump != "add 0x8(%ebp),%eax" {
+		t.Error("first instruction dump:", dump)
+	}
+	if second.Length != 2 || !bytes.Equal(second.Raw, []byte{0x89, 0xd8}) {
+		t.Errorf("second instruction length %d raw % x", second.Length, second.Raw)
+	}
+	if dump := second.DumpInsn(); dump != "mov %ebx,%eax" {
+		t.Error("second instruction dump:", dump)
+	}
+
+	insn, err := Decode([]byte{0x89, 0xd8}, Mode16)
+	if err != nil {
+		t.Fatal("decode error:", err)
+	}
+	if dump := insn.DumpInsn(); dump != "mov %bx,%ax" {
+		t.Error("16-bit mode dump:", dump)
+	}
+
+	if _, err = Decode([]byte{0x03}, Mode32); !errors.Is(err, ErrTruncated) {
+		t.Error("truncated instruction should return ErrTruncated, got:", err)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	tooLong := make([]byte, 16)
+	for i := range tooLong {
+		tooLong[i] = 0x66
+	}
+	testdata := []struct {
+		binary []byte
+		err    error
+		nbyte  int // Bytes consumed when the error is detected
+	}{
+		{[]byte{0x0f, 0x04}, ErrUnknownOpcode, 2},
+		{[]byte{0x0f, 0xff}, ErrUnknownOpcode, 2},
+		{[]byte{0xfe, 0x10}, ErrUnknownOpcode, 2}, // 0xfe /2 is not defined
+		{[]byte{0x8d, 0xc0}, ErrInvalidModRM, 2},  // lea with register operand
+		{[]byte{0x0f, 0x01, 0xd0}, ErrInvalidModRM, 3},
+		{[]byte{0x8e, 0xf8}, ErrInvalidModRM, 2}, // Segment register 7
+		{[]byte{0x8c, 0xf8}, ErrInvalidModRM, 2},
+		{[]byte{0x0f, 0x20, 0xe8}, ErrInvalidModRM, 3}, // cr5
+		{[]byte{0x0f, 0x22, 0xfe}, ErrInvalidModRM, 3},
+		{[]byte{0x0f, 0x20, 0x2c}, ErrInvalidModRM, 3},
+		{[]byte{0x03, 0x45}, ErrTruncated, 2},
+		{[]byte{0x66, 0x05, 0x01}, ErrTruncated, 2},
+		{tooLong, ErrTooLong, 15},
+	}
+	for _, td := range testdata {
+		_, err := Decode(td.binary, Mode32)
+		de, ok := err.(*DecodeError)
+		if !ok {
+			t.Errorf("% x: expect *DecodeError, got %v", td.binary, err)
+			continue
+		}
+		if !errors.Is(err, td.err) {
+			t.Errorf("% x: expect %v, got %v", td.binary, td.err, de.Err)
+		}
+		if de.Offset != 0 || !bytes.Equal(de.Bytes, td.binary[:td.nbyte]) {
+			t.Errorf("% x: error offset %d bytes % x", td.binary, de.Offset, de.Bytes)
+		}
+	}
+
+	if _, err := Decode([]byte{0x8e, 0xbe, 0xa8, 0x9c}, Mode16); !errors.Is(err, ErrInvalidModRM) {
+		t.Error("mov to segment register 7 should be invalid, got", err)
+	}
+
+	// Errors from the reader are returned as is, bugs are not hidden.
+	errReader := errors.New("read failed")
+	dc := NewDisContext(readerFunc(func(p []byte, off int64) (int, error) {
+		return 0, errReader
+	}))
+	if _, err := dc.NextInsn(); err != errReader {
+		t.Error("reader error not returned, got", err)
+	}
+	func() {
+		defer func() {
+			if _, ok := recover().(runtime.Error); !ok {
+				t.Error("runtime error in the reader should not be recovered")
+			}
+		}()
+		dc = NewDisContext(readerFunc(func(p []byte, off int64) (int, error) {
+			return copy(p, []byte(nil)[off+1:]), nil
+		}))
+		dc.NextInsn()
+	}()
+
+	// io.EOF along with all the bytes at the end of input is not an error
+	code := []byte{0x05, 0x01, 0x02, 0x03, 0x04}
+	dc = NewDisContext(readerFunc(func(p []byte, off int64) (int, error) {
+		if off >= int64(len(code)) {
+			return 0, io.EOF
+		}
+		n := copy(p, code[off:])
+		if off+int64(n) == int64(len(code)) {
+			return n, io.EOF
+		}
+		return n, nil
+	}))
+	insn, err := dc.NextInsn()
+	checkDump(insn, err, "add $0x4030201,%eax", t)
+	if _, err := dc.NextInsn(); err != io.EOF {
+		t.Error("should return io.EOF after the last instruction, got", err)
+	}
+
+	// Decoding should continue from the next byte after an error.
+	dc = NewDisContext(SliceReader([]byte{0x90, 0xfe, 0x38, 0x40, 0x05}))
+	expected := []string{"nop ", "", "cmp %al,0x5(%eax)"}
+	for i, exp := range expected {
+		insn, err := dc.NextInsn()
+		if exp == "" {
+			if err == nil {
+				t.Fatalf("instruction %d should fail to decode", i)
+			}
+			continue
+		}
+		checkDump(insn, err, exp, t)
+	}
+	if _, err := dc.NextInsn(); err != io.EOF {
+		t.Fatal("EOF not handled correctly")
+	}
+}
+
+// Run with -race to detect data race in the decoder.
+// Length-only decoding should agree with full decoding, including errors.
+func TestDecodeLen(t *testing.T) {
+	// Opcode escapes and prefixes followed by every opcode byte, then some
+	// ModR/M bytes covering SIB and displacement.
+	leading := [][]byte{
+		{}, {0x66}, {0x67}, {0xf3}, {0x48}, {0x0f}, {0x66, 0x0f}, {0xf2, 0x0f},
+		{0x0f, 0x38}, {0x0f, 0x3a}, {0x66, 0x0f, 0x3a},
+		{0xc5, 0xf9}, {0xc4, 0xe3, 0x79}, {0x62, 0xf1, 0x7c, 0x48}, {0x9b},
+	}
+	modrm := []byte{0x00, 0x04, 0x05, 0x06, 0x44, 0x46, 0x84, 0x86, 0xc0, 0xd8, 0xe8, 0xf8}
+	tail := []byte{0x25, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa}
+	for _, mode := range []Mode{Mode16, Mode32, Mode64} {
+		for _, lead := range leading {
+			for op := 0; op < 256; op++ {
+				for _, m := range modrm {
+					code := append(append(append([]byte{}, lead...), byte(op), m), tail...)
+					insn, err := Decode(code, mode)
+					n, lenErr := DecodeLen(code, mode)
+					if (err == nil) != (lenErr == nil) || (err == nil && n != insn.Length) {
+						t.Fatalf("mode %d % x: length %d error %v, should be %d %v",
+							mode, code, n, lenErr, insn.Length, err)
+					}
+				}
+			}
+		}
+	}
+
+	// Truncated immediate is detected without reading it
+	if _, err := DecodeLen([]byte{0xb8, 0x01, 0x02}, Mode32); !errors.Is(err, ErrTruncated) {
+		t.Error("truncated instruction should return ErrTruncated, got:", err)
+	}
+
+	// Scan instruction boundaries, decoding continues after an error
+	code := []byte{0x55, 0x89, 0xe5, 0xfe, 0x38, 0x40, 0x05, 0x48, 0xb8, 1, 2, 3, 4, 5, 6, 7, 8, 0xc3}
+	dc := NewDisContext(SliceReader(code))
+	dc.SetMode(Mode64)
+	expected := []int{1, 2, 0, 3, 10, 1}
+	for i, exp := range expected {
+		n, err := dc.NextLen()
+		if exp == 0 {
+			if err == nil {
+				t.Fatalf("instruction %d should fail to decode", i)
+			}
+			continue
+		}
+		if err != nil || n != exp {
+			t.Fatalf("instruction %d: length %d error %v, should be %d", i, n, err, exp)
+		}
+	}
+	if _, err := dc.NextLen(); err != io.EOF {
+		t.Error("should return io.EOF at the end, got:", err)
+	}
+}
+
+func TestInsnAccessors(t *testing.T) {
+	// Works with any io.ReaderAt
+	dc := NewDisContext(strings.NewReader("\x90\x03\x45\x08"))
+	dc.BaseAddr = 0xc0100000
+	dc.NextInsn()
+	insn, err := dc.NextInsn()
+	if err != nil {
+		t.Fatal("decode error:", err)
+	}
+	if insn.Len() != 3 || !bytes.Equal(insn.Bytes(), []byte{0x03, 0x45, 0x08}) ||
+		insn.Address() != 0xc0100001 {
+		t.Errorf("length %d bytes % x address %#x", insn.Len(), insn.Bytes(), insn.Address())
+	}
+}
+
+func TestConcurrentDecode(t *testing.T) {
+	testdata := []codeText{
+		{[]byte{0x64, 0x8b, 0x35, 0x40, 0xce, 0x2f, 0xc0}, "mov %fs:0xc02fce40,%esi"},
+		{[]byte{0x03, 0x04, 0x8d, 0x80, 0xa0, 0x2c, 0xc0}, "add -0x3fd35f80(,%ecx,4),%eax"},
+		{[]byte{0xf6, 0x86, 0x11, 0x02, 0x00, 0x00, 0x40}, "testb $0x40,0x211(%esi)"},
+		{[]byte{0x66, 0x8c, 0xd0}, "mov %ss,%ax"},
+		{[]byte{0x0f, 0x01, 0x15, 0xd2, 0xcd, 0x2b, 0x00}, "lgdtl 0x2bcdd2"},
+		{[]byte{0x88, 0x44, 0x3d, 0xd8}, "mov %al,-0x28(%ebp,%edi,1)"},
+		{[]byte{0xb9, 0x2f, 0x00, 0x00, 0x00}, "mov $0x2f,%ecx"},
+		{[]byte{0xf0, 0x83, 0x04, 0x24, 0x00}, "lock addl $0x0,(%esp)"},
+	}
+	code := codeTextArr2ReaderAt(testdata)
+
+	const ngoroutine = 16
+	const nround = 100
+	errc := make(chan error, ngoroutine)
+	var wg sync.WaitGroup
+	for g := 0; g < ngoroutine; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for r := 0; r < nround; r++ {
+				// Mix the stream decoder and Decode.
+				if (g+r)%2 == 0 {
+					dc := NewDisContext(code)
+					for _, ct := range testdata {
+						insn, err := dc.NextInsn()
+						if err != nil {
+							errc <- err
+							return
+						}
+						if dump := insn.DumpInsn(); dump != ct.assembly {
+							errc <- fmt.Errorf("expect %q, get %q", ct.assembly, dump)
+							return
+						}
+					}
+				} else {
+					off := 0
+					for _, ct := range testdata {
+						insn, err := Decode(code[off:], Mode32)
+						if err != nil {
+							errc <- err
+							return
+						}
+						if dump := insn.DumpInsn(); dump != ct.assembly {
+							errc <- fmt.Errorf("expect %q, get %q", ct.assembly, dump)
+							return
+						}
+						off += insn.Length
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errc)
+	for err := range errc {
+		t.Error(err)
+	}
+}
+
 // Disassemble the Linux kernel vmlinux file, see if the result matches
 // objdump's output.
 func checkLinux(t *testing.T) {
@@ -249,6 +822,13 @@ func checkLinux(t *testing.T) {
 		t.Fatal("reading text section failed")
 	}
 	dc := NewDisContext(SliceReader(rawbytes))
+	dc.BaseAddr = textSection.Addr
+	// objdump shows branch targets with symbols
+	sym, e3 := NewELFSymbolizer(f)
+	if e3 != nil {
+		t.Fatal("loading symbols failed:", e3)
+	}
+	att := ATTSyntax{Symbolizer: sym}
 
 	// Test instruction dump one by one
 	for i := 1; ; i++ {
@@ -262,8 +842,13 @@ func checkLinux(t *testing.T) {
 			t.Fatal("Disassemble file has very long line")
 		}
 
-		if !checkDump1(dc.NextInsn(), string(line), t) {
-			t.Fatal("Failed parsing the", i, "instruction")
+		insn, err := dc.NextInsn()
+		if err != nil {
+			t.Fatal("Failed parsing the", i, "instruction:", err)
+		}
+		if dump := insn.Format(att); dump != string(line) {
+			t.Fatalf("Failed parsing the %d instruction\nbinary: % x\nexpect: %s\nget:    %s",
+				i, insn.Bytes(), line, dump)
 		}
 	}
 }
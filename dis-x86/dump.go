@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"log"
+	"strings"
 )
 
 var regName = [...]string{
@@ -37,12 +38,34 @@ var regName8 = [...]string{
 	Bh: "bh",
 }
 
+// Byte registers when REX prefix is present, spl, bpl, sil and dil replace
+// ah, ch, dh and bh.
+var regName8Rex = [...]string{
+	Al: "al",
+	Cl: "cl",
+	Dl: "dl",
+	Bl: "bl",
+	Ah: "spl",
+	Ch: "bpl",
+	Dh: "sil",
+	Bh: "dil",
+}
+
+// Suffix of r8-r15 for different size
+var extRegSuffix = [...]string{
+	OpSizeByte: "b",
+	OpSizeWord: "w",
+	OpSizeLong: "d",
+	OpSizeQuad: "",
+}
+
 // Control register
 var cregName = [...]string{
 	Cr0: "cr0",
 	Cr2: "cr2",
 	Cr3: "cr3",
 	Cr4: "cr4",
+	Cr8: "cr8",
 }
 
 // Control register
@@ -57,15 +80,28 @@ var dregName = [...]string{
 	Dr7: "db7",
 }
 
-// Return the string name of a register
-func (dc *DisContext) formatReg(reg byte, size byte) (name string) {
+// Return the string name of a register in AT&T syntax
+func (insn *Instruction) formatReg(reg byte, size byte) string {
+	return "%" + insn.gpRegName(reg, size)
+}
+
+// Return the name of a general purpose register without syntax decoration
+func (insn *Instruction) gpRegName(reg byte, size byte) (name string) {
 	if size == OpSizeFull {
-		size = dc.EffectiveOperandSize()
+		size = insn.EffectiveOperandSize()
 	}
 	// debug.Println("size:", size, "reg:", reg)
+	if reg >= 8 && size <= OpSizeQuad {
+		// r8-r15, only available in 64-bit mode
+		return fmt.Sprintf("r%d%s", reg, extRegSuffix[size])
+	}
 	switch size {
 	case OpSizeByte:
-		name = regName8[reg]
+		if insn.Rex != 0 {
+			name = regName8Rex[reg]
+		} else {
+			name = regName8[reg]
+		}
 	case OpSizeWord:
 		name = regName[reg]
 	case OpSizeLong:
@@ -76,14 +112,125 @@ func (dc *DisContext) formatReg(reg byte, size byte) (name string) {
 	default:
 		log.Fatalf("reg size %d not correct\n", size)
 	}
-	return "%" + name
+	return
+}
+
+// Name prefix of vector registers, indexed by vector length.
+var vecRegName = [...]string{
+	0: "xmm",
+	1: "ymm",
+	2: "zmm",
+}
+
+// Suffix for each vector length
+var vecSizeSuffix = [...]string{
+	0: "x",
+	1: "y",
+}
+
+// Return the name of a vector register. l is the vector length as encoded in
+// VEX.L or EVEX.L'L.
+func formatVecReg(reg byte, l byte) string {
+	return "%" + vecReg(reg, l)
+}
+
+func vecReg(reg byte, l byte) string {
+	return fmt.Sprintf("%s%d", vecRegName[l], reg)
+}
+
+// Return the name of a MMX register. REX prefix does not extend MMX register.
+func formatMMReg(reg byte) string {
+	return "%" + mmReg(reg)
+}
+
+func mmReg(reg byte) string {
+	return fmt.Sprintf("mm%d", reg&7)
+}
+
+// Dump MMX register or memory operand specified by ModR/M.
+func (insn *Instruction) dumpMMRm() string {
+	if insn.Mod == 3 {
+		return formatMMReg(insn.Rm)
+	}
+	return insn.dumpMem(insn.EffectiveAddressSize())
+}
+
+// Return the name of an AVX-512 opmask register.
+func formatMaskReg(reg byte) string {
+	return "%" + maskReg(reg)
+}
+
+func maskReg(reg byte) string {
+	return fmt.Sprintf("k%d", reg)
+}
+
+// Return the name of x87 register stack st(i) in the rm field.
+func (insn *Instruction) formatFpuReg() string {
+	return "%" + insn.fpuReg()
+}
+
+func (insn *Instruction) fpuReg() string {
+	return fmt.Sprintf("st(%d)", insn.Rm&7)
+}
+
+// Dump vector register or memory operand specified by ModR/M. EVEX embedded
+// broadcast is shown after the memory operand, e.g. (%eax){1to16}.
+func (insn *Instruction) dumpVecRm(l byte) string {
+	if insn.Mod == 3 {
+		return formatVecReg(insn.Rm, l)
+	}
+	dump := insn.dumpMem(insn.EffectiveAddressSize())
+	if insn.Evex != 0 && insn.EvexB {
+		dump += fmt.Sprintf("{1to%d}", 16<<insn.VexL/insn.evexElemSize())
+	}
+	return dump
+}
+
+// EVEX rounding control, indexed by the RC field.
+var roundingName = [...]string{
+	"{rn-sae}",
+	"{rd-sae}",
+	"{ru-sae}",
+	"{rz-sae}",
+}
+
+// Dump EVEX embedded rounding or SAE, which is shown before the operands.
+func (insn *Instruction) dumpEvexRounding() string {
+	if insn.Evex == 0 || !insn.EvexB || insn.Mod != 3 {
+		return ""
+	}
+	if insn.Info.Flag&IFLAG_EVEX_ER != 0 {
+		return roundingName[insn.Rounding] + ","
+	}
+	return "{sae},"
+}
+
+// Dump EVEX opmask and zeroing, which is shown after the destination operand.
+func (insn *Instruction) dumpOpmask() (dump string) {
+	if insn.Opmask != 0 {
+		dump = fmt.Sprintf("{%%k%d}", insn.Opmask)
+	}
+	if insn.Zeroing {
+		dump += "{z}"
+	}
+	return
+}
+
+// Register encoded in the high 4 bits of an 8-bit immediate. Only 8 registers
+// are available outside 64-bit mode.
+func (insn *Instruction) immReg() byte {
+	reg := byte(insn.ImmOff) >> 4
+	if insn.Mode != Mode64 {
+		reg &= 7
+	}
+	return reg
 }
 
-func (dc *DisContext) dumpReg(size byte) string {
+func (insn *Instruction) dumpReg(size byte) string {
 	if size == OpSizeFull {
-		size = dc.EffectiveOperandSize()
+		size = insn.EffectiveOperandSize()
 	}
-	return dc.formatReg(dc.Reg, size)
+	return insn.formatReg(insn.Reg, size)
 }
 
 func dumpSignedValue(size byte, val int32) (dump string) {
@@ -98,115 +245,317 @@ func dumpSignedValue(size byte, val int32) (dump string) {
 	return
 }
 
-func (dc *DisContext) dumpDisp() (dump string) {
+func dumpUnsignedValue(size byte, val int64) (dump string) {
+	switch size {
+	case OpSizeByte:
+		dump = fmt.Sprintf("%#x", uint8(val))
+	case OpSizeWord:
+		dump = fmt.Sprintf("%#x", uint16(val))
+	case OpSizeLong:
+		dump = fmt.Sprintf("%#x", uint32(val))
+	default:
+		dump = fmt.Sprintf("%#x", uint64(val))
+	}
+	return
+}
+
+func (insn *Instruction) dumpDisp() (dump string) {
+	if sym := insn.dispSymbol(); sym != "" {
+		return sym
+	}
 	// If the displacement is used alone, take it as unsigned value.
-	if dc.Mod == 0 && dc.Rm == 5 {
-		dump = fmt.Sprintf("%#x", uint32(dc.Disp))
-	} else {
-		dump = dumpSignedValue(dc.DispSize, dc.Disp)
+	// RIP-relative displacement is signed.
+	switch {
+	case insn.IsRipRelative():
+		dump = dumpSignedValue(insn.DispSize, insn.Disp)
+	case insn.EffectiveAddressSize() == OpSizeWord && insn.Mod == 0 && insn.Rm == 6:
+		dump = dumpUnsignedValue(OpSizeWord, int64(insn.Disp))
+	case insn.EffectiveAddressSize() != OpSizeWord && insn.Mod == 0 && insn.Rm == 5:
+		dump = dumpUnsignedValue(OpSizeLong, int64(insn.Disp))
+	case insn.Evex != 0:
+		// disp8*N may not fit in a byte
+		dump = dumpSignedValue(OpSizeLong, insn.Disp)
+	default:
+		dump = dumpSignedValue(insn.DispSize, insn.Disp)
 	}
 	return
 }
 
-func (dc *DisContext) dumpImm() (dump string) {
-	return fmt.Sprintf("$%#x", uint32(dc.ImmOff))
+// Immediate value is shown as unsigned value of the operand size.
+func (insn *Instruction) dumpImm(size byte) (dump string) {
+	if size == OpSizeFull {
+		size = insn.EffectiveOperandSize()
+	}
+	return "$" + dumpUnsignedValue(size, insn.ImmOff)
 }
 
-func (dc *DisContext) dumpRm(operandSize, addressSize byte) (dump string) {
-	if dc.Mod == 3 {
+func (insn *Instruction) dumpRm(operandSize, addressSize byte) (dump string) {
+	if insn.Mod == 3 {
 		// debug.Println("modrm = 3")
 		if operandSize == OpSizeFull {
-			operandSize = dc.EffectiveOperandSize()
+			operandSize = insn.EffectiveOperandSize()
 		}
 		// debug.Println("operandSize:", operandSize)
-		return dc.formatReg(dc.Rm, operandSize)
+		return insn.formatReg(insn.Rm, operandSize)
 	}
+	return insn.dumpMem(addressSize)
+}
 
-	dump = dc.dumpSegPrefix()
+// Dump memory operand specified by ModR/M.
+func (insn *Instruction) dumpMem(addressSize byte) (dump string) {
+	dump = insn.dumpSegPrefix()
 	if addressSize == OpSizeFull {
-		addressSize = dc.EffectiveAddressSize()
+		addressSize = insn.EffectiveAddressSize()
 	}
 	// Output displacement
-	if dc.DispSize != 0 {
-		dump += dc.dumpDisp()
+	if insn.DispSize != 0 {
+		dump += insn.dumpDisp()
 	}
 	switch addressSize {
-	case OpSizeLong:
-		dump += dc.dumpRm32bit()
+	case OpSizeLong, OpSizeQuad:
+		dump += insn.dumpRm32bit()
 	case OpSizeWord:
-		dump += dc.dumpRm16bit()
+		dump += insn.dumpRm16bit()
 	}
 	return
 }
 
-func (dc *DisContext) dumpRm32bit() (dump string) {
-	if dc.Scale != 0 {
-		dump += dc.dumpSIB()
-	} else if !(dc.Rm == 5 && dc.Mod == 0) {
+func (insn *Instruction) dumpRm32bit() (dump string) {
+	if insn.Scale != 0 {
+		dump += insn.dumpSIB()
+	} else if insn.IsRipRelative() {
+		if insn.EffectiveAddressSize() == OpSizeQuad {
+			dump += "(%rip)"
+		} else {
+			dump += "(%eip)"
+		}
+	} else if !(insn.Rm == 5 && insn.Mod == 0) {
 		// Using register to access memory, so the size should be the address size.
-		dump += fmt.Sprintf("(%s)", dc.formatReg(dc.Rm, dc.EffectiveAddressSize()))
+		dump += fmt.Sprintf("(%s)", insn.formatReg(insn.Rm, insn.EffectiveAddressSize()))
 	}
 	return
 }
 
-func (dc *DisContext) dumpRm16bit() (dump string) {
-	if !(dc.Rm == 6 && dc.Mod == 0) {
+func (insn *Instruction) dumpRm16bit() (dump string) {
+	if !(insn.Rm == 6 && insn.Mod == 0) {
 		// Using register to access memory, so the size should be the address size.
-		dump += fmt.Sprintf("(%s)", dc.formatReg(dc.Rm, dc.EffectiveAddressSize()))
+		dump += fmt.Sprintf("(%s)", insn.formatReg(insn.Rm, insn.EffectiveAddressSize()))
 	}
 	return
 }
 
-func (dc *DisContext) dumpSIB() string {
+// Whether objdump shows the pseudo index register eiz or riz for SIB without
+// index. It's shown unless the base is esp, rsp or r12 with scale 1, which
+// needs SIB to be encoded. Without base, it's only omitted for absolute
+// address with scale 1 in 64-bit mode.
+func (insn *Instruction) hasPseudoIndex() bool {
+	if insn.Index != 4 {
+		return false
+	}
+	if insn.Base&7 == 5 && insn.Mod == 0 {
+		return insn.EffectiveAddressSize() != OpSizeQuad || insn.Scale != 1
+	}
+	return insn.Base&7 != 4 || insn.Scale != 1
+}
+
+func (insn *Instruction) dumpSIB() string {
 	// Refer to Intel Manual 2A Table 2-3
-	var scale, base, index string
+	var base, index string
+
+	// SIB is only allowed with 32 and 64-bit address size
+	addressSize := insn.EffectiveAddressSize()
 
-	if !(dc.Base == 5 && dc.Mod == 0) {
-		// SIB is only allowed in 32-bit mode
-		base = dc.formatReg(dc.Base, OpSizeLong)
+	if !(insn.Base&7 == 5 && insn.Mod == 0) {
+		base = insn.formatReg(insn.Base, addressSize)
 	}
 
-	if dc.Index != 4 {
-		// XXX What does none mean for scale index? Only use the base register
-		// in SIB?
-		index = dc.formatReg(dc.Index, OpSizeLong)
-		scale = fmt.Sprintf("%d", dc.Scale)
-	} else if dc.Info.OpId == Insn_Lea {
-		// Don't know why objdump uses "%eiz" when there's no index and scale
+	if insn.Index != 4 {
+		index = insn.formatReg(insn.Index, addressSize)
+	} else if insn.hasPseudoIndex() {
 		index = "%eiz"
-		scale = "1"
+		if addressSize == OpSizeQuad {
+			index = "%riz"
+		}
+	}
+	if index != "" {
+		return fmt.Sprintf("(%s,%s,%d)", base, index, insn.Scale)
 	}
-	if index != "" || scale != "" {
-		return fmt.Sprintf("(%s,%s,%s)", base, index, scale)
+	if base == "" {
+		// Only displacement
+		return ""
 	}
 	return fmt.Sprintf("(%s)", base)
 }
 
 var insnSizeSuffix = map[byte]string{
-	OT_ACC8:       "",
-	OT_RM8:        "b",
-	OT_RM_FULL:    "l",
-	OT_MEM16_3264: "l",
+	OT_ACC8: "",
+	OT_RM8:  "b",
+}
+
+// Suffix for each operand size
+var sizeSuffix = [...]string{
+	OpSizeByte: "b",
+	OpSizeWord: "w",
+	OpSizeLong: "l",
+	OpSizeQuad: "q",
+}
+
+// Return the size suffix according to the operand type.
+func (insn *Instruction) operandSuffix(operand byte) (suffix string, ok bool) {
+	switch operand {
+	case OT_RM_FULL:
+		return sizeSuffix[insn.EffectiveOperandSize()], true
+	case OT_MEM16_3264:
+		if insn.Mode == Mode64 {
+			return "q", true
+		}
+		return "l", true
+	}
+	suffix, ok = insnSizeSuffix[operand]
+	return
+}
+
+// Mnemonics which are different in AT&T syntax.
+var attMnemonic = map[uint16]string{
+	Insn_Jmp_far:  "ljmp",
+	Insn_Call_far: "lcall",
+	Insn_Cbw:      "cbtw",
+	Insn_Cwde:     "cwtl",
+	Insn_Cdqe:     "cltq",
+	Insn_Cwd:      "cwtd",
+	Insn_Cdq:      "cltd",
+	Insn_Cqo:      "cqto",
+}
+
+// Suffix of x87 instructions with memory operand, indexed by whether the
+// memory is an integer.
+var fpuSizeSuffix = [2]map[byte]string{
+	{OT_FPUM32: "s", OT_FPUM64: "l", OT_FPUM80: "t"},
+	{OT_FPUM16: "s", OT_FPUM32: "l", OT_FPUM64: "ll"},
+}
+
+// The register form of fsub(r) and fdiv(r) in dc and de, whose destination
+// is st(i), have the mnemonics swapped in AT&T syntax. This is an old bug
+// kept by gas and objdump for compatibility.
+var fpuSwappedMnemonic = map[uint16]uint16{
+	Insn_Fsub:   Insn_Fsubr,
+	Insn_Fsubr:  Insn_Fsub,
+	Insn_Fsubp:  Insn_Fsubrp,
+	Insn_Fsubrp: Insn_Fsubp,
+	Insn_Fdiv:   Insn_Fdivr,
+	Insn_Fdivr:  Insn_Fdiv,
+	Insn_Fdivp:  Insn_Fdivrp,
+	Insn_Fdivrp: Insn_Fdivp,
+}
+
+// x87 instructions use opcode d8 to df, which may follow fwait.
+func (insn *Instruction) isX87() bool {
+	op := insn.opcodeAll >> 8 & 0xff
+	return insn.Info.Flag&IFLAG_DIVIDED != 0 && op >= 0xd8 && op <= 0xdf
+}
+
+func (insn *Instruction) dumpFpuMnemonic(name string) string {
+	if insn.Mod == 3 {
+		op := insn.opcodeAll >> 8
+		if swapped, ok := fpuSwappedMnemonic[insn.OpId]; ok && (op == 0xdc || op == 0xde) {
+			return InsnName[swapped]
+		}
+		return name
+	}
+	// fild, fist etc. and fbld, fbstp use integer or BCD memory operand.
+	isInt := Btoi(name[1] == 'i' || name[1] == 'b')
+	return name + fpuSizeSuffix[isInt][insn.Info.Operand[0]]
+}
+
+// Comparison predicates of cmpps etc., selected by the immediate. SSE
+// instructions only use the first 8, VEX and EVEX encoding extend them to 32.
+var cmpPredicate = [...]string{
+	"eq", "lt", "le", "unord", "neq", "nlt", "nle", "ord",
+	"eq_uq", "nge", "ngt", "false", "neq_oq", "ge", "gt", "true",
+	"eq_os", "lt_oq", "le_oq", "unord_s", "neq_us", "nlt_uq", "nle_uq", "ord_s",
+	"eq_us", "nge_uq", "ngt_uq", "false_os", "neq_os", "ge_oq", "gt_oq", "true_us",
+}
+
+// Comparison predicates of AVX-512 integer compares, e.g. vpcmpd. objdump
+// shows the immediate for 3 and 7.
+var intCmpPredicate = [...]string{
+	"eq", "lt", "le", "", "neq", "nlt", "nle", "",
+}
+
+// Return the comparison predicate selected by the immediate of cmpps etc.,
+// or "" if it's not a valid predicate.
+func (insn *Instruction) cmpPredicateName() string {
+	predicates := cmpPredicate[:8]
+	switch {
+	case strings.HasPrefix(InsnName[insn.OpId], "vpcmp"):
+		predicates = intCmpPredicate[:]
+	case insn.Vex != 0 || insn.Evex != 0:
+		predicates = cmpPredicate[:]
+	}
+	if insn.ImmOff >= int64(len(predicates)) {
+		return ""
+	}
+	return predicates[insn.ImmOff]
+}
+
+// Return whether the immediate of cmpps etc. is a valid predicate.
+func (insn *Instruction) hasCmpPredicate() bool {
+	return insn.cmpPredicateName() != ""
+}
+
+// Insert the comparison predicate after cmp, e.g. cmpps with immediate 1 is
+// cmpltps, and vpcmpud is vpcmpltud.
+func (insn *Instruction) dumpCmpMnemonic(name string) string {
+	if !insn.hasCmpPredicate() {
+		return name
+	}
+	i := strings.Index(name, "cmp") + len("cmp")
+	return name[:i] + insn.cmpPredicateName() + name[i:]
+}
+
+// The immediate of cmpps etc. is shown as operand if it's not a valid
+// predicate.
+func (insn *Instruction) dumpPseudoImm() string {
+	if insn.Info.Flag&IFLAG_PSEUDO_OPCODE == 0 || insn.hasCmpPredicate() {
+		return ""
+	}
+	return insn.dumpImm(OpSizeByte) + ","
 }
 
-func (dc *DisContext) dumpInsn() (dump string) {
-	dump = InsnName[dc.Info.OpId]
+func (insn *Instruction) dumpInsn() (dump string) {
+	dump = InsnName[insn.OpId]
+	if name, ok := attMnemonic[insn.OpId]; ok {
+		dump = name
+	}
+	if insn.Info.Flag&IFLAG_PSEUDO_OPCODE != 0 {
+		dump = insn.dumpCmpMnemonic(dump)
+	}
+	if insn.isX87() {
+		dump = insn.dumpFpuMnemonic(dump)
+	}
 
 	// When the destination operand is memory address, and we can't infer
 	// operand size directly from the src operand, add the appropriate suffix.
 	// Example: test (0xf6), operand size is always 8bit. But when dumping
 	// ModRM with memory reference, we always use 32bit register.
-	if dc.Mod != 3 {
-		switch dc.opcodeAll {
+	if insn.Mod != 3 {
+		switch insn.opcodeAll {
 		case 0x8000, 0x8001, 0x8002, 0x8003, 0x8004, 0x8005, 0x8006, 0x8007, // Immediate Grp 1
 			0x8100, 0x8101, 0x8102, 0x8103, 0x8104, 0x8105, 0x8106, 0x8107, // Immediate Grp 1
 			0x8200, 0x8201, 0x8202, 0x8203, 0x8204, 0x8205, 0x8206, 0x8207, // Immediate Grp 1
 			0x8300, 0x8301, 0x8302, 0x8303, 0x8304, 0x8305, 0x8306, 0x8307, // Immediate Grp 1
 			0xf600, 0xf602, 0xf603, 0xf604, 0xf605, 0xf606, 0xf607, // Unary Grp 3
 			0xf700, 0xf702, 0xf703, 0xf704, 0xf705, 0xf706, 0xf707, // Unary Grp 3
+			0xc000, 0xc001, 0xc002, 0xc003, 0xc004, 0xc005, 0xc006, 0xc007, // Shift Grp 2
+			0xc100, 0xc101, 0xc102, 0xc103, 0xc104, 0xc105, 0xc106, 0xc107, // Shift Grp 2
+			0xd000, 0xd001, 0xd002, 0xd003, 0xd004, 0xd005, 0xd006, 0xd007, // Shift Grp 2
+			0xd100, 0xd101, 0xd102, 0xd103, 0xd104, 0xd105, 0xd106, 0xd107, // Shift Grp 2
+			0xd200, 0xd201, 0xd202, 0xd203, 0xd204, 0xd205, 0xd206, 0xd207, // Shift Grp 2
+			0xd300, 0xd301, 0xd302, 0xd303, 0xd304, 0xd305, 0xd306, 0xd307, // Shift Grp 2
 			0x0f0100, 0x0f0102, // sgdt, lgdt
+			0x0f19, 0x0f1a, 0x0f1b, 0x0f1c, 0x0f1d, 0x0f1e, 0x0f1f, // hint nop
 			0xc600, 0xc700: // Grp 11 (mov)
-			suffix, ok := insnSizeSuffix[dc.Info.Operand[0]]
+			suffix, ok := insn.operandSuffix(insn.Info.Operand[0])
 			if ok {
 				dump += suffix
 			} else {
@@ -215,153 +564,453 @@ func (dc *DisContext) dumpInsn() (dump string) {
 		}
 	}
 
-	// movsx (0x0fb6 & 0x0fb7) and movzx (0x0fbe & 0x0fbf) has fixed size src
-	// and destination operand. objdump differentiate the mnemonics for
-	// different size.
-	switch dc.opcodeAll {
-	case 0x0fb6:
-		dump = "movzbl"
-	case 0x0fb7:
-		dump = "movzwl"
-	case 0x0fbe:
-		dump = "movsbl"
-	case 0x0fbf:
-		dump = "movswl"
+	// crc32 has the same destination operand for different source operand
+	// size, so the suffix is needed for memory source operand.
+	if insn.OpId == Insn_Crc32 && insn.Mod != 3 {
+		suffix, _ := insn.operandSuffix(insn.Info.Operand[1])
+		dump += suffix
+	}
+
+	// cvtsi2ss and cvtsi2sd convert 32 or 64-bit integer in 64-bit mode, the
+	// size of memory source operand is shown by suffix.
+	switch insn.OpId {
+	case Insn_Cvtsi2ss, Insn_Cvtsi2sd, Insn_Vcvtsi2ss, Insn_Vcvtsi2sd:
+		if insn.Mode == Mode64 && insn.Mod != 3 {
+			size := insn.size32or64()
+			if insn.Vex != 0 {
+				size = insn.vexWSize()
+			}
+			dump += sizeSuffix[size]
+		}
+	}
+
+	// VEX instructions converting 128 or 256-bit source to 128-bit
+	// destination, the memory operand size is shown by x or y suffix.
+	if insn.Vex != 0 && insn.Mod != 3 && insn.Info.Operand[0] == OT_XMM &&
+		insn.Info.Operand[1] == OT_YXMM128_256 {
+		dump += vecSizeSuffix[insn.VexL]
+	}
+
+	// movzx (0x0fb6 & 0x0fb7) and movsx (0x0fbe & 0x0fbf) has fixed size src
+	// operand. objdump differentiate the mnemonics by the source size and
+	// the destination size, e.g. movzbq.
+	switch insn.opcodeAll {
+	case 0x0fb6, 0x0fb7, 0x0fbe, 0x0fbf:
+		dump = "movz"
+		if insn.opcodeAll >= 0x0fbe {
+			dump = "movs"
+		}
+		src := OpSizeByte
+		if insn.opcodeAll&1 != 0 {
+			src = OpSizeWord
+		}
+		dump += sizeSuffix[src] + sizeSuffix[insn.EffectiveOperandSize()]
+	case 0xa0, 0xa1, 0xa2, 0xa3:
+		// mov with 64-bit memory offset
+		if insn.EffectiveAddressSize() == OpSizeQuad {
+			dump = "movabs"
+		}
+	case 0xb8, 0xb9, 0xba, 0xbb, 0xbc, 0xbd, 0xbe, 0xbf:
+		// mov with 64-bit immediate
+		if insn.EffectiveOperandSize() == OpSizeQuad {
+			dump = "movabs"
+		}
+	}
+	if insn.hasWordSuffix(true) {
+		dump += "w"
+	}
+	// movsxd, objdump uses movslq if sign-extending to 64-bit
+	if insn.OpId == Insn_Movsxd && insn.Rex&RexW != 0 {
+		dump = "movslq"
 	}
 
 	return dump + " "
 }
 
 var prefixName = map[int]string{
-	PrefixREPNZ: "rep ",
-	PrefixREPZ:  "rep ",
-	PrefixCS:    "%cs:",
-	PrefixSS:    "%ss",
-	PrefixDS:    "%ds:",
-	PrefixES:    "%es:",
-	PrefixFS:    "%fs:",
-	PrefixGS:    "%gs:",
-	PrefixLOCK:  "lock ",
-}
-
-func (dc *DisContext) dumpRepLockPrefix() string {
-	name, ok := prefixName[dc.Prefix&(PrefixREPNZ|PrefixREPZ|PrefixLOCK)]
-	if ok {
-		return name
+	PrefixCS: "%cs:",
+	PrefixSS: "%ss:",
+	PrefixDS: "%ds:",
+	PrefixES: "%es:",
+	PrefixFS: "%fs:",
+	PrefixGS: "%gs:",
+}
+
+// Lock and repeat prefix, named as objdump does. F3 is rep for string
+// instructions repeated only by the counter, and repz otherwise, e.g. cmps
+// and scas which also test ZF. F2 is bnd for near branches, and repnz
+// otherwise.
+func (insn *Instruction) dumpRepLockPrefix() (dump string) {
+	if insn.Prefix&PrefixLOCK != 0 {
+		dump = "lock "
 	}
-	return ""
+	switch {
+	case insn.Prefix&PrefixREPNZ != 0:
+		if insn.isBndBranch() {
+			dump += "bnd "
+		} else {
+			dump += "repnz "
+		}
+	case insn.Prefix&PrefixREPZ != 0:
+		switch insn.OpId {
+		case Insn_Movs, Insn_Stos, Insn_Lods, Insn_Ins, Insn_Outs:
+			dump += "rep "
+		default:
+			dump += "repz "
+		}
+	}
+	return
+}
+
+// Near jmp, jcc, call and ret, which take the bnd prefix of MPX.
+func (insn *Instruction) isBndBranch() bool {
+	switch insn.FlowControl() {
+	case FlowBranch:
+		return true
+	case FlowCall:
+		return insn.OpId == Insn_Call
+	case FlowCondBranch:
+		_, ok := insn.Condition()
+		return ok
+	case FlowReturn:
+		return insn.OpId == Insn_Ret
+	}
+	return false
 }
 
-func (dc *DisContext) dumpSegPrefix() string {
-	name, ok := prefixName[dc.Prefix&(PrefixCS|PrefixDS|PrefixES|PrefixFS|PrefixGS)]
+func (insn *Instruction) dumpSegPrefix() string {
+	name, ok := prefixName[insn.Prefix&(PrefixCS|PrefixSS|PrefixDS|PrefixES|PrefixFS|PrefixGS)]
 	if ok {
 		return name
 	}
 	return ""
 }
 
-func (dc *DisContext) DumpInsn() (dump string) {
+func (insn *Instruction) DumpInsn() (dump string) {
 	var buf bytes.Buffer
 
-	buf.WriteString(dc.dumpRepLockPrefix())
+	buf.WriteString(insn.dumpRepLockPrefix())
 
-	if dumper, ok := specialInsnDump[dc.Info.OpId]; ok == true {
-		buf.WriteString(dumper(dc))
+	if dumper, ok := specialInsnDump[insn.OpId]; ok == true {
+		buf.WriteString(dumper(insn))
 		return buf.String()
 	}
 
-	buf.WriteString(dc.dumpInsn())
-	switch dc.Info.countOperand() {
-	case 1:
-		buf.WriteString(dc.dumpOperand(dc.Info.Operand[0]))
-	case 2:
-		buf.WriteString(dc.dumpOperand(dc.Info.Operand[1]))
-		buf.WriteString(",")
-		buf.WriteString(dc.dumpOperand(dc.Info.Operand[0]))
+	buf.WriteString(insn.dumpInsn())
+	buf.WriteString(insn.dumpPseudoImm())
+	buf.WriteString(insn.dumpEvexRounding())
+	// AT&T syntax puts the destination operand last. Shift by 1 only shows
+	// the shifted operand.
+	for i := insn.Info.countOperand() - 1; i >= 0; i-- {
+		if insn.Info.Operand[i] == OT_CONST1 {
+			continue
+		}
+		buf.WriteString(insn.dumpOperand(insn.Info.Operand[i]))
+		if i != 0 {
+			buf.WriteString(",")
+		}
+	}
+	if insn.Evex != 0 {
+		buf.WriteString(insn.dumpOpmask())
 	}
 	return buf.String()
 }
 
-func (dc *DisContext) dumpOperand(operand byte) (dump string) {
+func (insn *Instruction) dumpOperand(operand byte) (dump string) {
 	switch operand {
 	// Immediate value
-	case OT_IMM8, OT_IMM16, OT_IMM32, OT_IMM_FULL, OT_SEIMM8:
-		dump = dc.dumpImm()
+	case OT_IMM8, OT_IMM16, OT_IMM32, OT_IMM_FULL, OT_IMM16_1, OT_IMM8_1:
+		dump = insn.dumpImm(ot2size[operand])
+	case OT_IMM8_2:
+		dump = "$" + dumpUnsignedValue(OpSizeByte, insn.imm2)
+	// Sign-extended to the operand size
+	case OT_SEIMM8:
+		dump = insn.dumpImm(OpSizeFull)
+	// Far pointer is shown as selector and offset
+	case OT_PTR16_FULL:
+		dump = fmt.Sprintf("$%#x,$%s", uint16(insn.imm2),
+			dumpUnsignedValue(insn.EffectiveOperandSize(), insn.ImmOff))
 
 	// Memory offset are always unsigned
 	case OT_MOFFS8, OT_MOFFS_FULL:
-		dump = dc.dumpSegPrefix() + fmt.Sprintf("%#x", uint32(dc.ImmOff))
+		dump = insn.dumpSegPrefix()
+		if sym := insn.moffsSymbol(); sym != "" {
+			dump += sym
+		} else {
+			dump += dumpUnsignedValue(insn.EffectiveAddressSize(), insn.ImmOff)
+		}
 
 	// Register
 	case OT_REG8, OT_IB_RB, OT_REG16, OT_REG32,
-		OT_REG_FULL, OT_IB_R_FULL,
-		OT_ACC8, OT_ACC16, OT_ACC_FULL,
-		OT_REGI_EDI, OT_REGCL:
+		OT_REG_FULL, OT_IB_R_FULL:
 		// debug.Println("dump reg")
-		dump = dc.dumpReg(ot2size[operand])
+		dump = insn.dumpReg(ot2size[operand])
+	case OT_REG32_64:
+		dump = insn.dumpReg(insn.size32or64())
+	case OT_ACC8, OT_ACC16, OT_ACC_FULL:
+		dump = insn.formatReg(Eax, ot2size[operand])
+	// in and out can't use 64-bit register
+	case OT_ACC_FULL_NOT64:
+		size := insn.EffectiveOperandSize()
+		if size == OpSizeQuad {
+			size = OpSizeLong
+		}
+		dump = insn.formatReg(Eax, size)
+	case OT_REGI_EDI:
+		dump = insn.formatReg(Edi, ot2size[operand])
+	case OT_REGCL:
+		dump = insn.formatReg(Cl, ot2size[operand])
 	// Segment register
 	case OT_SREG, OT_SEG:
-		dump = "%" + segRegName[dc.Reg]
+		dump = "%" + segRegName[insn.Reg]
 	// Control register
 	case OT_CREG:
-		dump = "%" + cregName[dc.Reg]
+		dump = "%" + cregName[insn.Reg]
 	case OT_DREG:
-		dump = "%" + dregName[dc.Reg]
+		dump = "%" + dregName[insn.Reg]
 
 	// RM
 	// RM8 means the operand size is 8, but is the same with RM_FULL for
 	// address, which depends on address-size attribute. RM16 is the same.
 	// Example: mov (0x88) -- RM8, mov (0x89) -- RM_FULL
-	case OT_RM8, OT_RM16, OT_RM_FULL, OT_MEM:
-		// debug.Println("dump rm, address size:", dc.EffectiveAddressSize())
-		dump = dc.dumpRm(ot2size[operand], dc.EffectiveAddressSize())
+	case OT_RM8, OT_RM16, OT_RM_FULL, OT_MEM, OT_MEM16_FULL:
+		// debug.Println("dump rm, address size:", insn.EffectiveAddressSize())
+		dump = insn.dumpRm(ot2size[operand], insn.EffectiveAddressSize())
+	case OT_RM32:
+		dump = insn.dumpRm(OpSizeLong, insn.EffectiveAddressSize())
+	case OT_RM32_64:
+		dump = insn.dumpRm(insn.size32or64(), insn.EffectiveAddressSize())
 	// Messy x86, sigh. If the operand is register, use 32bit; if it's memory, use 16 bit.
 	// Example: mov (0x8c), when used as register, 32bit, but for memory, the operand size is 16bit
 	case OT_RFULL_M16:
-		dump = dc.dumpRm(dc.EffectiveOperandSize(), dc.EffectiveAddressSize())
+		dump = insn.dumpRm(insn.EffectiveOperandSize(), insn.EffectiveAddressSize())
 	case OT_MEM16_3264:
 		// What operand size should we use here?
-		dump = dc.dumpRm(OpSizeLong, OpSizeLong)
+		dump = insn.dumpRm(OpSizeLong, insn.EffectiveAddressSize())
 	// For mov control register insn.
 	case OT_FREG32_64_RM:
-		// In non-64 bit mode, always use 32bit operand size
-		dump = dc.formatReg(dc.Rm, OpSizeLong)
+		// In non-64 bit mode, always use 32bit operand size. In 64-bit mode,
+		// always use 64bit.
+		if insn.Mode == Mode64 {
+			dump = insn.formatReg(insn.Rm, OpSizeQuad)
+		} else {
+			dump = insn.formatReg(insn.Rm, OpSizeLong)
+		}
+	// Memory only operand with fixed size
+	case OT_MEM32, OT_MEM32_64, OT_MEM64, OT_MEM128, OT_MEM64_128:
+		dump = insn.dumpMem(insn.EffectiveAddressSize())
+	// The register form is another instruction without operand, e.g. clflush
+	// and sfence
+	case OT_MEM_OPT:
+		if insn.Mod != 3 {
+			dump = insn.dumpMem(insn.EffectiveAddressSize())
+		}
+	// 32-bit register if used as register, e.g. pextrb and pinsrw
+	case OT_R32_M8, OT_R32_M16, OT_R32_64_M8, OT_R32_64_M16:
+		dump = insn.dumpRm(OpSizeLong, insn.EffectiveAddressSize())
+	case OT_REG32_64_M8, OT_REG32_64_M16:
+		dump = insn.dumpRm(insn.size32or64(), insn.EffectiveAddressSize())
+	// General purpose register sized by VEX.W, e.g. vmovd and vmovq
+	case OT_WREG32_64:
+		dump = insn.formatReg(insn.Reg, insn.vexWSize())
+	case OT_WRM32_64:
+		dump = insn.dumpRm(insn.vexWSize(), insn.EffectiveAddressSize())
+
+	// Relative branch and call, shown as the absolute target
+	case OT_RELCB, OT_RELC_FULL:
+		dump = insn.targetString()
+
+	// MMX register or memory
+	case OT_MM:
+		dump = formatMMReg(insn.Reg)
+	case OT_MM_RM, OT_MM32, OT_MM64:
+		dump = insn.dumpMMRm()
+
+	// Vector register in the reg field
+	case OT_XMM:
+		dump = formatVecReg(insn.Reg, 0)
+	case OT_YXMM:
+		dump = formatVecReg(insn.Reg, insn.VexL)
+	case OT_YMM:
+		dump = formatVecReg(insn.Reg, 1)
+	// Vector register in VEX.vvvv
+	case OT_VXMM:
+		dump = formatVecReg(insn.Vvvv, 0)
+	case OT_VYXMM:
+		dump = formatVecReg(insn.Vvvv, insn.VexL)
+	case OT_VYMM:
+		dump = formatVecReg(insn.Vvvv, 1)
+	// Vector register in the immediate
+	case OT_XMM_IMM:
+		dump = formatVecReg(insn.immReg(), 0)
+	case OT_YXMM_IMM:
+		dump = formatVecReg(insn.immReg(), insn.VexL)
+	// Vector register or memory
+	case OT_XMM_RM, OT_XMM8, OT_XMM16, OT_XMM32, OT_XMM64, OT_XMM128,
+		OT_LXMM64_128, OT_WXMM32_64:
+		dump = insn.dumpVecRm(0)
+	case OT_YXMM64_256, OT_YXMM128_256, OT_LMEM128_256:
+		dump = insn.dumpVecRm(insn.VexL)
+	case OT_YMM256:
+		dump = insn.dumpVecRm(1)
+	// Opmask register in the reg field, VEX.vvvv, or register or memory
+	case OT_KREG:
+		dump = formatMaskReg(insn.Reg)
+	case OT_VK:
+		dump = formatMaskReg(insn.Vvvv)
+	case OT_KRM:
+		if insn.Mod == 3 {
+			dump = formatMaskReg(insn.Rm)
+		} else {
+			dump = insn.dumpMem(insn.EffectiveAddressSize())
+		}
+	// x87 register stack, st(0) is shown as %st
+	case OT_FPU_SI:
+		dump = insn.formatFpuReg()
+	case OT_FPU_SSI:
+		dump = insn.formatFpuReg() + ",%st"
+	case OT_FPU_SIS:
+		dump = "%st," + insn.formatFpuReg()
+	case OT_FPUM16, OT_FPUM32, OT_FPUM64, OT_FPUM80:
+		dump = insn.dumpMem(insn.EffectiveAddressSize())
+
+	// Implicit xmm0, e.g. blendvps
+	case OT_REGXMM0:
+		dump = formatVecReg(0, 0)
 	}
 
-	switch dc.opcodeAll {
-	case 0xff02: // Call with indirect target
+	switch insn.opcodeAll {
+	case 0xff02, 0xff03, 0xff04, 0xff05: // jmp and call with indirect target
 		dump = "*" + dump
 	}
 	return
 }
 
+// Whether objdump shows the w suffix for 16-bit operand size outside 16-bit
+// mode. This applies to near jmp and call with relative target, far jmp and
+// call, and enter. AT&T syntax also has the suffix for indirect jmp and call
+// with memory operand.
+func (insn *Instruction) hasWordSuffix(att bool) bool {
+	if insn.EffectiveOperandSize() != OpSizeWord || insn.Mode == Mode16 {
+		return false
+	}
+	switch insn.OpId {
+	case Insn_Jmp, Insn_Call:
+		if insn.Info.hasOperand(OT_RELC_FULL) {
+			return true
+		}
+		return att && insn.Info.Flag&IFLAG_MODRM_REQUIRED != 0 && insn.Mod != 3
+	case Insn_Jmp_far, Insn_Call_far:
+		return att
+	case Insn_Enter:
+		return true
+	}
+	return false
+}
+
+// Operand size of OT_REG32_64 and OT_RM32_64, which is 64-bit only with
+// REX.W, the operand-size prefix has no effect.
+func (insn *Instruction) size32or64() byte {
+	if insn.Rex&RexW != 0 {
+		return OpSizeQuad
+	}
+	return OpSizeLong
+}
+
+// Operand size of OT_WREG32_64 and OT_WRM32_64. VEX.W selects 64-bit operand
+// only in 64-bit mode.
+func (insn *Instruction) vexWSize() byte {
+	if insn.Mode == Mode64 && insn.VexW {
+		return OpSizeQuad
+	}
+	return OpSizeLong
+}
+
 // Some intructions are difficult to dump because the format returned by
 // objdump is not regular. For those instructions, I just use specific dump
 // function for each instruction.
 
-type insnDumper func(dc *DisContext) string
+type insnDumper func(insn *Instruction) string
+
+var specialInsnDump = map[uint16]insnDumper{
+	Insn_Stos:  dumpStos,
+	Insn_Movs:  dumpMovs,
+	Insn_Cmps:  dumpCmps,
+	Insn_Scas:  dumpScas,
+	Insn_Lods:  dumpLods,
+	Insn_Ins:   dumpIns,
+	Insn_Outs:  dumpOuts,
+	Insn_Xlat:  dumpXlat,
+	Insn_Enter: dumpEnter,
+}
 
-var specialInsnDump = map[byte]insnDumper{
-	Insn_Stos: dumpStos,
-	Insn_Movs: dumpMovs,
+// Operand size of string instructions. The byte version has the lowest bit of
+// the opcode cleared.
+func (insn *Instruction) stringOperandSize() byte {
+	if insn.opcodeAll&1 == 0 {
+		return OpSizeByte
+	}
+	return insn.EffectiveOperandSize()
 }
 
-func dumpStos(dc *DisContext) (dump string) {
-	switch dc.EffectiveAddressSize() {
-	case OpSizeWord:
-		panic("not implemented")
-	case OpSizeLong:
-		dump = "stos %eax,%es:(%edi)"
+// Operand size of ins and outs, which have no 64-bit version.
+func (insn *Instruction) ioStringOperandSize() byte {
+	if size := insn.stringOperandSize(); size != OpSizeQuad {
+		return size
 	}
-	return
+	return OpSizeLong
 }
 
-func dumpMovs(dc *DisContext) (dump string) {
-	switch dc.EffectiveAddressSize() {
-	case OpSizeWord:
-		panic("not implemented")
-	case OpSizeLong:
-		dump = "movsl %ds:(%esi),%es:(%edi)"
+// Memory operand addressed by esi, or ebx for xlat, which is in ds unless
+// overridden. Only fs and gs override is effective in 64-bit mode.
+func (insn *Instruction) dumpStringSrc(reg byte) string {
+	seg := insn.dumpSegPrefix()
+	if seg == "" || (insn.Mode == Mode64 && seg != "%fs:" && seg != "%gs:") {
+		seg = "%ds:"
 	}
-	return
+	return fmt.Sprintf("%s(%s)", seg, insn.formatReg(reg, insn.EffectiveAddressSize()))
+}
+
+// Memory operand addressed by edi, which is always in es.
+func (insn *Instruction) dumpStringDst() string {
+	return fmt.Sprintf("%%es:(%s)", insn.formatReg(Edi, insn.EffectiveAddressSize()))
+}
+
+func dumpStos(insn *Instruction) (dump string) {
+	return fmt.Sprintf("stos %s,%s", insn.formatReg(Eax, insn.stringOperandSize()), insn.dumpStringDst())
+}
+
+func dumpMovs(insn *Instruction) (dump string) {
+	return fmt.Sprintf("movs%s %s,%s", sizeSuffix[insn.stringOperandSize()],
+		insn.dumpStringSrc(Esi), insn.dumpStringDst())
+}
+
+func dumpCmps(insn *Instruction) (dump string) {
+	return fmt.Sprintf("cmps%s %s,%s", sizeSuffix[insn.stringOperandSize()],
+		insn.dumpStringDst(), insn.dumpStringSrc(Esi))
+}
+
+func dumpScas(insn *Instruction) (dump string) {
+	return fmt.Sprintf("scas %s,%s", insn.dumpStringDst(), insn.formatReg(Eax, insn.stringOperandSize()))
+}
+
+func dumpLods(insn *Instruction) (dump string) {
+	return fmt.Sprintf("lods %s,%s", insn.dumpStringSrc(Esi), insn.formatReg(Eax, insn.stringOperandSize()))
+}
+
+func dumpIns(insn *Instruction) (dump string) {
+	return fmt.Sprintf("ins%s (%%dx),%s", sizeSuffix[insn.ioStringOperandSize()], insn.dumpStringDst())
+}
+
+func dumpXlat(insn *Instruction) (dump string) {
+	return "xlat " + insn.dumpStringSrc(Ebx)
+}
+
+func dumpOuts(insn *Instruction) (dump string) {
+	return fmt.Sprintf("outs%s %s,(%%dx)", sizeSuffix[insn.ioStringOperandSize()], insn.dumpStringSrc(Esi))
+}
+
+// enter keeps the operand order of Intel syntax.
+func dumpEnter(insn *Instruction) string {
+	return insn.dumpInsn() + insn.dumpOperand(OT_IMM16_1) + "," + insn.dumpOperand(OT_IMM8_2)
 }
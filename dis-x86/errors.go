@@ -0,0 +1,33 @@
+package dis
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Reasons for failing to decode an instruction. Errors returned by the
+// decoder are *DecodeError wrapping one of these, use errors.Is to check.
+var (
+	ErrUnknownOpcode = errors.New("unknown opcode")
+	ErrTruncated     = errors.New("truncated instruction")
+	ErrInvalidModRM  = errors.New("invalid ModR/M for opcode")
+	ErrTooLong       = errors.New("instruction longer than 15 bytes")
+)
+
+// Intel Manual 2A Section 2.3.11: instruction length is limited to 15 bytes.
+const maxInsnLen = 15
+
+// DecodeError records where decoding failed and the bytes involved.
+type DecodeError struct {
+	Offset int64  // Begin offset of the instruction
+	Bytes  []byte // Bytes consumed before the error is detected
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%v at offset %#x: % x", e.Err, e.Offset, e.Bytes)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,98 @@
+package dis
+
+// FlowControl is the kind of control transfer done by an instruction.
+type FlowControl byte
+
+const (
+	FlowNone       FlowControl = iota // Continue with the next instruction
+	FlowBranch                        // Unconditional near jmp
+	FlowCondBranch                    // jcc, loop and jcxz
+	FlowCall                          // Near and far call
+	FlowReturn                        // ret, retf and iret
+	FlowInterrupt                     // int, int3, into, int1 and ud2
+	FlowSys                           // syscall, sysret, sysenter and sysexit
+	FlowHalt                          // hlt
+	FlowFarBranch                     // Far jmp, which changes cs
+)
+
+var flowControlName = [...]string{
+	FlowNone:       "none",
+	FlowBranch:     "branch",
+	FlowCondBranch: "conditional branch",
+	FlowCall:       "call",
+	FlowReturn:     "return",
+	FlowInterrupt:  "interrupt",
+	FlowSys:        "sys",
+	FlowHalt:       "halt",
+	FlowFarBranch:  "far branch",
+}
+
+func (fc FlowControl) String() string {
+	if int(fc) < len(flowControlName) {
+		return flowControlName[fc]
+	}
+	return "unknown"
+}
+
+// FlowControl returns the kind of control transfer of the instruction.
+func (insn *Instruction) FlowControl() FlowControl {
+	return flowControlOfInsn[insn.OpId]
+}
+
+// IsBranch tells whether the instruction is a jmp, jcc or call, which have a
+// target given by the operand.
+func (insn *Instruction) IsBranch() bool {
+	switch insn.FlowControl() {
+	case FlowBranch, FlowCondBranch, FlowCall, FlowFarBranch:
+		return true
+	}
+	return false
+}
+
+// IsIndirect tells whether the branch target is given by a register or
+// memory. Direct branch has the target encoded in the instruction, which is
+// in Target for relative branch, or the FarPtr operand for far branch.
+func (insn *Instruction) IsIndirect() bool {
+	if !insn.IsBranch() || len(insn.Operands) == 0 {
+		return false
+	}
+	switch insn.Operands[0].(type) {
+	case Reg, Mem:
+		return true
+	}
+	return false
+}
+
+// FallsThrough tells whether execution may continue with the next
+// instruction. Calls are assumed to return, int and syscall return to the
+// next instruction. int3 is commonly used as padding, so it doesn't fall
+// through, neither does ud2.
+func (insn *Instruction) FallsThrough() bool {
+	switch insn.FlowControl() {
+	case FlowNone, FlowCall, FlowCondBranch:
+		return true
+	case FlowInterrupt:
+		return insn.OpId != Insn_Ud2 && insn.OpId != Insn_Int_3
+	case FlowSys:
+		return insn.OpId != Insn_Sysret && insn.OpId != Insn_Sysexit
+	}
+	// Branch, return, halt and far branch
+	return false
+}
+
+// Successors returns the targets of jmp and jcc, and whether the instruction
+// falls through to the next one. Call targets are not included. Targets of
+// indirect jmp are returned by jumpTable, which may be nil if unknown.
+func (insn *Instruction) Successors(jumpTable func(addr uint64) []uint64) (targets []uint64, fallsThrough bool) {
+	switch insn.FlowControl() {
+	case FlowCondBranch:
+		targets = []uint64{insn.Target}
+	case FlowBranch:
+		if !insn.IsIndirect() {
+			targets = []uint64{insn.Target}
+		} else if jumpTable != nil {
+			targets = jumpTable(insn.Addr)
+		}
+	}
+	return targets, insn.FallsThrough()
+}
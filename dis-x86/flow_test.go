@@ -0,0 +1,98 @@
+package dis
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFlowControl(t *testing.T) {
+	testdata := []struct {
+		code     []byte
+		mode     Mode
+		flow     FlowControl
+		indirect bool
+	}{
+		{[]byte{0x89, 0xc8}, Mode32, FlowNone, false},                                    // mov
+		{[]byte{0x0f, 0x44, 0xc1}, Mode32, FlowNone, false},                              // cmove
+		{[]byte{0xeb, 0x00}, Mode32, FlowBranch, false},                                  // jmp rel8
+		{[]byte{0xe9, 0x00, 0x00, 0x00, 0x00}, Mode32, FlowBranch, false},                // jmp rel32
+		{[]byte{0xff, 0xe0}, Mode32, FlowBranch, true},                                   // jmp *%eax
+		{[]byte{0xff, 0x24, 0x85, 0x00, 0x10, 0x00, 0x00}, Mode32, FlowBranch, true},     // jmp *0x1000(,%eax,4)
+		{[]byte{0x74, 0x00}, Mode32, FlowCondBranch, false},                              // je
+		{[]byte{0x0f, 0x8f, 0x00, 0x00, 0x00, 0x00}, Mode32, FlowCondBranch, false},      // jg
+		{[]byte{0xe2, 0x00}, Mode32, FlowCondBranch, false},                              // loop
+		{[]byte{0xe3, 0x00}, Mode32, FlowCondBranch, false},                              // jecxz
+		{[]byte{0xe3, 0x00}, Mode64, FlowCondBranch, false},                              // jrcxz
+		{[]byte{0xe8, 0x00, 0x00, 0x00, 0x00}, Mode32, FlowCall, false},                  // call
+		{[]byte{0xff, 0x15, 0x00, 0x10, 0x00, 0x00}, Mode32, FlowCall, true},             // call *0x1000
+		{[]byte{0x9a, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00}, Mode32, FlowCall, false},      // lcall
+		{[]byte{0xff, 0x18}, Mode32, FlowCall, true},                                     // lcall *(%eax)
+		{[]byte{0xea, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00}, Mode32, FlowFarBranch, false}, // ljmp
+		{[]byte{0xff, 0x28}, Mode32, FlowFarBranch, true},                                // ljmp *(%eax)
+		{[]byte{0xc3}, Mode32, FlowReturn, false},                                        // ret
+		{[]byte{0xc2, 0x08, 0x00}, Mode32, FlowReturn, false},                            // ret $0x8
+		{[]byte{0xcb}, Mode32, FlowReturn, false},                                        // lret
+		{[]byte{0xcf}, Mode32, FlowReturn, false},                                        // iret
+		{[]byte{0xcc}, Mode32, FlowInterrupt, false},                                     // int3
+		{[]byte{0xcd, 0x80}, Mode32, FlowInterrupt, false},                               // int $0x80
+		{[]byte{0x0f, 0x0b}, Mode32, FlowInterrupt, false},                               // ud2
+		{[]byte{0x0f, 0x05}, Mode64, FlowSys, false},                                     // syscall
+		{[]byte{0x0f, 0x34}, Mode32, FlowSys, false},                                     // sysenter
+		{[]byte{0xf4}, Mode32, FlowHalt, false},                                          // hlt
+	}
+	for _, td := range testdata {
+		insn, err := Decode(td.code, td.mode)
+		if err != nil {
+			t.Errorf("% x: %v", td.code, err)
+			continue
+		}
+		if flow := insn.FlowControl(); flow != td.flow {
+			t.Errorf("% x %s: flow control %v, should be %v", td.code, insn.Format(ATTSyntax{}), flow, td.flow)
+		}
+		if indirect := insn.IsIndirect(); indirect != td.indirect {
+			t.Errorf("% x %s: indirect %v, should be %v", td.code, insn.Format(ATTSyntax{}), indirect, td.indirect)
+		}
+	}
+}
+
+func TestSuccessors(t *testing.T) {
+	jumpTable := func(addr uint64) []uint64 {
+		return []uint64{addr + 0x10, addr + 0x20}
+	}
+	testdata := []struct {
+		code    []byte
+		targets []uint64
+		next    bool
+	}{
+		{[]byte{0x89, 0xc8}, nil, true},                                // mov
+		{[]byte{0x74, 0x10}, []uint64{0x1012}, true},                   // je
+		{[]byte{0xeb, 0xfe}, []uint64{0x1000}, false},                  // jmp
+		{[]byte{0xff, 0xe0}, []uint64{0x1010, 0x1020}, false},          // jmp *%eax
+		{[]byte{0xe8, 0x00, 0x00, 0x00, 0x00}, nil, true},              // call
+		{[]byte{0xc3}, nil, false},                                     // ret
+		{[]byte{0xcd, 0x80}, nil, true},                                // int $0x80
+		{[]byte{0xcc}, nil, false},                                     // int3
+		{[]byte{0x0f, 0x0b}, nil, false},                               // ud2
+		{[]byte{0x0f, 0x34}, nil, true},                                // sysenter
+		{[]byte{0x0f, 0x35}, nil, false},                               // sysexit
+		{[]byte{0xf4}, nil, false},                                     // hlt
+		{[]byte{0xea, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00}, nil, false}, // ljmp
+	}
+	for _, td := range testdata {
+		insn, err := DecodeAt(td.code, 0x1000, Mode32)
+		if err != nil {
+			t.Errorf("% x: %v", td.code, err)
+			continue
+		}
+		targets, next := insn.Successors(jumpTable)
+		if fmt.Sprint(targets) != fmt.Sprint(td.targets) || next != td.next {
+			t.Errorf("% x %s: successors %x %v, should be %x %v", td.code, insn.Format(ATTSyntax{}),
+				targets, next, td.targets, td.next)
+		}
+	}
+	// Unknown jump table
+	insn, _ := Decode([]byte{0xff, 0xe0}, Mode32)
+	if targets, _ := insn.Successors(nil); targets != nil {
+		t.Errorf("jmp *%%eax without jump table: targets %x", targets)
+	}
+}
@@ -0,0 +1,47 @@
+package dis
+
+// Formatter converts a decoded instruction to its textual form in a specific
+// assembly syntax.
+type Formatter interface {
+	FormatInsn(insn *Instruction) string
+}
+
+// ATTSyntax is the AT&T syntax used by objdump and gas, the destination
+// operand is the last one. This is what DumpInsn returns.
+type ATTSyntax struct {
+	// Show addresses as symbols if not nil
+	Symbolizer Symbolizer
+}
+
+func (f ATTSyntax) FormatInsn(insn *Instruction) string {
+	return formatWithSymbolizer(insn, f.Symbolizer, (*Instruction).DumpInsn)
+}
+
+// IntelSyntax is the Intel syntax used by objdump -M intel and NASM style
+// assemblers. The destination operand is the first one, and the size of memory
+// operand is given by the PTR directive instead of mnemonic suffix.
+type IntelSyntax struct {
+	// Show addresses as symbols if not nil
+	Symbolizer Symbolizer
+}
+
+func (f IntelSyntax) FormatInsn(insn *Instruction) string {
+	return formatWithSymbolizer(insn, f.Symbolizer, (*Instruction).intelInsn)
+}
+
+// GoSyntax is the syntax used by go tool objdump and the Go assembler, which
+// is derived from Plan 9. Operands are in AT&T order with Plan 9 register
+// names, e.g. MOVL 0x8(BP), AX.
+type GoSyntax struct {
+	// Show addresses as symbols if not nil, e.g. runtime.main(SB)
+	Symbolizer Symbolizer
+}
+
+func (f GoSyntax) FormatInsn(insn *Instruction) string {
+	return formatWithSymbolizer(insn, f.Symbolizer, (*Instruction).plan9Insn)
+}
+
+// Format the instruction with the given syntax.
+func (insn *Instruction) Format(f Formatter) string {
+	return f.FormatInsn(insn)
+}
@@ -0,0 +1,105 @@
+package dis
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFPU(t *testing.T) {
+	testdata := []codeText{
+		// Memory operand, the suffix tells the size and type
+		{[]byte{0xd8, 0x00}, "fadds (%eax)"},
+		{[]byte{0xdc, 0x00}, "faddl (%eax)"},
+		{[]byte{0xdc, 0x38}, "fdivrl (%eax)"},
+		{[]byte{0xd9, 0x40, 0x04}, "flds 0x4(%eax)"},
+		{[]byte{0xdd, 0x40, 0x04}, "fldl 0x4(%eax)"},
+		{[]byte{0xdb, 0x68, 0x04}, "fldt 0x4(%eax)"},
+		{[]byte{0xdd, 0x5d, 0xf8}, "fstpl -0x8(%ebp)"},
+		{[]byte{0xdf, 0x00}, "filds (%eax)"},
+		{[]byte{0xdb, 0x00}, "fildl (%eax)"},
+		{[]byte{0xdf, 0x28}, "fildll (%eax)"},
+		{[]byte{0xdf, 0x38}, "fistpll (%eax)"},
+		{[]byte{0xde, 0x00}, "fiadds (%eax)"},
+		{[]byte{0xda, 0x00}, "fiaddl (%eax)"},
+		{[]byte{0xdb, 0x08}, "fisttpl (%eax)"},
+		{[]byte{0xdf, 0x20}, "fbld (%eax)"},
+		{[]byte{0xd9, 0x38}, "fnstcw (%eax)"},
+		{[]byte{0xd9, 0x28}, "fldcw (%eax)"},
+		{[]byte{0xdd, 0x20}, "frstor (%eax)"},
+		{[]byte{0xdd, 0x38}, "fnstsw (%eax)"},
+
+		// Register stack operand
+		{[]byte{0xd9, 0xc0}, "fld %st(0)"},
+		{[]byte{0xd9, 0xc3}, "fld %st(3)"},
+		{[]byte{0xdd, 0xdb}, "fstp %st(3)"},
+		{[]byte{0xdd, 0xc3}, "ffree %st(3)"},
+		{[]byte{0xd9, 0xc9}, "fxch %st(1)"},
+		{[]byte{0xd8, 0xd9}, "fcomp %st(1)"},
+		{[]byte{0xdd, 0xe1}, "fucom %st(1)"},
+		{[]byte{0xd8, 0xc2}, "fadd %st(2),%st"},
+		{[]byte{0xdc, 0xc2}, "fadd %st,%st(2)"},
+		{[]byte{0xde, 0xc1}, "faddp %st,%st(1)"},
+		{[]byte{0xde, 0xc9}, "fmulp %st,%st(1)"},
+		{[]byte{0xdb, 0xf2}, "fcomi %st(2),%st"},
+		{[]byte{0xdb, 0xea}, "fucomi %st(2),%st"},
+		{[]byte{0xdf, 0xf1}, "fcomip %st(1),%st"},
+		{[]byte{0xda, 0xc1}, "fcmovb %st(1),%st"},
+		// fsub(r) and fdiv(r) with st(i) as destination are swapped in
+		// AT&T syntax
+		{[]byte{0xd8, 0xe2}, "fsub %st(2),%st"},
+		{[]byte{0xdc, 0xe2}, "fsub %st,%st(2)"},
+		{[]byte{0xdc, 0xea}, "fsubr %st,%st(2)"},
+		{[]byte{0xdc, 0xf2}, "fdiv %st,%st(2)"},
+		{[]byte{0xde, 0xe1}, "fsubp %st,%st(1)"},
+		{[]byte{0xde, 0xe9}, "fsubrp %st,%st(1)"},
+		{[]byte{0xde, 0xf1}, "fdivp %st,%st(1)"},
+		{[]byte{0xde, 0xf9}, "fdivrp %st,%st(1)"},
+
+		// No operand
+		{[]byte{0xd9, 0xe8}, "fld1 "},
+		{[]byte{0xd9, 0xee}, "fldz "},
+		{[]byte{0xd9, 0xe0}, "fchs "},
+		{[]byte{0xd9, 0xd0}, "fnop "},
+		{[]byte{0xd9, 0xfa}, "fsqrt "},
+		{[]byte{0xde, 0xd9}, "fcompp "},
+		{[]byte{0xda, 0xe9}, "fucompp "},
+		{[]byte{0xdb, 0xe2}, "fnclex "},
+		{[]byte{0xdb, 0xe3}, "fninit "},
+		{[]byte{0xdf, 0xe0}, "fnstsw %ax"},
+
+		// fwait followed by x87 instruction
+		{[]byte{0x9b, 0xd9, 0x7d, 0xfc}, "fstcw -0x4(%ebp)"},
+		{[]byte{0x9b, 0xd9, 0x30}, "fstenv (%eax)"},
+		{[]byte{0x9b, 0xdd, 0x30}, "fsave (%eax)"},
+		{[]byte{0x9b, 0xdd, 0x38}, "fstsw (%eax)"},
+		{[]byte{0x9b, 0xdf, 0xe0}, "fstsw %ax"},
+		{[]byte{0x9b, 0xdb, 0xe2}, "fclex "},
+		{[]byte{0x9b, 0xdb, 0xe3}, "finit "},
+		{[]byte{0x9b}, "fwait "},
+		{[]byte{0x9b}, "fwait "},
+		{[]byte{0xd9, 0xc0}, "fld %st(0)"},
+	}
+	testDump(testdata, t)
+
+	// REX.B does not extend st(i)
+	testDumpMode([]codeText{
+		{[]byte{0xdd, 0x45, 0xf8}, "fldl -0x8(%rbp)"},
+		{[]byte{0x41, 0xd9, 0xc1}, "fld %st(1)"},
+	}, Mode64, t)
+
+	// Register forms which are not defined
+	invalid := [][]byte{
+		{0xd9, 0xd1},
+		{0xd9, 0x08},
+		{0xda, 0xe8},
+		{0xdb, 0xf8},
+		// Memory only
+		{0xdd, 0xf0},
+	}
+	for _, code := range invalid {
+		_, err := Decode(code, Mode32)
+		if !errors.Is(err, ErrUnknownOpcode) && !errors.Is(err, ErrInvalidModRM) {
+			t.Errorf("% x: got error %v, should be invalid", code, err)
+		}
+	}
+}
@@ -0,0 +1,446 @@
+package dis
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Intel syntax, following the output of objdump -M intel. Registers and
+// immediates have no sigil, and memory operand looks like
+// DWORD PTR fs:[eax+ecx*4+0x10].
+
+// Name of memory operand size used with PTR, indexed by size in bytes.
+var intelSizeName = map[int]string{
+	1:  "BYTE",
+	2:  "WORD",
+	4:  "DWORD",
+	6:  "FWORD",
+	8:  "QWORD",
+	10: "TBYTE",
+	16: "XMMWORD",
+	32: "YMMWORD",
+	64: "ZMMWORD",
+}
+
+// Mnemonics which are different in Intel syntax.
+var intelMnemonic = map[uint16]string{
+	Insn_Int_3:    "int3",
+	Insn_Jmp_far:  "jmp",
+	Insn_Call_far: "call",
+}
+
+// Register pairs of 16-bit addressing, indexed by the rm field.
+var intelRm16Name = [...]string{
+	"bx+si",
+	"bx+di",
+	"bp+si",
+	"bp+di",
+	"si",
+	"di",
+	"bp",
+	"bx",
+}
+
+var segPrefixName = map[int]string{
+	PrefixCS: "cs",
+	PrefixSS: "ss",
+	PrefixDS: "ds",
+	PrefixES: "es",
+	PrefixFS: "fs",
+	PrefixGS: "gs",
+}
+
+// Return the segment override prefix, or seg if there's no override. The
+// result includes the colon unless both are empty.
+func (insn *Instruction) intelSegPrefix(seg string) string {
+	if name, ok := segPrefixName[insn.Prefix&(PrefixCS|PrefixSS|PrefixDS|PrefixES|PrefixFS|PrefixGS)]; ok {
+		seg = name
+	}
+	if seg == "" {
+		return ""
+	}
+	return seg + ":"
+}
+
+// Return the PTR directive telling the size of memory operand. EVEX embedded
+// broadcast uses BCST with the element size instead.
+func (insn *Instruction) intelPtr(operand byte) string {
+	n := insn.memSize(operand)
+	switch {
+	case n == 0:
+		return ""
+	case insn.Evex != 0 && insn.EvexB:
+		return intelSizeName[n] + " BCST "
+	case operand == OT_MEM64_128 && n == 16:
+		// cmpxchg16b
+		return "OWORD PTR "
+	}
+	return intelSizeName[n] + " PTR "
+}
+
+// Return the operand size whose width is n bytes.
+func sizeOfBytes(n int) byte {
+	for size, width := range sizeInBytes {
+		if width == n {
+			return byte(size)
+		}
+	}
+	panic("no operand size for width")
+}
+
+// Memory operand specified by ModR/M, with the size directive.
+func (insn *Instruction) intelMem(operand byte) string {
+	return insn.intelPtr(operand) + insn.intelAddr()
+}
+
+// Memory address specified by ModR/M. Absolute address is shown as
+// segment:offset without brackets.
+func (insn *Instruction) intelAddr() string {
+	addressSize := insn.EffectiveAddressSize()
+	var regs string
+	switch {
+	case insn.IsRipRelative():
+		if addressSize == OpSizeQuad {
+			regs = "rip"
+		} else {
+			regs = "eip"
+		}
+	case addressSize == OpSizeWord:
+		if !(insn.Rm&7 == 6 && insn.Mod == 0) {
+			regs = intelRm16Name[insn.Rm&7]
+		}
+	case insn.Scale != 0:
+		regs = insn.intelSIB()
+	case !(insn.Rm&7 == 5 && insn.Mod == 0):
+		regs = insn.gpRegName(insn.Rm, addressSize)
+	}
+	sym := insn.dispSymbol()
+	if regs == "" {
+		if sym == "" {
+			sym = dumpUnsignedValue(addressSize, int64(insn.Disp))
+		}
+		return insn.intelSegPrefix("ds") + sym
+	}
+
+	dump := insn.intelSegPrefix("") + "[" + regs
+	// RIP-relative displacement is shown as unsigned value by objdump
+	if sym != "" {
+		dump += "+" + sym
+	} else if insn.IsRipRelative() {
+		dump += "+" + dumpUnsignedValue(addressSize, int64(insn.Disp))
+	} else if insn.DispSize != 0 {
+		disp := insn.dumpDisp()
+		if disp[0] != '-' {
+			disp = "+" + disp
+		}
+		dump += disp
+	}
+	return dump + "]"
+}
+
+// Base and scaled index of SIB. Pseudo index register eiz is used by objdump
+// in the same cases as AT&T syntax.
+func (insn *Instruction) intelSIB() string {
+	addressSize := insn.EffectiveAddressSize()
+	var base, index string
+	if !(insn.Base&7 == 5 && insn.Mod == 0) {
+		base = insn.gpRegName(insn.Base, addressSize)
+	}
+	if insn.Index != 4 {
+		index = fmt.Sprintf("%s*%d", insn.gpRegName(insn.Index, addressSize), insn.Scale)
+	} else if insn.hasPseudoIndex() {
+		index = fmt.Sprintf("eiz*%d", insn.Scale)
+		if addressSize == OpSizeQuad {
+			index = fmt.Sprintf("riz*%d", insn.Scale)
+		}
+	}
+	if base != "" && index != "" {
+		return base + "+" + index
+	}
+	return base + index
+}
+
+// Register or memory operand specified by ModR/M. size is the register size.
+func (insn *Instruction) intelRm(operand, size byte) string {
+	if insn.Mod == 3 {
+		return insn.gpRegName(insn.Rm, size)
+	}
+	return insn.intelMem(operand)
+}
+
+// Vector register or memory operand specified by ModR/M. l is the vector
+// length of the register.
+func (insn *Instruction) intelVecRm(operand, l byte) string {
+	if insn.Mod == 3 {
+		return vecReg(insn.Rm, l)
+	}
+	return insn.intelMem(operand)
+}
+
+// Immediate value is shown as unsigned value of the operand size.
+func (insn *Instruction) intelImm(size byte) string {
+	if size == OpSizeFull {
+		size = insn.EffectiveOperandSize()
+	}
+	return dumpUnsignedValue(size, insn.ImmOff)
+}
+
+// Implicit memory operand of string instructions, addressed by reg. The
+// destination of string instructions always uses es, and the source can be
+// overridden.
+func (insn *Instruction) intelStringMem(reg byte, size byte) string {
+	seg := insn.intelSegPrefix("ds")
+	if reg == Edi {
+		seg = "es:"
+	}
+	return fmt.Sprintf("%s PTR %s[%s]", intelSizeName[sizeInBytes[size]], seg,
+		insn.gpRegName(reg, insn.EffectiveAddressSize()))
+}
+
+func (insn *Instruction) intelOperand(operand byte) (dump string) {
+	switch operand {
+	// Immediate value
+	case OT_IMM8, OT_IMM16, OT_IMM32, OT_IMM_FULL, OT_IMM16_1, OT_IMM8_1:
+		dump = insn.intelImm(ot2size[operand])
+	case OT_IMM8_2:
+		dump = dumpUnsignedValue(OpSizeByte, insn.imm2)
+	case OT_PTR16_FULL:
+		dump = fmt.Sprintf("%#x:%s", uint16(insn.imm2),
+			dumpUnsignedValue(insn.EffectiveOperandSize(), insn.ImmOff))
+	// Sign-extended to the operand size
+	case OT_SEIMM8:
+		dump = insn.intelImm(OpSizeFull)
+	case OT_CONST1:
+		dump = "1"
+
+	// Memory offset are always unsigned
+	case OT_MOFFS8, OT_MOFFS_FULL:
+		dump = insn.intelSegPrefix("ds")
+		if sym := insn.moffsSymbol(); sym != "" {
+			dump += sym
+		} else {
+			dump += dumpUnsignedValue(insn.EffectiveAddressSize(), insn.ImmOff)
+		}
+
+	// Register
+	case OT_REG8, OT_IB_RB, OT_REG16, OT_REG32,
+		OT_REG_FULL, OT_IB_R_FULL:
+		dump = insn.gpRegName(insn.Reg, ot2size[operand])
+	case OT_REG32_64:
+		dump = insn.gpRegName(insn.Reg, insn.size32or64())
+	case OT_ACC8, OT_ACC16, OT_ACC_FULL:
+		dump = insn.gpRegName(Eax, ot2size[operand])
+	// in and out can't use 64-bit register
+	case OT_ACC_FULL_NOT64:
+		size := insn.EffectiveOperandSize()
+		if size == OpSizeQuad {
+			size = OpSizeLong
+		}
+		dump = insn.gpRegName(Eax, size)
+	case OT_REGCL:
+		dump = insn.gpRegName(Cl, ot2size[operand])
+	case OT_REGDX:
+		dump = insn.gpRegName(Edx, OpSizeWord)
+	// Segment register
+	case OT_SREG, OT_SEG:
+		dump = segRegName[insn.Reg]
+	// Control and debug register
+	case OT_CREG:
+		dump = cregName[insn.Reg]
+	case OT_DREG:
+		dump = fmt.Sprintf("dr%d", insn.Reg)
+
+	// Implicit memory operand of string instructions and xlat
+	case OT_REGI_EDI:
+		dump = insn.intelStringMem(Edi, insn.stringOperandSize())
+	case OT_REGI_ESI:
+		dump = insn.intelStringMem(Esi, insn.stringOperandSize())
+	case OT_REGI_EBXAL:
+		dump = insn.intelStringMem(Ebx, OpSizeByte)
+
+	// Register or memory
+	case OT_RM8, OT_RM16, OT_RM_FULL, OT_MEM, OT_MEM16_FULL:
+		dump = insn.intelRm(operand, ot2size[operand])
+	case OT_RM32:
+		dump = insn.intelRm(operand, OpSizeLong)
+	case OT_RM32_64:
+		dump = insn.intelRm(operand, insn.size32or64())
+	case OT_RFULL_M16:
+		dump = insn.intelRm(operand, insn.EffectiveOperandSize())
+	// lgdt and related, the size is shown in the mnemonic
+	case OT_MEM16_3264:
+		dump = insn.intelAddr()
+	case OT_FREG32_64_RM:
+		if insn.Mode == Mode64 {
+			dump = insn.gpRegName(insn.Rm, OpSizeQuad)
+		} else {
+			dump = insn.gpRegName(insn.Rm, OpSizeLong)
+		}
+	// Memory only operand with fixed size
+	case OT_MEM32, OT_MEM32_64, OT_MEM64, OT_MEM128, OT_MEM64_128:
+		dump = insn.intelMem(operand)
+	// The register form is another instruction without operand, e.g. clflush
+	// and sfence
+	case OT_MEM_OPT:
+		if insn.Mod != 3 {
+			dump = insn.intelMem(operand)
+		}
+	// 32-bit register if used as register, e.g. pextrb and pinsrw
+	case OT_R32_M8, OT_R32_M16, OT_R32_64_M8, OT_R32_64_M16:
+		dump = insn.intelRm(operand, OpSizeLong)
+	case OT_REG32_64_M8, OT_REG32_64_M16:
+		dump = insn.intelRm(operand, insn.size32or64())
+	// General purpose register sized by VEX.W, e.g. vmovd and vmovq
+	case OT_WREG32_64:
+		dump = insn.gpRegName(insn.Reg, insn.vexWSize())
+	case OT_WRM32_64:
+		dump = insn.intelRm(operand, insn.vexWSize())
+
+	// Relative branch and call, shown as the absolute target
+	case OT_RELCB, OT_RELC_FULL:
+		dump = insn.targetString()
+
+	// MMX register or memory
+	case OT_MM:
+		dump = mmReg(insn.Reg)
+	case OT_MM_RM, OT_MM32, OT_MM64:
+		if insn.Mod == 3 {
+			dump = mmReg(insn.Rm)
+		} else {
+			dump = insn.intelMem(operand)
+		}
+
+	// Vector register in the reg field
+	case OT_XMM:
+		dump = vecReg(insn.Reg, 0)
+	case OT_YXMM:
+		dump = vecReg(insn.Reg, insn.VexL)
+	case OT_YMM:
+		dump = vecReg(insn.Reg, 1)
+	// Vector register in VEX.vvvv
+	case OT_VXMM:
+		dump = vecReg(insn.Vvvv, 0)
+	case OT_VYXMM:
+		dump = vecReg(insn.Vvvv, insn.VexL)
+	case OT_VYMM:
+		dump = vecReg(insn.Vvvv, 1)
+	// Vector register in the immediate
+	case OT_XMM_IMM:
+		dump = vecReg(insn.immReg(), 0)
+	case OT_YXMM_IMM:
+		dump = vecReg(insn.immReg(), insn.VexL)
+	// Vector register or memory
+	case OT_XMM_RM, OT_XMM8, OT_XMM16, OT_XMM32, OT_XMM64, OT_XMM128,
+		OT_LXMM64_128, OT_WXMM32_64:
+		dump = insn.intelVecRm(operand, 0)
+	case OT_YXMM64_256, OT_YXMM128_256, OT_LMEM128_256:
+		dump = insn.intelVecRm(operand, insn.VexL)
+	case OT_YMM256:
+		dump = insn.intelVecRm(operand, 1)
+	// Opmask register in the reg field, VEX.vvvv, or register or memory
+	case OT_KREG:
+		dump = maskReg(insn.Reg)
+	case OT_VK:
+		dump = maskReg(insn.Vvvv)
+	case OT_KRM:
+		if insn.Mod == 3 {
+			dump = maskReg(insn.Rm)
+		} else {
+			dump = insn.intelMem(operand)
+		}
+	// x87 register stack, st(0) is shown as st
+	case OT_FPU_SI:
+		dump = insn.fpuReg()
+	case OT_FPU_SSI:
+		dump = "st," + insn.fpuReg()
+	case OT_FPU_SIS:
+		dump = insn.fpuReg() + ",st"
+	case OT_FPUM16, OT_FPUM32, OT_FPUM64, OT_FPUM80:
+		dump = insn.intelMem(operand)
+
+	// Implicit xmm0, e.g. blendvps
+	case OT_REGXMM0:
+		dump = vecReg(0, 0)
+	}
+	return
+}
+
+// Intel syntax has no size suffix, and keeps the original mnemonic of
+// fsub(r) and fdiv(r).
+func (insn *Instruction) intelMnemonic() string {
+	name := InsnName[insn.OpId]
+	if n, ok := intelMnemonic[insn.OpId]; ok {
+		name = n
+	}
+	if insn.Info.Flag&IFLAG_PSEUDO_OPCODE != 0 {
+		name = insn.dumpCmpMnemonic(name)
+	}
+
+	switch insn.opcodeAll {
+	case 0xa0, 0xa1, 0xa2, 0xa3:
+		// mov with 64-bit memory offset
+		if insn.EffectiveAddressSize() == OpSizeQuad {
+			name = "movabs"
+		}
+	case 0xb8, 0xb9, 0xba, 0xbb, 0xbc, 0xbd, 0xbe, 0xbf:
+		// mov with 64-bit immediate
+		if insn.EffectiveOperandSize() == OpSizeQuad {
+			name = "movabs"
+		}
+	}
+	if insn.hasWordSuffix(false) {
+		name += "w"
+	}
+	// lgdt and related outside 64-bit mode have the operand size as suffix
+	if insn.Info.Operand[0] == OT_MEM16_3264 && insn.Mode != Mode64 {
+		if insn.EffectiveOperandSize() == OpSizeWord {
+			name += "w"
+		} else {
+			name += "d"
+		}
+	}
+	return name + " "
+}
+
+// Dump EVEX opmask and zeroing, which is shown after the destination operand.
+func (insn *Instruction) intelOpmask() (dump string) {
+	if insn.Opmask != 0 {
+		dump = fmt.Sprintf("{k%d}", insn.Opmask)
+	}
+	if insn.Zeroing {
+		dump += "{z}"
+	}
+	return
+}
+
+func (insn *Instruction) intelInsn() string {
+	var buf bytes.Buffer
+
+	buf.WriteString(insn.dumpRepLockPrefix())
+	buf.WriteString(insn.intelMnemonic())
+
+	n := insn.Info.countOperand()
+	operands := make([]string, n, n+1)
+	for i := 0; i < n; i++ {
+		operands[i] = insn.intelOperand(insn.Info.Operand[i])
+	}
+	if n > 0 {
+		if insn.Evex != 0 {
+			operands[0] += insn.intelOpmask()
+		}
+		// Rounding and SAE follow the last register operand
+		operands[n-1] += strings.TrimSuffix(insn.dumpEvexRounding(), ",")
+	}
+	// The immediate of cmpps etc. is the last operand if it's not a valid
+	// predicate.
+	if insn.Info.Flag&IFLAG_PSEUDO_OPCODE != 0 && !insn.hasCmpPredicate() {
+		operands = append(operands, dumpUnsignedValue(OpSizeByte, insn.ImmOff))
+	}
+	// objdump shows the accumulator first for scas, unlike cmps
+	if insn.OpId == Insn_Scas {
+		operands[0], operands[1] = operands[1], operands[0]
+	}
+	buf.WriteString(strings.Join(operands, ","))
+	return buf.String()
+}
@@ -0,0 +1,207 @@
+package dis
+
+import (
+	"testing"
+)
+
+func testIntel(testdata []codeText, mode Mode, t *testing.T) {
+	for _, ct := range testdata {
+		insn, err := Decode(ct.binary, mode)
+		if err != nil {
+			t.Errorf("% x: %v", ct.binary, err)
+			continue
+		}
+		if dump := insn.Format(IntelSyntax{}); dump != ct.assembly {
+			t.Errorf("% x: got %s, should be %s", ct.binary, dump, ct.assembly)
+		}
+	}
+}
+
+// Expected output is from objdump -M intel, except that jz and jnz are used
+// for je and jne, and mnemonic without operand has a trailing space, both are
+// the same as AT&T syntax.
+func TestIntelSyntax(t *testing.T) {
+	testdata := []codeText{
+		// Memory operand size is given by PTR
+		{[]byte{0x83, 0x44, 0x88, 0x04, 0x01}, "add DWORD PTR [eax+ecx*4+0x4],0x1"},
+		{[]byte{0x66, 0x83, 0x00, 0x10}, "add WORD PTR [eax],0x10"},
+		{[]byte{0xf6, 0x00, 0x01}, "test BYTE PTR [eax],0x1"},
+		{[]byte{0x8b, 0x45, 0xec}, "mov eax,DWORD PTR [ebp-0x14]"},
+		{[]byte{0xff, 0x30}, "push DWORD PTR [eax]"},
+		{[]byte{0x0f, 0xb6, 0x00}, "movzx eax,BYTE PTR [eax]"},
+		{[]byte{0x66, 0x0f, 0xbe, 0x00}, "movsx ax,BYTE PTR [eax]"},
+		{[]byte{0x8e, 0x18}, "mov ds,WORD PTR [eax]"},
+		{[]byte{0x0f, 0x02, 0x18}, "lar ebx,WORD PTR [eax]"},
+		// Register operand of lar and lsl has the operand size
+		{[]byte{0x0f, 0x02, 0xdd}, "lar ebx,ebp"},
+		{[]byte{0x66, 0x0f, 0x03, 0xdd}, "lsl bx,bp"},
+		{[]byte{0xc5, 0x08}, "lds ecx,FWORD PTR [eax]"},
+		{[]byte{0xff, 0x28}, "jmp FWORD PTR [eax]"},
+		{[]byte{0x62, 0x10}, "bound edx,QWORD PTR [eax]"},
+		{[]byte{0x0f, 0xc7, 0x08}, "cmpxchg8b QWORD PTR [eax]"},
+		{[]byte{0x0f, 0x18, 0x08}, "prefetcht0 BYTE PTR [eax]"},
+		{[]byte{0x0f, 0xae, 0x38}, "clflush BYTE PTR [eax]"},
+		{[]byte{0x0f, 0x01, 0x38}, "invlpg BYTE PTR [eax]"},
+		// Address without size
+		{[]byte{0x8d, 0xa1, 0x00, 0x00, 0x00, 0x40}, "lea esp,[ecx+0x40000000]"},
+		{[]byte{0x8d, 0x34, 0x20}, "lea esi,[eax+eiz*1]"},
+		{[]byte{0x8d, 0x44, 0x24, 0x40}, "lea eax,[esp+0x40]"},
+		{[]byte{0x8b, 0x04, 0xa4}, "mov eax,DWORD PTR [esp+eiz*4]"},
+		{[]byte{0x0f, 0x01, 0x00}, "sgdtd [eax]"},
+		{[]byte{0x66, 0x0f, 0x01, 0x00}, "sgdtw [eax]"},
+		// Absolute address and segment override
+		{[]byte{0xff, 0x15, 0x5c, 0xb7, 0x30, 0xc0}, "call DWORD PTR ds:0xc030b75c"},
+		{[]byte{0xa1, 0x34, 0x12, 0x00, 0x00}, "mov eax,ds:0x1234"},
+		{[]byte{0xa2, 0x34, 0x12, 0x00, 0x00}, "mov ds:0x1234,al"},
+		{[]byte{0x64, 0xa1, 0x14, 0x00, 0x00, 0x00}, "mov eax,fs:0x14"},
+		{[]byte{0x64, 0x89, 0x01}, "mov DWORD PTR fs:[ecx],eax"},
+		{[]byte{0x8b, 0x04, 0x25, 0x10, 0x00, 0x00, 0x00}, "mov eax,DWORD PTR [eiz*1+0x10]"},
+		// 16-bit addressing
+		{[]byte{0x67, 0x8b, 0x00}, "mov eax,DWORD PTR [bx+si]"},
+
+		// Register and immediate
+		{[]byte{0x83, 0xc0, 0xff}, "add eax,0xffffffff"},
+		{[]byte{0x6a, 0xff}, "push 0xffffffff"},
+		{[]byte{0x6b, 0xc8, 0x10}, "imul ecx,eax,0x10"},
+		{[]byte{0x91}, "xchg ecx,eax"},
+		{[]byte{0xec}, "in al,dx"},
+		{[]byte{0xe7, 0x10}, "out 0x10,eax"},
+		{[]byte{0xd3, 0xe0}, "shl eax,cl"},
+		{[]byte{0xd1, 0x20}, "shl DWORD PTR [eax],1"},
+		{[]byte{0x0f, 0x20, 0xc0}, "mov eax,cr0"},
+		{[]byte{0x0f, 0x23, 0xf8}, "mov dr7,eax"},
+		{[]byte{0x8c, 0xd8}, "mov eax,ds"},
+		{[]byte{0xc2, 0x08, 0x00}, "ret 0x8"},
+		{[]byte{0xcd, 0x80}, "int 0x80"},
+		{[]byte{0xcc}, "int3 "},
+		{[]byte{0x98}, "cwde "},
+		{[]byte{0x99}, "cdq "},
+		{[]byte{0x75, 0x16}, "jnz 0x18"},
+		{[]byte{0x66, 0xe8, 0x00, 0x10}, "callw 0x1004"},
+
+		// String instructions show the implicit memory operands
+		{[]byte{0xa5}, "movs DWORD PTR es:[edi],DWORD PTR ds:[esi]"},
+		{[]byte{0xa6}, "cmps BYTE PTR ds:[esi],BYTE PTR es:[edi]"},
+		{[]byte{0xf3, 0xab}, "rep stos DWORD PTR es:[edi],eax"},
+		{[]byte{0xad}, "lods eax,DWORD PTR ds:[esi]"},
+		{[]byte{0x26, 0xad}, "lods eax,DWORD PTR es:[esi]"},
+		{[]byte{0xf2, 0xae}, "repnz scas al,BYTE PTR es:[edi]"},
+		{[]byte{0xf3, 0xa6}, "repz cmps BYTE PTR ds:[esi],BYTE PTR es:[edi]"},
+		{[]byte{0xf2, 0xe8, 0x00, 0x00, 0x00, 0x00}, "bnd call 0x6"},
+		{[]byte{0xf3, 0xc3}, "repz ret "},
+		{[]byte{0x6c}, "ins BYTE PTR es:[edi],dx"},
+		{[]byte{0x6f}, "outs dx,DWORD PTR ds:[esi]"},
+		{[]byte{0xd7}, "xlat BYTE PTR ds:[ebx]"},
+		{[]byte{0xf0, 0xff, 0x00}, "lock inc DWORD PTR [eax]"},
+
+		// x87, fsub(r) and fdiv(r) are not swapped in Intel syntax
+		{[]byte{0xd8, 0xc2}, "fadd st,st(2)"},
+		{[]byte{0xdc, 0xe2}, "fsubr st(2),st"},
+		{[]byte{0xde, 0xe1}, "fsubrp st(1),st"},
+		{[]byte{0xd9, 0xc3}, "fld st(3)"},
+		{[]byte{0xdb, 0x28}, "fld TBYTE PTR [eax]"},
+		{[]byte{0xdf, 0x28}, "fild QWORD PTR [eax]"},
+		{[]byte{0xdf, 0x00}, "fild WORD PTR [eax]"},
+		{[]byte{0xdf, 0x20}, "fbld TBYTE PTR [eax]"},
+		{[]byte{0xd9, 0x28}, "fldcw WORD PTR [eax]"},
+		{[]byte{0xd9, 0x30}, "fnstenv [eax]"},
+		{[]byte{0xdf, 0xe0}, "fnstsw ax"},
+
+		// MMX and SSE
+		{[]byte{0x0f, 0x6f, 0x08}, "movq mm1,QWORD PTR [eax]"},
+		{[]byte{0x0f, 0x7e, 0x00}, "movd DWORD PTR [eax],mm0"},
+		{[]byte{0x0f, 0xc4, 0x08, 0x05}, "pinsrw mm1,WORD PTR [eax],0x5"},
+		{[]byte{0xf2, 0x0f, 0x10, 0x00}, "movsd xmm0,QWORD PTR [eax]"},
+		{[]byte{0x0f, 0x17, 0x00}, "movhps QWORD PTR [eax],xmm0"},
+		{[]byte{0x0f, 0xc2, 0x08, 0x01}, "cmpltps xmm1,XMMWORD PTR [eax]"},
+		{[]byte{0xf3, 0x0f, 0x12, 0x00}, "movsldup xmm0,XMMWORD PTR [eax]"},
+		{[]byte{0xf3, 0x0f, 0x16, 0x00}, "movshdup xmm0,XMMWORD PTR [eax]"},
+		{[]byte{0x0f, 0xc2, 0xc1, 0x08}, "cmpps xmm0,xmm1,0x8"},
+
+		// Two immediates
+		{[]byte{0xc8, 0x10, 0x00, 0x01}, "enter 0x10,0x1"},
+		{[]byte{0x66, 0xc8, 0x10, 0x00, 0x01}, "enterw 0x10,0x1"},
+		{[]byte{0xea, 0x78, 0x56, 0x34, 0x12, 0x10, 0x00}, "jmp 0x10:0x12345678"},
+		{[]byte{0x9a, 0x78, 0x56, 0x34, 0x12, 0x10, 0x00}, "call 0x10:0x12345678"},
+	}
+	testIntel(testdata, Mode32, t)
+
+	testdata = []codeText{
+		{[]byte{0x8b, 0x05, 0x00, 0x01, 0x00, 0x00}, "mov eax,DWORD PTR [rip+0x100]"},
+		{[]byte{0xc5, 0xfa, 0x10, 0x05, 0xf0, 0xff, 0xff, 0xff}, "vmovss xmm0,DWORD PTR [rip+0xfffffffffffffff0]"},
+		{[]byte{0x8b, 0x04, 0x25, 0x34, 0x12, 0x00, 0x00}, "mov eax,DWORD PTR ds:0x1234"},
+		{[]byte{0x65, 0x48, 0x8b, 0x04, 0x25, 0x28, 0x00, 0x00, 0x00}, "mov rax,QWORD PTR gs:0x28"},
+		{[]byte{0x48, 0x8d, 0x04, 0x8d, 0x10, 0x00, 0x00, 0x00}, "lea rax,[rcx*4+0x10]"},
+		{[]byte{0x48, 0x8d, 0x44, 0x24, 0x40}, "lea rax,[rsp+0x40]"},
+		{[]byte{0x41, 0x8b, 0x04, 0xa4}, "mov eax,DWORD PTR [r12+riz*4]"},
+		{[]byte{0x8b, 0x04, 0x65, 0x00, 0x00, 0x00, 0x00}, "mov eax,DWORD PTR [riz*2+0x0]"},
+		{[]byte{0x0f, 0x01, 0x10}, "lgdt [rax]"},
+		{[]byte{0xa1, 0x90, 0x78, 0x56, 0x34, 0x12, 0x00, 0x00, 0x00}, "movabs eax,ds:0x1234567890"},
+		{[]byte{0x48, 0xb8, 0x90, 0x78, 0x56, 0x34, 0x12, 0x00, 0x00, 0x00}, "movabs rax,0x1234567890"},
+		{[]byte{0x48, 0x63, 0x00}, "movsxd rax,DWORD PTR [rax]"},
+		{[]byte{0x40, 0x0f, 0xb6, 0xc6}, "movzx eax,sil"},
+		{[]byte{0x48, 0x0f, 0xc7, 0x08}, "cmpxchg16b OWORD PTR [rax]"},
+		{[]byte{0xf2, 0x48, 0x0f, 0x38, 0xf1, 0x00}, "crc32 rax,QWORD PTR [rax]"},
+		{[]byte{0xf2, 0x48, 0x0f, 0x2a, 0x00}, "cvtsi2sd xmm0,QWORD PTR [rax]"},
+		{[]byte{0x66, 0x0f, 0x3a, 0x14, 0x00, 0x01}, "pextrb BYTE PTR [rax],xmm0,0x1"},
+		{[]byte{0x66, 0x0f, 0x38, 0x14, 0x08}, "blendvps xmm1,XMMWORD PTR [rax],xmm0"},
+
+		// VEX
+		{[]byte{0xc5, 0xf9, 0x5a, 0x00}, "vcvtpd2ps xmm0,XMMWORD PTR [rax]"},
+		{[]byte{0xc5, 0xfd, 0x5a, 0x00}, "vcvtpd2ps xmm0,YMMWORD PTR [rax]"},
+		{[]byte{0xc5, 0xfc, 0x5a, 0x00}, "vcvtps2pd ymm0,XMMWORD PTR [rax]"},
+		{[]byte{0xc5, 0xf8, 0x5a, 0x00}, "vcvtps2pd xmm0,QWORD PTR [rax]"},
+		{[]byte{0xc4, 0xe1, 0xf9, 0x7e, 0xc0}, "vmovq rax,xmm0"},
+		{[]byte{0xc4, 0xe1, 0xf2, 0x2a, 0x00}, "vcvtsi2ss xmm0,xmm1,QWORD PTR [rax]"},
+		{[]byte{0xc4, 0xe3, 0x71, 0x4a, 0x00, 0x30}, "vblendvps xmm0,xmm1,XMMWORD PTR [rax],xmm3"},
+		{[]byte{0xc5, 0xf4, 0xc2, 0xc2, 0x01}, "vcmpltps ymm0,ymm1,ymm2"},
+
+		// EVEX opmask, broadcast and rounding
+		{[]byte{0x62, 0xf1, 0x7c, 0x48, 0x10, 0x08}, "vmovups zmm1,ZMMWORD PTR [rax]"},
+		{[]byte{0x62, 0xf1, 0x75, 0x48, 0xef, 0x40, 0x01}, "vpxord zmm0,zmm1,ZMMWORD PTR [rax+0x40]"},
+		{[]byte{0x62, 0xf1, 0x74, 0xd9, 0x58, 0x00}, "vaddps zmm0{k1}{z},zmm1,DWORD BCST [rax]"},
+		{[]byte{0x62, 0xf1, 0xf5, 0x58, 0x58, 0x00}, "vaddpd zmm0,zmm1,QWORD BCST [rax]"},
+		{[]byte{0x62, 0xf1, 0x74, 0x18, 0x58, 0xc2}, "vaddps zmm0,zmm1,zmm2{rn-sae}"},
+
+		// hint nop
+		{[]byte{0xf3, 0x0f, 0x1e, 0xfa}, "endbr64 "},
+		{[]byte{0x0f, 0x1f, 0x44, 0x00, 0x00}, "nop DWORD PTR [rax+rax*1+0x0]"},
+		{[]byte{0x66, 0x0f, 0x1e, 0x00}, "nop WORD PTR [rax]"},
+		{[]byte{0xf3, 0x0f, 0x1e, 0x00}, "repz nop DWORD PTR [rax]"},
+
+		// AVX-512 opmask, compare, ternlog, broadcast and permute
+		{[]byte{0xc5, 0xf8, 0x92, 0xc8}, "kmovw k1,eax"},
+		{[]byte{0xc4, 0xe1, 0xfb, 0x93, 0xc1}, "kmovq rax,k1"},
+		{[]byte{0xc5, 0xf8, 0x90, 0x08}, "kmovw k1,WORD PTR [rax]"},
+		{[]byte{0xc4, 0xe1, 0xf9, 0x91, 0x08}, "kmovd DWORD PTR [rax],k1"},
+		{[]byte{0xc5, 0xec, 0x41, 0xcb}, "kandw k1,k2,k3"},
+		{[]byte{0xc5, 0xf8, 0x98, 0xca}, "kortestw k1,k2"},
+		{[]byte{0xc4, 0xe3, 0xf9, 0x30, 0xca, 0x05}, "kshiftrw k1,k2,0x5"},
+		{[]byte{0x62, 0xf1, 0x7d, 0x4a, 0x76, 0xc1}, "vpcmpeqd k0{k2},zmm0,zmm1"},
+		{[]byte{0x62, 0xf1, 0x7d, 0x58, 0x76, 0x40, 0x01}, "vpcmpeqd k0,zmm0,DWORD BCST [rax+0x4]"},
+		{[]byte{0x62, 0xf3, 0x75, 0x48, 0x1f, 0xc2, 0x01}, "vpcmpltd k0,zmm1,zmm2"},
+		{[]byte{0x62, 0xf3, 0x75, 0x48, 0x1f, 0xc2, 0x03}, "vpcmpd k0,zmm1,zmm2,0x3"},
+		{[]byte{0x62, 0xf1, 0x74, 0x18, 0xc2, 0xca, 0x01}, "vcmpltps k1,zmm1,zmm2{sae}"},
+		{[]byte{0x62, 0xf1, 0x74, 0x18, 0xc2, 0xca, 0x20}, "vcmpps k1,zmm1,zmm2{sae},0x20"},
+		{[]byte{0x62, 0xf3, 0x75, 0x48, 0x25, 0xc2, 0x96}, "vpternlogd zmm0,zmm1,zmm2,0x96"},
+		{[]byte{0x62, 0xf2, 0x7d, 0x48, 0x78, 0x50, 0x01}, "vpbroadcastb zmm2,BYTE PTR [rax+0x1]"},
+		{[]byte{0x62, 0xf2, 0x7d, 0x48, 0x58, 0x78, 0x01}, "vpbroadcastd zmm7,DWORD PTR [rax+0x4]"},
+		{[]byte{0x62, 0xf2, 0xfd, 0x48, 0x7c, 0xc0}, "vpbroadcastq zmm0,rax"},
+		{[]byte{0x62, 0xf2, 0x75, 0x48, 0x77, 0xc2}, "vpermi2ps zmm0,zmm1,zmm2"},
+		{[]byte{0x62, 0xf2, 0x7e, 0x48, 0x29, 0xc1}, "vpmovb2m k0,zmm1"},
+		{[]byte{0x62, 0xf2, 0x7e, 0x48, 0x38, 0xc1}, "vpmovm2d zmm0,k1"},
+	}
+	testIntel(testdata, Mode64, t)
+}
+
+// The AT&T formatter is the same as DumpInsn.
+func TestATTSyntax(t *testing.T) {
+	code := []byte{0x8b, 0x45, 0xec}
+	insn, err := Decode(code, Mode32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dump := insn.Format(ATTSyntax{}); dump != "mov -0x14(%ebp),%eax" || dump != insn.DumpInsn() {
+		t.Errorf("% x: got %s, should be %s", code, dump, insn.DumpInsn())
+	}
+}
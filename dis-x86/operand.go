@@ -0,0 +1,537 @@
+package dis
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Operand is a decoded operand. It's one of Reg, Mem, Imm, Rel and FarPtr.
+type Operand interface {
+	String() string
+	isOperand()
+}
+
+// RegClass tells the kind of a register.
+type RegClass byte
+
+const (
+	RegGP    RegClass = iota + 1 // General purpose register
+	RegSeg                       // Segment register
+	RegCtrl                      // Control register
+	RegDebug                     // Debug register
+	RegMMX                       // MMX register
+	RegVec                       // xmm, ymm or zmm register, given by Size
+	RegMask                      // AVX-512 opmask register
+	RegFPU                       // x87 register stack st(i)
+	RegIP                        // Instruction pointer, only used as base of RIP-relative address
+)
+
+// Reg is a register operand. Num is the register number as encoded in the
+// instruction, e.g. Eax or ES. For ah, ch, dh and bh, Num is the number of the
+// containing register (Eax for ah) and High is set.
+type Reg struct {
+	Class RegClass
+	Num   byte
+	Size  int // Size in bytes
+	High  bool
+}
+
+// Mem is a memory operand. Base and Index have zero Class if not used.
+type Mem struct {
+	Segment byte // Segment register used, either the override or the default one
+	Base    Reg
+	Index   Reg
+	Scale   byte
+	Disp    int64
+	// Bytes accessed, 0 if unknown or the memory is not accessed, e.g. lea.
+	// Element size for EVEX embedded broadcast.
+	Size      int
+	Broadcast bool
+}
+
+// Imm is an immediate operand. Value is sign-extended from Size bytes.
+type Imm struct {
+	Value int64
+	Size  int
+}
+
+// Rel is the target of relative branch and call. Offset is relative to the
+// next instruction, Target is the absolute address.
+type Rel struct {
+	Offset int64
+	Target uint64
+}
+
+// FarPtr is the immediate far pointer of jmp and call, e.g. ljmp $0x10,$0x0.
+type FarPtr struct {
+	Segment uint16
+	Offset  uint32
+}
+
+func (Reg) isOperand()    {}
+func (Mem) isOperand()    {}
+func (Imm) isOperand()    {}
+func (Rel) isOperand()    {}
+func (FarPtr) isOperand() {}
+
+// Register name in Intel syntax.
+func (r Reg) String() string {
+	switch r.Class {
+	case RegGP:
+		size := sizeOfBytes(r.Size)
+		switch {
+		case r.High:
+			return regName8[r.Num+Ah]
+		case r.Num >= 8:
+			return fmt.Sprintf("r%d%s", r.Num, extRegSuffix[size])
+		case size == OpSizeByte:
+			return regName8Rex[r.Num]
+		case size == OpSizeWord:
+			return regName[r.Num]
+		case size == OpSizeLong:
+			return "e" + regName[r.Num]
+		}
+		return "r" + regName[r.Num]
+	case RegSeg:
+		return segRegName[r.Num]
+	case RegCtrl:
+		return fmt.Sprintf("cr%d", r.Num)
+	case RegDebug:
+		return fmt.Sprintf("dr%d", r.Num)
+	case RegMMX:
+		return mmReg(r.Num)
+	case RegVec:
+		return fmt.Sprintf("%s%d", vecRegName[r.Size>>5], r.Num)
+	case RegMask:
+		return fmt.Sprintf("k%d", r.Num)
+	case RegFPU:
+		return fmt.Sprintf("st(%d)", r.Num)
+	case RegIP:
+		if r.Size == 8 {
+			return "rip"
+		}
+		return "eip"
+	}
+	return "?"
+}
+
+// Memory operand in Intel syntax, e.g. ds:[eax+ecx*4+0x10].
+func (m Mem) String() string {
+	var parts []string
+	if m.Base.Class != 0 {
+		parts = append(parts, m.Base.String())
+	}
+	if m.Index.Class != 0 {
+		parts = append(parts, fmt.Sprintf("%s*%d", m.Index, m.Scale))
+	}
+	if m.Disp != 0 || len(parts) == 0 {
+		parts = append(parts, fmt.Sprintf("%#x", m.Disp))
+	}
+	return segRegName[m.Segment] + ":[" + strings.Join(parts, "+") + "]"
+}
+
+func (i Imm) String() string {
+	return dumpUnsignedValue(sizeOfBytes(i.Size), i.Value)
+}
+
+func (r Rel) String() string {
+	return fmt.Sprintf("%#x", r.Target)
+}
+
+func (p FarPtr) String() string {
+	return fmt.Sprintf("%#x:%#x", p.Segment, p.Offset)
+}
+
+// Segment register selected by the segment override prefix.
+var segOverride = map[int]byte{
+	PrefixCS: CS,
+	PrefixSS: SS,
+	PrefixDS: DS,
+	PrefixES: ES,
+	PrefixFS: FS,
+	PrefixGS: GS,
+}
+
+// Return the segment used by memory operand, def is used if there's no
+// override prefix.
+func (insn *Instruction) segment(def byte) byte {
+	if seg, ok := segOverride[insn.Prefix&(PrefixCS|PrefixSS|PrefixDS|PrefixES|PrefixFS|PrefixGS)]; ok {
+		return seg
+	}
+	return def
+}
+
+// General purpose register of the given operand size. Without REX prefix,
+// byte register 4 to 7 are ah, ch, dh and bh.
+func (insn *Instruction) gpReg(num byte, size byte) Reg {
+	if size == OpSizeFull {
+		size = insn.EffectiveOperandSize()
+	}
+	r := Reg{Class: RegGP, Num: num, Size: sizeInBytes[size]}
+	if size == OpSizeByte && insn.Rex == 0 && num >= Ah && num <= Bh {
+		r.Num -= Ah
+		r.High = true
+	}
+	return r
+}
+
+// Vector register, l is the vector length as encoded in VEX.L or EVEX.L'L.
+func vecRegOperand(num byte, l byte) Reg {
+	return Reg{Class: RegVec, Num: num, Size: 16 << l}
+}
+
+// Base and index register of 16-bit addressing, indexed by the rm field.
+var rm16Regs = [...][2]byte{
+	{Ebx, Esi},
+	{Ebx, Edi},
+	{Ebp, Esi},
+	{Ebp, Edi},
+	{Esi, 0xff},
+	{Edi, 0xff},
+	{Ebp, 0xff},
+	{Ebx, 0xff},
+}
+
+// Memory operand specified by ModR/M, accessing size bytes.
+func (insn *Instruction) memOperand(size int) Mem {
+	addressSize := insn.EffectiveAddressSize()
+	m := Mem{Disp: int64(insn.Disp), Size: size}
+	switch {
+	case insn.IsRipRelative():
+		m.Base = Reg{Class: RegIP, Size: sizeInBytes[addressSize]}
+	case addressSize == OpSizeWord:
+		if !(insn.Rm&7 == 6 && insn.Mod == 0) {
+			regs := rm16Regs[insn.Rm&7]
+			m.Base = insn.gpReg(regs[0], OpSizeWord)
+			if regs[1] != 0xff {
+				m.Index, m.Scale = insn.gpReg(regs[1], OpSizeWord), 1
+			}
+		}
+	case insn.Scale != 0:
+		if !(insn.Base&7 == 5 && insn.Mod == 0) {
+			m.Base = insn.gpReg(insn.Base, addressSize)
+		}
+		if insn.Index != 4 {
+			m.Index, m.Scale = insn.gpReg(insn.Index, addressSize), insn.Scale
+		}
+	case !(insn.Rm&7 == 5 && insn.Mod == 0):
+		m.Base = insn.gpReg(insn.Rm, addressSize)
+	}
+
+	// Stack segment is the default if the base is esp or ebp
+	def := DS
+	if m.Base.Class == RegGP && (m.Base.Num == Esp || m.Base.Num == Ebp) {
+		def = SS
+	}
+	m.Segment = insn.segment(def)
+	if insn.Evex != 0 && insn.EvexB {
+		m.Broadcast = true
+	}
+	return m
+}
+
+// Implicit memory operand of string instructions and xlat, addressed by reg.
+// The destination of string instructions always uses es.
+func (insn *Instruction) stringMemOperand(reg byte, size byte) Mem {
+	m := Mem{
+		Segment: insn.segment(DS),
+		Base:    insn.gpReg(reg, insn.EffectiveAddressSize()),
+		Size:    sizeInBytes[size],
+	}
+	if reg == Edi {
+		m.Segment = ES
+	}
+	return m
+}
+
+// Size of OT_MEM and OT_KRM operands in bytes, which is not given by the
+// operand type. Those not listed here, such as lea and fxsave, have unknown
+// size.
+var memSizeOfInsn = map[uint16]int{
+	Insn_Invlpg:      1,
+	Insn_Prefetchnta: 1,
+	Insn_Prefetcht0:  1,
+	Insn_Prefetcht1:  1,
+	Insn_Prefetcht2:  1,
+	Insn_Clflush:     1,
+	Insn_Fldcw:       2,
+	Insn_Fnstcw:      2,
+	Insn_Fstcw:       2,
+	Insn_Fnstsw:      2,
+	Insn_Fstsw:       2,
+	Insn_Ldmxcsr:     4,
+	Insn_Stmxcsr:     4,
+	Insn_Kmovb:       1,
+	Insn_Kmovw:       2,
+	Insn_Kmovd:       4,
+	Insn_Kmovq:       8,
+}
+
+// Number of bytes accessed by the memory form of operand, 0 if unknown. EVEX
+// embedded broadcast accesses one element.
+func (insn *Instruction) memSize(operand byte) int {
+	if insn.Evex != 0 && insn.EvexB {
+		return insn.evexElemSize()
+	}
+	switch operand {
+	case OT_RM8, OT_R32_M8, OT_R32_64_M8, OT_REG32_64_M8, OT_XMM8:
+		return 1
+	case OT_RM16, OT_R32_M16, OT_R32_64_M16, OT_REG32_64_M16, OT_RFULL_M16,
+		OT_FPUM16, OT_XMM16:
+		return 2
+	case OT_RM_FULL:
+		return sizeInBytes[insn.EffectiveOperandSize()]
+	case OT_RM32, OT_MEM32, OT_FPUM32, OT_XMM32, OT_MM32:
+		return 4
+	case OT_RM32_64, OT_MEM32_64:
+		return sizeInBytes[insn.size32or64()]
+	case OT_WRM32_64, OT_WXMM32_64:
+		return sizeInBytes[insn.vexWSize()]
+	case OT_MEM64, OT_FPUM64, OT_XMM64, OT_MM_RM, OT_MM64:
+		return 8
+	case OT_FPUM80:
+		return 10
+	case OT_MEM128, OT_XMM_RM, OT_XMM128:
+		return 16
+	case OT_YMM256:
+		return 32
+	case OT_YXMM128_256, OT_LMEM128_256:
+		return 16 << insn.VexL
+	// 64-bit for xmm, or the whole vector register otherwise
+	case OT_YXMM64_256:
+		if insn.VexL != 0 {
+			return 16 << insn.VexL
+		}
+		return 8
+	// Half of the destination vector register
+	case OT_LXMM64_128:
+		return 8 << insn.VexL
+	// cmpxchg8b and cmpxchg16b
+	case OT_MEM64_128:
+		if insn.Rex&RexW != 0 {
+			return 16
+		}
+		return 8
+	// Far pointer with 16-bit selector
+	case OT_MEM16_FULL:
+		return sizeInBytes[insn.EffectiveOperandSize()] + 2
+	// Pseudo-descriptor of lgdt and related, 16-bit limit and the base
+	case OT_MEM16_3264:
+		if insn.Mode == Mode64 {
+			return 10
+		}
+		return 6
+	case OT_MEM, OT_MEM_OPT, OT_KRM:
+		// bound takes a pair of signed integers of the operand size
+		if insn.OpId == Insn_Bound {
+			return 2 * sizeInBytes[insn.EffectiveOperandSize()]
+		}
+		return memSizeOfInsn[insn.OpId]
+	}
+	return 0
+}
+
+// General purpose register or memory operand specified by ModR/M.
+func (insn *Instruction) rmOperand(operand byte, size byte) Operand {
+	if insn.Mod == 3 {
+		return insn.gpReg(insn.Rm, size)
+	}
+	return insn.memOperand(insn.memSize(operand))
+}
+
+// Vector register or memory operand specified by ModR/M.
+func (insn *Instruction) vecRmOperand(operand byte, l byte) Operand {
+	if insn.Mod == 3 {
+		return vecRegOperand(insn.Rm, l)
+	}
+	return insn.memOperand(insn.memSize(operand))
+}
+
+func (insn *Instruction) immOperand(size byte) Imm {
+	if size == OpSizeFull {
+		size = insn.EffectiveOperandSize()
+	}
+	return Imm{Value: insn.ImmOff, Size: sizeInBytes[size]}
+}
+
+// Append the operands of operand type to ops. x87 instructions with two
+// register operands have them in one operand type.
+func (insn *Instruction) appendOperand(ops []Operand, operand byte) []Operand {
+	var op Operand
+	switch operand {
+	// Immediate value
+	case OT_IMM8, OT_IMM16, OT_IMM32, OT_IMM_FULL, OT_IMM16_1, OT_IMM8_1:
+		op = insn.immOperand(ot2size[operand])
+	case OT_IMM8_2:
+		op = Imm{Value: insn.imm2, Size: 1}
+	// Sign-extended to the operand size
+	case OT_SEIMM8:
+		op = insn.immOperand(OpSizeFull)
+	case OT_CONST1:
+		op = Imm{Value: 1, Size: 1}
+	case OT_PTR16_FULL:
+		op = FarPtr{Segment: uint16(insn.imm2), Offset: uint32(insn.ImmOff)}
+
+	case OT_MOFFS8, OT_MOFFS_FULL:
+		size := OpSizeByte
+		if operand == OT_MOFFS_FULL {
+			size = insn.EffectiveOperandSize()
+		}
+		op = Mem{Segment: insn.segment(DS), Disp: insn.ImmOff, Size: sizeInBytes[size]}
+
+	// Register
+	case OT_REG8, OT_IB_RB, OT_REG16, OT_REG32, OT_REG_FULL, OT_IB_R_FULL:
+		op = insn.gpReg(insn.Reg, ot2size[operand])
+	case OT_REG32_64:
+		op = insn.gpReg(insn.Reg, insn.size32or64())
+	case OT_ACC8, OT_ACC16, OT_ACC_FULL:
+		op = insn.gpReg(Eax, ot2size[operand])
+	// in and out can't use 64-bit register
+	case OT_ACC_FULL_NOT64:
+		size := insn.EffectiveOperandSize()
+		if size == OpSizeQuad {
+			size = OpSizeLong
+		}
+		op = insn.gpReg(Eax, size)
+	case OT_REGCL:
+		op = insn.gpReg(Cl, OpSizeByte)
+	case OT_REGDX:
+		op = insn.gpReg(Edx, OpSizeWord)
+	case OT_SREG, OT_SEG:
+		op = Reg{Class: RegSeg, Num: insn.Reg, Size: 2}
+	case OT_CREG, OT_DREG:
+		class := RegCtrl
+		if operand == OT_DREG {
+			class = RegDebug
+		}
+		op = Reg{Class: class, Num: insn.Reg, Size: sizeInBytes[insn.ctrlRegSize()]}
+	case OT_FREG32_64_RM:
+		op = insn.gpReg(insn.Rm, insn.ctrlRegSize())
+	case OT_WREG32_64:
+		op = insn.gpReg(insn.Reg, insn.vexWSize())
+
+	// Implicit memory operand of string instructions and xlat
+	case OT_REGI_EDI:
+		op = insn.stringMemOperand(Edi, insn.stringOperandSize())
+	case OT_REGI_ESI:
+		op = insn.stringMemOperand(Esi, insn.stringOperandSize())
+	case OT_REGI_EBXAL:
+		m := insn.stringMemOperand(Ebx, OpSizeByte)
+		m.Index, m.Scale = insn.gpReg(Al, OpSizeByte), 1
+		op = m
+
+	// Register or memory
+	case OT_RM8, OT_RM16, OT_RM_FULL, OT_MEM, OT_MEM16_FULL:
+		op = insn.rmOperand(operand, ot2size[operand])
+	case OT_RM32, OT_R32_M8, OT_R32_M16, OT_R32_64_M8, OT_R32_64_M16:
+		op = insn.rmOperand(operand, OpSizeLong)
+	case OT_RM32_64, OT_REG32_64_M8, OT_REG32_64_M16:
+		op = insn.rmOperand(operand, insn.size32or64())
+	case OT_RFULL_M16:
+		op = insn.rmOperand(operand, OpSizeFull)
+	case OT_WRM32_64:
+		op = insn.rmOperand(operand, insn.vexWSize())
+	case OT_MEM16_3264, OT_MEM32, OT_MEM32_64, OT_MEM64, OT_MEM128, OT_MEM64_128,
+		OT_FPUM16, OT_FPUM32, OT_FPUM64, OT_FPUM80:
+		op = insn.memOperand(insn.memSize(operand))
+	// The register form is another instruction without operand, e.g. clflush
+	// and sfence
+	case OT_MEM_OPT:
+		if insn.Mod == 3 {
+			return ops
+		}
+		op = insn.memOperand(insn.memSize(operand))
+
+	// Relative branch and call
+	case OT_RELCB, OT_RELC_FULL:
+		op = Rel{Offset: insn.ImmOff, Target: insn.Target}
+
+	// MMX register or memory
+	case OT_MM:
+		op = Reg{Class: RegMMX, Num: insn.Reg & 7, Size: 8}
+	case OT_MM_RM, OT_MM32, OT_MM64:
+		if insn.Mod == 3 {
+			op = Reg{Class: RegMMX, Num: insn.Rm & 7, Size: 8}
+		} else {
+			op = insn.memOperand(insn.memSize(operand))
+		}
+
+	// Vector register in the reg field, VEX.vvvv or the immediate
+	case OT_XMM:
+		op = vecRegOperand(insn.Reg, 0)
+	case OT_YXMM:
+		op = vecRegOperand(insn.Reg, insn.VexL)
+	case OT_YMM:
+		op = vecRegOperand(insn.Reg, 1)
+	case OT_VXMM:
+		op = vecRegOperand(insn.Vvvv, 0)
+	case OT_VYXMM:
+		op = vecRegOperand(insn.Vvvv, insn.VexL)
+	case OT_VYMM:
+		op = vecRegOperand(insn.Vvvv, 1)
+	case OT_XMM_IMM:
+		op = vecRegOperand(insn.immReg(), 0)
+	case OT_YXMM_IMM:
+		op = vecRegOperand(insn.immReg(), insn.VexL)
+	case OT_REGXMM0:
+		op = vecRegOperand(0, 0)
+	// Vector register or memory
+	case OT_XMM_RM, OT_XMM8, OT_XMM16, OT_XMM32, OT_XMM64, OT_XMM128,
+		OT_LXMM64_128, OT_WXMM32_64:
+		op = insn.vecRmOperand(operand, 0)
+	case OT_YXMM64_256, OT_YXMM128_256, OT_LMEM128_256:
+		op = insn.vecRmOperand(operand, insn.VexL)
+	case OT_YMM256:
+		op = insn.vecRmOperand(operand, 1)
+
+	// Opmask register in the reg field, VEX.vvvv, or register or memory
+	case OT_KREG:
+		op = Reg{Class: RegMask, Num: insn.Reg, Size: 8}
+	case OT_VK:
+		op = Reg{Class: RegMask, Num: insn.Vvvv, Size: 8}
+	case OT_KRM:
+		if insn.Mod == 3 {
+			op = Reg{Class: RegMask, Num: insn.Rm, Size: 8}
+		} else {
+			op = insn.memOperand(insn.memSize(operand))
+		}
+
+	// x87 register stack
+	case OT_FPU_SI:
+		op = Reg{Class: RegFPU, Num: insn.Rm & 7, Size: 10}
+	case OT_FPU_SSI:
+		return append(ops, Reg{Class: RegFPU, Size: 10}, Reg{Class: RegFPU, Num: insn.Rm & 7, Size: 10})
+	case OT_FPU_SIS:
+		return append(ops, Reg{Class: RegFPU, Num: insn.Rm & 7, Size: 10}, Reg{Class: RegFPU, Size: 10})
+	default:
+		return ops
+	}
+	return append(ops, op)
+}
+
+// Size of control and debug registers, which is also the size of the general
+// purpose register moved from or to them.
+func (insn *Instruction) ctrlRegSize() byte {
+	if insn.Mode == Mode64 {
+		return OpSizeQuad
+	}
+	return OpSizeLong
+}
+
+// Build Operands from the operand types of the instruction. The order is the
+// same as Intel syntax, the destination operand is the first one. The
+// immediate of cmpps etc. is the last operand if it's not shown by the
+// mnemonic.
+func (insn *Instruction) buildOperands() {
+	ops := make([]Operand, 0, 4)
+	for _, operand := range insn.Info.Operand {
+		if operand == OT_NONE {
+			break
+		}
+		ops = insn.appendOperand(ops, operand)
+	}
+	if insn.Info.Flag&IFLAG_PSEUDO_OPCODE != 0 && !insn.hasCmpPredicate() {
+		ops = append(ops, Imm{Value: int64(uint8(insn.ImmOff)), Size: 1})
+	}
+	insn.Operands = ops
+}
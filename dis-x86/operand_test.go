@@ -0,0 +1,86 @@
+package dis
+
+import (
+	"reflect"
+	"testing"
+)
+
+type codeOperands struct {
+	code     []byte
+	operands []Operand
+}
+
+func testOperands(testdata []codeOperands, mode Mode, t *testing.T) {
+	for _, td := range testdata {
+		insn, err := Decode(td.code, mode)
+		if err != nil {
+			t.Errorf("% x: %v", td.code, err)
+			continue
+		}
+		if !reflect.DeepEqual(insn.Operands, td.operands) {
+			t.Errorf("% x: got %v, should be %v", td.code, insn.Operands, td.operands)
+		}
+	}
+}
+
+func TestOperands(t *testing.T) {
+	eax := Reg{Class: RegGP, Num: Eax, Size: 4}
+	ecx := Reg{Class: RegGP, Num: Ecx, Size: 4}
+	xmm1 := Reg{Class: RegVec, Num: 1, Size: 16}
+	testdata := []codeOperands{
+		{[]byte{0x89, 0xc1}, []Operand{ecx, eax}},
+		{[]byte{0x88, 0xe0}, []Operand{Reg{Class: RegGP, Num: Eax, Size: 1}, Reg{Class: RegGP, Num: Eax, Size: 1, High: true}}},
+		{[]byte{0x6b, 0xc1, 0xf0}, []Operand{eax, ecx, Imm{Value: -0x10, Size: 4}}},
+		{[]byte{0x0f, 0xa4, 0xc8, 0x05}, []Operand{eax, ecx, Imm{Value: 5, Size: 1}}},
+		{[]byte{0xc8, 0x10, 0x00, 0x01}, []Operand{Imm{Value: 0x10, Size: 2}, Imm{Value: 1, Size: 1}}},
+		{[]byte{0xea, 0x78, 0x56, 0x34, 0x12, 0x10, 0x00}, []Operand{FarPtr{Segment: 0x10, Offset: 0x12345678}}},
+		{[]byte{0xeb, 0xfe}, []Operand{Rel{Offset: -2, Target: 0}}},
+		{[]byte{0x0f, 0x84, 0x10, 0x00, 0x00, 0x00}, []Operand{Rel{Offset: 0x10, Target: 0x16}}},
+		{[]byte{0x64, 0x8b, 0x44, 0x8d, 0x10}, []Operand{eax, Mem{
+			Segment: FS, Base: Reg{Class: RegGP, Num: Ebp, Size: 4}, Index: ecx, Scale: 4, Disp: 0x10, Size: 4}}},
+		{[]byte{0x8b, 0x45, 0xfc}, []Operand{eax, Mem{Segment: SS, Base: Reg{Class: RegGP, Num: Ebp, Size: 4}, Disp: -4, Size: 4}}},
+		{[]byte{0x8d, 0x04, 0x85, 0x00, 0x01, 0x00, 0x00}, []Operand{eax, Mem{Segment: DS, Index: eax, Scale: 4, Disp: 0x100}}},
+		{[]byte{0xd7}, []Operand{Mem{
+			Segment: DS, Base: Reg{Class: RegGP, Num: Ebx, Size: 4}, Index: Reg{Class: RegGP, Num: Eax, Size: 1}, Scale: 1, Size: 1}}},
+		{[]byte{0xa5}, []Operand{
+			Mem{Segment: ES, Base: Reg{Class: RegGP, Num: Edi, Size: 4}, Size: 4},
+			Mem{Segment: DS, Base: Reg{Class: RegGP, Num: Esi, Size: 4}, Size: 4}}},
+		{[]byte{0x0f, 0x22, 0xd8}, []Operand{Reg{Class: RegCtrl, Num: 3, Size: 4}, eax}},
+		{[]byte{0x8e, 0xd8}, []Operand{Reg{Class: RegSeg, Num: DS, Size: 2}, eax}},
+		{[]byte{0xd8, 0xc1}, []Operand{Reg{Class: RegFPU, Num: 0, Size: 10}, Reg{Class: RegFPU, Num: 1, Size: 10}}},
+		{[]byte{0x0f, 0x58, 0x08}, []Operand{xmm1, Mem{Segment: DS, Base: eax, Size: 16}}},
+		// Predicate out of range has no pseudo-opcode, kept as immediate
+		{[]byte{0x0f, 0xc2, 0xc1, 0x08}, []Operand{Reg{Class: RegVec, Size: 16}, xmm1, Imm{Value: 8, Size: 1}}},
+		{[]byte{0x0f, 0xc2, 0xc1, 0x01}, []Operand{Reg{Class: RegVec, Size: 16}, xmm1}},
+	}
+	testOperands(testdata, Mode32, t)
+
+	testdata = []codeOperands{
+		{[]byte{0x48, 0x8b, 0x05, 0x10, 0x00, 0x00, 0x00}, []Operand{
+			Reg{Class: RegGP, Num: Eax, Size: 8}, Mem{Segment: DS, Base: Reg{Class: RegIP, Size: 8}, Disp: 0x10, Size: 8}}},
+		{[]byte{0x40, 0x88, 0xe0}, []Operand{Reg{Class: RegGP, Num: Eax, Size: 1}, Reg{Class: RegGP, Num: Esp, Size: 1}}},
+		{[]byte{0xc5, 0xf4, 0x58, 0xc2}, []Operand{
+			Reg{Class: RegVec, Num: 0, Size: 32}, Reg{Class: RegVec, Num: 1, Size: 32}, Reg{Class: RegVec, Num: 2, Size: 32}}},
+	}
+	testOperands(testdata, Mode64, t)
+}
+
+func TestOperandString(t *testing.T) {
+	testdata := []struct {
+		operand Operand
+		text    string
+	}{
+		{Reg{Class: RegGP, Num: Eax, Size: 1, High: true}, "ah"},
+		{Reg{Class: RegGP, Num: 9, Size: 2}, "r9w"},
+		{Reg{Class: RegVec, Num: 3, Size: 32}, "ymm3"},
+		{Mem{Segment: DS, Base: Reg{Class: RegGP, Num: Eax, Size: 4}, Index: Reg{Class: RegGP, Num: Ecx, Size: 4}, Scale: 4, Disp: 0x10}, "ds:[eax+ecx*4+0x10]"},
+		{Mem{Segment: DS}, "ds:[0x0]"},
+		{Imm{Value: -1, Size: 1}, "0xff"},
+		{FarPtr{Segment: 0x10, Offset: 0x1000}, "0x10:0x1000"},
+	}
+	for _, td := range testdata {
+		if s := td.operand.String(); s != td.text {
+			t.Errorf("got %s, should be %s", s, td.text)
+		}
+	}
+}
@@ -0,0 +1,544 @@
+package dis
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Go assembler syntax, following the output of go tool objdump. It's derived
+// from Plan 9: operands are in AT&T order, registers have no size, e.g. AX is
+// used for al, ax, eax and rax, and the operand size is given by a suffix for
+// some instructions.
+
+var plan9RegName = [...]string{
+	Eax: "AX",
+	Ecx: "CX",
+	Edx: "DX",
+	Ebx: "BX",
+	Esp: "SP",
+	Ebp: "BP",
+	Esi: "SI",
+	Edi: "DI",
+}
+
+var plan9RegName8 = [...]string{
+	Al: "AL",
+	Cl: "CL",
+	Dl: "DL",
+	Bl: "BL",
+	Ah: "AH",
+	Ch: "CH",
+	Dh: "DH",
+	Bh: "BH",
+}
+
+// Base and index register of 16-bit addressing, indexed by the rm field.
+var plan9Rm16Name = [...][2]string{
+	{"BX", "SI"},
+	{"BX", "DI"},
+	{"BP", "SI"},
+	{"BP", "DI"},
+	{"SI", ""},
+	{"DI", ""},
+	{"BP", ""},
+	{"BX", ""},
+}
+
+// Mnemonics which are different in Go syntax, before converting to upper
+// case.
+var plan9Mnemonic = map[uint16]string{
+	Insn_Jz:       "je",
+	Insn_Jnz:      "jne",
+	Insn_Setz:     "sete",
+	Insn_Setnz:    "setne",
+	Insn_Cmovz:    "cmove",
+	Insn_Cmovnz:   "cmovne",
+	Insn_Loopz:    "loope",
+	Insn_Loopnz:   "loopne",
+	Insn_Xlat:     "xlatb",
+	Insn_Int_3:    "int",
+	Insn_Jmp_far:  "ljmp",
+	Insn_Call_far: "lcall",
+}
+
+// Instructions having the operand size as suffix.
+var plan9Suffix = map[uint16]bool{
+	Insn_Adc:       true,
+	Insn_Add:       true,
+	Insn_And:       true,
+	Insn_Bsf:       true,
+	Insn_Bsr:       true,
+	Insn_Bt:        true,
+	Insn_Btc:       true,
+	Insn_Btr:       true,
+	Insn_Bts:       true,
+	Insn_Cmp:       true,
+	Insn_Cmpxchg:   true,
+	Insn_Cvtsi2sd:  true,
+	Insn_Cvtsi2ss:  true,
+	Insn_Cvtsd2si:  true,
+	Insn_Cvtss2si:  true,
+	Insn_Cvttsd2si: true,
+	Insn_Cvttss2si: true,
+	Insn_Dec:       true,
+	Insn_Div:       true,
+	Insn_Idiv:      true,
+	Insn_Imul:      true,
+	Insn_In:        true,
+	Insn_Inc:       true,
+	Insn_Lea:       true,
+	Insn_Mov:       true,
+	Insn_Movnti:    true,
+	Insn_Mul:       true,
+	Insn_Neg:       true,
+	Insn_Nop:       true,
+	Insn_Not:       true,
+	Insn_Or:        true,
+	Insn_Out:       true,
+	Insn_Pop:       true,
+	Insn_Popcnt:    true,
+	Insn_Push:      true,
+	Insn_Rcl:       true,
+	Insn_Rcr:       true,
+	Insn_Rol:       true,
+	Insn_Ror:       true,
+	Insn_Sal:       true,
+	Insn_Sar:       true,
+	Insn_Sbb:       true,
+	Insn_Shl:       true,
+	Insn_Shld:      true,
+	Insn_Shr:       true,
+	Insn_Shrd:      true,
+	Insn_Sub:       true,
+	Insn_Test:      true,
+	Insn_Xadd:      true,
+	Insn_Xchg:      true,
+	Insn_Xor:       true,
+}
+
+var plan9SizeSuffix = [...]string{
+	OpSizeByte: "B",
+	OpSizeWord: "W",
+	OpSizeLong: "L",
+	OpSizeQuad: "Q",
+}
+
+// Suffix of string instructions and pusha etc. uses Intel's name for double
+// word.
+var plan9StringSuffix = [...]string{
+	OpSizeByte: "B",
+	OpSizeWord: "W",
+	OpSizeLong: "D",
+	OpSizeQuad: "Q",
+}
+
+// Rounding control suffix, indexed by the RC field.
+var plan9RoundingName = [...]string{
+	".RN_SAE",
+	".RD_SAE",
+	".RU_SAE",
+	".RZ_SAE",
+}
+
+// Return the Plan 9 name of a general purpose register.
+func (insn *Instruction) plan9Reg(reg byte, size byte) string {
+	if reg >= 8 {
+		return fmt.Sprintf("R%d", reg)
+	}
+	// spl, bpl, sil and dil share the name with sp, bp, si and di
+	if size == OpSizeByte && (insn.Rex == 0 || reg < 4) {
+		return plan9RegName8[reg]
+	}
+	return plan9RegName[reg]
+}
+
+func plan9VecReg(reg byte, l byte) string {
+	return fmt.Sprintf("%c%d", "XYZ"[l], reg)
+}
+
+// Return the segment override prefix, or seg if there's no override.
+func (insn *Instruction) plan9SegPrefix(seg string) string {
+	return strings.ToUpper(insn.intelSegPrefix(seg))
+}
+
+// Memory operand specified by ModR/M, e.g. -0x8(BP)(CX*4). The displacement
+// is always shown, 8-bit displacement is signed and the wider ones are
+// unsigned as go tool objdump does.
+func (insn *Instruction) plan9Mem() string {
+	addressSize := insn.EffectiveAddressSize()
+	var base, index string
+	var scale byte = 1
+	switch {
+	case insn.IsRipRelative():
+		base = "IP"
+	case addressSize == OpSizeWord:
+		if !(insn.Rm&7 == 6 && insn.Mod == 0) {
+			base, index = plan9Rm16Name[insn.Rm&7][0], plan9Rm16Name[insn.Rm&7][1]
+		}
+	case insn.Scale != 0:
+		if !(insn.Base&7 == 5 && insn.Mod == 0) {
+			base = insn.plan9Reg(insn.Base, addressSize)
+		}
+		if insn.Index != 4 {
+			index = insn.plan9Reg(insn.Index, addressSize)
+			scale = insn.Scale
+		}
+	case !(insn.Rm&7 == 5 && insn.Mod == 0):
+		base = insn.plan9Reg(insn.Rm, addressSize)
+	}
+
+	dump := insn.plan9SegPrefix("")
+	switch sym := insn.dispSymbol(); {
+	case sym != "":
+		// Symbol is relative to the static base pseudo-register
+		dump += sym + "(SB)"
+		base = ""
+	case insn.Disp == 0:
+		dump += "0"
+	case insn.DispSize == OpSizeByte:
+		dump += fmt.Sprintf("%#x", insn.Disp)
+	default:
+		dump += dumpUnsignedValue(insn.DispSize, int64(insn.Disp))
+	}
+	if base != "" {
+		dump += "(" + base + ")"
+	}
+	if index != "" {
+		dump += fmt.Sprintf("(%s*%d)", index, scale)
+	}
+	return dump
+}
+
+// Register or memory operand specified by ModR/M. size is the register size.
+func (insn *Instruction) plan9Rm(size byte) string {
+	if insn.Mod == 3 {
+		return insn.plan9Reg(insn.Rm, size)
+	}
+	return insn.plan9Mem()
+}
+
+func (insn *Instruction) plan9VecRm(l byte) string {
+	if insn.Mod == 3 {
+		return plan9VecReg(insn.Rm, l)
+	}
+	return insn.plan9Mem()
+}
+
+// Immediate value. Outside 64-bit mode it's unsigned value of the operand
+// size. In 64-bit mode, immediates wider than a byte are shown as signed value
+// if they fit in 32-bit, as go tool objdump does.
+func (insn *Instruction) plan9Imm(size byte) string {
+	if size == OpSizeFull {
+		size = insn.EffectiveOperandSize()
+	}
+	if insn.Mode != Mode64 || size == OpSizeByte {
+		return "$" + dumpUnsignedValue(size, insn.ImmOff)
+	}
+	if insn.ImmOff == int64(int32(insn.ImmOff)) {
+		return fmt.Sprintf("$%#x", insn.ImmOff)
+	}
+	return fmt.Sprintf("$%#x", uint64(insn.ImmOff))
+}
+
+// Implicit memory operand of string instructions, addressed by reg.
+func (insn *Instruction) plan9StringMem(reg byte) string {
+	seg := insn.plan9SegPrefix("ds")
+	if reg == Edi {
+		seg = "ES:"
+	}
+	return fmt.Sprintf("%s0(%s)", seg, insn.plan9Reg(reg, insn.EffectiveAddressSize()))
+}
+
+func (insn *Instruction) plan9Operand(operand byte) (dump string) {
+	switch operand {
+	// Immediate value
+	case OT_IMM8, OT_IMM16, OT_IMM32, OT_IMM_FULL, OT_IMM16_1, OT_IMM8_1:
+		dump = insn.plan9Imm(ot2size[operand])
+	case OT_IMM8_2:
+		dump = "$" + dumpUnsignedValue(OpSizeByte, insn.imm2)
+	// Far pointer is shown as offset and selector, as operands are reversed
+	case OT_PTR16_FULL:
+		dump = fmt.Sprintf("$%s, $%#x",
+			dumpUnsignedValue(insn.EffectiveOperandSize(), insn.ImmOff), uint16(insn.imm2))
+	// Sign-extended to the operand size
+	case OT_SEIMM8:
+		dump = insn.plan9Imm(OpSizeFull)
+	case OT_CONST1:
+		dump = "$0x1"
+
+	// Memory offset is unsigned, except the 64-bit one which fits in int32
+	case OT_MOFFS8, OT_MOFFS_FULL:
+		dump = insn.plan9SegPrefix("")
+		switch sym := insn.moffsSymbol(); {
+		case sym != "":
+			dump += sym + "(SB)"
+		case insn.ImmOff == 0:
+			dump += "0"
+		case insn.EffectiveAddressSize() == OpSizeQuad && insn.ImmOff == int64(int32(insn.ImmOff)):
+			dump += fmt.Sprintf("%#x", insn.ImmOff)
+		default:
+			dump += dumpUnsignedValue(insn.EffectiveAddressSize(), insn.ImmOff)
+		}
+
+	// Register
+	case OT_REG8, OT_IB_RB, OT_REG16, OT_REG32,
+		OT_REG_FULL, OT_IB_R_FULL:
+		dump = insn.plan9Reg(insn.Reg, ot2size[operand])
+	case OT_REG32_64:
+		dump = insn.plan9Reg(insn.Reg, insn.size32or64())
+	case OT_ACC8, OT_ACC16, OT_ACC_FULL, OT_ACC_FULL_NOT64:
+		dump = insn.plan9Reg(Eax, ot2size[operand])
+	case OT_REGCL:
+		dump = insn.plan9Reg(Cl, OpSizeByte)
+	case OT_REGDX:
+		dump = insn.plan9Reg(Edx, OpSizeWord)
+	// Segment, control and debug register
+	case OT_SREG, OT_SEG:
+		dump = strings.ToUpper(segRegName[insn.Reg])
+	case OT_CREG:
+		dump = strings.ToUpper(cregName[insn.Reg])
+	case OT_DREG:
+		dump = fmt.Sprintf("DR%d", insn.Reg)
+
+	// Implicit memory operand of string instructions and xlat
+	case OT_REGI_EDI:
+		dump = insn.plan9StringMem(Edi)
+	case OT_REGI_ESI:
+		dump = insn.plan9StringMem(Esi)
+	case OT_REGI_EBXAL:
+		dump = insn.plan9StringMem(Ebx)
+
+	// Register or memory
+	case OT_RM8, OT_RM16, OT_RM_FULL, OT_MEM, OT_MEM16_FULL:
+		dump = insn.plan9Rm(ot2size[operand])
+	case OT_RM32, OT_R32_M8, OT_R32_M16, OT_R32_64_M8, OT_R32_64_M16:
+		dump = insn.plan9Rm(OpSizeLong)
+	case OT_RM32_64, OT_REG32_64_M8, OT_REG32_64_M16:
+		dump = insn.plan9Rm(insn.size32or64())
+	case OT_RFULL_M16:
+		dump = insn.plan9Rm(insn.EffectiveOperandSize())
+	case OT_MEM16_3264, OT_MEM32, OT_MEM32_64, OT_MEM64, OT_MEM128, OT_MEM64_128:
+		dump = insn.plan9Mem()
+	case OT_MEM_OPT:
+		if insn.Mod != 3 {
+			dump = insn.plan9Mem()
+		}
+	case OT_FREG32_64_RM:
+		dump = insn.plan9Reg(insn.Rm, OpSizeLong)
+	// General purpose register sized by VEX.W, e.g. vmovd and vmovq
+	case OT_WREG32_64:
+		dump = insn.plan9Reg(insn.Reg, insn.vexWSize())
+	case OT_WRM32_64:
+		dump = insn.plan9Rm(insn.vexWSize())
+
+	// Relative branch and call, shown as the absolute target
+	case OT_RELCB, OT_RELC_FULL:
+		if sym := insn.symbolize(insn.Target); sym != "" {
+			dump = sym + "(SB)"
+		} else {
+			dump = fmt.Sprintf("%#x", insn.Target)
+		}
+
+	// MMX register or memory
+	case OT_MM:
+		dump = fmt.Sprintf("M%d", insn.Reg&7)
+	case OT_MM_RM, OT_MM32, OT_MM64:
+		if insn.Mod == 3 {
+			dump = fmt.Sprintf("M%d", insn.Rm&7)
+		} else {
+			dump = insn.plan9Mem()
+		}
+
+	// Vector register
+	case OT_XMM:
+		dump = plan9VecReg(insn.Reg, 0)
+	case OT_YXMM:
+		dump = plan9VecReg(insn.Reg, insn.VexL)
+	case OT_YMM:
+		dump = plan9VecReg(insn.Reg, 1)
+	case OT_VXMM:
+		dump = plan9VecReg(insn.Vvvv, 0)
+	case OT_VYXMM:
+		dump = plan9VecReg(insn.Vvvv, insn.VexL)
+	case OT_VYMM:
+		dump = plan9VecReg(insn.Vvvv, 1)
+	case OT_XMM_IMM:
+		dump = plan9VecReg(insn.immReg(), 0)
+	case OT_YXMM_IMM:
+		dump = plan9VecReg(insn.immReg(), insn.VexL)
+	case OT_REGXMM0:
+		dump = plan9VecReg(0, 0)
+	// Vector register or memory
+	case OT_XMM_RM, OT_XMM8, OT_XMM16, OT_XMM32, OT_XMM64, OT_XMM128,
+		OT_LXMM64_128, OT_WXMM32_64:
+		dump = insn.plan9VecRm(0)
+	case OT_YXMM64_256, OT_YXMM128_256, OT_LMEM128_256:
+		dump = insn.plan9VecRm(insn.VexL)
+	case OT_YMM256:
+		dump = insn.plan9VecRm(1)
+
+	// Opmask register
+	case OT_KREG:
+		dump = fmt.Sprintf("K%d", insn.Reg)
+	case OT_VK:
+		dump = fmt.Sprintf("K%d", insn.Vvvv)
+	case OT_KRM:
+		if insn.Mod == 3 {
+			dump = fmt.Sprintf("K%d", insn.Rm)
+		} else {
+			dump = insn.plan9Mem()
+		}
+
+	// x87 register stack. The operand pairs are already in Plan 9 order.
+	case OT_FPU_SI:
+		dump = fmt.Sprintf("F%d", insn.Rm&7)
+	case OT_FPU_SSI:
+		dump = fmt.Sprintf("F%d, F0", insn.Rm&7)
+	case OT_FPU_SIS:
+		dump = fmt.Sprintf("F0, F%d", insn.Rm&7)
+	case OT_FPUM16, OT_FPUM32, OT_FPUM64, OT_FPUM80:
+		dump = insn.plan9Mem()
+	}
+	return
+}
+
+// Size of general purpose register or memory operand, OpSizeNone for other
+// operand types.
+func (insn *Instruction) gprOperandSize(operand byte) byte {
+	switch operand {
+	case OT_RM8, OT_REG8, OT_ACC8, OT_IB_RB:
+		return OpSizeByte
+	case OT_RM16, OT_REG16, OT_ACC16:
+		return OpSizeWord
+	case OT_RM_FULL, OT_REG_FULL, OT_ACC_FULL, OT_IB_R_FULL:
+		return insn.EffectiveOperandSize()
+	case OT_ACC_FULL_NOT64:
+		if size := insn.EffectiveOperandSize(); size != OpSizeQuad {
+			return size
+		}
+		return OpSizeLong
+	case OT_RM32, OT_REG32:
+		return OpSizeLong
+	case OT_RM32_64, OT_REG32_64, OT_MEM32_64:
+		return insn.size32or64()
+	case OT_RFULL_M16:
+		if insn.Mod == 3 {
+			return insn.EffectiveOperandSize()
+		}
+		return OpSizeWord
+	case OT_FREG32_64_RM:
+		if insn.Mode == Mode64 {
+			return OpSizeQuad
+		}
+		return OpSizeLong
+	}
+	return OpSizeNone
+}
+
+// Size of the data the instruction operates on, which is the size of the
+// first general purpose register or memory operand.
+func (insn *Instruction) plan9DataSize() byte {
+	for i := 0; i < insn.Info.countOperand(); i++ {
+		if size := insn.gprOperandSize(insn.Info.Operand[i]); size != OpSizeNone {
+			return size
+		}
+	}
+	return insn.EffectiveOperandSize()
+}
+
+func (insn *Instruction) plan9InsnName() string {
+	name := InsnName[insn.OpId]
+	if n, ok := plan9Mnemonic[insn.OpId]; ok {
+		name = n
+	}
+
+	switch insn.OpId {
+	case Insn_Movs, Insn_Cmps, Insn_Stos, Insn_Lods, Insn_Scas, Insn_Ins, Insn_Outs:
+		name += plan9StringSuffix[insn.stringOperandSize()]
+	case Insn_Pusha, Insn_Popa:
+		name += plan9StringSuffix[insn.EffectiveOperandSize()]
+	case Insn_Pushf, Insn_Popf:
+		if size := insn.EffectiveOperandSize(); size != OpSizeWord {
+			name += plan9StringSuffix[size]
+		}
+	// VEX conversion from 128 or 256-bit source to xmm
+	case Insn_Vcvtpd2ps, Insn_Vcvtpd2dq, Insn_Vcvttpd2dq:
+		if insn.Evex == 0 {
+			name += vecSizeSuffix[insn.VexL]
+		}
+	case Insn_Vcvtsi2ss, Insn_Vcvtsi2sd:
+		name += plan9SizeSuffix[insn.vexWSize()]
+	case Insn_Vcvtss2si, Insn_Vcvtsd2si, Insn_Vcvttss2si, Insn_Vcvttsd2si:
+		if insn.vexWSize() == OpSizeQuad {
+			name += "Q"
+		}
+	default:
+		if plan9Suffix[insn.OpId] {
+			name += plan9SizeSuffix[insn.plan9DataSize()]
+		}
+	}
+	return strings.ToUpper(name)
+}
+
+func (insn *Instruction) plan9Insn() string {
+	var buf bytes.Buffer
+
+	switch {
+	case insn.Prefix&PrefixREPZ != 0:
+		buf.WriteString("REP; ")
+	case insn.Prefix&PrefixREPNZ != 0:
+		buf.WriteString("REPNE; ")
+	}
+	if insn.Prefix&PrefixLOCK != 0 {
+		buf.WriteString("LOCK ")
+	}
+
+	buf.WriteString(insn.plan9InsnName())
+	if insn.Evex != 0 {
+		if insn.EvexB && insn.Mod != 3 {
+			buf.WriteString(".BCST")
+		}
+		if insn.EvexB && insn.Mod == 3 {
+			if insn.Info.Flag&IFLAG_EVEX_ER != 0 {
+				buf.WriteString(plan9RoundingName[insn.Rounding])
+			} else {
+				buf.WriteString(".SAE")
+			}
+		}
+		if insn.Zeroing {
+			buf.WriteString(".Z")
+		}
+	}
+
+	// Collect operands in Intel order first
+	n := insn.Info.countOperand()
+	args := make([]string, 0, n+2)
+	for i := 0; i < n; i++ {
+		args = append(args, insn.plan9Operand(insn.Info.Operand[i]))
+	}
+	// Opmask follows the destination operand
+	if insn.Opmask != 0 {
+		args = append(args[:1], append([]string{fmt.Sprintf("K%d", insn.Opmask)}, args[1:]...)...)
+	}
+	// The predicate of cmpps etc. is always an operand
+	if insn.Info.Flag&IFLAG_PSEUDO_OPCODE != 0 {
+		args = append(args, insn.plan9Imm(OpSizeByte))
+	}
+	switch insn.OpId {
+	case Insn_Int_3:
+		args = append(args, "$0x3")
+	case Insn_Scas:
+		args[0], args[1] = args[1], args[0]
+	}
+	// Destination operand is the last one, except cmp which reads left to
+	// right.
+	if insn.OpId != Insn_Cmp {
+		for i, j := 0, len(args)-1; i < j; i, j = i+1, j-1 {
+			args[i], args[j] = args[j], args[i]
+		}
+	}
+	buf.WriteString(" ")
+	buf.WriteString(strings.Join(args, ", "))
+	return buf.String()
+}
@@ -0,0 +1,99 @@
+package dis
+
+import (
+	"testing"
+)
+
+func testGo(testdata []codeText, mode Mode, t *testing.T) {
+	for _, ct := range testdata {
+		insn, err := Decode(ct.binary, mode)
+		if err != nil {
+			t.Errorf("% x: %v", ct.binary, err)
+			continue
+		}
+		if dump := insn.Format(GoSyntax{}); dump != ct.assembly {
+			t.Errorf("% x: got %s, should be %s", ct.binary, dump, ct.assembly)
+		}
+	}
+}
+
+// Expected output is from go tool objdump, except that byte operation has the
+// B suffix (go tool objdump prints MOVL AH, AL), and immediate in 32-bit mode
+// is shown as unsigned value of the operand size.
+func TestGoSyntax(t *testing.T) {
+	testdata := []codeText{
+		// Memory operand
+		{[]byte{0x8b, 0x45, 0x08}, "MOVL 0x8(BP), AX"},
+		{[]byte{0x8b, 0x45, 0xf8}, "MOVL -0x8(BP), AX"},
+		{[]byte{0x8b, 0x80, 0xf0, 0xff, 0xff, 0xff}, "MOVL 0xfffffff0(AX), AX"},
+		{[]byte{0x8b, 0x04, 0x88}, "MOVL 0(AX)(CX*4), AX"},
+		{[]byte{0x8b, 0x04, 0x8d, 0x00, 0x10, 0x00, 0x00}, "MOVL 0x1000(CX*4), AX"},
+		{[]byte{0xa1, 0xf0, 0xff, 0xff, 0xff}, "MOVL 0xfffffff0, AX"},
+		{[]byte{0x64, 0xa1, 0x00, 0x00, 0x00, 0x00}, "MOVL FS:0, AX"},
+		{[]byte{0x67, 0x8b, 0x00}, "MOVL 0(BX)(SI*1), AX"},
+		{[]byte{0x67, 0x8b, 0x86, 0xf0, 0xff}, "MOVL 0xfff0(BP), AX"},
+		{[]byte{0xf3, 0x0f, 0x58, 0x05, 0xf0, 0xff, 0xff, 0xff}, "ADDSS 0xfffffff0, X0"},
+
+		// Operand size suffix
+		{[]byte{0x66, 0x89, 0xc8}, "MOVW CX, AX"},
+		{[]byte{0x88, 0xe0}, "MOVB AH, AL"},
+		{[]byte{0xa8, 0x01}, "TESTB $0x1, AL"},
+		{[]byte{0x0f, 0xb6, 0xc1}, "MOVZX CL, AX"},
+		{[]byte{0x0f, 0xbf, 0xc1}, "MOVSX CX, AX"},
+		{[]byte{0x8e, 0xc0}, "MOVL AX, ES"},
+		{[]byte{0x0f, 0x20, 0xc0}, "MOVL CR0, AX"},
+		{[]byte{0x50}, "PUSHL AX"},
+		{[]byte{0x90}, "NOPL "},
+		{[]byte{0xc3}, "RET "},
+
+		// Immediate
+		{[]byte{0xb0, 0xeb}, "MOVB $0xeb, AL"},
+		{[]byte{0xb8, 0xff, 0xff, 0xff, 0xff}, "MOVL $0xffffffff, AX"},
+		{[]byte{0x83, 0xc0, 0xf0}, "ADDL $0xfffffff0, AX"},
+		{[]byte{0x6a, 0x10}, "PUSHL $0x10"},
+		{[]byte{0xcc}, "INT $0x3"},
+		{[]byte{0xcd, 0x80}, "INT $0x80"},
+
+		// String instruction and prefix
+		{[]byte{0xf3, 0xa4}, "REP; MOVSB DS:0(SI), ES:0(DI)"},
+		{[]byte{0xf3, 0xab}, "REP; STOSD AX, ES:0(DI)"},
+		{[]byte{0xf2, 0xae}, "REPNE; SCASB ES:0(DI), AL"},
+		{[]byte{0xf0, 0x83, 0x04, 0x24, 0xff}, "LOCK ADDL $0xffffffff, 0(SP)"},
+
+		// SSE/AVX
+		{[]byte{0x66, 0x0f, 0x6f, 0xc1}, "MOVDQA X1, X0"},
+		{[]byte{0x0f, 0x28, 0xc1}, "MOVAPS X1, X0"},
+		{[]byte{0xc5, 0xf4, 0x58, 0x00}, "VADDPS 0(AX), Y1, Y0"},
+		{[]byte{0xc5, 0xf9, 0x5a, 0x00}, "VCVTPD2PSX 0(AX), X0"},
+		{[]byte{0xc5, 0xfd, 0x5a, 0x00}, "VCVTPD2PSY 0(AX), X0"},
+
+		// x87
+		{[]byte{0xd9, 0xc9}, "FXCH F1"},
+		{[]byte{0xd8, 0xc2}, "FADD F2, F0"},
+		{[]byte{0xdc, 0xc2}, "FADD F0, F2"},
+		{[]byte{0xdd, 0x45, 0xf8}, "FLD -0x8(BP)"},
+	}
+	testGo(testdata, Mode32, t)
+
+	testdata = []codeText{
+		{[]byte{0x48, 0x8b, 0x45, 0x08}, "MOVQ 0x8(BP), AX"},
+		{[]byte{0x4c, 0x8b, 0x4c, 0x24, 0x08}, "MOVQ 0x8(SP), R9"},
+		{[]byte{0x48, 0x89, 0xe5}, "MOVQ SP, BP"},
+		{[]byte{0x8b, 0x05, 0xf0, 0xff, 0xff, 0xff}, "MOVL 0xfffffff0(IP), AX"},
+		{[]byte{0x48, 0x8d, 0x05, 0x00, 0x10, 0x00, 0x00}, "LEAQ 0x1000(IP), AX"},
+		{[]byte{0xa1, 0xf0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, "MOVL -0x10, AX"},
+		{[]byte{0x44, 0x88, 0xc0}, "MOVB R8, AL"},
+		{[]byte{0x48, 0xc7, 0xc0, 0xf0, 0xff, 0xff, 0xff}, "MOVQ $-0x10, AX"},
+		{[]byte{0x48, 0xb8, 0xef, 0xcd, 0xab, 0x89, 0x67, 0x45, 0x23, 0x01}, "MOVQ $0x123456789abcdef, AX"},
+		{[]byte{0xf3, 0x48, 0xab}, "REP; STOSQ AX, ES:0(DI)"},
+		{[]byte{0xf2, 0x0f, 0x2a, 0xc1}, "CVTSI2SDL CX, X0"},
+		{[]byte{0xf2, 0x48, 0x0f, 0x2a, 0xc0}, "CVTSI2SDQ AX, X0"},
+		{[]byte{0x62, 0xf1, 0x74, 0x48, 0x58, 0x15, 0xc0, 0xff, 0xff, 0xff}, "VADDPS 0xffffffc0(IP), Z1, Z2"},
+		{[]byte{0xc5, 0xf8, 0x92, 0xc8}, "KMOVW AX, K1"},
+		{[]byte{0xc5, 0xec, 0x41, 0xcb}, "KANDW K3, K2, K1"},
+		{[]byte{0x62, 0xf1, 0x7d, 0x4a, 0x76, 0xc1}, "VPCMPEQD Z1, Z0, K2, K0"},
+		{[]byte{0x62, 0xf3, 0x75, 0x48, 0x1f, 0xc2, 0x01}, "VPCMPD $0x1, Z2, Z1, K0"},
+		{[]byte{0x62, 0xf3, 0x75, 0x48, 0x25, 0xc2, 0x96}, "VPTERNLOGD $0x96, Z2, Z1, Z0"},
+	}
+	testGo(testdata, Mode64, t)
+}
@@ -28,6 +28,14 @@ const (
 	PrefixAddressSize
 )
 
+// REX prefix (0x40-0x4f) bits in 64-bit mode. From Intel manual 2A, Section 2.2.1
+const (
+	RexB = 1 << iota // Extension of ModR/M rm, SIB base, or opcode reg field
+	RexX             // Extension of SIB index field
+	RexR             // Extension of ModR/M reg field
+	RexW             // 64-bit operand size
+)
+
 // Branch hints Prefix, in group 2
 const (
 	PrefixNotaken = PrefixCS
@@ -54,9 +62,16 @@ var Prefix = map[byte]int{
 
 // Read only one byte, store information in the Prefix field
 func (dc *DisContext) __parsePrefix() (got bool) {
-	pref, ok := Prefix[dc.nextByte()]
+	b := dc.nextByte()
+	if dc.Mode == Mode64 && b&0xf0 == 0x40 {
+		dc.Rex = b
+		return true
+	}
+	pref, ok := Prefix[b]
 	if ok {
 		got = true
+		// REX prefix is ignored if not immediately preceding the opcode
+		dc.Rex = 0
 		dc.Prefix |= pref
 		switch pref {
 		case PrefixOperandSize:
@@ -73,5 +88,8 @@ func (dc *DisContext) __parsePrefix() (got bool) {
 func (dc *DisContext) parsePrefix() {
 	// Keep parsing prefix until no one is find.
 	for dc.__parsePrefix() {
+		if dc.offset-dc.insnStart >= maxInsnLen {
+			panic(ErrTooLong)
+		}
 	}
 }
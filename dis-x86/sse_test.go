@@ -0,0 +1,465 @@
+package dis
+
+import (
+	"errors"
+	"testing"
+)
+
+// Check the mnemonic and length of instructions, the mandatory prefix should
+// not be reported as normal prefix.
+func testMnemonic(testdata []codeText, mode Mode, t *testing.T) {
+	for _, ct := range testdata {
+		insn, err := Decode(ct.binary, mode)
+		if err != nil {
+			t.Errorf("% x: %v", ct.binary, err)
+			continue
+		}
+		if name := InsnName[insn.OpId]; name != ct.assembly {
+			t.Errorf("% x: got %s, should be %s", ct.binary, name, ct.assembly)
+		}
+		if insn.Length != len(ct.binary) {
+			t.Errorf("% x: length %d, should be %d", ct.binary, insn.Length, len(ct.binary))
+		}
+		if insn.Prefix != 0 {
+			t.Errorf("% x: mandatory prefix reported as prefix %#x", ct.binary, insn.Prefix)
+		}
+	}
+}
+
+func TestSSEMnemonic(t *testing.T) {
+	testdata := []codeText{
+		// MMX
+		{[]byte{0x0f, 0x60, 0xc1}, "punpcklbw"},
+		{[]byte{0x0f, 0x61, 0xc1}, "punpcklwd"},
+		{[]byte{0x0f, 0x62, 0xc1}, "punpckldq"},
+		{[]byte{0x0f, 0x63, 0xc1}, "packsswb"},
+		{[]byte{0x0f, 0x64, 0xc1}, "pcmpgtb"},
+		{[]byte{0x0f, 0x65, 0xc1}, "pcmpgtw"},
+		{[]byte{0x0f, 0x66, 0xc1}, "pcmpgtd"},
+		{[]byte{0x0f, 0x67, 0xc1}, "packuswb"},
+		{[]byte{0x0f, 0x68, 0xc1}, "punpckhbw"},
+		{[]byte{0x0f, 0x69, 0xc1}, "punpckhwd"},
+		{[]byte{0x0f, 0x6a, 0xc1}, "punpckhdq"},
+		{[]byte{0x0f, 0x6b, 0xc1}, "packssdw"},
+		{[]byte{0x0f, 0x6e, 0xc1}, "movd"},
+		{[]byte{0x0f, 0x6f, 0xc1}, "movq"},
+		{[]byte{0x0f, 0x71, 0xd1, 0x05}, "psrlw"},
+		{[]byte{0x0f, 0x71, 0xe1, 0x05}, "psraw"},
+		{[]byte{0x0f, 0x71, 0xf1, 0x05}, "psllw"},
+		{[]byte{0x0f, 0x72, 0xd1, 0x05}, "psrld"},
+		{[]byte{0x0f, 0x72, 0xe1, 0x05}, "psrad"},
+		{[]byte{0x0f, 0x72, 0xf1, 0x05}, "pslld"},
+		{[]byte{0x0f, 0x73, 0xd1, 0x05}, "psrlq"},
+		{[]byte{0x0f, 0x73, 0xf1, 0x05}, "psllq"},
+		{[]byte{0x0f, 0x74, 0xc1}, "pcmpeqb"},
+		{[]byte{0x0f, 0x75, 0xc1}, "pcmpeqw"},
+		{[]byte{0x0f, 0x76, 0xc1}, "pcmpeqd"},
+		{[]byte{0x0f, 0x77}, "emms"},
+		{[]byte{0x0f, 0x7e, 0xc1}, "movd"},
+		{[]byte{0x0f, 0x7f, 0xc1}, "movq"},
+		{[]byte{0x0f, 0xd1, 0xc1}, "psrlw"},
+		{[]byte{0x0f, 0xd2, 0xc1}, "psrld"},
+		{[]byte{0x0f, 0xd3, 0xc1}, "psrlq"},
+		{[]byte{0x0f, 0xd5, 0xc1}, "pmullw"},
+		{[]byte{0x0f, 0xd8, 0xc1}, "psubusb"},
+		{[]byte{0x0f, 0xd9, 0xc1}, "psubusw"},
+		{[]byte{0x0f, 0xdb, 0xc1}, "pand"},
+		{[]byte{0x0f, 0xdc, 0xc1}, "paddusb"},
+		{[]byte{0x0f, 0xdd, 0xc1}, "paddusw"},
+		{[]byte{0x0f, 0xdf, 0xc1}, "pandn"},
+		{[]byte{0x0f, 0xe1, 0xc1}, "psraw"},
+		{[]byte{0x0f, 0xe2, 0xc1}, "psrad"},
+		{[]byte{0x0f, 0xe5, 0xc1}, "pmulhw"},
+		{[]byte{0x0f, 0xe8, 0xc1}, "psubsb"},
+		{[]byte{0x0f, 0xe9, 0xc1}, "psubsw"},
+		{[]byte{0x0f, 0xeb, 0xc1}, "por"},
+		{[]byte{0x0f, 0xec, 0xc1}, "paddsb"},
+		{[]byte{0x0f, 0xed, 0xc1}, "paddsw"},
+		{[]byte{0x0f, 0xef, 0xc1}, "pxor"},
+		{[]byte{0x0f, 0xf1, 0xc1}, "psllw"},
+		{[]byte{0x0f, 0xf2, 0xc1}, "pslld"},
+		{[]byte{0x0f, 0xf3, 0xc1}, "psllq"},
+		{[]byte{0x0f, 0xf5, 0xc1}, "pmaddwd"},
+		{[]byte{0x0f, 0xf8, 0xc1}, "psubb"},
+		{[]byte{0x0f, 0xf9, 0xc1}, "psubw"},
+		{[]byte{0x0f, 0xfa, 0xc1}, "psubd"},
+		{[]byte{0x0f, 0xfc, 0xc1}, "paddb"},
+		{[]byte{0x0f, 0xfd, 0xc1}, "paddw"},
+		{[]byte{0x0f, 0xfe, 0xc1}, "paddd"},
+		// SSE
+		{[]byte{0x0f, 0x10, 0xc1}, "movups"},
+		{[]byte{0x0f, 0x11, 0xc1}, "movups"},
+		{[]byte{0x0f, 0x12, 0xc1}, "movhlps"},
+		{[]byte{0x0f, 0x13, 0x00}, "movlps"},
+		{[]byte{0x0f, 0x14, 0xc1}, "unpcklps"},
+		{[]byte{0x0f, 0x15, 0xc1}, "unpckhps"},
+		{[]byte{0x0f, 0x16, 0xc1}, "movlhps"},
+		{[]byte{0x0f, 0x17, 0x00}, "movhps"},
+		{[]byte{0x0f, 0x18, 0x00}, "prefetchnta"},
+		{[]byte{0x0f, 0x18, 0x08}, "prefetcht0"},
+		{[]byte{0x0f, 0x18, 0x10}, "prefetcht1"},
+		{[]byte{0x0f, 0x18, 0x18}, "prefetcht2"},
+		{[]byte{0x0f, 0x28, 0xc1}, "movaps"},
+		{[]byte{0x0f, 0x29, 0xc1}, "movaps"},
+		{[]byte{0x0f, 0x2a, 0xc1}, "cvtpi2ps"},
+		{[]byte{0x0f, 0x2b, 0x00}, "movntps"},
+		{[]byte{0x0f, 0x2c, 0xc1}, "cvttps2pi"},
+		{[]byte{0x0f, 0x2d, 0xc1}, "cvtps2pi"},
+		{[]byte{0x0f, 0x2e, 0xc1}, "ucomiss"},
+		{[]byte{0x0f, 0x2f, 0xc1}, "comiss"},
+		{[]byte{0x0f, 0x50, 0xc1}, "movmskps"},
+		{[]byte{0x0f, 0x51, 0xc1}, "sqrtps"},
+		{[]byte{0x0f, 0x52, 0xc1}, "rsqrtps"},
+		{[]byte{0x0f, 0x53, 0xc1}, "rcpps"},
+		{[]byte{0x0f, 0x54, 0xc1}, "andps"},
+		{[]byte{0x0f, 0x55, 0xc1}, "andnps"},
+		{[]byte{0x0f, 0x56, 0xc1}, "orps"},
+		{[]byte{0x0f, 0x57, 0xc1}, "xorps"},
+		{[]byte{0x0f, 0x58, 0xc1}, "addps"},
+		{[]byte{0x0f, 0x59, 0xc1}, "mulps"},
+		{[]byte{0x0f, 0x5c, 0xc1}, "subps"},
+		{[]byte{0x0f, 0x5d, 0xc1}, "minps"},
+		{[]byte{0x0f, 0x5e, 0xc1}, "divps"},
+		{[]byte{0x0f, 0x5f, 0xc1}, "maxps"},
+		{[]byte{0x0f, 0x70, 0xc1, 0x05}, "pshufw"},
+		{[]byte{0x0f, 0xc2, 0xc1, 0x01}, "cmpps"},
+		{[]byte{0x0f, 0xc4, 0xc1, 0x05}, "pinsrw"},
+		{[]byte{0x0f, 0xc5, 0xc1, 0x05}, "pextrw"},
+		{[]byte{0x0f, 0xc6, 0xc1, 0x05}, "shufps"},
+		{[]byte{0x0f, 0xd7, 0xc1}, "pmovmskb"},
+		{[]byte{0x0f, 0xda, 0xc1}, "pminub"},
+		{[]byte{0x0f, 0xde, 0xc1}, "pmaxub"},
+		{[]byte{0x0f, 0xe0, 0xc1}, "pavgb"},
+		{[]byte{0x0f, 0xe3, 0xc1}, "pavgw"},
+		{[]byte{0x0f, 0xe4, 0xc1}, "pmulhuw"},
+		{[]byte{0x0f, 0xe7, 0x00}, "movntq"},
+		{[]byte{0x0f, 0xea, 0xc1}, "pminsw"},
+		{[]byte{0x0f, 0xee, 0xc1}, "pmaxsw"},
+		{[]byte{0x0f, 0xf6, 0xc1}, "psadbw"},
+		{[]byte{0x0f, 0xf7, 0xc1}, "maskmovq"},
+		{[]byte{0xf3, 0x0f, 0x10, 0xc1}, "movss"},
+		{[]byte{0xf3, 0x0f, 0x11, 0xc1}, "movss"},
+		{[]byte{0xf3, 0x0f, 0x2a, 0xc1}, "cvtsi2ss"},
+		{[]byte{0xf3, 0x0f, 0x2c, 0xc1}, "cvttss2si"},
+		{[]byte{0xf3, 0x0f, 0x2d, 0xc1}, "cvtss2si"},
+		{[]byte{0xf3, 0x0f, 0x51, 0xc1}, "sqrtss"},
+		{[]byte{0xf3, 0x0f, 0x52, 0xc1}, "rsqrtss"},
+		{[]byte{0xf3, 0x0f, 0x53, 0xc1}, "rcpss"},
+		{[]byte{0xf3, 0x0f, 0x58, 0xc1}, "addss"},
+		{[]byte{0xf3, 0x0f, 0x59, 0xc1}, "mulss"},
+		{[]byte{0xf3, 0x0f, 0x5c, 0xc1}, "subss"},
+		{[]byte{0xf3, 0x0f, 0x5d, 0xc1}, "minss"},
+		{[]byte{0xf3, 0x0f, 0x5e, 0xc1}, "divss"},
+		{[]byte{0xf3, 0x0f, 0x5f, 0xc1}, "maxss"},
+		{[]byte{0xf3, 0x0f, 0xc2, 0xc1, 0x01}, "cmpss"},
+		// SSE2
+		{[]byte{0x0f, 0x5a, 0xc1}, "cvtps2pd"},
+		{[]byte{0x0f, 0x5b, 0xc1}, "cvtdq2ps"},
+		{[]byte{0x0f, 0xc3, 0x00}, "movnti"},
+		{[]byte{0x0f, 0xd4, 0xc1}, "paddq"},
+		{[]byte{0x0f, 0xf4, 0xc1}, "pmuludq"},
+		{[]byte{0x0f, 0xfb, 0xc1}, "psubq"},
+		{[]byte{0x66, 0x0f, 0x10, 0xc1}, "movupd"},
+		{[]byte{0x66, 0x0f, 0x11, 0xc1}, "movupd"},
+		{[]byte{0x66, 0x0f, 0x12, 0x00}, "movlpd"},
+		{[]byte{0x66, 0x0f, 0x13, 0x00}, "movlpd"},
+		{[]byte{0x66, 0x0f, 0x14, 0xc1}, "unpcklpd"},
+		{[]byte{0x66, 0x0f, 0x15, 0xc1}, "unpckhpd"},
+		{[]byte{0x66, 0x0f, 0x16, 0x00}, "movhpd"},
+		{[]byte{0x66, 0x0f, 0x17, 0x00}, "movhpd"},
+		{[]byte{0x66, 0x0f, 0x28, 0xc1}, "movapd"},
+		{[]byte{0x66, 0x0f, 0x29, 0xc1}, "movapd"},
+		{[]byte{0x66, 0x0f, 0x2a, 0xc1}, "cvtpi2pd"},
+		{[]byte{0x66, 0x0f, 0x2b, 0x00}, "movntpd"},
+		{[]byte{0x66, 0x0f, 0x2c, 0xc1}, "cvttpd2pi"},
+		{[]byte{0x66, 0x0f, 0x2d, 0xc1}, "cvtpd2pi"},
+		{[]byte{0x66, 0x0f, 0x2e, 0xc1}, "ucomisd"},
+		{[]byte{0x66, 0x0f, 0x2f, 0xc1}, "comisd"},
+		{[]byte{0x66, 0x0f, 0x50, 0xc1}, "movmskpd"},
+		{[]byte{0x66, 0x0f, 0x51, 0xc1}, "sqrtpd"},
+		{[]byte{0x66, 0x0f, 0x54, 0xc1}, "andpd"},
+		{[]byte{0x66, 0x0f, 0x55, 0xc1}, "andnpd"},
+		{[]byte{0x66, 0x0f, 0x56, 0xc1}, "orpd"},
+		{[]byte{0x66, 0x0f, 0x57, 0xc1}, "xorpd"},
+		{[]byte{0x66, 0x0f, 0x58, 0xc1}, "addpd"},
+		{[]byte{0x66, 0x0f, 0x59, 0xc1}, "mulpd"},
+		{[]byte{0x66, 0x0f, 0x5a, 0xc1}, "cvtpd2ps"},
+		{[]byte{0x66, 0x0f, 0x5b, 0xc1}, "cvtps2dq"},
+		{[]byte{0x66, 0x0f, 0x5c, 0xc1}, "subpd"},
+		{[]byte{0x66, 0x0f, 0x5d, 0xc1}, "minpd"},
+		{[]byte{0x66, 0x0f, 0x5e, 0xc1}, "divpd"},
+		{[]byte{0x66, 0x0f, 0x5f, 0xc1}, "maxpd"},
+		{[]byte{0x66, 0x0f, 0x60, 0xc1}, "punpcklbw"},
+		{[]byte{0x66, 0x0f, 0x61, 0xc1}, "punpcklwd"},
+		{[]byte{0x66, 0x0f, 0x62, 0xc1}, "punpckldq"},
+		{[]byte{0x66, 0x0f, 0x63, 0xc1}, "packsswb"},
+		{[]byte{0x66, 0x0f, 0x64, 0xc1}, "pcmpgtb"},
+		{[]byte{0x66, 0x0f, 0x65, 0xc1}, "pcmpgtw"},
+		{[]byte{0x66, 0x0f, 0x66, 0xc1}, "pcmpgtd"},
+		{[]byte{0x66, 0x0f, 0x67, 0xc1}, "packuswb"},
+		{[]byte{0x66, 0x0f, 0x68, 0xc1}, "punpckhbw"},
+		{[]byte{0x66, 0x0f, 0x69, 0xc1}, "punpckhwd"},
+		{[]byte{0x66, 0x0f, 0x6a, 0xc1}, "punpckhdq"},
+		{[]byte{0x66, 0x0f, 0x6b, 0xc1}, "packssdw"},
+		{[]byte{0x66, 0x0f, 0x6c, 0xc1}, "punpcklqdq"},
+		{[]byte{0x66, 0x0f, 0x6d, 0xc1}, "punpckhqdq"},
+		{[]byte{0x66, 0x0f, 0x6e, 0xc1}, "movd"},
+		{[]byte{0x66, 0x0f, 0x6f, 0xc1}, "movdqa"},
+		{[]byte{0x66, 0x0f, 0x70, 0xc1, 0x05}, "pshufd"},
+		{[]byte{0x66, 0x0f, 0x71, 0xd1, 0x05}, "psrlw"},
+		{[]byte{0x66, 0x0f, 0x71, 0xe1, 0x05}, "psraw"},
+		{[]byte{0x66, 0x0f, 0x71, 0xf1, 0x05}, "psllw"},
+		{[]byte{0x66, 0x0f, 0x72, 0xd1, 0x05}, "psrld"},
+		{[]byte{0x66, 0x0f, 0x72, 0xe1, 0x05}, "psrad"},
+		{[]byte{0x66, 0x0f, 0x72, 0xf1, 0x05}, "pslld"},
+		{[]byte{0x66, 0x0f, 0x73, 0xd1, 0x05}, "psrlq"},
+		{[]byte{0x66, 0x0f, 0x73, 0xd9, 0x05}, "psrldq"},
+		{[]byte{0x66, 0x0f, 0x73, 0xf1, 0x05}, "psllq"},
+		{[]byte{0x66, 0x0f, 0x73, 0xf9, 0x05}, "pslldq"},
+		{[]byte{0x66, 0x0f, 0x74, 0xc1}, "pcmpeqb"},
+		{[]byte{0x66, 0x0f, 0x75, 0xc1}, "pcmpeqw"},
+		{[]byte{0x66, 0x0f, 0x76, 0xc1}, "pcmpeqd"},
+		{[]byte{0x66, 0x0f, 0x7e, 0xc1}, "movd"},
+		{[]byte{0x66, 0x0f, 0x7f, 0xc1}, "movdqa"},
+		{[]byte{0x66, 0x0f, 0xc2, 0xc1, 0x01}, "cmppd"},
+		{[]byte{0x66, 0x0f, 0xc4, 0xc1, 0x05}, "pinsrw"},
+		{[]byte{0x66, 0x0f, 0xc5, 0xc1, 0x05}, "pextrw"},
+		{[]byte{0x66, 0x0f, 0xc6, 0xc1, 0x05}, "shufpd"},
+		{[]byte{0x66, 0x0f, 0xd1, 0xc1}, "psrlw"},
+		{[]byte{0x66, 0x0f, 0xd2, 0xc1}, "psrld"},
+		{[]byte{0x66, 0x0f, 0xd3, 0xc1}, "psrlq"},
+		{[]byte{0x66, 0x0f, 0xd4, 0xc1}, "paddq"},
+		{[]byte{0x66, 0x0f, 0xd5, 0xc1}, "pmullw"},
+		{[]byte{0x66, 0x0f, 0xd6, 0xc1}, "movq"},
+		{[]byte{0x66, 0x0f, 0xd7, 0xc1}, "pmovmskb"},
+		{[]byte{0x66, 0x0f, 0xd8, 0xc1}, "psubusb"},
+		{[]byte{0x66, 0x0f, 0xd9, 0xc1}, "psubusw"},
+		{[]byte{0x66, 0x0f, 0xda, 0xc1}, "pminub"},
+		{[]byte{0x66, 0x0f, 0xdb, 0xc1}, "pand"},
+		{[]byte{0x66, 0x0f, 0xdc, 0xc1}, "paddusb"},
+		{[]byte{0x66, 0x0f, 0xdd, 0xc1}, "paddusw"},
+		{[]byte{0x66, 0x0f, 0xde, 0xc1}, "pmaxub"},
+		{[]byte{0x66, 0x0f, 0xdf, 0xc1}, "pandn"},
+		{[]byte{0x66, 0x0f, 0xe0, 0xc1}, "pavgb"},
+		{[]byte{0x66, 0x0f, 0xe1, 0xc1}, "psraw"},
+		{[]byte{0x66, 0x0f, 0xe2, 0xc1}, "psrad"},
+		{[]byte{0x66, 0x0f, 0xe3, 0xc1}, "pavgw"},
+		{[]byte{0x66, 0x0f, 0xe4, 0xc1}, "pmulhuw"},
+		{[]byte{0x66, 0x0f, 0xe5, 0xc1}, "pmulhw"},
+		{[]byte{0x66, 0x0f, 0xe6, 0xc1}, "cvttpd2dq"},
+		{[]byte{0x66, 0x0f, 0xe7, 0x00}, "movntdq"},
+		{[]byte{0x66, 0x0f, 0xe8, 0xc1}, "psubsb"},
+		{[]byte{0x66, 0x0f, 0xe9, 0xc1}, "psubsw"},
+		{[]byte{0x66, 0x0f, 0xea, 0xc1}, "pminsw"},
+		{[]byte{0x66, 0x0f, 0xeb, 0xc1}, "por"},
+		{[]byte{0x66, 0x0f, 0xec, 0xc1}, "paddsb"},
+		{[]byte{0x66, 0x0f, 0xed, 0xc1}, "paddsw"},
+		{[]byte{0x66, 0x0f, 0xee, 0xc1}, "pmaxsw"},
+		{[]byte{0x66, 0x0f, 0xef, 0xc1}, "pxor"},
+		{[]byte{0x66, 0x0f, 0xf1, 0xc1}, "psllw"},
+		{[]byte{0x66, 0x0f, 0xf2, 0xc1}, "pslld"},
+		{[]byte{0x66, 0x0f, 0xf3, 0xc1}, "psllq"},
+		{[]byte{0x66, 0x0f, 0xf4, 0xc1}, "pmuludq"},
+		{[]byte{0x66, 0x0f, 0xf5, 0xc1}, "pmaddwd"},
+		{[]byte{0x66, 0x0f, 0xf6, 0xc1}, "psadbw"},
+		{[]byte{0x66, 0x0f, 0xf7, 0xc1}, "maskmovdqu"},
+		{[]byte{0x66, 0x0f, 0xf8, 0xc1}, "psubb"},
+		{[]byte{0x66, 0x0f, 0xf9, 0xc1}, "psubw"},
+		{[]byte{0x66, 0x0f, 0xfa, 0xc1}, "psubd"},
+		{[]byte{0x66, 0x0f, 0xfb, 0xc1}, "psubq"},
+		{[]byte{0x66, 0x0f, 0xfc, 0xc1}, "paddb"},
+		{[]byte{0x66, 0x0f, 0xfd, 0xc1}, "paddw"},
+		{[]byte{0x66, 0x0f, 0xfe, 0xc1}, "paddd"},
+		{[]byte{0xf2, 0x0f, 0x10, 0xc1}, "movsd"},
+		{[]byte{0xf2, 0x0f, 0x11, 0xc1}, "movsd"},
+		{[]byte{0xf2, 0x0f, 0x2a, 0xc1}, "cvtsi2sd"},
+		{[]byte{0xf2, 0x0f, 0x2c, 0xc1}, "cvttsd2si"},
+		{[]byte{0xf2, 0x0f, 0x2d, 0xc1}, "cvtsd2si"},
+		{[]byte{0xf2, 0x0f, 0x51, 0xc1}, "sqrtsd"},
+		{[]byte{0xf2, 0x0f, 0x58, 0xc1}, "addsd"},
+		{[]byte{0xf2, 0x0f, 0x59, 0xc1}, "mulsd"},
+		{[]byte{0xf2, 0x0f, 0x5a, 0xc1}, "cvtsd2ss"},
+		{[]byte{0xf2, 0x0f, 0x5c, 0xc1}, "subsd"},
+		{[]byte{0xf2, 0x0f, 0x5d, 0xc1}, "minsd"},
+		{[]byte{0xf2, 0x0f, 0x5e, 0xc1}, "divsd"},
+		{[]byte{0xf2, 0x0f, 0x5f, 0xc1}, "maxsd"},
+		{[]byte{0xf2, 0x0f, 0x70, 0xc1, 0x05}, "pshuflw"},
+		{[]byte{0xf2, 0x0f, 0xc2, 0xc1, 0x01}, "cmpsd"},
+		{[]byte{0xf2, 0x0f, 0xd6, 0xc1}, "movdq2q"},
+		{[]byte{0xf2, 0x0f, 0xe6, 0xc1}, "cvtpd2dq"},
+		{[]byte{0xf3, 0x0f, 0x5a, 0xc1}, "cvtss2sd"},
+		{[]byte{0xf3, 0x0f, 0x5b, 0xc1}, "cvttps2dq"},
+		{[]byte{0xf3, 0x0f, 0x6f, 0xc1}, "movdqu"},
+		{[]byte{0xf3, 0x0f, 0x70, 0xc1, 0x05}, "pshufhw"},
+		{[]byte{0xf3, 0x0f, 0x7e, 0xc1}, "movq"},
+		{[]byte{0xf3, 0x0f, 0x7f, 0xc1}, "movdqu"},
+		{[]byte{0xf3, 0x0f, 0xd6, 0xc1}, "movq2dq"},
+		{[]byte{0xf3, 0x0f, 0xe6, 0xc1}, "cvtdq2pd"},
+		// SSE3
+		{[]byte{0x66, 0x0f, 0x7c, 0xc1}, "haddpd"},
+		{[]byte{0x66, 0x0f, 0x7d, 0xc1}, "hsubpd"},
+		{[]byte{0x66, 0x0f, 0xd0, 0xc1}, "addsubpd"},
+		{[]byte{0xf2, 0x0f, 0x12, 0xc1}, "movddup"},
+		{[]byte{0xf3, 0x0f, 0x12, 0xc1}, "movsldup"},
+		{[]byte{0xf2, 0x0f, 0x7c, 0xc1}, "haddps"},
+		{[]byte{0xf2, 0x0f, 0x7d, 0xc1}, "hsubps"},
+		{[]byte{0xf2, 0x0f, 0xd0, 0xc1}, "addsubps"},
+		{[]byte{0xf2, 0x0f, 0xf0, 0x00}, "lddqu"},
+		{[]byte{0xf3, 0x0f, 0x16, 0xc1}, "movshdup"},
+	}
+	testMnemonic(testdata, Mode32, t)
+
+	testdata = []codeText{
+		// Register and memory operand select different instruction
+		{[]byte{0x0f, 0x12, 0x00}, "movlps"},
+		{[]byte{0x0f, 0x16, 0x00}, "movhps"},
+		{[]byte{0x0f, 0xae, 0xf8}, "sfence"},
+		{[]byte{0x0f, 0xae, 0x38}, "clflush"},
+		{[]byte{0xf3, 0x90}, "pause"},
+	}
+	testMnemonic(testdata, Mode32, t)
+
+	testdata = []codeText{
+		{[]byte{0x66, 0x0f, 0x6e, 0xc0}, "movd"},
+		{[]byte{0x66, 0x48, 0x0f, 0x6e, 0xc0}, "movq"},
+		{[]byte{0x48, 0x0f, 0x7e, 0xc0}, "movq"},
+		{[]byte{0xf2, 0x48, 0x0f, 0x2a, 0xc0}, "cvtsi2sd"},
+		{[]byte{0x66, 0x41, 0x0f, 0x73, 0xd8, 0x08}, "psrldq"},
+		{[]byte{0xf3, 0x44, 0x0f, 0x7e, 0x05, 0x00, 0x01, 0x00, 0x00}, "movq"},
+	}
+	testMnemonic(testdata, Mode64, t)
+}
+
+func TestSSEDump(t *testing.T) {
+	testdata := []codeText{
+		// MMX
+		{[]byte{0x0f, 0x60, 0xc1}, "punpcklbw %mm1,%mm0"},
+		{[]byte{0x0f, 0x6e, 0xc1}, "movd %ecx,%mm0"},
+		{[]byte{0x0f, 0x7e, 0xc1}, "movd %mm0,%ecx"},
+		{[]byte{0x0f, 0x6f, 0x48, 0x04}, "movq 0x4(%eax),%mm1"},
+		{[]byte{0x0f, 0x7f, 0x48, 0x04}, "movq %mm1,0x4(%eax)"},
+		{[]byte{0x0f, 0x71, 0xd1, 0x05}, "psrlw $0x5,%mm1"},
+		{[]byte{0x0f, 0x73, 0xf1, 0x05}, "psllq $0x5,%mm1"},
+		{[]byte{0x0f, 0xd4, 0xc1}, "paddq %mm1,%mm0"},
+		{[]byte{0x0f, 0xf7, 0xc1}, "maskmovq %mm1,%mm0"},
+		{[]byte{0x0f, 0xd7, 0xc1}, "pmovmskb %mm1,%eax"},
+		{[]byte{0x0f, 0xc4, 0x48, 0x04, 0x05}, "pinsrw $0x5,0x4(%eax),%mm1"},
+		{[]byte{0x0f, 0xc5, 0xc1, 0x05}, "pextrw $0x5,%mm1,%eax"},
+		{[]byte{0x0f, 0x70, 0xc1, 0x05}, "pshufw $0x5,%mm1,%mm0"},
+		{[]byte{0x0f, 0xe7, 0x00}, "movntq %mm0,(%eax)"},
+		{[]byte{0x0f, 0x2a, 0xc1}, "cvtpi2ps %mm1,%xmm0"},
+		{[]byte{0x0f, 0x2d, 0xc1}, "cvtps2pi %xmm1,%mm0"},
+
+		// SSE
+		{[]byte{0x0f, 0x10, 0xc1}, "movups %xmm1,%xmm0"},
+		{[]byte{0x0f, 0x11, 0x48, 0x04}, "movups %xmm1,0x4(%eax)"},
+		{[]byte{0x0f, 0x12, 0xc1}, "movhlps %xmm1,%xmm0"},
+		{[]byte{0x0f, 0x12, 0x48, 0x04}, "movlps 0x4(%eax),%xmm1"},
+		{[]byte{0x0f, 0x17, 0x00}, "movhps %xmm0,(%eax)"},
+		{[]byte{0x0f, 0x58, 0xc1}, "addps %xmm1,%xmm0"},
+		{[]byte{0x0f, 0x57, 0x48, 0x04}, "xorps 0x4(%eax),%xmm1"},
+		{[]byte{0x0f, 0x50, 0xc1}, "movmskps %xmm1,%eax"},
+		{[]byte{0x0f, 0xc6, 0xc1, 0x05}, "shufps $0x5,%xmm1,%xmm0"},
+		{[]byte{0x0f, 0x18, 0x08}, "prefetcht0 (%eax)"},
+		{[]byte{0xf3, 0x0f, 0x10, 0xc1}, "movss %xmm1,%xmm0"},
+		{[]byte{0xf3, 0x0f, 0x2a, 0xc1}, "cvtsi2ss %ecx,%xmm0"},
+		{[]byte{0xf3, 0x0f, 0x2c, 0xc1}, "cvttss2si %xmm1,%eax"},
+		{[]byte{0xf3, 0x0f, 0x58, 0x48, 0x04}, "addss 0x4(%eax),%xmm1"},
+
+		// SSE2
+		{[]byte{0xf3, 0x0f, 0xd6, 0xc1}, "movq2dq %mm1,%xmm0"},
+		{[]byte{0xf2, 0x0f, 0xd6, 0xc1}, "movdq2q %xmm1,%mm0"},
+		{[]byte{0x66, 0x0f, 0x2d, 0xc1}, "cvtpd2pi %xmm1,%mm0"},
+		{[]byte{0x66, 0x0f, 0x6e, 0xc1}, "movd %ecx,%xmm0"},
+		{[]byte{0x66, 0x0f, 0x7e, 0x48, 0x04}, "movd %xmm1,0x4(%eax)"},
+		{[]byte{0x66, 0x0f, 0x6f, 0x48, 0x04}, "movdqa 0x4(%eax),%xmm1"},
+		{[]byte{0x66, 0x0f, 0x73, 0xd9, 0x05}, "psrldq $0x5,%xmm1"},
+		{[]byte{0x66, 0x0f, 0xd6, 0xc1}, "movq %xmm0,%xmm1"},
+		{[]byte{0x66, 0x0f, 0xc5, 0xc1, 0x05}, "pextrw $0x5,%xmm1,%eax"},
+		{[]byte{0x66, 0x0f, 0xf7, 0xc1}, "maskmovdqu %xmm1,%xmm0"},
+		{[]byte{0xf3, 0x0f, 0x7e, 0xc1}, "movq %xmm1,%xmm0"},
+		{[]byte{0xf2, 0x0f, 0x2a, 0x00}, "cvtsi2sd (%eax),%xmm0"},
+		{[]byte{0xf2, 0x0f, 0xf0, 0x00}, "lddqu (%eax),%xmm0"},
+
+		// The immediate selects the comparison predicate
+		{[]byte{0x0f, 0xc2, 0xc1, 0x01}, "cmpltps %xmm1,%xmm0"},
+		{[]byte{0x0f, 0xc2, 0x48, 0x04, 0x07}, "cmpordps 0x4(%eax),%xmm1"},
+		{[]byte{0xf2, 0x0f, 0xc2, 0xc1, 0x04}, "cmpneqsd %xmm1,%xmm0"},
+		{[]byte{0x66, 0x0f, 0xc2, 0xc1, 0x08}, "cmppd $0x8,%xmm1,%xmm0"},
+		{[]byte{0xc5, 0xf4, 0xc2, 0xc2, 0x1f}, "vcmptrue_usps %ymm2,%ymm1,%ymm0"},
+		{[]byte{0xc5, 0xf3, 0xc2, 0xc2, 0x00}, "vcmpeqsd %xmm2,%xmm1,%xmm0"},
+		{[]byte{0xc5, 0xf0, 0xc2, 0xc2, 0x20}, "vcmpps $0x20,%xmm2,%xmm1,%xmm0"},
+	}
+	testDump(testdata, t)
+
+	testdata = []codeText{
+		{[]byte{0x66, 0x45, 0x0f, 0x58, 0xc1}, "addpd %xmm9,%xmm8"},
+		{[]byte{0x66, 0x45, 0x0f, 0x6f, 0x48, 0x04}, "movdqa 0x4(%r8),%xmm9"},
+		{[]byte{0xf3, 0x44, 0x0f, 0x7e, 0x05, 0x00, 0x01, 0x00, 0x00}, "movq 0x100(%rip),%xmm8"},
+		{[]byte{0x66, 0x48, 0x0f, 0x6e, 0xc0}, "movq %rax,%xmm0"},
+		{[]byte{0xf3, 0x45, 0x0f, 0x2a, 0xc1}, "cvtsi2ss %r9d,%xmm8"},
+		// REX does not extend MMX register. objdump shows the unused bits
+		// as rex.RB, which is not dumped here.
+		{[]byte{0x45, 0x0f, 0x6f, 0x48, 0x04}, "movq 0x4(%r8),%mm1"},
+		// Integer source size is ambiguous for memory operand
+		{[]byte{0xf2, 0x0f, 0x2a, 0x00}, "cvtsi2sdl (%rax),%xmm0"},
+		{[]byte{0xf2, 0x48, 0x0f, 0x2a, 0x00}, "cvtsi2sdq (%rax),%xmm0"},
+		{[]byte{0xc5, 0xf3, 0x2a, 0x00}, "vcvtsi2sdl (%rax),%xmm1,%xmm0"},
+		{[]byte{0xc4, 0xe1, 0xf3, 0x2a, 0x00}, "vcvtsi2sdq (%rax),%xmm1,%xmm0"},
+	}
+	testDumpMode(testdata, Mode64, t)
+
+	// pextrw with memory operand is only available in SSE4.1 (66 0f 3a 15)
+	if _, err := Decode([]byte{0x66, 0x0f, 0xc5, 0x00, 0x05}, Mode32); !errors.Is(err, ErrInvalidModRM) {
+		t.Error("pextrw 66 0f c5 with memory operand should be invalid, got", err)
+	}
+}
+
+func TestMandatoryPrefix(t *testing.T) {
+	// Prefixes not used as mandatory prefix keeps the normal meaning.
+	testdata := []codeText{
+		{[]byte{0xf3, 0xab}, "rep stos %eax,%es:(%edi)"},
+		{[]byte{0x66, 0x0f, 0xb6, 0xc1}, "movzbw %cl,%ax"},
+		{[]byte{0x98}, "cwtl "},
+		{[]byte{0x66, 0x98}, "cbtw "},
+		{[]byte{0x99}, "cltd "},
+		{[]byte{0xf3, 0x0f, 0x2b, 0x77, 0x77}, "movntss %xmm6,0x77(%edi)"},
+		{[]byte{0xf2, 0x0f, 0x2b, 0x39}, "movntsd %xmm7,(%ecx)"},
+	}
+	testDump(testdata, t)
+
+	testdata = []codeText{
+		{[]byte{0x48, 0x98}, "cltq "},
+		{[]byte{0x48, 0x99}, "cqto "},
+	}
+	testDumpMode(testdata, Mode64, t)
+
+	insn, err := Decode([]byte{0xf3, 0x0f, 0x10, 0xc1}, Mode32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if insn.MandatoryPrefix != 0xf3 || insn.dumpRepLockPrefix() != "" {
+		t.Error("f3 should be used as mandatory prefix for movss")
+	}
+
+	// F2 and F3 take precedence, 66 is then the operand-size prefix
+	insn, err = Decode([]byte{0x66, 0xf2, 0x0f, 0x58, 0xc1}, Mode32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if InsnName[insn.OpId] != "addsd" || insn.Prefix != PrefixOperandSize {
+		t.Errorf("66 f2 0f 58 should be addsd with operand-size prefix, got %s", InsnName[insn.OpId])
+	}
+
+	// F2 and F3 not defined for an opcode selected by mandatory prefix
+	for _, b := range [][]byte{{0xf2, 0x0f, 0x7f, 0xc2}, {0xf2, 0x0f, 0xbc, 0xc0}, {0xf3, 0x0f, 0x2f, 0xc0}} {
+		if _, err := Decode(b, Mode32); !errors.Is(err, ErrUnknownOpcode) {
+			t.Errorf("% x should be invalid, got %v", b, err)
+		}
+	}
+
+	// Register operand only
+	if _, err := Decode([]byte{0x0f, 0x50, 0x00}, Mode32); !errors.Is(err, ErrInvalidModRM) {
+		t.Error("movmskps with memory operand should be invalid, got", err)
+	}
+}
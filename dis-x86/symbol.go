@@ -0,0 +1,173 @@
+package dis
+
+import (
+	"debug/elf"
+	"fmt"
+	"sort"
+)
+
+// Symbolizer finds the symbol containing an address. Formatters use it to show
+// absolute addresses, branch targets and RIP-relative references as
+// name+offset instead of hex value.
+type Symbolizer interface {
+	// Symbolize returns the name and start address of the symbol containing
+	// addr. The name is empty if no symbol is found.
+	Symbolize(addr uint64) (name string, base uint64)
+}
+
+// Return addr as name or name+offset, empty if there's no symbolizer or no
+// symbol contains addr.
+func (insn *Instruction) symbolize(addr uint64) string {
+	if insn.symbolizer == nil {
+		return ""
+	}
+	name, base := insn.symbolizer.Symbolize(addr)
+	switch {
+	case name == "":
+		return ""
+	case addr == base:
+		return name
+	}
+	return fmt.Sprintf("%s+%#x", name, addr-base)
+}
+
+// Return the branch target the way objdump shows it, the address in hex
+// followed by the symbol, e.g. c0100123 <foo+0x12>. The address is shown as
+// 0x hex value if there's no symbol.
+func (insn *Instruction) targetString() string {
+	if sym := insn.symbolize(insn.Target); sym != "" {
+		return fmt.Sprintf("%x <%s>", insn.Target, sym)
+	}
+	return fmt.Sprintf("%#x", insn.Target)
+}
+
+// Return the address given by displacement of the memory operand, if it's not
+// relative to a base register. The index register may present, the
+// displacement is then the start of an array.
+func (insn *Instruction) dispAddr() (addr uint64, ok bool) {
+	addressSize := insn.EffectiveAddressSize()
+	switch {
+	case insn.IsRipRelative():
+		addr = insn.Addr + uint64(insn.Length) + uint64(int64(insn.Disp))
+	case addressSize == OpSizeWord:
+		if !(insn.Rm&7 == 6 && insn.Mod == 0) {
+			return 0, false
+		}
+	case insn.Scale != 0:
+		if !(insn.Base&7 == 5 && insn.Mod == 0) {
+			return 0, false
+		}
+		addr = uint64(int64(insn.Disp))
+	case insn.Rm&7 == 5 && insn.Mod == 0:
+		addr = uint64(int64(insn.Disp))
+	default:
+		return 0, false
+	}
+	switch addressSize {
+	case OpSizeWord:
+		addr = uint64(uint16(insn.Disp))
+	case OpSizeLong:
+		addr &= 0xffffffff
+	}
+	return addr, true
+}
+
+// Return the symbol of address given by displacement, empty if there's none.
+func (insn *Instruction) dispSymbol() string {
+	addr, ok := insn.dispAddr()
+	if !ok {
+		return ""
+	}
+	return insn.symbolize(addr)
+}
+
+// Return the symbol of memory offset used by mov (0xa0 - 0xa3).
+func (insn *Instruction) moffsSymbol() string {
+	addr := uint64(insn.ImmOff)
+	switch insn.EffectiveAddressSize() {
+	case OpSizeWord:
+		addr &= 0xffff
+	case OpSizeLong:
+		addr &= 0xffffffff
+	}
+	return insn.symbolize(addr)
+}
+
+// Format the instruction with symbolizer set. The instruction is copied so
+// the caller's one is not modified.
+func formatWithSymbolizer(insn *Instruction, s Symbolizer, format func(*Instruction) string) string {
+	if s == nil {
+		return format(insn)
+	}
+	withSym := *insn
+	withSym.symbolizer = s
+	return format(&withSym)
+}
+
+// ELFSymbolizer looks up symbols in the symbol table of an ELF file.
+type ELFSymbolizer struct {
+	syms []elf.Symbol // Sorted by address
+	// Largest end address of syms[0] to syms[i], no symbol before i+1
+	// contains addresses after it.
+	maxEnd []uint64
+}
+
+// Create symbolizer from the symbol table of f. The dynamic symbol table is
+// used if there's no symbol table, e.g. stripped shared library.
+func NewELFSymbolizer(f *elf.File) (*ELFSymbolizer, error) {
+	syms, err := f.Symbols()
+	if err == elf.ErrNoSymbols {
+		syms, err = f.DynamicSymbols()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ELFSymbolizer{}
+	for _, sym := range syms {
+		switch elf.ST_TYPE(sym.Info) {
+		case elf.STT_NOTYPE, elf.STT_OBJECT, elf.STT_FUNC, elf.STT_TLS:
+		default:
+			continue
+		}
+		if sym.Section == elf.SHN_UNDEF || sym.Name == "" {
+			continue
+		}
+		s.syms = append(s.syms, sym)
+	}
+	sort.SliceStable(s.syms, func(i, j int) bool {
+		return s.syms[i].Value < s.syms[j].Value
+	})
+	s.setMaxEnd()
+	return s, nil
+}
+
+func (s *ELFSymbolizer) setMaxEnd() {
+	s.maxEnd = make([]uint64, len(s.syms))
+	var end uint64
+	for i, sym := range s.syms {
+		if e := sym.Value + sym.Size; e > end {
+			end = e
+		}
+		s.maxEnd[i] = end
+	}
+}
+
+// Symbolize returns the symbol containing addr. A symbol without size only
+// contains its start address. If the last symbol starting at or before addr
+// doesn't contain it, e.g. a local label inside a function, earlier symbols
+// are searched for an enclosing one.
+func (s *ELFSymbolizer) Symbolize(addr uint64) (name string, base uint64) {
+	i := sort.Search(len(s.syms), func(i int) bool {
+		return s.syms[i].Value > addr
+	}) - 1
+	if i >= 0 && s.syms[i].Value == addr {
+		return s.syms[i].Name, addr
+	}
+	for ; i >= 0 && s.maxEnd[i] > addr; i-- {
+		if sym := &s.syms[i]; addr-sym.Value < sym.Size {
+			return sym.Name, sym.Value
+		}
+	}
+	return "", 0
+}
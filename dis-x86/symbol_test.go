@@ -0,0 +1,138 @@
+package dis
+
+import (
+	"debug/elf"
+	"testing"
+)
+
+type testSymbol struct {
+	name       string
+	addr, size uint64
+}
+
+type testSymbolizer []testSymbol
+
+func (ts testSymbolizer) Symbolize(addr uint64) (string, uint64) {
+	for _, sym := range ts {
+		if sym.addr <= addr && addr < sym.addr+sym.size {
+			return sym.name, sym.addr
+		}
+	}
+	return "", 0
+}
+
+var symbols = testSymbolizer{
+	{"foo", 0x1000, 0x100},
+	{"table", 0x2000, 0x40},
+	{"per_cpu__current_task", 0xc02fce40, 4},
+}
+
+func testSymbolize(f Formatter, testdata []codeText, mode Mode, t *testing.T) {
+	for _, ct := range testdata {
+		insn, err := Decode(ct.binary, mode)
+		if err != nil {
+			t.Errorf("% x: %v", ct.binary, err)
+			continue
+		}
+		if dump := insn.Format(f); dump != ct.assembly {
+			t.Errorf("% x: got %s, should be %s", ct.binary, dump, ct.assembly)
+		}
+	}
+}
+
+func TestSymbolize(t *testing.T) {
+	testdata := []codeText{
+		{[]byte{0x64, 0x8b, 0x35, 0x40, 0xce, 0x2f, 0xc0}, "mov %fs:per_cpu__current_task,%esi"},
+		{[]byte{0xa1, 0x10, 0x10, 0x00, 0x00}, "mov foo+0x10,%eax"},
+		{[]byte{0x8b, 0x04, 0x8d, 0x00, 0x20, 0x00, 0x00}, "mov table(,%ecx,4),%eax"},
+		{[]byte{0xe8, 0xfb, 0x0f, 0x00, 0x00}, "call 1000 <foo>"},
+		{[]byte{0xe9, 0x0b, 0x10, 0x00, 0x00}, "jmp 1010 <foo+0x10>"},
+		{[]byte{0xeb, 0x7e}, "jmp 0x80"},
+		// Displacement relative to base register is not symbolized
+		{[]byte{0x8b, 0x80, 0x00, 0x10, 0x00, 0x00}, "mov 0x1000(%eax),%eax"},
+	}
+	testSymbolize(ATTSyntax{symbols}, testdata, Mode32, t)
+
+	testdata = []codeText{
+		{[]byte{0x64, 0x8b, 0x35, 0x40, 0xce, 0x2f, 0xc0}, "mov esi,DWORD PTR fs:per_cpu__current_task"},
+		{[]byte{0xa1, 0x10, 0x10, 0x00, 0x00}, "mov eax,ds:foo+0x10"},
+		{[]byte{0x8b, 0x04, 0x8d, 0x00, 0x20, 0x00, 0x00}, "mov eax,DWORD PTR [ecx*4+table]"},
+		{[]byte{0xe8, 0xfb, 0x0f, 0x00, 0x00}, "call 1000 <foo>"},
+	}
+	testSymbolize(IntelSyntax{symbols}, testdata, Mode32, t)
+
+	testdata = []codeText{
+		{[]byte{0x64, 0x8b, 0x35, 0x40, 0xce, 0x2f, 0xc0}, "MOVL FS:per_cpu__current_task(SB), SI"},
+		{[]byte{0xa1, 0x10, 0x10, 0x00, 0x00}, "MOVL foo+0x10(SB), AX"},
+		{[]byte{0x8b, 0x04, 0x8d, 0x00, 0x20, 0x00, 0x00}, "MOVL table(SB)(CX*4), AX"},
+		{[]byte{0xe8, 0xfb, 0x0f, 0x00, 0x00}, "CALL foo(SB)"},
+	}
+	testSymbolize(GoSyntax{symbols}, testdata, Mode32, t)
+
+	// RIP-relative reference is relative to the next instruction
+	testdata = []codeText{
+		{[]byte{0x48, 0x8b, 0x05, 0xf9, 0x0f, 0x00, 0x00}, "mov foo(%rip),%rax"},
+		{[]byte{0x48, 0x8d, 0x05, 0xf9, 0x1f, 0x00, 0x00}, "lea table(%rip),%rax"},
+	}
+	testSymbolize(ATTSyntax{symbols}, testdata, Mode64, t)
+	testdata = []codeText{
+		{[]byte{0x48, 0x8b, 0x05, 0xf9, 0x0f, 0x00, 0x00}, "mov rax,QWORD PTR [rip+foo]"},
+	}
+	testSymbolize(IntelSyntax{symbols}, testdata, Mode64, t)
+	testdata = []codeText{
+		{[]byte{0x48, 0x8b, 0x05, 0xf9, 0x0f, 0x00, 0x00}, "MOVQ foo(SB), AX"},
+	}
+	testSymbolize(GoSyntax{symbols}, testdata, Mode64, t)
+}
+
+func TestELFSymbolizer(t *testing.T) {
+	s := &ELFSymbolizer{syms: []elf.Symbol{
+		{Name: "_start", Value: 0x1000},
+		{Name: "foo", Value: 0x1010, Size: 0x20},
+		{Name: "bar", Value: 0x1040, Size: 0x10},
+		{Name: "outer", Value: 0x1100, Size: 0x100},
+		{Name: "inner", Value: 0x1120, Size: 0x10},
+		{Name: ".Llabel", Value: 0x1140},
+	}}
+	s.setMaxEnd()
+	testdata := []struct {
+		addr uint64
+		name string
+		base uint64
+	}{
+		{0x0fff, "", 0},
+		{0x1000, "_start", 0x1000},
+		{0x1001, "", 0}, // Symbol without size only matches its start
+		{0x1010, "foo", 0x1010},
+		{0x102f, "foo", 0x1010},
+		{0x1030, "", 0},
+		{0x1048, "bar", 0x1040},
+		// Nested symbols, the innermost one containing the address is used
+		{0x1128, "inner", 0x1120},
+		{0x1130, "outer", 0x1100},
+		{0x1140, ".Llabel", 0x1140},
+		{0x1148, "outer", 0x1100},
+		{0x1200, "", 0},
+		{0x2000, "", 0},
+	}
+	for _, td := range testdata {
+		if name, base := s.Symbolize(td.addr); name != td.name || base != td.base {
+			t.Errorf("%#x: got %s %#x, should be %s %#x", td.addr, name, base, td.name, td.base)
+		}
+	}
+
+	// Load symbols from the kernel image if it's there
+	f, err := elf.Open("testdata/vmlinux")
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	if s, err = NewELFSymbolizer(f); err != nil {
+		t.Fatal("loading vmlinux symbols failed:", err)
+	}
+	for i := 1; i < len(s.syms); i++ {
+		if s.syms[i-1].Value > s.syms[i].Value {
+			t.Fatal("symbols not sorted by address")
+		}
+	}
+}
@@ -0,0 +1,323 @@
+// Package emu emulates an IA-32 processor.
+//
+// Instructions are fetched and decoded by dis.DisContext, then executed on
+// the CPU state. Only the integer instruction set is supported. Segment
+// registers are loaded from GDT and LDT with limit and access rights checked,
+// but gates and task switch are not supported. Paging is not supported, and
+// exceptions are returned to the caller instead of delivered through the IDT,
+// so code snippets can be run deterministically in tests.
+//
+// Memory is the guest physical address space backed by RAM, with MMIO regions
+// handled by device models.
+package emu
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	dis "github.com/cyfdecyf/GoEmu/dis-x86"
+)
+
+// Bus is the memory accessed by the CPU with linear address. As paging is
+// not supported, linear address is the same as physical address.
+type Bus interface {
+	io.ReaderAt
+	io.WriterAt
+}
+
+// CodeReader is implemented by buses having a separate path to fetch
+// instructions, which has no side effects. Memory implements it.
+type CodeReader interface {
+	Code() io.ReaderAt
+}
+
+// CR0 bits.
+const (
+	CR0PE uint32 = 1 << 0 // Protection enable
+	CR0MP uint32 = 1 << 1
+	CR0EM uint32 = 1 << 2
+	CR0TS uint32 = 1 << 3
+	CR0ET uint32 = 1 << 4
+	CR0NE uint32 = 1 << 5
+	CR0WP uint32 = 1 << 16
+	CR0AM uint32 = 1 << 18
+	CR0NW uint32 = 1 << 29
+	CR0CD uint32 = 1 << 30
+	CR0PG uint32 = 1 << 31
+)
+
+// Segment register. Base, Limit and Attr are the hidden part loaded from the
+// descriptor with the selector. Limit is in bytes. Attr is zero if loaded
+// with null selector.
+type Segment struct {
+	Selector uint16
+	Base     uint32
+	Limit    uint32
+	Attr     uint16 // Access rights, SegP etc.
+}
+
+type CPU struct {
+	Regs [8]uint32 // General purpose registers, indexed by dis.Eax etc.
+	EIP  uint32
+	Seg  [6]Segment // Indexed by dis.ES etc.
+	CPL  uint16     // Current privilege level, set when CS is loaded
+	CR   [5]uint32  // CR0 to CR4, CR1 is reserved
+
+	GDTR DescTable
+	IDTR DescTable
+	LDTR Segment
+
+	Bus    Bus
+	Halted bool // Set by hlt
+
+	eflags     uint32
+	lazy       lazyFlags
+	eagerFlags bool // Evaluate flags by every operation, used to test lazy evaluation
+	dc         *dis.DisContext
+	insn       *dis.Instruction // Instruction being executed
+	next       uint32           // EIP after the instruction
+	// Registers restored if the instruction doesn't complete, so faults
+	// are restartable. Saved after each iteration of rep string
+	// instructions, which are restarted from the faulting iteration.
+	savedRegs [8]uint32
+}
+
+// Exception vectors.
+const (
+	VectorDE = 0  // Divide error
+	VectorDB = 1  // Debug
+	VectorBP = 3  // Breakpoint
+	VectorOF = 4  // Overflow
+	VectorBR = 5  // Bound range exceeded
+	VectorUD = 6  // Invalid opcode
+	VectorNM = 7  // Device not available
+	VectorDF = 8  // Double fault
+	VectorTS = 10 // Invalid TSS
+	VectorNP = 11 // Segment not present
+	VectorSS = 12 // Stack-segment fault
+	VectorGP = 13 // General protection
+	VectorPF = 14 // Page fault
+	VectorAC = 17 // Alignment check
+)
+
+var exceptionName = map[byte]string{
+	VectorDE: "DE",
+	VectorDB: "DB",
+	VectorBP: "BP",
+	VectorOF: "OF",
+	VectorBR: "BR",
+	VectorUD: "UD",
+	VectorNM: "NM",
+	VectorDF: "DF",
+	VectorTS: "TS",
+	VectorNP: "NP",
+	VectorSS: "SS",
+	VectorGP: "GP",
+	VectorPF: "PF",
+	VectorAC: "AC",
+}
+
+// Exceptions pushing an error code.
+var hasErrorCode = map[byte]bool{
+	VectorDF: true,
+	VectorTS: true,
+	VectorNP: true,
+	VectorSS: true,
+	VectorGP: true,
+	VectorPF: true,
+	VectorAC: true,
+}
+
+// Exception raised by an instruction, including software interrupt by int.
+// For faults, EIP points to the faulting instruction and general purpose
+// registers are not changed, so the instruction is executed again by the
+// next Step. Memory written before the fault is not restored. For traps (int, int3 and into), EIP
+// points to the next instruction.
+type Exception struct {
+	Vector    byte
+	ErrorCode uint32
+}
+
+func (e *Exception) Error() string {
+	name, ok := exceptionName[e.Vector]
+	if !ok {
+		return fmt.Sprintf("interrupt %#x", e.Vector)
+	}
+	if hasErrorCode[e.Vector] {
+		return fmt.Sprintf("#%s(%#x)", name, e.ErrorCode)
+	}
+	return "#" + name
+}
+
+func fault(vector byte, errorCode uint32) {
+	panic(&Exception{Vector: vector, ErrorCode: errorCode})
+}
+
+// Error returned by the bus. It's wrapped when panicking so Step can tell it
+// from other errors.
+type busError struct {
+	err error
+}
+
+// UnsupportedError is returned for valid instructions which the emulator
+// can't execute.
+type UnsupportedError struct {
+	Addr uint32
+	Insn string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("emu: unsupported instruction %s at %#x", e.Insn, e.Addr)
+}
+
+// Error for the instruction being executed.
+func (cpu *CPU) unsupported() *UnsupportedError {
+	return &UnsupportedError{Addr: cpu.EIP, Insn: strings.TrimSpace(cpu.insn.Format(dis.ATTSyntax{}))}
+}
+
+var (
+	ErrHalted    = errors.New("emu: CPU halted")
+	ErrStepLimit = errors.New("emu: step limit reached")
+)
+
+const eflagsReserved = 1 << 1 // Always set
+
+// Access rights of the flat segments.
+const (
+	flatCode = SegP | SegS | SegCode | SegReadable | SegAccessed | SegDB | SegG
+	flatData = SegP | SegS | SegWritable | SegAccessed | SegDB | SegG
+)
+
+// NewCPU creates a CPU in 32-bit protected mode with flat segments, which
+// have base 0 and limit 4G. CPL is 0. As GDT is empty, loading segment
+// registers faults until GDTR is set. Instructions are fetched through Code
+// if bus implements CodeReader.
+func NewCPU(bus Bus) *CPU {
+	cpu := &CPU{Bus: bus, eflags: eflagsReserved}
+	cpu.CR[0] = CR0PE | CR0ET
+	for i := range cpu.Seg {
+		cpu.Seg[i] = Segment{Limit: 0xffffffff, Attr: flatData}
+	}
+	cpu.Seg[dis.CS].Attr = flatCode
+	var code io.ReaderAt = bus
+	if c, ok := bus.(CodeReader); ok {
+		code = c.Code()
+	}
+	cpu.dc = dis.NewDisContext(code)
+	cpu.dc.SetMode(dis.Mode32)
+	return cpu
+}
+
+// EFLAGS returns the value of EFLAGS.
+func (cpu *CPU) EFLAGS() dis.Flags {
+	cpu.evalFlags()
+	return dis.Flags(cpu.eflags)
+}
+
+func (cpu *CPU) SetEFLAGS(f dis.Flags) {
+	cpu.lazy.pending = false
+	cpu.eflags = uint32(f&dis.FlagsAll) | eflagsReserved
+}
+
+// Step executes one instruction. Errors returned by the bus, *Exception and
+// *UnsupportedError are returned as is, with the general purpose registers
+// restored. Other panics are not recovered.
+func (cpu *CPU) Step() (err error) {
+	if cpu.Halted {
+		return ErrHalted
+	}
+	cpu.savedRegs = cpu.Regs
+	defer func() {
+		if r := recover(); r != nil {
+			switch e := r.(type) {
+			case *Exception:
+				err = e
+			case *UnsupportedError:
+				err = e
+			case busError:
+				err = e.err
+			default:
+				panic(r)
+			}
+			cpu.Regs = cpu.savedRegs
+		}
+	}()
+
+	cpu.dc.SetOffset(int64(cpu.Seg[dis.CS].Base + cpu.EIP))
+	if _, err := cpu.dc.NextInsn(); err != nil {
+		if _, ok := err.(*dis.DecodeError); ok {
+			fault(VectorUD, 0)
+		}
+		return err
+	}
+	cpu.insn = &cpu.dc.Instruction
+	if !cpu.Seg[dis.CS].inLimit(cpu.EIP, cpu.insn.Length) {
+		fault(VectorGP, 0)
+	}
+	exec, ok := execOfInsn[cpu.insn.OpId]
+	if !ok {
+		return cpu.unsupported()
+	}
+	cpu.next = cpu.EIP + uint32(cpu.insn.Length)
+	if cpu.insn.Mode == dis.Mode16 {
+		cpu.next &= 0xffff
+	}
+	exec(cpu)
+	cpu.EIP = cpu.next
+	return nil
+}
+
+// Run executes instructions until the CPU is halted, or an error occurs. At
+// most limit instructions are executed if limit is positive. Returns nil if
+// halted.
+func (cpu *CPU) Run(limit int) error {
+	for i := 0; limit <= 0 || i < limit; i++ {
+		if cpu.Halted {
+			return nil
+		}
+		if err := cpu.Step(); err != nil {
+			return err
+		}
+	}
+	if cpu.Halted {
+		return nil
+	}
+	return ErrStepLimit
+}
+
+func (cpu *CPU) setCR(num byte, v uint32) {
+	if num == 0 {
+		cpu.dc.SetProtected(v&CR0PE != 0)
+		if v&CR0PE == 0 {
+			// Real mode runs at CPL 0
+			cpu.CPL = 0
+		}
+	}
+	cpu.CR[num] = v
+}
+
+// Operand size of the instruction in bytes.
+func (cpu *CPU) opSize() int {
+	if cpu.insn.EffectiveOperandSize() == dis.OpSizeWord {
+		return 2
+	}
+	return 4
+}
+
+// Address size of the instruction in bytes.
+func (cpu *CPU) addrSize() int {
+	if cpu.insn.EffectiveAddressSize() == dis.OpSizeWord {
+		return 2
+	}
+	return 4
+}
+
+// Size of the stack pointer in bytes, which is set by the B bit of SS.
+func (cpu *CPU) stackSize() int {
+	if cpu.Seg[dis.SS].Attr&SegDB == 0 {
+		return 2
+	}
+	return 4
+}
@@ -0,0 +1,138 @@
+package emu
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	dis "github.com/cyfdecyf/GoEmu/dis-x86"
+)
+
+// Memory of the test CPU.
+type ram []byte
+
+func (r ram) ReadAt(b []byte, off int64) (int, error) {
+	if off < 0 || off+int64(len(b)) > int64(len(r)) {
+		return 0, io.EOF
+	}
+	return copy(b, r[off:]), nil
+}
+
+func (r ram) WriteAt(b []byte, off int64) (int, error) {
+	if off < 0 || off+int64(len(b)) > int64(len(r)) {
+		return 0, io.ErrShortWrite
+	}
+	return copy(r[off:], b), nil
+}
+
+// Create a CPU with code loaded at address 0.
+func newTestCPU(code []byte) (*CPU, ram) {
+	mem := make(ram, 0x2000)
+	copy(mem, code)
+	return NewCPU(mem), mem
+}
+
+func TestException(t *testing.T) {
+	testdata := []struct {
+		code []byte
+		err  string
+		eip  uint32 // EIP after the exception
+	}{
+		{[]byte{0x31, 0xc9, 0xf7, 0xf1}, "#DE", 2},                               // xor %ecx,%ecx; div %ecx
+		{[]byte{0xcd, 0x80}, "interrupt 0x80", 2},                                // int $0x80
+		{[]byte{0xcc}, "#BP", 1},                                                 // int3
+		{[]byte{0x90, 0x0f, 0x0b}, "#UD", 1},                                     // nop; ud2
+		{[]byte{0xf3, 0x0f, 0x1e, 0xfb, 0x0f, 0x1f, 0x00, 0x0f, 0x0b}, "#UD", 7}, // endbr32; nopl (%eax); ud2
+		{[]byte{0xd9, 0xe8}, "emu: unsupported instruction fld1 at 0x0", 0},      // fld1
+	}
+	for _, td := range testdata {
+		cpu, _ := newTestCPU(td.code)
+		err := cpu.Run(10)
+		if err == nil || err.Error() != td.err {
+			t.Errorf("% x: error %v, should be %s", td.code, err, td.err)
+		}
+		if cpu.EIP != td.eip {
+			t.Errorf("% x: eip %#x, should be %#x", td.code, cpu.EIP, td.eip)
+		}
+	}
+}
+
+// Device with a bug, panicking on access.
+type panicDevice struct{}
+
+var errDeviceBug = errors.New("device bug")
+
+func (panicDevice) ReadMMIO(offset uint32, size int) uint64 {
+	panic(errDeviceBug)
+}
+
+func (panicDevice) WriteMMIO(offset uint32, size int, v uint64) {
+	panic(errDeviceBug)
+}
+
+func TestStepError(t *testing.T) {
+	// Bus error is returned as is
+	cpu, _ := newTestCPU([]byte{0xa3, 0x00, 0x30, 0x00, 0x00}) // mov %eax,0x3000
+	if err := cpu.Step(); err != io.ErrShortWrite {
+		t.Errorf("write out of RAM: %v, should be %v", err, io.ErrShortWrite)
+	}
+
+	// Other panics are not recovered
+	m := NewMemory(0x1000)
+	m.AddMMIO(0x2000, 0x10, panicDevice{})
+	copy(m.RAM(), []byte{0xa1, 0x00, 0x20, 0x00, 0x00}) // mov 0x2000,%eax
+	cpu = NewCPU(m)
+	defer func() {
+		if r := recover(); r != errDeviceBug {
+			t.Errorf("panic %v, should be %v", r, errDeviceBug)
+		}
+	}()
+	cpu.Step()
+	t.Error("Step should panic")
+}
+
+func TestRun(t *testing.T) {
+	cpu, _ := newTestCPU([]byte{0xeb, 0xfe}) // jmp .
+	if err := cpu.Run(100); err != ErrStepLimit {
+		t.Errorf("error %v, should be %v", err, ErrStepLimit)
+	}
+
+	cpu, _ = newTestCPU([]byte{0x90, 0xf4}) // nop; hlt
+	if err := cpu.Run(0); err != nil {
+		t.Fatal(err)
+	}
+	if !cpu.Halted || cpu.EIP != 2 {
+		t.Errorf("halted %v at %#x, should halt at 0x2", cpu.Halted, cpu.EIP)
+	}
+	if err := cpu.Step(); err != ErrHalted {
+		t.Errorf("step after hlt: %v, should be %v", err, ErrHalted)
+	}
+}
+
+func TestEFLAGS(t *testing.T) {
+	cpu, _ := newTestCPU(nil)
+	if f := cpu.EFLAGS(); f != 2 {
+		t.Errorf("initial EFLAGS %#x, should be 0x2", f)
+	}
+	cpu.SetEFLAGS(dis.FlagCF | dis.FlagZF)
+	if f := cpu.EFLAGS(); f != dis.FlagCF|dis.FlagZF|2 {
+		t.Errorf("EFLAGS %#x, should be 0x43", f)
+	}
+}
+
+func BenchmarkRun(b *testing.B) {
+	cpu, _ := newTestCPU([]byte{
+		0x31, 0xc0, // xor %eax,%eax
+		0x01, 0xc8, // 1: add %ecx,%eax
+		0x83, 0xf8, 0x10, // cmp $0x10,%eax
+		0x83, 0xd2, 0x00, // adc $0x0,%edx
+		0x49,       // dec %ecx
+		0x75, 0xf5, // jnz 1b
+		0xf4, // hlt
+	})
+	cpu.Regs[dis.Ecx] = uint32(b.N)
+	b.ResetTimer()
+	if err := cpu.Run(0); err != nil {
+		b.Fatal(err)
+	}
+}
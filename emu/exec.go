@@ -0,0 +1,991 @@
+package emu
+
+import (
+	dis "github.com/cyfdecyf/GoEmu/dis-x86"
+)
+
+// Execution of each instruction. The instruction is in cpu.insn, cpu.next
+// is the EIP after the instruction, which is changed by branches. Errors are
+// reported by panic and recovered in Step.
+var execOfInsn map[uint16]func(cpu *CPU)
+
+func init() {
+	execOfInsn = map[uint16]func(cpu *CPU){
+		dis.Insn_Mov:      execMov,
+		dis.Insn_Movzx:    execMov,
+		dis.Insn_Movsx:    execMovsx,
+		dis.Insn_Lea:      execLea,
+		dis.Insn_Xchg:     execXchg,
+		dis.Insn_Bswap:    execBswap,
+		dis.Insn_Cbw:      execCbw,
+		dis.Insn_Cwde:     execCbw,
+		dis.Insn_Cwd:      execCwd,
+		dis.Insn_Cdq:      execCwd,
+		dis.Insn_Xlat:     execXlat,
+		dis.Insn_Nop:      func(cpu *CPU) {},
+		dis.Insn_Pause:    func(cpu *CPU) {},
+		dis.Insn_Endbr32:  func(cpu *CPU) {}, // CET is not emulated
+		dis.Insn_Endbr64:  func(cpu *CPU) {},
+		dis.Insn_Push:     execPush,
+		dis.Insn_Pop:      execPop,
+		dis.Insn_Pusha:    execPusha,
+		dis.Insn_Popa:     execPopa,
+		dis.Insn_Pushf:    execPushf,
+		dis.Insn_Popf:     execPopf,
+		dis.Insn_Lahf:     execLahf,
+		dis.Insn_Sahf:     execSahf,
+		dis.Insn_Enter:    execEnter,
+		dis.Insn_Leave:    execLeave,
+		dis.Insn_Add:      execAdd,
+		dis.Insn_Adc:      execAdc,
+		dis.Insn_Sub:      execSub,
+		dis.Insn_Sbb:      execSbb,
+		dis.Insn_Cmp:      execCmp,
+		dis.Insn_And:      execLogic,
+		dis.Insn_Or:       execLogic,
+		dis.Insn_Xor:      execLogic,
+		dis.Insn_Test:     execLogic,
+		dis.Insn_Not:      execNot,
+		dis.Insn_Neg:      execNeg,
+		dis.Insn_Inc:      execIncDec,
+		dis.Insn_Dec:      execIncDec,
+		dis.Insn_Mul:      execMul,
+		dis.Insn_Imul:     execImul,
+		dis.Insn_Div:      execDiv,
+		dis.Insn_Idiv:     execDiv,
+		dis.Insn_Xadd:     execXadd,
+		dis.Insn_Cmpxchg:  execCmpxchg,
+		dis.Insn_Shl:      execShift,
+		dis.Insn_Sal:      execShift,
+		dis.Insn_Shr:      execShift,
+		dis.Insn_Sar:      execShift,
+		dis.Insn_Rol:      execRotate,
+		dis.Insn_Ror:      execRotate,
+		dis.Insn_Rcl:      execRotate,
+		dis.Insn_Rcr:      execRotate,
+		dis.Insn_Shld:     execShiftDouble,
+		dis.Insn_Shrd:     execShiftDouble,
+		dis.Insn_Bt:       execBitTest,
+		dis.Insn_Bts:      execBitTest,
+		dis.Insn_Btr:      execBitTest,
+		dis.Insn_Btc:      execBitTest,
+		dis.Insn_Bsf:      execBitScan,
+		dis.Insn_Bsr:      execBitScan,
+		dis.Insn_Jmp:      execJmp,
+		dis.Insn_Jmp_far:  execJmpFar,
+		dis.Insn_Jcxz:     execJcxz,
+		dis.Insn_Jecxz:    execJcxz,
+		dis.Insn_Loop:     execLoop,
+		dis.Insn_Loopz:    execLoop,
+		dis.Insn_Loopnz:   execLoop,
+		dis.Insn_Call:     execCall,
+		dis.Insn_Call_far: execCallFar,
+		dis.Insn_Ret:      execRet,
+		dis.Insn_Retf:     execRetf,
+		dis.Insn_Int:      execInt,
+		dis.Insn_Int_3:    execInt,
+		dis.Insn_Into:     execInt,
+		dis.Insn_Ud2:      func(cpu *CPU) { fault(VectorUD, 0) },
+		dis.Insn_Hlt:      execHlt,
+		dis.Insn_Clc:      func(cpu *CPU) { cpu.setFlagBits(flagCF, 0) },
+		dis.Insn_Stc:      func(cpu *CPU) { cpu.setFlagBits(flagCF, flagCF) },
+		dis.Insn_Cmc:      func(cpu *CPU) { cpu.setFlagBits(flagCF, boolFlag(!cpu.flag(flagCF), flagCF)) },
+		dis.Insn_Cld:      func(cpu *CPU) { cpu.eflags &^= flagDF },
+		dis.Insn_Std:      func(cpu *CPU) { cpu.eflags |= flagDF },
+		dis.Insn_Cli:      execCliSti,
+		dis.Insn_Sti:      execCliSti,
+		dis.Insn_Lds:      execLoadFarPtr,
+		dis.Insn_Les:      execLoadFarPtr,
+		dis.Insn_Lfs:      execLoadFarPtr,
+		dis.Insn_Lgs:      execLoadFarPtr,
+		dis.Insn_Lss:      execLoadFarPtr,
+		dis.Insn_Lgdt:     execLoadDescTable,
+		dis.Insn_Lidt:     execLoadDescTable,
+		dis.Insn_Sgdt:     execStoreDescTable,
+		dis.Insn_Sidt:     execStoreDescTable,
+		dis.Insn_Lldt:     execLldt,
+		dis.Insn_Sldt:     execSldt,
+		dis.Insn_Movs:     execMovs,
+		dis.Insn_Cmps:     execCmps,
+		dis.Insn_Scas:     execScas,
+		dis.Insn_Lods:     execLods,
+		dis.Insn_Stos:     execStos,
+	}
+	for _, opid := range []uint16{
+		dis.Insn_Jo, dis.Insn_Jno, dis.Insn_Jb, dis.Insn_Jae,
+		dis.Insn_Jz, dis.Insn_Jnz, dis.Insn_Jbe, dis.Insn_Ja,
+		dis.Insn_Js, dis.Insn_Jns, dis.Insn_Jp, dis.Insn_Jnp,
+		dis.Insn_Jl, dis.Insn_Jge, dis.Insn_Jle, dis.Insn_Jg,
+	} {
+		execOfInsn[opid] = execJcc
+	}
+	for _, opid := range []uint16{
+		dis.Insn_Seto, dis.Insn_Setno, dis.Insn_Setb, dis.Insn_Setae,
+		dis.Insn_Setz, dis.Insn_Setnz, dis.Insn_Setbe, dis.Insn_Seta,
+		dis.Insn_Sets, dis.Insn_Setns, dis.Insn_Setp, dis.Insn_Setnp,
+		dis.Insn_Setl, dis.Insn_Setge, dis.Insn_Setle, dis.Insn_Setg,
+	} {
+		execOfInsn[opid] = execSetcc
+	}
+	for _, opid := range []uint16{
+		dis.Insn_Cmovo, dis.Insn_Cmovno, dis.Insn_Cmovb, dis.Insn_Cmovae,
+		dis.Insn_Cmovz, dis.Insn_Cmovnz, dis.Insn_Cmovbe, dis.Insn_Cmova,
+		dis.Insn_Cmovs, dis.Insn_Cmovns, dis.Insn_Cmovp, dis.Insn_Cmovnp,
+		dis.Insn_Cmovl, dis.Insn_Cmovge, dis.Insn_Cmovle, dis.Insn_Cmovg,
+	} {
+		execOfInsn[opid] = execCmovcc
+	}
+}
+
+func (cpu *CPU) operand(i int) dis.Operand {
+	return cpu.insn.Operands[i]
+}
+
+// Data transfer
+
+func execMov(cpu *CPU) {
+	cpu.write(cpu.operand(0), cpu.read(cpu.operand(1)))
+}
+
+func execMovsx(cpu *CPU) {
+	src := cpu.operand(1)
+	cpu.write(cpu.operand(0), signExtend(cpu.read(src), operandSize(src)))
+}
+
+func execLea(cpu *CPU) {
+	m, ok := cpu.operand(1).(dis.Mem)
+	if !ok {
+		fault(VectorUD, 0)
+	}
+	cpu.write(cpu.operand(0), cpu.effectiveAddr(m))
+}
+
+func execXchg(cpu *CPU) {
+	a, b := cpu.read(cpu.operand(0)), cpu.read(cpu.operand(1))
+	cpu.write(cpu.operand(0), b)
+	cpu.write(cpu.operand(1), a)
+}
+
+func execBswap(cpu *CPU) {
+	v := cpu.read(cpu.operand(0))
+	cpu.write(cpu.operand(0), v>>24|v>>8&0xff00|v<<8&0xff0000|v<<24)
+}
+
+// cbw and cwde
+func execCbw(cpu *CPU) {
+	size := cpu.opSize()
+	cpu.setReg(gpReg(dis.Eax, size), signExtend(cpu.Regs[dis.Eax], size/2))
+}
+
+// cwd and cdq
+func execCwd(cpu *CPU) {
+	size := cpu.opSize()
+	var v uint32
+	if cpu.Regs[dis.Eax]&signBit(size) != 0 {
+		v = 0xffffffff
+	}
+	cpu.setReg(gpReg(dis.Edx, size), v)
+}
+
+func execXlat(cpu *CPU) {
+	cpu.setReg(gpReg(dis.Eax, 1), cpu.read(cpu.operand(0)))
+}
+
+func execCmovcc(cpu *CPU) {
+	cc, _ := cpu.insn.Condition()
+	// The source is read even if the condition is false
+	v := cpu.read(cpu.operand(1))
+	if cpu.condition(cc) {
+		cpu.write(cpu.operand(0), v)
+	}
+}
+
+func execSetcc(cpu *CPU) {
+	cc, _ := cpu.insn.Condition()
+	var v uint32
+	if cpu.condition(cc) {
+		v = 1
+	}
+	cpu.write(cpu.operand(0), v)
+}
+
+// Stack
+
+func execPush(cpu *CPU) {
+	cpu.push(cpu.read(cpu.operand(0)), cpu.opSize())
+}
+
+func execPop(cpu *CPU) {
+	// Memory operand using esp is calculated after esp is incremented
+	cpu.write(cpu.operand(0), cpu.pop(cpu.opSize()))
+}
+
+func execPusha(cpu *CPU) {
+	size := cpu.opSize()
+	sp := cpu.Regs[dis.Esp]
+	for i := dis.Eax; i <= dis.Edi; i++ {
+		v := cpu.Regs[i]
+		if i == dis.Esp {
+			v = sp
+		}
+		cpu.push(v, size)
+	}
+}
+
+func execPopa(cpu *CPU) {
+	size := cpu.opSize()
+	for i := int(dis.Edi); i >= int(dis.Eax); i-- {
+		v := cpu.pop(size)
+		if byte(i) != dis.Esp {
+			cpu.setReg(gpReg(byte(i), size), v)
+		}
+	}
+}
+
+// EFLAGS bits which can be changed by popf.
+const popfMask = flagsStatus | uint32(dis.FlagTF|dis.FlagIF|dis.FlagDF|dis.FlagIOPL|
+	dis.FlagNT|dis.FlagAC|dis.FlagID)
+
+func execPushf(cpu *CPU) {
+	// VM and RF are cleared in the pushed value
+	cpu.push(uint32(cpu.EFLAGS()&^(dis.FlagVM|dis.FlagRF)), cpu.opSize())
+}
+
+// IOPL is only changed at CPL 0, and IF only if CPL is not above IOPL.
+// Other bits are changed without fault.
+func execPopf(cpu *CPU) {
+	size := cpu.opSize()
+	bits := popfMask & mask(size)
+	if cpu.CPL > 0 {
+		bits &^= uint32(dis.FlagIOPL)
+	}
+	if cpu.CPL > cpu.iopl() {
+		bits &^= uint32(dis.FlagIF)
+	}
+	cpu.setFlagBits(bits, cpu.pop(size))
+}
+
+// cli and sti fault if CPL is above IOPL.
+func execCliSti(cpu *CPU) {
+	if cpu.CPL > cpu.iopl() {
+		fault(VectorGP, 0)
+	}
+	if cpu.insn.OpId == dis.Insn_Cli {
+		cpu.eflags &^= uint32(dis.FlagIF)
+	} else {
+		cpu.eflags |= uint32(dis.FlagIF)
+	}
+}
+
+func execLahf(cpu *CPU) {
+	cpu.setReg(dis.Reg{Class: dis.RegGP, Num: dis.Eax, Size: 1, High: true},
+		uint32(cpu.EFLAGS())&(flagsStatus&^flagOF)|eflagsReserved)
+}
+
+func execSahf(cpu *CPU) {
+	cpu.setFlagBits(flagsStatus&^flagOF, cpu.Regs[dis.Eax]>>8)
+}
+
+func execEnter(cpu *CPU) {
+	size := cpu.opSize()
+	alloc := cpu.read(cpu.operand(0))
+	level := cpu.read(cpu.operand(1)) & 0x1f
+	bp := gpReg(dis.Ebp, cpu.stackSize())
+
+	cpu.push(cpu.Regs[dis.Ebp], size)
+	frame := cpu.reg(cpu.sp())
+	if level > 0 {
+		// Copy the frame pointers of the enclosing procedures
+		fp := cpu.reg(bp)
+		for i := uint32(1); i < level; i++ {
+			fp -= uint32(size)
+			cpu.push(cpu.readMem(dis.SS, fp&mask(cpu.stackSize()), size), size)
+		}
+		cpu.push(frame, size)
+	}
+	cpu.setReg(gpReg(dis.Ebp, size), frame)
+	cpu.setReg(cpu.sp(), cpu.reg(cpu.sp())-alloc)
+}
+
+func execLeave(cpu *CPU) {
+	cpu.setReg(cpu.sp(), cpu.reg(gpReg(dis.Ebp, cpu.stackSize())))
+	cpu.setReg(gpReg(dis.Ebp, cpu.opSize()), cpu.pop(cpu.opSize()))
+}
+
+// Arithmetic and logic
+
+// Execute binary arithmetic operation op on the first two operands. The
+// result is stored in the first operand if store is set.
+func (cpu *CPU) arith(op flagOp, store bool) {
+	dst := cpu.operand(0)
+	size := operandSize(dst)
+	a, b := cpu.read(dst), cpu.read(cpu.operand(1))&mask(size)
+	var r uint32
+	switch op {
+	case opAdd:
+		r = a + b
+	case opAdc:
+		r = a + b + 1
+	case opSub:
+		r = a - b
+	case opSbb:
+		r = a - b - 1
+	}
+	r &= mask(size)
+	if store {
+		cpu.write(dst, r)
+	}
+	cpu.setFlags(op, size, a, b, r)
+}
+
+func execAdd(cpu *CPU) {
+	cpu.arith(opAdd, true)
+}
+
+func execAdc(cpu *CPU) {
+	if cpu.flag(flagCF) {
+		cpu.arith(opAdc, true)
+	} else {
+		cpu.arith(opAdd, true)
+	}
+}
+
+func execSub(cpu *CPU) {
+	cpu.arith(opSub, true)
+}
+
+func execSbb(cpu *CPU) {
+	if cpu.flag(flagCF) {
+		cpu.arith(opSbb, true)
+	} else {
+		cpu.arith(opSub, true)
+	}
+}
+
+func execCmp(cpu *CPU) {
+	cpu.arith(opSub, false)
+}
+
+// and, or, xor and test
+func execLogic(cpu *CPU) {
+	dst := cpu.operand(0)
+	size := operandSize(dst)
+	a, b := cpu.read(dst), cpu.read(cpu.operand(1))
+	var r uint32
+	switch cpu.insn.OpId {
+	case dis.Insn_And, dis.Insn_Test:
+		r = a & b
+	case dis.Insn_Or:
+		r = a | b
+	case dis.Insn_Xor:
+		r = a ^ b
+	}
+	r &= mask(size)
+	if cpu.insn.OpId != dis.Insn_Test {
+		cpu.write(dst, r)
+	}
+	cpu.setFlags(opLogic, size, a, b, r)
+}
+
+func execNot(cpu *CPU) {
+	dst := cpu.operand(0)
+	cpu.write(dst, ^cpu.read(dst))
+}
+
+func execNeg(cpu *CPU) {
+	dst := cpu.operand(0)
+	size := operandSize(dst)
+	a := cpu.read(dst)
+	r := -a & mask(size)
+	cpu.write(dst, r)
+	cpu.setFlags(opSub, size, 0, a, r)
+}
+
+func execIncDec(cpu *CPU) {
+	dst := cpu.operand(0)
+	size := operandSize(dst)
+	a := cpu.read(dst)
+	op, r := opInc, a+1
+	if cpu.insn.OpId == dis.Insn_Dec {
+		op, r = opDec, a-1
+	}
+	r &= mask(size)
+	cpu.write(dst, r)
+	cpu.setFlags(op, size, a, 1, r)
+}
+
+// Store the double sized result of mul, imul and div in the accumulator and
+// dx. For byte operand, ax holds the whole result.
+func (cpu *CPU) setAccPair(size int, lo, hi uint32) {
+	if size == 1 {
+		cpu.setReg(gpReg(dis.Eax, 2), hi<<8|lo&0xff)
+		return
+	}
+	cpu.setReg(gpReg(dis.Eax, size), lo)
+	cpu.setReg(gpReg(dis.Edx, size), hi)
+}
+
+func execMul(cpu *CPU) {
+	src := cpu.operand(0)
+	size := operandSize(src)
+	p := uint64(cpu.Regs[dis.Eax]&mask(size)) * uint64(cpu.read(src))
+	lo, hi := uint32(p)&mask(size), uint32(p>>(8*uint(size)))&mask(size)
+	cpu.setAccPair(size, lo, hi)
+	cpu.setFlags(opMul, size, 0, boolFlag(hi != 0, 1), lo)
+}
+
+func execImul(cpu *CPU) {
+	ops := cpu.insn.Operands
+	size := operandSize(ops[0])
+	if len(ops) == 1 {
+		a := int64(int32(signExtend(cpu.Regs[dis.Eax], size)))
+		p := a * int64(int32(signExtend(cpu.read(ops[0]), size)))
+		lo, hi := uint32(p)&mask(size), uint32(p>>(8*uint(size)))&mask(size)
+		cpu.setAccPair(size, lo, hi)
+		cpu.setFlags(opMul, size, 0, boolFlag(int64(int32(signExtend(lo, size))) != p, 1), lo)
+		return
+	}
+	// Two and three operand forms store the truncated product in the first
+	// operand.
+	a, b := ops[0], ops[1]
+	if len(ops) == 3 {
+		a, b = ops[1], ops[2]
+	}
+	p := int64(int32(signExtend(cpu.read(a), size))) * int64(int32(signExtend(cpu.read(b), size)))
+	r := uint32(p) & mask(size)
+	cpu.write(ops[0], r)
+	cpu.setFlags(opMul, size, 0, boolFlag(int64(int32(signExtend(r, size))) != p, 1), r)
+}
+
+// div and idiv
+func execDiv(cpu *CPU) {
+	src := cpu.operand(0)
+	size := operandSize(src)
+	divisor := cpu.read(src)
+	if divisor == 0 {
+		fault(VectorDE, 0)
+	}
+	var dividend uint64
+	if size == 1 {
+		dividend = uint64(cpu.Regs[dis.Eax] & 0xffff)
+	} else {
+		dividend = uint64(cpu.Regs[dis.Edx]&mask(size))<<(8*uint(size)) | uint64(cpu.Regs[dis.Eax]&mask(size))
+	}
+
+	var q, r uint64
+	if cpu.insn.OpId == dis.Insn_Div {
+		q, r = dividend/uint64(divisor), dividend%uint64(divisor)
+		if q > uint64(mask(size)) {
+			fault(VectorDE, 0)
+		}
+	} else {
+		// Sign-extend the dividend from twice the operand size
+		shift := 64 - 16*uint(size)
+		n := int64(dividend<<shift) >> shift
+		d := int64(int32(signExtend(divisor, size)))
+		sq, sr := n/d, n%d
+		min := -int64(signBit(size))
+		if sq < min || sq > -min-1 {
+			fault(VectorDE, 0)
+		}
+		q, r = uint64(sq), uint64(sr)
+	}
+	cpu.setAccPair(size, uint32(q)&mask(size), uint32(r)&mask(size))
+}
+
+func execXadd(cpu *CPU) {
+	dst, src := cpu.operand(0), cpu.operand(1)
+	size := operandSize(dst)
+	a, b := cpu.read(dst), cpu.read(src)
+	r := (a + b) & mask(size)
+	cpu.write(src, a)
+	cpu.write(dst, r)
+	cpu.setFlags(opAdd, size, a, b, r)
+}
+
+func execCmpxchg(cpu *CPU) {
+	dst := cpu.operand(0)
+	size := operandSize(dst)
+	acc := gpReg(dis.Eax, size)
+	a, v := cpu.read(acc), cpu.read(dst)
+	cpu.setFlags(opSub, size, a, v, (a-v)&mask(size))
+	if a == v {
+		cpu.write(dst, cpu.read(cpu.operand(1)))
+	} else {
+		// The destination is always written, with its original value
+		cpu.write(dst, v)
+		cpu.write(acc, v)
+	}
+}
+
+// Shift and rotate
+
+// Shift count, masked to 5 bits.
+func (cpu *CPU) shiftCount(op dis.Operand) uint32 {
+	return cpu.read(op) & 0x1f
+}
+
+// shl, shr, sal and sar
+func execShift(cpu *CPU) {
+	dst := cpu.operand(0)
+	size := operandSize(dst)
+	count := cpu.shiftCount(cpu.operand(1))
+	if count == 0 {
+		return
+	}
+	a := cpu.read(dst)
+	var op flagOp
+	var r uint32
+	switch cpu.insn.OpId {
+	case dis.Insn_Shl, dis.Insn_Sal:
+		op, r = opShl, a<<count
+	case dis.Insn_Shr:
+		op, r = opShr, a>>count
+	case dis.Insn_Sar:
+		op, r = opSar, uint32(int32(signExtend(a, size))>>count)
+	}
+	r &= mask(size)
+	cpu.write(dst, r)
+	cpu.setFlags(op, size, a, count, r)
+}
+
+// rol, ror, rcl and rcr only change CF and OF.
+func execRotate(cpu *CPU) {
+	dst := cpu.operand(0)
+	size := operandSize(dst)
+	width := uint32(8 * size)
+	sign := signBit(size)
+	count := cpu.shiftCount(cpu.operand(1))
+	if count == 0 {
+		return
+	}
+	a := cpu.read(dst)
+	r := a
+	var cf bool
+	switch cpu.insn.OpId {
+	case dis.Insn_Rol:
+		n := count % width
+		r = (a<<n | a>>(width-n)) & mask(size)
+		cf = r&1 != 0
+	case dis.Insn_Ror:
+		n := count % width
+		r = (a>>n | a<<(width-n)) & mask(size)
+		cf = r&sign != 0
+	case dis.Insn_Rcl:
+		cf = cpu.flag(flagCF)
+		for i := uint32(0); i < count%(width+1); i++ {
+			out := r&sign != 0
+			r = (r<<1 | uint32(boolFlag(cf, 1))) & mask(size)
+			cf = out
+		}
+	case dis.Insn_Rcr:
+		cf = cpu.flag(flagCF)
+		for i := uint32(0); i < count%(width+1); i++ {
+			out := r&1 != 0
+			r = r>>1 | boolFlag(cf, sign)
+			cf = out
+		}
+	}
+	var of bool
+	switch cpu.insn.OpId {
+	case dis.Insn_Rol, dis.Insn_Rcl:
+		of = (r&sign != 0) != cf
+	default:
+		// XOR of the two most significant bits of the result
+		of = (r&sign != 0) != (r&(sign>>1) != 0)
+	}
+	cpu.write(dst, r)
+	cpu.setFlagBits(flagCF|flagOF, boolFlag(cf, flagCF)|boolFlag(of, flagOF))
+}
+
+// shld and shrd
+func execShiftDouble(cpu *CPU) {
+	dst := cpu.operand(0)
+	size := operandSize(dst)
+	width := uint(8 * size)
+	count := uint(cpu.shiftCount(cpu.operand(2)))
+	if count == 0 {
+		return
+	}
+	a, b := uint64(cpu.read(dst)), uint64(cpu.read(cpu.operand(1)))
+	var r uint32
+	var cf bool
+	if cpu.insn.OpId == dis.Insn_Shld {
+		v := a<<width | b
+		r = uint32(v<<count>>width) & mask(size)
+		cf = v>>(2*width-count)&1 != 0
+	} else {
+		v := b<<width | a
+		r = uint32(v>>count) & mask(size)
+		cf = v>>(count-1)&1 != 0
+	}
+	cpu.write(dst, r)
+	of := (r^uint32(a))&signBit(size) != 0
+	f := resultFlags(r, size) | boolFlag(cf, flagCF) | boolFlag(of, flagOF)
+	cpu.setFlagBits(flagsStatus, f)
+}
+
+// Bit operation
+
+// bt, bts, btr and btc
+func execBitTest(cpu *CPU) {
+	base, off := cpu.operand(0), cpu.operand(1)
+	size := operandSize(base)
+	width := uint32(8 * size)
+	bit := cpu.read(off)
+	// Register bit offset selects memory outside of the operand
+	if m, ok := base.(dis.Mem); ok {
+		if _, ok := off.(dis.Reg); ok {
+			n := int32(signExtend(bit, operandSize(off)))
+			m.Disp += int64(n>>uint(3+size/2)) * int64(size)
+			base = m
+		}
+	}
+	bit %= width
+
+	v := cpu.read(base)
+	cf := v>>bit&1 != 0
+	switch cpu.insn.OpId {
+	case dis.Insn_Bts:
+		cpu.write(base, v|1<<bit)
+	case dis.Insn_Btr:
+		cpu.write(base, v&^(1<<bit))
+	case dis.Insn_Btc:
+		cpu.write(base, v^1<<bit)
+	}
+	cpu.setFlagBits(flagCF, boolFlag(cf, flagCF))
+}
+
+// bsf and bsr
+func execBitScan(cpu *CPU) {
+	v := cpu.read(cpu.operand(1))
+	if v == 0 {
+		// Destination is undefined, left unchanged
+		cpu.setFlagBits(flagZF, flagZF)
+		return
+	}
+	cpu.setFlagBits(flagZF, 0)
+	var n uint32
+	if cpu.insn.OpId == dis.Insn_Bsf {
+		for v&(1<<n) == 0 {
+			n++
+		}
+	} else {
+		for n = 31; v&(1<<n) == 0; n-- {
+		}
+	}
+	cpu.write(cpu.operand(0), n)
+}
+
+// Segmentation
+
+var segOfInsn = map[uint16]byte{
+	dis.Insn_Lds: dis.DS,
+	dis.Insn_Les: dis.ES,
+	dis.Insn_Lfs: dis.FS,
+	dis.Insn_Lgs: dis.GS,
+	dis.Insn_Lss: dis.SS,
+}
+
+// lds, les, lfs, lgs and lss
+func execLoadFarPtr(cpu *CPU) {
+	m, ok := cpu.operand(1).(dis.Mem)
+	if !ok {
+		fault(VectorUD, 0)
+	}
+	sel, off := cpu.farPointer(m)
+	cpu.loadSegment(segOfInsn[cpu.insn.OpId], sel)
+	cpu.write(cpu.operand(0), off)
+}
+
+// Memory operand of lgdt, lidt, sgdt and sidt, which is 16-bit limit
+// followed by 32-bit base.
+func (cpu *CPU) descTableOperand() (m dis.Mem, table *DescTable) {
+	m, ok := cpu.operand(0).(dis.Mem)
+	if !ok {
+		fault(VectorUD, 0)
+	}
+	switch cpu.insn.OpId {
+	case dis.Insn_Lgdt, dis.Insn_Sgdt:
+		table = &cpu.GDTR
+	default:
+		table = &cpu.IDTR
+	}
+	return m, table
+}
+
+// Base is 24-bit with 16-bit operand size.
+func (cpu *CPU) descTableBaseMask() uint32 {
+	if cpu.opSize() == 2 {
+		return 0xffffff
+	}
+	return 0xffffffff
+}
+
+// lgdt and lidt
+func execLoadDescTable(cpu *CPU) {
+	cpu.checkPrivileged()
+	m, table := cpu.descTableOperand()
+	addr := cpu.effectiveAddr(m)
+	limit := cpu.readMem(m.Segment, addr, 2)
+	base := cpu.readMem(m.Segment, (addr+2)&mask(cpu.addrSize()), 4)
+	*table = DescTable{Base: base & cpu.descTableBaseMask(), Limit: uint16(limit)}
+}
+
+// sgdt and sidt
+func execStoreDescTable(cpu *CPU) {
+	m, table := cpu.descTableOperand()
+	addr := cpu.effectiveAddr(m)
+	cpu.writeMem(m.Segment, addr, 2, uint32(table.Limit))
+	cpu.writeMem(m.Segment, (addr+2)&mask(cpu.addrSize()), 4, table.Base&cpu.descTableBaseMask())
+}
+
+func execLldt(cpu *CPU) {
+	if !cpu.protected() {
+		fault(VectorUD, 0)
+	}
+	cpu.checkPrivileged()
+	cpu.loadLDT(uint16(cpu.read(cpu.operand(0))))
+}
+
+func execSldt(cpu *CPU) {
+	if !cpu.protected() {
+		fault(VectorUD, 0)
+	}
+	op := cpu.operand(0)
+	if m, ok := op.(dis.Mem); ok {
+		// Only the selector is stored to memory
+		m.Size = 2
+		op = m
+	}
+	cpu.write(op, uint32(cpu.LDTR.Selector))
+}
+
+// Control transfer
+
+// Set EIP of the next instruction, truncated to the operand size.
+func (cpu *CPU) jump(target uint32) {
+	cpu.next = target & mask(cpu.opSize())
+}
+
+func execJmp(cpu *CPU) {
+	cpu.jump(cpu.read(cpu.operand(0)))
+}
+
+func execJcc(cpu *CPU) {
+	cc, _ := cpu.insn.Condition()
+	if cpu.condition(cc) {
+		cpu.jump(cpu.read(cpu.operand(0)))
+	}
+}
+
+// jcxz and jecxz use the counter of the address size.
+func execJcxz(cpu *CPU) {
+	if cpu.reg(gpReg(dis.Ecx, cpu.addrSize())) == 0 {
+		cpu.jump(cpu.read(cpu.operand(0)))
+	}
+}
+
+// loop, loopz and loopnz
+func execLoop(cpu *CPU) {
+	counter := gpReg(dis.Ecx, cpu.addrSize())
+	n := cpu.reg(counter) - 1
+	cpu.setReg(counter, n)
+	taken := n&mask(counter.Size) != 0
+	switch cpu.insn.OpId {
+	case dis.Insn_Loopz:
+		taken = taken && cpu.flag(flagZF)
+	case dis.Insn_Loopnz:
+		taken = taken && !cpu.flag(flagZF)
+	}
+	if taken {
+		cpu.jump(cpu.read(cpu.operand(0)))
+	}
+}
+
+func execCall(cpu *CPU) {
+	// Target is read before esp is changed
+	target := cpu.read(cpu.operand(0))
+	cpu.push(cpu.next, cpu.opSize())
+	cpu.jump(target)
+}
+
+func execRet(cpu *CPU) {
+	size := cpu.opSize()
+	cpu.jump(cpu.pop(size))
+	if len(cpu.insn.Operands) > 0 {
+		sp := cpu.sp()
+		cpu.setReg(sp, cpu.reg(sp)+cpu.read(cpu.operand(0)))
+	}
+}
+
+// Selector and offset of far pointer operand, which is either immediate or
+// in memory with the offset followed by the selector.
+func (cpu *CPU) farPointer(op dis.Operand) (uint16, uint32) {
+	switch op := op.(type) {
+	case dis.FarPtr:
+		return op.Segment, op.Offset
+	case dis.Mem:
+		size := cpu.opSize()
+		addr := cpu.effectiveAddr(op)
+		off := cpu.readMem(op.Segment, addr, size)
+		sel := cpu.readMem(op.Segment, (addr+uint32(size))&mask(cpu.addrSize()), 2)
+		return uint16(sel), off
+	}
+	fault(VectorUD, 0)
+	return 0, 0
+}
+
+func execJmpFar(cpu *CPU) {
+	sel, off := cpu.farPointer(cpu.operand(0))
+	cpu.setCS(cpu.codeSegment(sel, off, false))
+	cpu.jump(off)
+}
+
+func execCallFar(cpu *CPU) {
+	sel, off := cpu.farPointer(cpu.operand(0))
+	cs := cpu.codeSegment(sel, off, false)
+	size := cpu.opSize()
+	cpu.push(uint32(cpu.Seg[dis.CS].Selector), size)
+	cpu.push(cpu.next, size)
+	cpu.setCS(cs)
+	cpu.jump(off)
+}
+
+// Far ret to a less privileged level also pops SS:ESP. Data segment
+// registers not accessible at the new level are loaded with null selector.
+func execRetf(cpu *CPU) {
+	size := cpu.opSize()
+	off := cpu.pop(size)
+	sel := uint16(cpu.pop(size))
+	var imm uint32
+	if len(cpu.insn.Operands) > 0 {
+		imm = cpu.read(cpu.operand(0))
+	}
+	sp := cpu.sp()
+	cpu.setReg(sp, cpu.reg(sp)+imm)
+	cs := cpu.codeSegment(sel, off, true)
+	if rpl := sel & 3; rpl > cpu.CPL {
+		esp := cpu.pop(size)
+		ss := cpu.dataSegment(dis.SS, uint16(cpu.pop(size)), rpl)
+		cpu.Seg[dis.SS] = ss
+		cpu.setReg(cpu.sp(), esp+imm)
+		cpu.setCS(cs)
+		cpu.checkDataSegments()
+	} else {
+		cpu.setCS(cs)
+	}
+	cpu.jump(off)
+}
+
+func execHlt(cpu *CPU) {
+	cpu.checkPrivileged()
+	cpu.Halted = true
+}
+
+// int, int3 and into are traps, returned to the caller of Step with EIP
+// pointing to the next instruction.
+func execInt(cpu *CPU) {
+	var vector byte
+	switch cpu.insn.OpId {
+	case dis.Insn_Int:
+		vector = byte(cpu.read(cpu.operand(0)))
+	case dis.Insn_Int_3:
+		vector = VectorBP
+	case dis.Insn_Into:
+		if !cpu.flag(flagOF) {
+			return
+		}
+		vector = VectorOF
+	}
+	cpu.EIP = cpu.next
+	fault(vector, 0)
+}
+
+// String
+
+// Memory operand of string instruction addressed by esi or edi.
+func (cpu *CPU) stringOperand(reg byte) dis.Mem {
+	for _, op := range cpu.insn.Operands {
+		if m, ok := op.(dis.Mem); ok && m.Base.Num == reg {
+			return m
+		}
+	}
+	panic(cpu.unsupported())
+}
+
+// Advance esi or edi by size bytes in the direction given by DF.
+func (cpu *CPU) advance(reg byte, size int) {
+	r := gpReg(reg, cpu.addrSize())
+	if cpu.flag(flagDF) {
+		cpu.setReg(r, cpu.reg(r)-uint32(size))
+	} else {
+		cpu.setReg(r, cpu.reg(r)+uint32(size))
+	}
+}
+
+// Execute one iteration of string instruction, or repeat it by the rep
+// prefix. cmps and scas also stop by ZF for repz and repnz.
+func (cpu *CPU) repeat(once func()) {
+	prefix := cpu.insn.Prefix & (dis.PrefixREPZ | dis.PrefixREPNZ)
+	if prefix == 0 {
+		once()
+		return
+	}
+	counter := gpReg(dis.Ecx, cpu.addrSize())
+	testZF := cpu.insn.OpId == dis.Insn_Cmps || cpu.insn.OpId == dis.Insn_Scas
+	for cpu.reg(counter) != 0 {
+		once()
+		cpu.setReg(counter, cpu.reg(counter)-1)
+		cpu.savedRegs = cpu.Regs
+		if testZF && cpu.flag(flagZF) != (prefix == dis.PrefixREPZ) {
+			break
+		}
+	}
+}
+
+func execMovs(cpu *CPU) {
+	dst, src := cpu.stringOperand(dis.Edi), cpu.stringOperand(dis.Esi)
+	cpu.repeat(func() {
+		cpu.write(dst, cpu.read(src))
+		cpu.advance(dis.Esi, src.Size)
+		cpu.advance(dis.Edi, dst.Size)
+	})
+}
+
+func execCmps(cpu *CPU) {
+	src1, src2 := cpu.stringOperand(dis.Esi), cpu.stringOperand(dis.Edi)
+	size := src1.Size
+	cpu.repeat(func() {
+		a, b := cpu.read(src1), cpu.read(src2)
+		cpu.setFlags(opSub, size, a, b, (a-b)&mask(size))
+		cpu.advance(dis.Esi, size)
+		cpu.advance(dis.Edi, size)
+	})
+}
+
+func execScas(cpu *CPU) {
+	src := cpu.stringOperand(dis.Edi)
+	size := src.Size
+	cpu.repeat(func() {
+		a, b := cpu.Regs[dis.Eax]&mask(size), cpu.read(src)
+		cpu.setFlags(opSub, size, a, b, (a-b)&mask(size))
+		cpu.advance(dis.Edi, size)
+	})
+}
+
+func execLods(cpu *CPU) {
+	src := cpu.stringOperand(dis.Esi)
+	cpu.repeat(func() {
+		cpu.setReg(gpReg(dis.Eax, src.Size), cpu.read(src))
+		cpu.advance(dis.Esi, src.Size)
+	})
+}
+
+func execStos(cpu *CPU) {
+	dst := cpu.stringOperand(dis.Edi)
+	cpu.repeat(func() {
+		cpu.write(dst, cpu.Regs[dis.Eax])
+		cpu.advance(dis.Edi, dst.Size)
+	})
+}
@@ -0,0 +1,295 @@
+package emu
+
+import (
+	"testing"
+
+	dis "github.com/cyfdecyf/GoEmu/dis-x86"
+)
+
+type regValue struct {
+	reg byte
+	val uint32
+}
+
+type memValue struct {
+	addr uint32
+	data string
+}
+
+func TestExec(t *testing.T) {
+	testdata := []struct {
+		name string
+		code []byte
+		mem  []memValue // Initial memory
+		regs []regValue // Initial registers
+		// Expected result
+		eregs []regValue
+		emem  []memValue
+		flags dis.Flags // Expected status flags and DF
+	}{
+		{
+			name: "sum",
+			code: []byte{
+				0x31, 0xc0, // xor %eax,%eax
+				0x01, 0xc8, // 1: add %ecx,%eax
+				0xe2, 0xfc, // loop 1b
+				0xf4, // hlt
+			},
+			regs:  []regValue{{dis.Ecx, 10}},
+			eregs: []regValue{{dis.Eax, 55}, {dis.Ecx, 0}},
+		},
+		{
+			name: "call",
+			code: []byte{
+				0xbc, 0x00, 0x10, 0x00, 0x00, // mov $0x1000,%esp
+				0x6a, 0x03, // push $0x3
+				0x6a, 0x04, // push $0x4
+				0xe8, 0x04, 0x00, 0x00, 0x00, // call f
+				0x83, 0xc4, 0x08, // add $0x8,%esp
+				0xf4,       // hlt
+				0x55,       // f: push %ebp
+				0x89, 0xe5, // mov %esp,%ebp
+				0x8b, 0x45, 0x08, // mov 0x8(%ebp),%eax
+				0x0f, 0xaf, 0x45, 0x0c, // imul 0xc(%ebp),%eax
+				0x5d, // pop %ebp
+				0xc3, // ret
+			},
+			regs:  []regValue{{dis.Ebp, 0x1234}},
+			eregs: []regValue{{dis.Eax, 12}, {dis.Esp, 0x1000}, {dis.Ebp, 0x1234}},
+			emem:  []memValue{{0xff0, "\x34\x12\x00\x00\x0e\x00\x00\x00\x04\x00\x00\x00\x03"}},
+			flags: dis.FlagPF | dis.FlagAF,
+		},
+		{
+			name: "rep movsb",
+			code: []byte{
+				0xbe, 0x00, 0x02, 0x00, 0x00, // mov $0x200,%esi
+				0xbf, 0x00, 0x03, 0x00, 0x00, // mov $0x300,%edi
+				0xb9, 0x05, 0x00, 0x00, 0x00, // mov $0x5,%ecx
+				0xfc,       // cld
+				0xf3, 0xa4, // rep movsb
+				0xf4, // hlt
+			},
+			mem:   []memValue{{0x200, "hello world"}},
+			eregs: []regValue{{dis.Esi, 0x205}, {dis.Edi, 0x305}, {dis.Ecx, 0}},
+			emem:  []memValue{{0x300, "hello\x00"}},
+		},
+		{
+			name: "strlen",
+			code: []byte{
+				0xbf, 0x00, 0x02, 0x00, 0x00, // mov $0x200,%edi
+				0x31, 0xc0, // xor %eax,%eax
+				0xb9, 0xff, 0xff, 0xff, 0xff, // mov $0xffffffff,%ecx
+				0xf2, 0xae, // repnz scas %es:(%edi),%al
+				0xf7, 0xd1, // not %ecx
+				0x49, // dec %ecx
+				0xf4, // hlt
+			},
+			mem:   []memValue{{0x200, "hello world\x00"}},
+			eregs: []regValue{{dis.Ecx, 11}, {dis.Edi, 0x20c}},
+		},
+		{
+			name: "std lods stos",
+			code: []byte{
+				0xbe, 0x04, 0x02, 0x00, 0x00, // mov $0x204,%esi
+				0xbf, 0x00, 0x03, 0x00, 0x00, // mov $0x300,%edi
+				0xfd, // std
+				0xad, // lods %ds:(%esi),%eax
+				0xab, // stos %eax,%es:(%edi)
+				0xf4, // hlt
+			},
+			mem:   []memValue{{0x204, "\x78\x56\x34\x12"}},
+			eregs: []regValue{{dis.Eax, 0x12345678}, {dis.Esi, 0x200}, {dis.Edi, 0x2fc}},
+			emem:  []memValue{{0x300, "\x78\x56\x34\x12"}},
+			flags: dis.FlagDF,
+		},
+		{
+			name: "pusha popa",
+			code: []byte{
+				0xbc, 0x00, 0x10, 0x00, 0x00, // mov $0x1000,%esp
+				0xb8, 0x01, 0x00, 0x00, 0x00, // mov $0x1,%eax
+				0xbb, 0x02, 0x00, 0x00, 0x00, // mov $0x2,%ebx
+				0x60,       // pusha
+				0x31, 0xc0, // xor %eax,%eax
+				0x31, 0xdb, // xor %ebx,%ebx
+				0x61,                   // popa
+				0x9c,                   // pushf
+				0x5a,                   // pop %edx
+				0xc8, 0x10, 0x00, 0x00, // enter $0x10,$0x0
+				0xc9, // leave
+				0xf4, // hlt
+			},
+			eregs: []regValue{{dis.Eax, 1}, {dis.Ebx, 2}, {dis.Edx, 0x46}, {dis.Esp, 0x1000}},
+			emem:  []memValue{{0xfec, "\x00\x10\x00\x00"}},
+			flags: dis.FlagPF | dis.FlagZF,
+		},
+		{
+			name: "memory operand",
+			code: []byte{
+				0xc7, 0x05, 0x00, 0x02, 0x00, 0x00, 0x44, 0x33, 0x22, 0x11, // movl $0x11223344,0x200
+				0x83, 0x05, 0x00, 0x02, 0x00, 0x00, 0x01, // addl $0x1,0x200
+				0x0f, 0xb6, 0x05, 0x01, 0x02, 0x00, 0x00, // movzbl 0x201,%eax
+				0x0f, 0xbe, 0x1d, 0x03, 0x02, 0x00, 0x00, // movsbl 0x203,%ebx
+				0x87, 0x05, 0x00, 0x02, 0x00, 0x00, // xchg %eax,0x200
+				0xf4, // hlt
+			},
+			eregs: []regValue{{dis.Eax, 0x11223345}, {dis.Ebx, 0x11}},
+			emem:  []memValue{{0x200, "\x33\x00\x00\x00"}},
+		},
+		{
+			name: "jcc",
+			code: []byte{
+				0xb8, 0x05, 0x00, 0x00, 0x00, // mov $0x5,%eax
+				0x83, 0xf8, 0x03, // cmp $0x3,%eax
+				0x7f, 0x06, // jg 1f
+				0xbb, 0x01, 0x00, 0x00, 0x00, // mov $0x1,%ebx
+				0xf4,                         // hlt
+				0xbb, 0x02, 0x00, 0x00, 0x00, // 1: mov $0x2,%ebx
+				0x0f, 0x9c, 0xc1, // setl %cl
+				0x0f, 0x4f, 0xd0, // cmovg %eax,%edx
+				0xf4, // hlt
+			},
+			regs:  []regValue{{dis.Ecx, 0xffff}},
+			eregs: []regValue{{dis.Ebx, 2}, {dis.Ecx, 0xff00}, {dis.Edx, 5}},
+		},
+		{
+			name: "jump table",
+			code: []byte{
+				0xb8, 0x02, 0x00, 0x00, 0x00, // mov $0x2,%eax
+				0xff, 0x24, 0x85, 0x00, 0x02, 0x00, 0x00, // jmp *0x200(,%eax,4)
+				0xf4, // 0xc: hlt
+				0x43, // 0xd: inc %ebx
+				0xf4, // hlt
+			},
+			mem:   []memValue{{0x208, "\x0d\x00\x00\x00"}},
+			eregs: []regValue{{dis.Ebx, 1}},
+		},
+		{
+			name: "div",
+			code: []byte{
+				0xf7, 0xf1, // div %ecx
+				0x99,       // cltd
+				0xf7, 0xfb, // idiv %ebx
+				0xf4, // hlt
+			},
+			regs:  []regValue{{dis.Eax, 100}, {dis.Ecx, 7}, {dis.Ebx, 0xfffffffd}},
+			eregs: []regValue{{dis.Eax, 0xfffffffc}, {dis.Edx, 2}},
+		},
+		{
+			name: "16-bit operand",
+			code: []byte{
+				0x66, 0x01, 0xc8, // add %cx,%ax
+				0x66, 0x6a, 0xff, // pushw $0xffff
+				0x66, 0x5a, // pop %dx
+				0xf4, // hlt
+			},
+			regs:  []regValue{{dis.Eax, 0x1234ffff}, {dis.Ecx, 1}, {dis.Esp, 0x1000}, {dis.Edx, 0x55550000}},
+			eregs: []regValue{{dis.Eax, 0x12340000}, {dis.Edx, 0x5555ffff}, {dis.Esp, 0x1000}},
+			flags: dis.FlagCF | dis.FlagPF | dis.FlagAF | dis.FlagZF,
+		},
+	}
+	for _, td := range testdata {
+		cpu, mem := newTestCPU(td.code)
+		for _, m := range td.mem {
+			copy(mem[m.addr:], m.data)
+		}
+		for _, r := range td.regs {
+			cpu.Regs[r.reg] = r.val
+		}
+		if err := cpu.Run(1000); err != nil {
+			t.Errorf("%s: %v at %#x", td.name, err, cpu.EIP)
+			continue
+		}
+		for _, r := range td.eregs {
+			if cpu.Regs[r.reg] != r.val {
+				t.Errorf("%s: %s %#x, should be %#x", td.name,
+					dis.Reg{Class: dis.RegGP, Num: r.reg, Size: 4}, cpu.Regs[r.reg], r.val)
+			}
+		}
+		for _, m := range td.emem {
+			if data := string(mem[m.addr : m.addr+uint32(len(m.data))]); data != m.data {
+				t.Errorf("%s: memory at %#x %q, should be %q", td.name, m.addr, data, m.data)
+			}
+		}
+		if flags := cpu.EFLAGS() & (dis.FlagsStatus | dis.FlagDF); flags != td.flags {
+			t.Errorf("%s: flags %#x, should be %#x", td.name, flags, td.flags)
+		}
+	}
+}
+
+// Relative branch targets are offsets in CS, not linear addresses.
+func TestRelBranchCSBase(t *testing.T) {
+	testdata := []struct {
+		name string
+		code []byte
+		cs   Segment
+		real bool
+		eip  uint32 // EIP after hlt
+		ret  string // Return address of call on the stack
+	}{
+		{
+			name: "32-bit",
+			code: []byte{
+				0xeb, 0x01, // jmp 1f
+				0xf4,                         // hlt
+				0xe8, 0x01, 0x00, 0x00, 0x00, // 1: call 2f
+				0xf4,       // hlt
+				0x31, 0xc0, // 2: xor %eax,%eax
+				0x74, 0x01, // jz 3f
+				0xf4,                         // hlt
+				0xb9, 0x02, 0x00, 0x00, 0x00, // 3: mov $0x2,%ecx
+				0xe2, 0xfe, // 4: loop 4b
+				0xe3, 0x01, // jecxz 5f
+				0xf4, // hlt
+				0xc3, // 5: ret
+			},
+			cs:  Segment{Selector: 8, Base: 0x1000, Limit: 0xffff, Attr: flatCode},
+			eip: 0x9,
+			ret: "\x08\x00\x00\x00",
+		},
+		{
+			name: "real mode 07c0:0000",
+			code: []byte{
+				0xeb, 0x01, // jmp 1f
+				0xf4,             // hlt
+				0xe8, 0x01, 0x00, // 1: call 2f
+				0xf4,       // hlt
+				0x31, 0xc0, // 2: xor %ax,%ax
+				0x74, 0x01, // jz 3f
+				0xf4,             // hlt
+				0xb9, 0x02, 0x00, // 3: mov $0x2,%cx
+				0xe2, 0xfe, // 4: loop 4b
+				0xe3, 0x01, // jcxz 5f
+				0xf4, // hlt
+				0xc3, // 5: ret
+			},
+			cs:   Segment{Selector: 0x7c0, Base: 0x7c00, Limit: 0xffff, Attr: SegP | SegS | SegCode | SegReadable},
+			real: true,
+			eip:  0x7,
+			ret:  "\x06\x00",
+		},
+	}
+	for _, td := range testdata {
+		mem := NewMemory(0x10000)
+		copy(mem.RAM()[td.cs.Base:], td.code)
+		cpu := NewCPU(mem)
+		if td.real {
+			cpu.setCR(0, CR0ET)
+			for i := range cpu.Seg {
+				cpu.Seg[i] = Segment{Limit: 0xffff, Attr: SegP | SegS | SegWritable | SegAccessed}
+			}
+		}
+		cpu.setCS(td.cs)
+		cpu.Regs[dis.Esp] = 0x800
+		if err := cpu.Run(20); err != nil {
+			t.Errorf("%s: %v at %#x", td.name, err, cpu.EIP)
+			continue
+		}
+		if cpu.EIP != td.eip {
+			t.Errorf("%s: halted at %#x, should be %#x", td.name, cpu.EIP, td.eip)
+		}
+		ret := string(mem.RAM()[0x800-len(td.ret) : 0x800])
+		if ret != td.ret {
+			t.Errorf("%s: return address % x, should be % x", td.name, ret, td.ret)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package emu
+
+import (
+	"math/bits"
+
+	dis "github.com/cyfdecyf/GoEmu/dis-x86"
+)
+
+const (
+	flagCF = uint32(dis.FlagCF)
+	flagPF = uint32(dis.FlagPF)
+	flagAF = uint32(dis.FlagAF)
+	flagZF = uint32(dis.FlagZF)
+	flagSF = uint32(dis.FlagSF)
+	flagOF = uint32(dis.FlagOF)
+	flagDF = uint32(dis.FlagDF)
+
+	flagsStatus = uint32(dis.FlagsStatus)
+)
+
+// Operation setting the status flags. The flags are calculated from the
+// operands and the result.
+type flagOp byte
+
+const (
+	opAdd   flagOp = iota
+	opAdc          // add with carry in set, adc without carry is opAdd
+	opSub          // sub, cmp and neg, which is 0 - dst
+	opSbb          // sbb with carry in set
+	opLogic        // and, or, xor and test
+	opInc
+	opDec
+	opShl // src is the count, which is not zero
+	opShr
+	opSar
+	opMul // src is 1 if the high half of the product is significant
+)
+
+// Status flags modified by each operation. AF is undefined for logic and
+// shift, and SF, ZF, AF and PF for mul. They are set as if defined.
+var flagsOfOp = [...]uint32{
+	opAdd:   flagsStatus,
+	opAdc:   flagsStatus,
+	opSub:   flagsStatus,
+	opSbb:   flagsStatus,
+	opLogic: flagsStatus,
+	opInc:   flagsStatus &^ flagCF,
+	opDec:   flagsStatus &^ flagCF,
+	opShl:   flagsStatus,
+	opShr:   flagsStatus,
+	opSar:   flagsStatus,
+	opMul:   flagsStatus,
+}
+
+func boolFlag(b bool, flag uint32) uint32 {
+	if b {
+		return flag
+	}
+	return 0
+}
+
+// SF, ZF and PF of the result.
+func resultFlags(result uint32, size int) uint32 {
+	f := boolFlag(result&signBit(size) != 0, flagSF)
+	f |= boolFlag(result&mask(size) == 0, flagZF)
+	f |= boolFlag(bits.OnesCount8(uint8(result))%2 == 0, flagPF)
+	return f
+}
+
+// Status flags of the operation. dst, src and result are truncated to size
+// bytes.
+func statusFlags(op flagOp, size int, dst, src, result uint32) uint32 {
+	sign := signBit(size)
+	f := resultFlags(result, size)
+	switch op {
+	case opAdd, opAdc, opInc:
+		if op == opInc {
+			src = 1
+		}
+		if op == opAdc {
+			f |= boolFlag(result <= dst, flagCF)
+		} else {
+			f |= boolFlag(result < dst, flagCF)
+		}
+		f |= boolFlag((dst^result)&(src^result)&sign != 0, flagOF)
+		f |= (dst ^ src ^ result) & flagAF
+	case opSub, opSbb, opDec:
+		if op == opDec {
+			src = 1
+		}
+		if op == opSbb {
+			f |= boolFlag(dst <= src, flagCF)
+		} else {
+			f |= boolFlag(dst < src, flagCF)
+		}
+		f |= boolFlag((dst^src)&(dst^result)&sign != 0, flagOF)
+		f |= (dst ^ src ^ result) & flagAF
+	case opShl:
+		cf := src <= uint32(8*size) && dst>>(uint32(8*size)-src)&1 != 0
+		f |= boolFlag(cf, flagCF)
+		f |= boolFlag(cf != (result&sign != 0), flagOF)
+	case opShr:
+		f |= boolFlag(dst>>(src-1)&1 != 0, flagCF)
+		f |= boolFlag(dst&sign != 0, flagOF)
+	case opSar:
+		f |= boolFlag(signExtend(dst, size)>>(src-1)&1 != 0, flagCF)
+	case opMul:
+		f |= boolFlag(src != 0, flagCF|flagOF)
+	}
+	return f
+}
+
+// The last operation setting the status flags. Flags are evaluated from it
+// only when read, as most flags set by an instruction are overwritten by the
+// next arithmetic instruction before being read.
+type lazyFlags struct {
+	op               flagOp
+	size             int
+	dst, src, result uint32
+	pending          bool // Status flags in eflags are not evaluated yet
+}
+
+// Set the status flags modified by the operation.
+func (cpu *CPU) setFlags(op flagOp, size int, dst, src, result uint32) {
+	mod := flagsOfOp[op]
+	if cpu.eagerFlags || mod != flagsStatus {
+		// inc and dec keep CF, which may be set by the pending operation
+		cpu.evalFlags()
+		cpu.eflags = cpu.eflags&^mod | statusFlags(op, size, dst, src, result)&mod
+		return
+	}
+	cpu.lazy = lazyFlags{op: op, size: size, dst: dst, src: src, result: result, pending: true}
+}
+
+// Evaluate the status flags set by the pending operation.
+func (cpu *CPU) evalFlags() {
+	l := &cpu.lazy
+	if !l.pending {
+		return
+	}
+	l.pending = false
+	cpu.eflags = cpu.eflags&^flagsStatus | statusFlags(l.op, l.size, l.dst, l.src, l.result)
+}
+
+// Set the flags in mod to the value in f.
+func (cpu *CPU) setFlagBits(mod, f uint32) {
+	if mod&flagsStatus == flagsStatus {
+		cpu.lazy.pending = false
+	} else if mod&flagsStatus != 0 {
+		cpu.evalFlags()
+	}
+	cpu.eflags = cpu.eflags&^mod | f&mod
+}
+
+func (cpu *CPU) flag(f uint32) bool {
+	if f&flagsStatus != 0 {
+		cpu.evalFlags()
+	}
+	return cpu.eflags&f != 0
+}
+
+// Test the condition code of jcc, setcc and cmovcc.
+func (cpu *CPU) condition(cc byte) bool {
+	var r bool
+	switch cc >> 1 {
+	case 0:
+		r = cpu.flag(flagOF)
+	case 1:
+		r = cpu.flag(flagCF)
+	case 2:
+		r = cpu.flag(flagZF)
+	case 3:
+		r = cpu.flag(flagCF) || cpu.flag(flagZF)
+	case 4:
+		r = cpu.flag(flagSF)
+	case 5:
+		r = cpu.flag(flagPF)
+	case 6:
+		r = cpu.flag(flagSF) != cpu.flag(flagOF)
+	case 7:
+		r = cpu.flag(flagZF) || cpu.flag(flagSF) != cpu.flag(flagOF)
+	}
+	return r != (cc&1 != 0)
+}
@@ -0,0 +1,159 @@
+package emu
+
+import (
+	"math/rand"
+	"testing"
+
+	dis "github.com/cyfdecyf/GoEmu/dis-x86"
+)
+
+// Instructions setting the status flags, operating on %eax and %ecx, or %al
+// and %cl. Instructions in word32 are also tested with operand size prefix.
+var (
+	flagsInsnByte = [][]byte{
+		{0x00, 0xc8},       // add %cl,%al
+		{0x08, 0xc8},       // or %cl,%al
+		{0x10, 0xc8},       // adc %cl,%al
+		{0x18, 0xc8},       // sbb %cl,%al
+		{0x20, 0xc8},       // and %cl,%al
+		{0x28, 0xc8},       // sub %cl,%al
+		{0x30, 0xc8},       // xor %cl,%al
+		{0x38, 0xc8},       // cmp %cl,%al
+		{0x84, 0xc8},       // test %cl,%al
+		{0xfe, 0xc0},       // inc %al
+		{0xfe, 0xc8},       // dec %al
+		{0xf6, 0xd8},       // neg %al
+		{0xd2, 0xe0},       // shl %cl,%al
+		{0xd2, 0xe8},       // shr %cl,%al
+		{0xd2, 0xf8},       // sar %cl,%al
+		{0xd0, 0xe0},       // shl %al
+		{0xd0, 0xe8},       // shr %al
+		{0xd0, 0xf8},       // sar %al
+		{0xd2, 0xc0},       // rol %cl,%al
+		{0xd2, 0xc8},       // ror %cl,%al
+		{0xd2, 0xd0},       // rcl %cl,%al
+		{0xd2, 0xd8},       // rcr %cl,%al
+		{0xf6, 0xe1},       // mul %cl
+		{0xf6, 0xe9},       // imul %cl
+		{0x0f, 0xc0, 0xc8}, // xadd %cl,%al
+		{0x0f, 0xb0, 0xc8}, // cmpxchg %cl,%al
+	}
+	flagsInsnWord32 = [][]byte{
+		{0x01, 0xc8},       // add %ecx,%eax
+		{0x09, 0xc8},       // or %ecx,%eax
+		{0x11, 0xc8},       // adc %ecx,%eax
+		{0x19, 0xc8},       // sbb %ecx,%eax
+		{0x21, 0xc8},       // and %ecx,%eax
+		{0x29, 0xc8},       // sub %ecx,%eax
+		{0x31, 0xc8},       // xor %ecx,%eax
+		{0x39, 0xc8},       // cmp %ecx,%eax
+		{0x85, 0xc8},       // test %ecx,%eax
+		{0x83, 0xc0, 0x80}, // add $-0x80,%eax
+		{0x83, 0xe8, 0x01}, // sub $0x1,%eax
+		{0x40},             // inc %eax
+		{0x48},             // dec %eax
+		{0xf7, 0xd8},       // neg %eax
+		{0xd3, 0xe0},       // shl %cl,%eax
+		{0xd3, 0xe8},       // shr %cl,%eax
+		{0xd3, 0xf8},       // sar %cl,%eax
+		{0xd1, 0xe0},       // shl %eax
+		{0xd1, 0xe8},       // shr %eax
+		{0xd1, 0xf8},       // sar %eax
+		{0xd3, 0xc0},       // rol %cl,%eax
+		{0xd3, 0xc8},       // ror %cl,%eax
+		{0xd3, 0xd0},       // rcl %cl,%eax
+		{0xd3, 0xd8},       // rcr %cl,%eax
+		{0xf7, 0xe1},       // mul %ecx
+		{0xf7, 0xe9},       // imul %ecx
+		{0x0f, 0xaf, 0xc1}, // imul %ecx,%eax
+		{0x6b, 0xc1, 0x7f}, // imul $0x7f,%ecx,%eax
+		{0x0f, 0xa5, 0xc8}, // shld %cl,%ecx,%eax
+		{0x0f, 0xad, 0xc8}, // shrd %cl,%ecx,%eax
+		{0x0f, 0xa3, 0xc8}, // bt %ecx,%eax
+		{0x0f, 0xbb, 0xc8}, // btc %ecx,%eax
+		{0x0f, 0xbc, 0xc1}, // bsf %ecx,%eax
+		{0x0f, 0xbd, 0xc1}, // bsr %ecx,%eax
+		{0x0f, 0xc1, 0xc8}, // xadd %ecx,%eax
+		{0x0f, 0xb1, 0xc8}, // cmpxchg %ecx,%eax
+	}
+)
+
+// Instructions reading the flags set by the tested instruction. Results are
+// left in %ebx, %edx and %esi.
+var flagsReader = [][]byte{
+	{0x11, 0xcb},             // adc %ecx,%ebx
+	{0x19, 0xcb},             // sbb %ecx,%ebx
+	{0x9c, 0x5e},             // pushf; pop %esi
+	{0x9f, 0x88, 0xe6},       // lahf; mov %ah,%dh
+	{0x43, 0x9c, 0x5e},       // inc %ebx; pushf; pop %esi
+	{0xf5, 0x9c, 0x5e},       // cmc; pushf; pop %esi
+	{0x0f, 0xba, 0xe3, 0x01}, // bt $0x1,%ebx
+	{0x0f, 0x42, 0xd1},       // cmovb %ecx,%edx
+	{0x7c, 0x01, 0x4b},       // jl 1f; dec %ebx; 1:
+}
+
+func init() {
+	for cc := byte(0); cc < 16; cc++ {
+		flagsReader = append(flagsReader, []byte{0x0f, 0x90 | cc, 0xc2}) // setcc %dl
+	}
+}
+
+var flagsValue = []uint32{0, 1, 2, 0x1f, 0x20, 0x7f, 0x80, 0xff, 0x100, 0x7fff,
+	0x8000, 0xffff, 0x10000, 0x7fffffff, 0x80000000, 0x80000001, 0xffffffff}
+
+func randFlagsValue(r *rand.Rand) uint32 {
+	if r.Intn(2) == 0 {
+		return flagsValue[r.Intn(len(flagsValue))]
+	}
+	return r.Uint32()
+}
+
+// Run the code with lazy and eager flags evaluation, and compare the result.
+func testLazyFlags(t *testing.T, code []byte, regs [4]uint32, flags dis.Flags) bool {
+	var cpus [2]*CPU
+	for i := range cpus {
+		cpu, _ := newTestCPU(append(code, 0xf4)) // hlt
+		cpu.eagerFlags = i == 1
+		copy(cpu.Regs[:], regs[:])
+		cpu.Regs[dis.Esp] = 0x1000
+		cpu.SetEFLAGS(flags)
+		if err := cpu.Run(10); err != nil {
+			t.Errorf("% x: %v", code, err)
+			return false
+		}
+		cpus[i] = cpu
+	}
+	lazy, eager := cpus[0], cpus[1]
+	if lazy.Regs != eager.Regs || lazy.EFLAGS() != eager.EFLAGS() {
+		t.Errorf("% x: eax %#x ecx %#x eflags %#x\nlazy:  %#x eflags %#x\neager: %#x eflags %#x",
+			code, regs[dis.Eax], regs[dis.Ecx], flags,
+			lazy.Regs, lazy.EFLAGS(), eager.Regs, eager.EFLAGS())
+		return false
+	}
+	return true
+}
+
+func TestLazyFlags(t *testing.T) {
+	var insns [][]byte
+	insns = append(insns, flagsInsnByte...)
+	for _, insn := range flagsInsnWord32 {
+		insns = append(insns, insn, append([]byte{0x66}, insn...))
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for _, insn := range insns {
+		for _, reader := range flagsReader {
+			code := append(append([]byte{}, insn...), reader...)
+			for i := 0; i < 20; i++ {
+				var regs [4]uint32
+				for j := range regs {
+					regs[j] = randFlagsValue(r)
+				}
+				flags := dis.Flags(r.Uint32()) & (dis.FlagsStatus | dis.FlagDF)
+				if !testLazyFlags(t, code, regs, flags) {
+					break
+				}
+			}
+		}
+	}
+}
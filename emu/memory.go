@@ -0,0 +1,256 @@
+package emu
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// MMIOHandler is the device model handling accesses to a MMIO region. offset
+// is relative to the start of the region. size is 1, 2, 4 or 8 bytes. Values
+// are little-endian, the lowest address is in the least significant byte.
+type MMIOHandler interface {
+	ReadMMIO(offset uint32, size int) uint64
+	WriteMMIO(offset uint32, size int, v uint64)
+}
+
+type mmioRegion struct {
+	addr, size uint32
+	handler    MMIOHandler
+}
+
+func (r *mmioRegion) end() uint64 {
+	return uint64(r.addr) + uint64(r.size)
+}
+
+// Memory is the guest physical address space. RAM starts at address 0, MMIO
+// regions can be placed anywhere in the 4G address space and take precedence
+// over RAM. Other addresses are unmapped.
+//
+// Memory implements Bus, so it can be used by CPU. Instructions in guest
+// memory are decoded by dis.DisContext through Code, which doesn't call MMIO
+// handlers.
+type Memory struct {
+	ram  []byte
+	mmio []*mmioRegion // Sorted by address
+}
+
+// AccessError is returned when accessing unmapped address, or fetching
+// instructions from MMIO region.
+type AccessError struct {
+	Addr  uint64
+	Write bool
+	Fetch bool // Instruction fetch from MMIO region
+}
+
+func (e *AccessError) Error() string {
+	if e.Fetch {
+		return fmt.Sprintf("emu: instruction fetch from MMIO address %#x", e.Addr)
+	}
+	op := "read"
+	if e.Write {
+		op = "write"
+	}
+	return fmt.Sprintf("emu: %s of unmapped address %#x", op, e.Addr)
+}
+
+var ErrMMIORegion = errors.New("emu: MMIO region is empty or overlaps another region")
+
+const addrSpaceSize = 1 << 32
+
+// NewMemory creates a Memory with size bytes RAM.
+func NewMemory(size uint32) *Memory {
+	return &Memory{ram: make([]byte, size)}
+}
+
+// RAM returns the RAM backing the memory. It can be used to load images
+// without going through MMIO regions.
+func (m *Memory) RAM() []byte {
+	return m.ram
+}
+
+// AddMMIO registers the handler for the size bytes region starting at addr.
+func (m *Memory) AddMMIO(addr, size uint32, handler MMIOHandler) error {
+	r := &mmioRegion{addr, size, handler}
+	if size == 0 || r.end() > addrSpaceSize {
+		return ErrMMIORegion
+	}
+	i := m.regionAfter(addr)
+	if i < len(m.mmio) && uint64(m.mmio[i].addr) < r.end() {
+		return ErrMMIORegion
+	}
+	m.mmio = append(m.mmio, nil)
+	copy(m.mmio[i+1:], m.mmio[i:])
+	m.mmio[i] = r
+	return nil
+}
+
+// Index of the first MMIO region ending after addr.
+func (m *Memory) regionAfter(addr uint32) int {
+	return sort.Search(len(m.mmio), func(i int) bool {
+		return m.mmio[i].end() > uint64(addr)
+	})
+}
+
+// Access len(b) bytes starting at addr. The part of the access lying in a
+// MMIO region is passed to the handler as a whole if it has a valid size,
+// otherwise the handler is called for each byte.
+func (m *Memory) access(addr uint64, b []byte, write bool) (n int, err error) {
+	for n < len(b) {
+		a := addr + uint64(n)
+		if a >= addrSpaceSize {
+			return n, &AccessError{Addr: a, Write: write}
+		}
+		// Bytes till the next MMIO region or the end of the current one
+		chunk := uint64(len(b) - n)
+		var region *mmioRegion
+		if i := m.regionAfter(uint32(a)); i < len(m.mmio) {
+			region = m.mmio[i]
+			limit := region.end()
+			if uint64(region.addr) > a {
+				limit = uint64(region.addr)
+				region = nil
+			}
+			if limit-a < chunk {
+				chunk = limit - a
+			}
+		}
+		p := b[n : n+int(chunk)]
+
+		switch {
+		case region != nil:
+			offset := uint32(a) - region.addr
+			switch len(p) {
+			case 1, 2, 4, 8:
+				m.accessMMIO(region, offset, p, write)
+			default:
+				for i := range p {
+					m.accessMMIO(region, offset+uint32(i), p[i:i+1], write)
+				}
+			}
+		case a < uint64(len(m.ram)):
+			if write {
+				p = p[:copy(m.ram[a:], p)]
+			} else {
+				p = p[:copy(p, m.ram[a:])]
+			}
+		default:
+			return n, &AccessError{Addr: a, Write: write}
+		}
+		n += len(p)
+	}
+	return n, nil
+}
+
+func (m *Memory) accessMMIO(region *mmioRegion, offset uint32, b []byte, write bool) {
+	var buf [8]byte
+	if write {
+		copy(buf[:], b)
+		region.handler.WriteMMIO(offset, len(b), binary.LittleEndian.Uint64(buf[:]))
+	} else {
+		binary.LittleEndian.PutUint64(buf[:], region.handler.ReadMMIO(offset, len(b)))
+		copy(b, buf[:])
+	}
+}
+
+// ReadAt implements io.ReaderAt. *AccessError is returned if part of the
+// data is unmapped.
+func (m *Memory) ReadAt(b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, &AccessError{Addr: uint64(off)}
+	}
+	return m.access(uint64(off), b, false)
+}
+
+// WriteAt implements io.WriterAt. Writes to unmapped address are not
+// performed and *AccessError is returned.
+func (m *Memory) WriteAt(b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, &AccessError{Addr: uint64(off), Write: true}
+	}
+	return m.access(uint64(off), b, true)
+}
+
+// Code returns the reader used to fetch instructions, e.g. by
+// dis.NewDisContext. The decoder may read the same bytes several times, so
+// MMIO handlers are not called. Reading MMIO region returns *AccessError
+// with Fetch set.
+func (m *Memory) Code() io.ReaderAt {
+	return codeReader{m}
+}
+
+type codeReader struct {
+	m *Memory
+}
+
+func (r codeReader) ReadAt(b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, &AccessError{Addr: uint64(off)}
+	}
+	m, addr, end := r.m, uint64(off), uint64(off)+uint64(len(b))
+	if addr < addrSpaceSize {
+		if i := m.regionAfter(uint32(addr)); i < len(m.mmio) && uint64(m.mmio[i].addr) < end {
+			// Bytes before the region are read
+			start := addr
+			if uint64(m.mmio[i].addr) > start {
+				start = uint64(m.mmio[i].addr)
+			}
+			n, err := m.access(addr, b[:start-addr], false)
+			if err != nil {
+				return n, err
+			}
+			return n, &AccessError{Addr: start, Fetch: true}
+		}
+	}
+	return m.access(addr, b, false)
+}
+
+func (m *Memory) read(addr uint32, size int) (uint64, error) {
+	var buf [8]byte
+	_, err := m.access(uint64(addr), buf[:size], false)
+	return binary.LittleEndian.Uint64(buf[:]), err
+}
+
+func (m *Memory) write(addr uint32, size int, v uint64) error {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	_, err := m.access(uint64(addr), buf[:size], true)
+	return err
+}
+
+func (m *Memory) Read8(addr uint32) (uint8, error) {
+	v, err := m.read(addr, 1)
+	return uint8(v), err
+}
+
+func (m *Memory) Read16(addr uint32) (uint16, error) {
+	v, err := m.read(addr, 2)
+	return uint16(v), err
+}
+
+func (m *Memory) Read32(addr uint32) (uint32, error) {
+	v, err := m.read(addr, 4)
+	return uint32(v), err
+}
+
+func (m *Memory) Read64(addr uint32) (uint64, error) {
+	return m.read(addr, 8)
+}
+
+func (m *Memory) Write8(addr uint32, v uint8) error {
+	return m.write(addr, 1, uint64(v))
+}
+
+func (m *Memory) Write16(addr uint32, v uint16) error {
+	return m.write(addr, 2, uint64(v))
+}
+
+func (m *Memory) Write32(addr uint32, v uint32) error {
+	return m.write(addr, 4, uint64(v))
+}
+
+func (m *Memory) Write64(addr uint32, v uint64) error {
+	return m.write(addr, 8, v)
+}
@@ -0,0 +1,169 @@
+package emu
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	dis "github.com/cyfdecyf/GoEmu/dis-x86"
+)
+
+// Device recording accesses. Reads return the offset in each byte.
+type testDevice struct {
+	log []string
+}
+
+func (d *testDevice) ReadMMIO(offset uint32, size int) uint64 {
+	d.log = append(d.log, fmt.Sprintf("r %#x %d", offset, size))
+	return uint64(offset) * 0x0101010101010101
+}
+
+func (d *testDevice) WriteMMIO(offset uint32, size int, v uint64) {
+	d.log = append(d.log, fmt.Sprintf("w %#x %d %#x", offset, size, v))
+}
+
+func TestMemoryRAM(t *testing.T) {
+	m := NewMemory(0x100)
+	if err := m.Write64(0x10, 0x0807060504030201); err != nil {
+		t.Fatal(err)
+	}
+	if b := string(m.RAM()[0x10:0x18]); b != "\x01\x02\x03\x04\x05\x06\x07\x08" {
+		t.Errorf("RAM %q, not little-endian", b)
+	}
+	if v, _ := m.Read8(0x11); v != 0x02 {
+		t.Errorf("Read8 %#x, should be 0x02", v)
+	}
+	if v, _ := m.Read16(0x11); v != 0x0302 {
+		t.Errorf("Read16 %#x, should be 0x0302", v)
+	}
+	if v, _ := m.Read32(0x13); v != 0x07060504 {
+		t.Errorf("Read32 %#x, should be 0x07060504", v)
+	}
+	if v, _ := m.Read64(0x10); v != 0x0807060504030201 {
+		t.Errorf("Read64 %#x, should be 0x0807060504030201", v)
+	}
+	m.Write8(0x20, 0xaa)
+	m.Write16(0x21, 0xccbb)
+	m.Write32(0x23, 0x11ffeedd)
+	if v, _ := m.Read64(0x20); v != 0x11ffeeddccbbaa {
+		t.Errorf("Read64 %#x, should be 0x11ffeeddccbbaa", v)
+	}
+
+	// Access crossing the end of RAM
+	if _, err := m.Read32(0xfe); err == nil || err.Error() != "emu: read of unmapped address 0x100" {
+		t.Errorf("read crossing RAM end: %v", err)
+	}
+	if err := m.Write16(0x1000, 0); err == nil || err.Error() != "emu: write of unmapped address 0x1000" {
+		t.Errorf("write unmapped: %v", err)
+	}
+	if _, err := m.ReadAt(make([]byte, 2), 0xffffffff); err == nil {
+		t.Error("read crossing 4G should fail")
+	}
+}
+
+func TestMemoryMMIO(t *testing.T) {
+	m := NewMemory(0x100)
+	dev := &testDevice{}
+	if err := m.AddMMIO(0x80, 0x10, dev); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddMMIO(0xfee00000, 0x1000, dev); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range [][2]uint32{{0x70, 0x11}, {0x8f, 1}, {0x78, 0x100}, {0, 0}, {0xfffff000, 0x2000}} {
+		if err := m.AddMMIO(r[0], r[1], dev); err != ErrMMIORegion {
+			t.Errorf("AddMMIO(%#x, %#x) returns %v, should fail", r[0], r[1], err)
+		}
+	}
+
+	m.RAM()[0x7f] = 0x55
+	m.Write32(0x84, 0x12345678)
+	m.Write64(0xfee00300, 1)
+	v32, _ := m.Read32(0x88)
+	v16, _ := m.Read16(0xfee00020)
+	// Crossing into the region
+	v16x, _ := m.Read16(0x7f)
+	// Handler called for each byte
+	b := make([]byte, 3)
+	m.ReadAt(b, 0x81)
+	if v32 != 0x08080808 || v16 != 0x2020 || v16x != 0x0055 || string(b) != "\x01\x02\x03" {
+		t.Errorf("read %#x %#x %#x %q", v32, v16, v16x, b)
+	}
+	// Crossing out of the region
+	if _, err := m.Read32(0xfee00ffe); err == nil {
+		t.Error("read from region to unmapped address should fail")
+	}
+	if m.RAM()[0x84] != 0 {
+		t.Error("MMIO write goes to RAM")
+	}
+	log := strings.Join(dev.log, ", ")
+	elog := "w 0x4 4 0x12345678, w 0x300 8 0x1, r 0x8 4, r 0x20 2, r 0x0 1, " +
+		"r 0x1 1, r 0x2 1, r 0x3 1, r 0xffe 2"
+	if log != elog {
+		t.Errorf("MMIO access:\n%s\nshould be:\n%s", log, elog)
+	}
+}
+
+func TestMemoryBus(t *testing.T) {
+	m := NewMemory(0x1000)
+	copy(m.RAM()[0x10:], []byte{
+		0xb8, 0x01, 0x00, 0x00, 0x00, // mov $0x1,%eax
+		0xa3, 0x00, 0x20, 0x00, 0x00, // mov %eax,0x2000
+		0xf4, // hlt
+	})
+	dev := &testDevice{}
+	m.AddMMIO(0x2000, 0x10, dev)
+
+	// Decode from memory till the end of RAM
+	dc := dis.NewDisContext(m.Code())
+	dc.SetMode(dis.Mode32)
+	dc.SetOffset(0xffd)
+	if _, err := dc.NextInsn(); err != nil {
+		t.Fatal(err)
+	}
+	_, err := dc.NextInsn()
+	if _, ok := err.(*AccessError); !ok {
+		t.Errorf("decode at end of RAM returns %v, should be *AccessError", err)
+	}
+
+	cpu := NewCPU(m)
+	cpu.EIP = 0x10
+	if err := cpu.Run(10); err != nil {
+		t.Fatal(err)
+	}
+	if len(dev.log) != 1 || dev.log[0] != "w 0x0 4 0x1" {
+		t.Errorf("MMIO access %v, should be w 0x0 4 0x1", dev.log)
+	}
+	cpu.EIP = 0x1000
+	cpu.Halted = false
+	if err := cpu.Step(); err == nil || err.Error() != "emu: read of unmapped address 0x1000" {
+		t.Errorf("fetch from unmapped address: %v", err)
+	}
+	var _ io.ReaderAt = m
+
+	// Instruction fetch doesn't call MMIO handlers
+	dev.log = nil
+	b := make([]byte, 4)
+	if n, err := m.Code().ReadAt(b, 0x1ffe); n != 0 || err == nil ||
+		err.Error() != "emu: read of unmapped address 0x1ffe" {
+		t.Errorf("fetch unmapped: %d %v", n, err)
+	}
+	copy(m.RAM()[0xffe:], "\x90\x90")
+	if n, err := m.Code().ReadAt(b, 0xffe); n != 2 || err == nil ||
+		err.Error() != "emu: read of unmapped address 0x1000" {
+		t.Errorf("fetch crossing RAM end: %d %v", n, err)
+	}
+	m.AddMMIO(0xffc, 2, dev)
+	if n, err := m.Code().ReadAt(b, 0xffa); n != 2 || err == nil ||
+		err.Error() != "emu: instruction fetch from MMIO address 0xffc" {
+		t.Errorf("fetch crossing into MMIO: %d %v", n, err)
+	}
+	cpu.EIP = 0x2000
+	if err := cpu.Step(); err == nil || err.Error() != "emu: instruction fetch from MMIO address 0x2000" {
+		t.Errorf("execute MMIO: %v", err)
+	}
+	if len(dev.log) != 0 {
+		t.Errorf("MMIO access %v by instruction fetch", dev.log)
+	}
+}
@@ -0,0 +1,161 @@
+package emu
+
+import (
+	"encoding/binary"
+
+	dis "github.com/cyfdecyf/GoEmu/dis-x86"
+)
+
+// Mask of the low size bytes.
+func mask(size int) uint32 {
+	return uint32(1<<(8*uint(size)) - 1)
+}
+
+func signBit(size int) uint32 {
+	return 1 << (8*uint(size) - 1)
+}
+
+// Sign-extend the low size bytes of v to 32 bits.
+func signExtend(v uint32, size int) uint32 {
+	shift := 32 - 8*uint(size)
+	return uint32(int32(v<<shift) >> shift)
+}
+
+// Size of operand in bytes.
+func operandSize(op dis.Operand) int {
+	switch op := op.(type) {
+	case dis.Reg:
+		return op.Size
+	case dis.Mem:
+		return op.Size
+	case dis.Imm:
+		return op.Size
+	}
+	return 4
+}
+
+// General purpose register.
+func gpReg(num byte, size int) dis.Reg {
+	return dis.Reg{Class: dis.RegGP, Num: num, Size: size}
+}
+
+func (cpu *CPU) reg(r dis.Reg) uint32 {
+	v := cpu.Regs[r.Num]
+	if r.High {
+		return v >> 8 & 0xff
+	}
+	return v & mask(r.Size)
+}
+
+func (cpu *CPU) setReg(r dis.Reg, v uint32) {
+	p := &cpu.Regs[r.Num]
+	switch {
+	case r.High:
+		*p = *p&^0xff00 | (v&0xff)<<8
+	case r.Size == 4:
+		*p = v
+	default:
+		*p = *p&^mask(r.Size) | v&mask(r.Size)
+	}
+}
+
+// Effective address of memory operand, which is the offset in the segment.
+func (cpu *CPU) effectiveAddr(m dis.Mem) uint32 {
+	addr := uint32(m.Disp)
+	if m.Base.Class == dis.RegGP {
+		addr += cpu.reg(m.Base)
+	}
+	if m.Index.Class == dis.RegGP {
+		addr += cpu.reg(m.Index) * uint32(m.Scale)
+	}
+	return addr & mask(cpu.addrSize())
+}
+
+func (cpu *CPU) readMem(seg byte, offset uint32, size int) uint32 {
+	var buf [4]byte
+	b := buf[:size]
+	if _, err := cpu.Bus.ReadAt(b, int64(cpu.linearAddr(seg, offset, size, false))); err != nil {
+		panic(busError{err})
+	}
+	switch size {
+	case 1:
+		return uint32(b[0])
+	case 2:
+		return uint32(binary.LittleEndian.Uint16(b))
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (cpu *CPU) writeMem(seg byte, offset uint32, size int, v uint32) {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	if _, err := cpu.Bus.WriteAt(buf[:size], int64(cpu.linearAddr(seg, offset, size, true))); err != nil {
+		panic(busError{err})
+	}
+}
+
+// Read the value of operand.
+func (cpu *CPU) read(op dis.Operand) uint32 {
+	switch op := op.(type) {
+	case dis.Reg:
+		switch op.Class {
+		case dis.RegGP:
+			return cpu.reg(op)
+		case dis.RegSeg:
+			return uint32(cpu.Seg[op.Num].Selector)
+		case dis.RegCtrl:
+			cpu.checkPrivileged()
+			return cpu.CR[op.Num]
+		}
+	case dis.Mem:
+		return cpu.readMem(op.Segment, cpu.effectiveAddr(op), op.Size)
+	case dis.Imm:
+		return uint32(op.Value) & mask(op.Size)
+	case dis.Rel:
+		// Not op.Target, which is the linear address as instructions are
+		// decoded at CS.Base+EIP.
+		return cpu.next + uint32(op.Offset)
+	}
+	panic(cpu.unsupported())
+}
+
+// Write v to operand.
+func (cpu *CPU) write(op dis.Operand, v uint32) {
+	switch op := op.(type) {
+	case dis.Reg:
+		switch op.Class {
+		case dis.RegGP:
+			cpu.setReg(op, v)
+			return
+		case dis.RegSeg:
+			cpu.loadSegment(op.Num, uint16(v))
+			return
+		case dis.RegCtrl:
+			cpu.checkPrivileged()
+			cpu.setCR(op.Num, v)
+			return
+		}
+	case dis.Mem:
+		cpu.writeMem(op.Segment, cpu.effectiveAddr(op), op.Size, v)
+		return
+	}
+	panic(cpu.unsupported())
+}
+
+// Stack pointer register.
+func (cpu *CPU) sp() dis.Reg {
+	return gpReg(dis.Esp, cpu.stackSize())
+}
+
+func (cpu *CPU) push(v uint32, size int) {
+	sp := (cpu.reg(cpu.sp()) - uint32(size)) & mask(cpu.stackSize())
+	cpu.writeMem(dis.SS, sp, size, v)
+	cpu.setReg(cpu.sp(), sp)
+}
+
+func (cpu *CPU) pop(size int) uint32 {
+	sp := cpu.reg(cpu.sp())
+	v := cpu.readMem(dis.SS, sp, size)
+	cpu.setReg(cpu.sp(), sp+uint32(size))
+	return v
+}
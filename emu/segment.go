@@ -0,0 +1,304 @@
+package emu
+
+import (
+	"encoding/binary"
+
+	dis "github.com/cyfdecyf/GoEmu/dis-x86"
+)
+
+// Segment access rights in Segment.Attr. They are bits 8 to 23 of the high
+// dword of the descriptor, with the limit bits cleared.
+const (
+	SegAccessed   uint16 = 1 << 0
+	SegWritable   uint16 = 1 << 1 // Data segment
+	SegReadable   uint16 = 1 << 1 // Code segment
+	SegExpandDown uint16 = 1 << 2 // Data segment
+	SegConforming uint16 = 1 << 2 // Code segment
+	SegCode       uint16 = 1 << 3
+	SegS          uint16 = 1 << 4 // Code or data segment, system segment if clear
+	SegDPL        uint16 = 3 << 5
+	SegP          uint16 = 1 << 7 // Present
+	SegAVL        uint16 = 1 << 12
+	SegL          uint16 = 1 << 13
+	SegDB         uint16 = 1 << 14 // Default operand size of code, stack size of SS
+	SegG          uint16 = 1 << 15 // Limit is in 4K units
+)
+
+const segTypeLDT = 2 // System segment type of LDT
+
+// Descriptor table register, GDTR and IDTR.
+type DescTable struct {
+	Base  uint32
+	Limit uint16
+}
+
+func (s *Segment) dpl() uint16 {
+	return s.Attr & SegDPL >> 5
+}
+
+// Offset is valid if the size bytes starting at it are in the limit. Valid
+// offsets of expand-down segments are above the limit.
+func (s *Segment) inLimit(offset uint32, size int) bool {
+	last := uint64(offset) + uint64(size) - 1
+	if s.Attr&(SegS|SegCode|SegExpandDown) == SegS|SegExpandDown {
+		upper := uint64(0xffff)
+		if s.Attr&SegDB != 0 {
+			upper = 0xffffffff
+		}
+		return offset > s.Limit && last <= upper
+	}
+	return last <= uint64(s.Limit)
+}
+
+// Segment descriptor in GDT or LDT.
+type descriptor uint64
+
+func (d descriptor) segment(selector uint16) Segment {
+	s := Segment{
+		Selector: selector,
+		Base:     uint32(d>>16&0xffffff | d>>32&0xff000000),
+		Limit:    uint32(d&0xffff | d>>32&0xf0000),
+		Attr:     uint16(d>>40) & 0xf0ff,
+	}
+	if s.Attr&SegG != 0 {
+		s.Limit = s.Limit<<12 | 0xfff
+	}
+	return s
+}
+
+func (cpu *CPU) protected() bool {
+	return cpu.CR[0]&CR0PE != 0
+}
+
+// Privileged instructions fault unless CPL is 0.
+func (cpu *CPU) checkPrivileged() {
+	if cpu.CPL != 0 {
+		fault(VectorGP, 0)
+	}
+}
+
+// I/O privilege level in EFLAGS.
+func (cpu *CPU) iopl() uint16 {
+	return uint16(cpu.eflags & uint32(dis.FlagIOPL) >> 12)
+}
+
+func isNullSelector(selector uint16) bool {
+	return selector&^3 == 0
+}
+
+// Address of the descriptor selected by selector in GDT or LDT.
+func (cpu *CPU) descriptorAddr(selector uint16) uint32 {
+	errorCode := uint32(selector &^ 3)
+	table := cpu.GDTR.Base
+	limit := uint32(cpu.GDTR.Limit)
+	if selector&4 != 0 {
+		if cpu.LDTR.Attr&SegP == 0 {
+			fault(VectorGP, errorCode)
+		}
+		table, limit = cpu.LDTR.Base, cpu.LDTR.Limit
+	}
+	off := uint32(selector &^ 7)
+	if off+7 > limit {
+		fault(VectorGP, errorCode)
+	}
+	return table + off
+}
+
+// Read the descriptor selected by selector from GDT or LDT.
+func (cpu *CPU) readDescriptor(selector uint16) Segment {
+	var buf [8]byte
+	if _, err := cpu.Bus.ReadAt(buf[:], int64(cpu.descriptorAddr(selector))); err != nil {
+		panic(busError{err})
+	}
+	return descriptor(binary.LittleEndian.Uint64(buf[:])).segment(selector)
+}
+
+// Set the accessed bit of code or data segment s in the descriptor table.
+// It's done after all checks, so a faulting load doesn't change the
+// descriptor.
+func (cpu *CPU) setAccessed(s *Segment) {
+	if s.Attr&(SegS|SegAccessed) != SegS {
+		return
+	}
+	s.Attr |= SegAccessed
+	// The low byte of Attr is byte 5 of the descriptor
+	b := []byte{byte(s.Attr)}
+	if _, err := cpu.Bus.WriteAt(b, int64(cpu.descriptorAddr(s.Selector)+5)); err != nil {
+		panic(busError{err})
+	}
+}
+
+// Load the data segment register or SS with selector.
+func (cpu *CPU) loadSegment(seg byte, selector uint16) {
+	if seg == dis.CS {
+		// mov and pop can't load CS
+		fault(VectorUD, 0)
+	}
+	cpu.Seg[seg] = cpu.dataSegment(seg, selector, cpu.CPL)
+}
+
+// Check loading the data segment register or SS with selector at privilege
+// level cpl, and return the segment to load. In real mode only the selector
+// and base are changed, the limit and access rights are kept.
+func (cpu *CPU) dataSegment(seg byte, selector uint16, cpl uint16) Segment {
+	if !cpu.protected() {
+		s := cpu.Seg[seg]
+		s.Selector = selector
+		s.Base = uint32(selector) << 4
+		return s
+	}
+
+	errorCode := uint32(selector &^ 3)
+	rpl := selector & 3
+	if isNullSelector(selector) {
+		if seg == dis.SS {
+			fault(VectorGP, 0)
+		}
+		// Accessing memory with null selector causes #GP
+		return Segment{Selector: selector}
+	}
+	s := cpu.readDescriptor(selector)
+	if s.Attr&SegS == 0 {
+		fault(VectorGP, errorCode)
+	}
+	if seg == dis.SS {
+		if rpl != cpl || s.dpl() != cpl || s.Attr&(SegCode|SegWritable) != SegWritable {
+			fault(VectorGP, errorCode)
+		}
+		if s.Attr&SegP == 0 {
+			fault(VectorSS, errorCode)
+		}
+	} else {
+		if s.Attr&(SegCode|SegReadable) == SegCode {
+			// Execute-only code segment
+			fault(VectorGP, errorCode)
+		}
+		conforming := s.Attr&(SegCode|SegConforming) == SegCode|SegConforming
+		if !conforming && (rpl > s.dpl() || cpl > s.dpl()) {
+			fault(VectorGP, errorCode)
+		}
+		if s.Attr&SegP == 0 {
+			fault(VectorNP, errorCode)
+		}
+	}
+	cpu.setAccessed(&s)
+	return s
+}
+
+// Check the far jmp, call or ret to selector:offset, and return the code
+// segment to load into CS. ret is set for far ret, which may return to a
+// less privileged level. The returned segment's RPL is the new CPL.
+func (cpu *CPU) codeSegment(selector uint16, offset uint32, ret bool) Segment {
+	var s Segment
+	if !cpu.protected() {
+		s = cpu.Seg[dis.CS]
+		s.Selector = selector
+		s.Base = uint32(selector) << 4
+	} else {
+		errorCode := uint32(selector &^ 3)
+		rpl, cpl := selector&3, cpu.CPL
+		if isNullSelector(selector) {
+			fault(VectorGP, 0)
+		}
+		s = cpu.readDescriptor(selector)
+		if s.Attr&SegS == 0 {
+			// Call gate, task gate and TSS
+			panic(cpu.unsupported())
+		}
+		if s.Attr&SegCode == 0 {
+			fault(VectorGP, errorCode)
+		}
+		conforming := s.Attr&SegConforming != 0
+		if ret {
+			if rpl < cpl || (conforming && s.dpl() > rpl) || (!conforming && s.dpl() != rpl) {
+				fault(VectorGP, errorCode)
+			}
+		} else {
+			if (conforming && s.dpl() > cpl) || (!conforming && (rpl > cpl || s.dpl() != cpl)) {
+				fault(VectorGP, errorCode)
+			}
+			// CPL is not changed
+			s.Selector = selector&^3 | cpl
+		}
+		if s.Attr&SegP == 0 {
+			fault(VectorNP, errorCode)
+		}
+	}
+	if !s.inLimit(offset, 1) {
+		fault(VectorGP, 0)
+	}
+	if cpu.protected() {
+		cpu.setAccessed(&s)
+	}
+	return s
+}
+
+// Load CS, the decoder's default operand size follows the D bit. CPL is the
+// RPL of the selector in protected mode, and 0 in real mode.
+func (cpu *CPU) setCS(s Segment) {
+	cpu.Seg[dis.CS] = s
+	cpu.CPL = 0
+	if cpu.protected() {
+		cpu.CPL = s.Selector & 3
+	}
+	cpu.dc.SetDflag(s.Attr&SegDB != 0)
+}
+
+// Data segment registers are loaded with null selector if not accessible
+// after returning to a less privileged level.
+func (cpu *CPU) checkDataSegments() {
+	cpl := cpu.CPL
+	for _, seg := range []byte{dis.ES, dis.DS, dis.FS, dis.GS} {
+		s := &cpu.Seg[seg]
+		conforming := s.Attr&(SegCode|SegConforming) == SegCode|SegConforming
+		if !conforming && s.dpl() < cpl {
+			*s = Segment{}
+		}
+	}
+}
+
+// Load LDTR with selector, which selects a LDT descriptor in GDT.
+func (cpu *CPU) loadLDT(selector uint16) {
+	if isNullSelector(selector) {
+		cpu.LDTR = Segment{Selector: selector}
+		return
+	}
+	errorCode := uint32(selector &^ 3)
+	if selector&4 != 0 {
+		fault(VectorGP, errorCode)
+	}
+	s := cpu.readDescriptor(selector)
+	if s.Attr&(SegS|0xf) != segTypeLDT {
+		fault(VectorGP, errorCode)
+	}
+	if s.Attr&SegP == 0 {
+		fault(VectorNP, errorCode)
+	}
+	cpu.LDTR = s
+}
+
+// Check the access to size bytes at offset in the segment, and return the
+// linear address. Access rights are only checked in protected mode.
+func (cpu *CPU) linearAddr(seg byte, offset uint32, size int, write bool) uint32 {
+	s := &cpu.Seg[seg]
+	vector := byte(VectorGP)
+	if seg == dis.SS {
+		vector = VectorSS
+	}
+	if cpu.protected() {
+		if s.Attr&SegP == 0 {
+			// Null selector
+			fault(vector, 0)
+		}
+		if write && s.Attr&(SegCode|SegWritable) != SegWritable {
+			fault(vector, 0)
+		}
+		if !write && s.Attr&(SegCode|SegReadable) == SegCode {
+			fault(vector, 0)
+		}
+	}
+	if !s.inLimit(offset, size) {
+		fault(vector, 0)
+	}
+	return s.Base + offset
+}
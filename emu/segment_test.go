@@ -0,0 +1,480 @@
+package emu
+
+import (
+	"testing"
+
+	dis "github.com/cyfdecyf/GoEmu/dis-x86"
+)
+
+const testGDT = 0x1000
+
+var testDescriptors = []uint64{
+	0,                  // 0x00 null
+	0x00cf9a000000ffff, // 0x08 flat code
+	0x00cf92000000ffff, // 0x10 flat data
+	0x00cf12000000ffff, // 0x18 not present data
+	0x0040900020000fff, // 0x20 read-only data, base 0x2000, limit 0xfff
+	0x00cf98000000ffff, // 0x28 execute-only code
+	0x00409200300000ff, // 0x30 data, base 0x3000, limit 0xff
+	0x0040960000000fff, // 0x38 expand-down data, limit 0xfff
+	0x00009a000000ffff, // 0x40 16-bit code, limit 0xffff
+	0x0000820018000017, // 0x48 LDT at 0x1800
+	0x00cffa000000ffff, // 0x50 flat code, DPL 3
+	0x00cff2000000ffff, // 0x58 flat data, DPL 3
+}
+
+// Create a CPU with flat segments, and GDT loaded with testDescriptors. LDT
+// has one data segment with base 0x4000.
+func newSegTestCPU(code []byte) (*CPU, *Memory) {
+	mem := NewMemory(0x10000)
+	copy(mem.RAM(), code)
+	for i, d := range testDescriptors {
+		mem.Write64(testGDT+uint32(i)*8, d)
+	}
+	mem.Write64(0x1800, 0x004092004000ffff)
+	cpu := NewCPU(mem)
+	cpu.GDTR = DescTable{Base: testGDT, Limit: uint16(len(testDescriptors)*8 - 1)}
+	cpu.Regs[dis.Esp] = 0x8000
+	return cpu, mem
+}
+
+func TestSegmentFault(t *testing.T) {
+	testdata := []struct {
+		name string
+		code []byte
+		err  string
+	}{
+		{
+			"beyond GDT limit",
+			[]byte{0x66, 0xb8, 0x60, 0x00, 0x8e, 0xd8}, // mov $0x60,%ax; mov %ax,%ds
+			"#GP(0x60)",
+		},
+		{
+			"null DS",
+			[]byte{
+				0x66, 0xb8, 0x00, 0x00, // mov $0x0,%ax
+				0x8e, 0xd8, // mov %ax,%ds
+				0xa1, 0x00, 0x01, 0x00, 0x00, // mov 0x100,%eax
+			},
+			"#GP(0x0)",
+		},
+		{
+			"null SS",
+			[]byte{0x66, 0xb8, 0x00, 0x00, 0x8e, 0xd0}, // mov $0x0,%ax; mov %ax,%ss
+			"#GP(0x0)",
+		},
+		{
+			"not present",
+			[]byte{0x66, 0xb8, 0x18, 0x00, 0x8e, 0xd8}, // mov $0x18,%ax; mov %ax,%ds
+			"#NP(0x18)",
+		},
+		{
+			"SS not present",
+			[]byte{0x66, 0xb8, 0x18, 0x00, 0x8e, 0xd0}, // mov $0x18,%ax; mov %ax,%ss
+			"#SS(0x18)",
+		},
+		{
+			"read-only SS",
+			[]byte{0x66, 0xb8, 0x20, 0x00, 0x8e, 0xd0}, // mov $0x20,%ax; mov %ax,%ss
+			"#GP(0x20)",
+		},
+		{
+			"execute-only DS",
+			[]byte{0x66, 0xb8, 0x28, 0x00, 0x8e, 0xd8}, // mov $0x28,%ax; mov %ax,%ds
+			"#GP(0x28)",
+		},
+		{
+			"RPL above DPL",
+			[]byte{0x66, 0xb8, 0x13, 0x00, 0x8e, 0xd8}, // mov $0x13,%ax; mov %ax,%ds
+			"#GP(0x10)",
+		},
+		{
+			"read read-only",
+			[]byte{
+				0x66, 0xb8, 0x20, 0x00, // mov $0x20,%ax
+				0x8e, 0xd8, // mov %ax,%ds
+				0xa1, 0x00, 0x01, 0x00, 0x00, // mov 0x100,%eax
+			},
+			"",
+		},
+		{
+			"write read-only",
+			[]byte{
+				0x66, 0xb8, 0x20, 0x00, // mov $0x20,%ax
+				0x8e, 0xd8, // mov %ax,%ds
+				0xa3, 0x00, 0x01, 0x00, 0x00, // mov %eax,0x100
+			},
+			"#GP(0x0)",
+		},
+		{
+			"in limit",
+			[]byte{
+				0x66, 0xb8, 0x30, 0x00, // mov $0x30,%ax
+				0x8e, 0xc0, // mov %ax,%es
+				0x26, 0xa1, 0xfc, 0x00, 0x00, 0x00, // mov %es:0xfc,%eax
+			},
+			"",
+		},
+		{
+			"beyond limit",
+			[]byte{
+				0x66, 0xb8, 0x30, 0x00, // mov $0x30,%ax
+				0x8e, 0xc0, // mov %ax,%es
+				0x26, 0xa1, 0xfd, 0x00, 0x00, 0x00, // mov %es:0xfd,%eax
+			},
+			"#GP(0x0)",
+		},
+		{
+			"stack beyond limit",
+			[]byte{
+				0x66, 0xb8, 0x30, 0x00, // mov $0x30,%ax
+				0x8e, 0xd0, // mov %ax,%ss
+				0xbc, 0x02, 0x00, 0x00, 0x00, // mov $0x2,%esp
+				0x50, // push %eax
+			},
+			"#SS(0x0)",
+		},
+		{
+			"expand-down above limit",
+			[]byte{
+				0x66, 0xb8, 0x38, 0x00, // mov $0x38,%ax
+				0x8e, 0xd8, // mov %ax,%ds
+				0xa1, 0x00, 0x10, 0x00, 0x00, // mov 0x1000,%eax
+			},
+			"",
+		},
+		{
+			"expand-down below limit",
+			[]byte{
+				0x66, 0xb8, 0x38, 0x00, // mov $0x38,%ax
+				0x8e, 0xd8, // mov %ax,%ds
+				0xa1, 0x00, 0x08, 0x00, 0x00, // mov 0x800,%eax
+			},
+			"#GP(0x0)",
+		},
+		{
+			"mov to CS",
+			[]byte{0x66, 0xb8, 0x08, 0x00, 0x8e, 0xc8}, // mov $0x8,%ax; mov %ax,%cs
+			"#UD",
+		},
+		{
+			"ljmp to data",
+			[]byte{0xea, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00}, // ljmp $0x10,$0x0
+			"#GP(0x10)",
+		},
+		{
+			"ljmp beyond limit",
+			[]byte{0xea, 0x00, 0x00, 0x01, 0x00, 0x40, 0x00}, // ljmp $0x40,$0x10000
+			"#GP(0x0)",
+		},
+		{
+			"lldt in GDT",
+			[]byte{0x66, 0xb8, 0x08, 0x00, 0x0f, 0x00, 0xd0}, // mov $0x8,%ax; lldt %ax
+			"#GP(0x8)",
+		},
+		{
+			"LDT not loaded",
+			[]byte{0x66, 0xb8, 0x04, 0x00, 0x8e, 0xd8}, // mov $0x4,%ax; mov %ax,%ds
+			"#GP(0x4)",
+		},
+	}
+	for _, td := range testdata {
+		cpu, _ := newSegTestCPU(append(td.code, 0xf4)) // hlt
+		err := cpu.Run(10)
+		if td.err == "" {
+			if err != nil {
+				t.Errorf("%s: %v", td.name, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != td.err {
+			t.Errorf("%s: error %v, should be %s", td.name, err, td.err)
+		}
+	}
+}
+
+func TestDescriptorLoad(t *testing.T) {
+	cpu, mem := newSegTestCPU([]byte{
+		0x66, 0xb8, 0x48, 0x00, // mov $0x48,%ax
+		0x0f, 0x00, 0xd0, // lldt %ax
+		0x66, 0xb8, 0x04, 0x00, // mov $0x4,%ax
+		0x8e, 0xd8, // mov %ax,%ds
+		0xa1, 0x10, 0x00, 0x00, 0x00, // mov 0x10,%eax
+		0x0f, 0x00, 0xc3, // sldt %ebx
+		0x0f, 0x01, 0x0d, 0x00, 0x01, 0x00, 0x00, // sidt 0x100
+		0xf4, // hlt
+	})
+	mem.Write32(0x4010, 0x12345678)
+	cpu.IDTR = DescTable{Base: 0x12345678, Limit: 0x7ff}
+	if err := cpu.Run(10); err != nil {
+		t.Fatal(err)
+	}
+	if cpu.Regs[dis.Eax] != 0x12345678 || cpu.Regs[dis.Ebx] != 0x48 {
+		t.Errorf("eax %#x ebx %#x, should be 0x12345678 0x48", cpu.Regs[dis.Eax], cpu.Regs[dis.Ebx])
+	}
+	ds := Segment{Selector: 4, Base: 0x4000, Limit: 0xffff, Attr: SegP | SegS | SegWritable | SegAccessed | SegDB}
+	if cpu.Seg[dis.DS] != ds {
+		t.Errorf("DS %+v, should be %+v", cpu.Seg[dis.DS], ds)
+	}
+	if cpu.LDTR.Base != 0x1800 || cpu.LDTR.Limit != 0x17 {
+		t.Errorf("LDTR %+v", cpu.LDTR)
+	}
+	// Accessed bit is set in LDT, not in the LDT descriptor
+	if v, _ := mem.Read8(0x1805); v != 0x93 {
+		t.Errorf("access byte of DS descriptor %#x, should be 0x93", v)
+	}
+	if v, _ := mem.Read8(testGDT + 0x48 + 5); v != 0x82 {
+		t.Errorf("access byte of LDT descriptor %#x, should be 0x82", v)
+	}
+	// sidt stores limit and base at DS:0x100
+	if v, _ := mem.Read64(0x4100); v != 0x1234567807ff {
+		t.Errorf("sidt stores %#x, should be 0x1234567807ff", v)
+	}
+
+	// Faulting load doesn't set the accessed bit
+	for _, sel := range []byte{0x18, 0x13} {
+		cpu, mem = newSegTestCPU([]byte{
+			0x66, 0xb8, sel, 0x00, // mov $sel,%ax
+			0x8e, 0xd8, // mov %ax,%ds
+		})
+		if err := cpu.Run(10); err == nil {
+			t.Errorf("loading DS with %#x should fault", sel)
+		}
+		addr := testGDT + uint32(sel&^7) + 5
+		if v, _ := mem.Read8(addr); v != byte(testDescriptors[sel>>3]>>40) {
+			t.Errorf("access byte %#x changed by faulting load of %#x", v, sel)
+		}
+	}
+}
+
+// Privileged and I/O sensitive instructions at CPL 3.
+func TestPrivileged(t *testing.T) {
+	ring3 := []byte{
+		0x6a, 0x5b, // push $0x5b
+		0x68, 0x00, 0x70, 0x00, 0x00, // push $0x7000
+		0x6a, 0x53, // push $0x53
+		0x68, 0x0f, 0x00, 0x00, 0x00, // push $0xf
+		0xcb, // lret
+	}
+	testdata := []struct {
+		name  string
+		code  []byte // Executed at CPL 3
+		iopl  dis.Flags
+		err   string
+		eip   uint32
+		flags dis.Flags // Expected IOPL, IF and CF
+	}{
+		{"mov to cr0", []byte{0x0f, 0x22, 0xc0}, 0, "#GP(0x0)", 0xf, 0},
+		{"mov from cr0", []byte{0x0f, 0x20, 0xc0}, 0, "#GP(0x0)", 0xf, 0},
+		{"hlt", []byte{0xf4}, 0, "#GP(0x0)", 0xf, 0},
+		{"cli", []byte{0xfa}, 0, "#GP(0x0)", 0xf, 0},
+		{"sti", []byte{0xfb}, 0, "#GP(0x0)", 0xf, 0},
+		{"cli with IOPL 3", []byte{0xfa, 0xf4}, dis.FlagIOPL, "#GP(0x0)", 0x10, dis.FlagIOPL},
+		{
+			// IOPL and IF not changed
+			"popf",
+			[]byte{
+				0x68, 0x01, 0x32, 0x00, 0x00, // push $0x3201
+				0x9d, // popf
+				0xf4, // hlt
+			},
+			0, "#GP(0x0)", 0x15, dis.FlagCF,
+		},
+		{
+			// IF changed with IOPL 3
+			"popf with IOPL 3",
+			[]byte{
+				0x68, 0x01, 0x02, 0x00, 0x00, // push $0x201
+				0x9d, // popf
+				0xf4, // hlt
+			},
+			dis.FlagIOPL, "#GP(0x0)", 0x15, dis.FlagIOPL | dis.FlagIF | dis.FlagCF,
+		},
+	}
+	for _, td := range testdata {
+		cpu, _ := newSegTestCPU(append(ring3, td.code...))
+		cpu.SetEFLAGS(td.iopl)
+		err := cpu.Run(10)
+		if err == nil || err.Error() != td.err || cpu.EIP != td.eip {
+			t.Errorf("%s: %v at %#x, should be %s at %#x", td.name, err, cpu.EIP, td.err, td.eip)
+		}
+		if cpu.CPL != 3 {
+			t.Errorf("%s: CPL %d, should be 3", td.name, cpu.CPL)
+		}
+		if flags := cpu.EFLAGS() & (dis.FlagIOPL | dis.FlagIF | dis.FlagCF); flags != td.flags {
+			t.Errorf("%s: flags %#x, should be %#x", td.name, flags, td.flags)
+		}
+	}
+}
+
+func TestFarTransfer(t *testing.T) {
+	// Jump to 16-bit code segment
+	cpu, _ := newSegTestCPU([]byte{
+		0xea, 0x08, 0x00, 0x00, 0x00, 0x40, 0x00, // ljmp $0x40,$0x8
+		0x90,             // nop
+		0xb8, 0x34, 0x12, // mov $0x1234,%ax
+		0xf4, // hlt
+	})
+	cpu.Regs[dis.Eax] = 0xffff0000
+	if err := cpu.Run(10); err != nil {
+		t.Fatal(err)
+	}
+	if cpu.Regs[dis.Eax] != 0xffff1234 || cpu.EIP != 0xc {
+		t.Errorf("eax %#x eip %#x, should be 0xffff1234 0xc", cpu.Regs[dis.Eax], cpu.EIP)
+	}
+	if cpu.dc.Dflag {
+		t.Error("decoder D flag not cleared by 16-bit code segment")
+	}
+
+	// lcall and lret at the same privilege level
+	cpu, _ = newSegTestCPU([]byte{
+		0xea, 0x07, 0x00, 0x00, 0x00, 0x08, 0x00, // ljmp $0x8,$0x7
+		0x9a, 0x0f, 0x00, 0x00, 0x00, 0x08, 0x00, // lcall $0x8,$0xf
+		0xf4,             // hlt
+		0xca, 0x04, 0x00, // 0xf: lret $0x4
+	})
+	if err := cpu.Run(10); err != nil {
+		t.Fatal(err)
+	}
+	if cpu.Seg[dis.CS].Selector != 8 || cpu.Regs[dis.Esp] != 0x8004 || cpu.EIP != 0xf {
+		t.Errorf("cs %#x esp %#x eip %#x after lret", cpu.Seg[dis.CS].Selector, cpu.Regs[dis.Esp], cpu.EIP)
+	}
+
+	// lret to ring 3
+	cpu, _ = newSegTestCPU([]byte{
+		0x66, 0xb8, 0x10, 0x00, // mov $0x10,%ax
+		0x8e, 0xd8, // mov %ax,%ds
+		0x6a, 0x5b, // push $0x5b
+		0x68, 0x00, 0x70, 0x00, 0x00, // push $0x7000
+		0x6a, 0x53, // push $0x53
+		0x68, 0x15, 0x00, 0x00, 0x00, // push $0x15
+		0xcb,                                     // lret
+		0x0f, 0x01, 0x15, 0x00, 0x01, 0x00, 0x00, // 0x15: lgdt 0x100
+	})
+	err := cpu.Run(10)
+	if err == nil || err.Error() != "#GP(0x0)" || cpu.EIP != 0x15 {
+		t.Errorf("lgdt at CPL 3: %v at %#x, should be #GP(0x0) at 0x15", err, cpu.EIP)
+	}
+	if cpu.CPL != 3 || cpu.Seg[dis.CS].Selector != 0x53 || cpu.Seg[dis.SS].Selector != 0x5b ||
+		cpu.Regs[dis.Esp] != 0x7000 {
+		t.Errorf("CPL %d cs %#x ss %#x esp %#x after lret", cpu.CPL,
+			cpu.Seg[dis.CS].Selector, cpu.Seg[dis.SS].Selector, cpu.Regs[dis.Esp])
+	}
+	if cpu.Seg[dis.DS] != (Segment{}) {
+		t.Errorf("DS %+v, should be null after returning to ring 3", cpu.Seg[dis.DS])
+	}
+}
+
+// Switch from real mode to protected mode like a boot loader.
+// Faulting instructions don't change registers, so they can be restarted.
+func TestRestartableFault(t *testing.T) {
+	testdata := []struct {
+		name string
+		code []byte
+		err  string
+		eip  uint32
+		regs []regValue
+	}{
+		{
+			"pop invalid DS",
+			[]byte{
+				0x6a, 0x60, // push $0x60
+				0x1f, // pop %ds
+			},
+			"#GP(0x60)", 2,
+			[]regValue{{dis.Esp, 0x7ffc}},
+		},
+		{
+			"lret to invalid CS",
+			[]byte{
+				0x6a, 0x60, // push $0x60
+				0x6a, 0x00, // push $0x0
+				0xca, 0x04, 0x00, // lret $0x4
+			},
+			"#GP(0x60)", 4,
+			[]regValue{{dis.Esp, 0x7ff8}},
+		},
+		{
+			// Restarted from the faulting iteration
+			"rep movsb beyond limit",
+			[]byte{
+				0x66, 0xb8, 0x30, 0x00, // mov $0x30,%ax
+				0x8e, 0xc0, // mov %ax,%es
+				0xbf, 0xfe, 0x00, 0x00, 0x00, // mov $0xfe,%edi
+				0xb9, 0x04, 0x00, 0x00, 0x00, // mov $0x4,%ecx
+				0x31, 0xf6, // xor %esi,%esi
+				0xf3, 0xa4, // rep movsb
+			},
+			"#GP(0x0)", 0x12,
+			[]regValue{{dis.Ecx, 2}, {dis.Esi, 2}, {dis.Edi, 0x100}},
+		},
+	}
+	for _, td := range testdata {
+		cpu, _ := newSegTestCPU(td.code)
+		err := cpu.Run(10)
+		if err == nil || err.Error() != td.err || cpu.EIP != td.eip {
+			t.Errorf("%s: %v at %#x, should be %s at %#x", td.name, err, cpu.EIP, td.err, td.eip)
+		}
+		for _, r := range td.regs {
+			if cpu.Regs[r.reg] != r.val {
+				t.Errorf("%s: %s %#x, should be %#x", td.name,
+					dis.Reg{Class: dis.RegGP, Num: r.reg, Size: 4}, cpu.Regs[r.reg], r.val)
+			}
+		}
+	}
+}
+
+func TestProtectedModeSwitch(t *testing.T) {
+	mem := NewMemory(0x10000)
+	copy(mem.RAM()[0x7c00:], []byte{
+		0xfa,                         // cli
+		0x0f, 0x01, 0x16, 0x20, 0x7d, // lgdtw 0x7d20
+		0x0f, 0x20, 0xc0, // mov %cr0,%eax
+		0x66, 0x83, 0xc8, 0x01, // or $0x1,%eax
+		0x0f, 0x22, 0xc0, // mov %eax,%cr0
+		0xea, 0x15, 0x7c, 0x08, 0x00, // ljmp $0x8,$0x7c15
+		// 32-bit code
+		0x66, 0xb8, 0x10, 0x00, // 0x7c15: mov $0x10,%ax
+		0x8e, 0xd8, // mov %ax,%ds
+		0x8e, 0xd0, // mov %ax,%ss
+		0xbc, 0x00, 0x90, 0x00, 0x00, // mov $0x9000,%esp
+		0x68, 0x78, 0x56, 0x34, 0x12, // push $0x12345678
+		0x5b,                               // pop %ebx
+		0x8b, 0x0d, 0x00, 0x01, 0x00, 0x00, // mov 0x100,%ecx
+		0xf4, // hlt
+	})
+	mem.Write64(0x7d08, 0x00cf9a000000ffff)
+	mem.Write64(0x7d10, 0x00cf92000000ffff)
+	mem.Write16(0x7d20, 0x17)
+	mem.Write32(0x7d22, 0x7d00)
+	mem.Write32(0x100, 0xcafebabe)
+
+	// Real mode state after reset, with CS:IP 0:0x7c00
+	cpu := NewCPU(mem)
+	cpu.setCR(0, CR0ET)
+	for i := range cpu.Seg {
+		cpu.Seg[i] = Segment{Limit: 0xffff, Attr: SegP | SegS | SegWritable | SegAccessed}
+	}
+	cpu.setCS(Segment{Limit: 0xffff, Attr: SegP | SegS | SegCode | SegReadable | SegAccessed})
+	cpu.EIP = 0x7c00
+	cpu.Regs[dis.Esp] = 0x7c00
+
+	if err := cpu.Run(20); err != nil {
+		t.Fatalf("%v at %#x", err, cpu.EIP)
+	}
+	if cpu.GDTR != (DescTable{Base: 0x7d00, Limit: 0x17}) {
+		t.Errorf("GDTR %+v", cpu.GDTR)
+	}
+	if !cpu.dc.Protected || !cpu.dc.Dflag {
+		t.Error("decoder not in 32-bit protected mode")
+	}
+	if cpu.Seg[dis.CS].Selector != 8 || cpu.CPL != 0 || cpu.stackSize() != 4 {
+		t.Errorf("cs %#x CPL %d stack size %d", cpu.Seg[dis.CS].Selector, cpu.CPL, cpu.stackSize())
+	}
+	if cpu.Regs[dis.Ebx] != 0x12345678 || cpu.Regs[dis.Ecx] != 0xcafebabe || cpu.Regs[dis.Esp] != 0x9000 {
+		t.Errorf("ebx %#x ecx %#x esp %#x", cpu.Regs[dis.Ebx], cpu.Regs[dis.Ecx], cpu.Regs[dis.Esp])
+	}
+	if v, _ := mem.Read8(0x7d0d); v != 0x9b {
+		t.Errorf("access byte of code descriptor %#x, accessed bit not set", v)
+	}
+}